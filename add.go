@@ -0,0 +1,173 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// AddBody is a struct for defining the request body structure for adding a value under a generated key.
+type AddBody struct {
+	Value      string `json:"value"`
+	TTLSeconds *int64 `json:"ttl_seconds"` // Optional lifetime of the key, zero means the key never expires. Defaults to the TTL of the key's prefix.
+}
+
+// newKey returns a random version 4 UUID, e.g. "0f8fad5b-d9cb-469f-a165-70867728950e".
+func newKey() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40 // Version 4.
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant.
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// Add stores value under a new key and returns the key. The key expires according to the default TTL of its
+// prefix, if any.
+func Add(value string) (string, error) {
+	return add(value, nil)
+}
+
+// add stores value under a new random key, which expires after ttlSeconds, and returns the key. The key is
+// checked under the store's lock, and generated again in the unlikely case it already exists, so it never
+// overwrites a key, even one written under the same name by a client. The put is logged along with the key,
+// so that replaying the log stores the value under the same key.
+func add(value string, ttlSeconds *int64) (string, error) {
+	changes.RLock()
+	defer changes.RUnlock()
+
+	for {
+		key, err := newKey()
+		if err != nil {
+			return "", err
+		}
+		expiresAt, err := requestExpiry(key, ttlSeconds)
+		if err != nil {
+			return "", err
+		}
+
+		created, err := addKey(key, value, expiresAt)
+		if err != nil {
+			return "", err
+		}
+		if created {
+			return key, nil
+		}
+	}
+}
+
+// addKey stores value under key for add, unless the key already exists, and reports whether it did.
+func addKey(key, value string, expiresAt time.Time) (bool, error) {
+	stored, compressed, err := encodeValue(key, value)
+	if err != nil {
+		return false, err
+	}
+
+	stripe := keyLock(key)
+	stripe.Lock()
+	defer stripe.Unlock()
+
+	store.Lock()
+	if _, exists := store.m[key]; exists {
+		store.Unlock()
+		return false, nil
+	}
+	err = setLocked(key, value, stored, compressed, expiresAt, "")
+	store.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	if err := logger.WriteEvent(Event{EventType: EventPut, Key: key, Value: stored, Expiry: unixNano(expiresAt), Compressed: compressed, verbatim: true}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AddHandler is a handler function for the endpoint adding a value under a generated key.
+func AddHandler(rw http.ResponseWriter, r *http.Request) {
+	var body AddBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value, err := decodeWire(binary, body.Value)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value = stripNewlines(binary, value)
+
+	key, err := add(value, body.TTLSeconds)
+	var schemaErr *SchemaError
+	if errors.Is(err, errNegativeTTL) || errors.As(err, &schemaErr) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrorValueTooLarge) {
+		writeError(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, errStoreFull) {
+		writeError(rw, err.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("added value under new key \"%s\"\n", key)
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(rw).Encode(struct {
+		Key string `json:"key"`
+	}{encodeWire(binary, key)}); err != nil {
+		log.Println(err)
+	}
+}
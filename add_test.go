@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that values added without a key get a new UUID as their key, which survives a replay.
+func TestAdd(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-add.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	add := func(body string) (int, string) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/add", strings.NewReader(body)))
+
+		var resp struct {
+			Key string `json:"key"`
+		}
+		if rec.Code == http.StatusCreated {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, resp.Key
+	}
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	// Every value gets a key of its own.
+	keys := make(map[string]string)
+	for _, value := range []string{"first", "second", "third"} {
+		code, key := add(`{"value": "` + value + `"}`)
+		if code != http.StatusCreated || !uuid.MatchString(key) {
+			t.Fatalf("Expected 201 with a UUID, got %d %q", code, key)
+		}
+		if _, ok := keys[key]; ok {
+			t.Fatalf("Expected a new key, got %q twice", key)
+		}
+		keys[key] = value
+	}
+
+	for key, value := range keys {
+		if got, err := Get(key); err != nil || got != value {
+			t.Errorf("Expected %q under %q, got %q %v", value, key, got, err)
+		}
+	}
+
+	// A negative TTL is rejected without adding anything.
+	if code, _ := add(`{"value": "fourth", "ttl_seconds": -1}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a negative TTL, got %d", code)
+	}
+
+	// Replaying the log stores the values under the same keys.
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	if n := Count(""); n != len(keys) {
+		t.Errorf("Expected %d keys after a replay, got %d", len(keys), n)
+	}
+	for key, value := range keys {
+		if got, err := Get(key); err != nil || got != value {
+			t.Errorf("Expected %q under %q after a replay, got %q %v", value, key, got, err)
+		}
+	}
+}
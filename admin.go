@@ -0,0 +1,102 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// FlushBody is the body of a flush request, which must explicitly confirm the flush.
+type FlushBody struct {
+	Confirm bool `json:"confirm"`
+}
+
+// Flush deletes every key of the default store, logging a delete for each of them, and returns the number of deleted
+// keys. Every key is deleted even if some of the deletes can't be logged, and the first such error is returned.
+func Flush() (int, error) {
+	changes.RLock()
+	defer changes.RUnlock()
+	unlock := lockAllKeys()
+	defer unlock()
+
+	store.Lock()
+	keys := make([]string, 0, len(store.m))
+	for key := range store.m {
+		keys = append(keys, key)
+	}
+
+	store.m = make(map[string]string)
+	store.expiry = make(map[string]time.Time)
+	store.compressed = make(map[string]bool)
+	store.contentType = make(map[string]string)
+	store.version = make(map[string]uint64)
+	store.index.reset()
+	store.Unlock()
+
+	var logErr error
+	for _, key := range keys {
+		if err := logger.WriteDelete(key); err != nil && logErr == nil {
+			logErr = err
+		}
+	}
+
+	return len(keys), logErr
+}
+
+// FlushHandler is a handler function for the admin flush endpoint.
+func FlushHandler(rw http.ResponseWriter, r *http.Request) {
+	var body FlushBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Flushing is destructive, so it has to be confirmed.
+	if !body.Confirm {
+		writeError(rw, "flush must be confirmed with {\"confirm\":true}", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := Flush()
+	log.Printf("flushed %d keys", deleted)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(struct {
+		Deleted int `json:"deleted"`
+	}{deleted}); err != nil {
+		log.Println(err)
+	}
+}
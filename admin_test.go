@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that a flush empties the store, also after replaying the log.
+func TestFlush(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-flush.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	defer func() { config.allowFlush = false }()
+
+	var err error
+	logger, err = NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Log()
+
+	for _, key := range []string{"yakv1", "yakv2", "yakv3"} {
+		Put(key, "value")
+		logger.WritePut(key, "value")
+	}
+
+	config.allowFlush = true
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	// Flushing without a confirmation is refused.
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/admin/flush", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest || len(store.m) != 3 {
+		t.Fatalf("Expected an unconfirmed flush to be refused, got %d with %d keys left", rec.Code, len(store.m))
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/admin/flush", strings.NewReader(`{"confirm": true}`)))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"deleted":3}` {
+		t.Errorf("Expected 3 deleted keys, got %d %q", rec.Code, rec.Body.String())
+	}
+	if len(store.m) != 0 {
+		t.Errorf("Expected an empty store, got %v", store.m)
+	}
+	logger.Close()
+
+	// Replaying the log reconstructs the empty store.
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+
+	if len(store.m) != 0 {
+		t.Errorf("Expected an empty store after replay, got %v", store.m)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// AppendBody is a struct for defining the request body structure for appending to a value.
+type AppendBody struct {
+	Key   string `json:"key"`
+	Value string `json:"value"` // Suffix appended to the value of the key.
+}
+
+// Append appends suffix to the value of key under a single lock, creating the key with the default TTL of its
+// prefix if it doesn't exist, and returns the new value. Existing keys keep their expiry and content type.
+// The whole new value is logged as a put, so that replaying doesn't depend on earlier values.
+func Append(key, suffix string) (string, error) {
+	key = normalizeKey(key)
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	changes.RLock()
+	defer changes.RUnlock()
+	stripe := keyLock(key)
+	stripe.Lock()
+	defer stripe.Unlock()
+
+	store.Lock()
+	value, e, err := appendLocked(key, suffix, now)
+	store.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if err := logger.WriteEvent(e); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// appendLocked appends suffix to the value of key for Append, returning the new value and the event logging it.
+// The caller must hold the store's lock.
+func appendLocked(key, suffix string, now time.Time) (string, Event, error) {
+	if err := checkPutLocked(key); err != nil {
+		return "", Event{}, err
+	}
+
+	// Keys which have expired but haven't been swept yet start over, like missing keys.
+	var value string
+	expiresAt, expires := store.expiry[key]
+	stored, exists := store.m[key]
+	if exists && expires && hasExpired(key, expiresAt, now) {
+		exists = false
+	}
+
+	if exists {
+		item, err := storeEntry{key: key, value: stored, compressed: store.compressed[key]}.decode()
+		if err != nil {
+			return "", Event{}, err
+		}
+		value = item.Value
+	} else {
+		expiresAt = defaultExpiry(key, now)
+	}
+
+	value += suffix
+	stored, compressed, err := encodeValue(key, value)
+	if err != nil {
+		return "", Event{}, err
+	}
+
+	if !exists {
+		if err := admitKey(key); err != nil {
+			return "", Event{}, err
+		}
+		delete(store.contentType, key)
+		delete(store.pinned, key)
+	}
+	touchKey(key, store.pinned[key])
+
+	store.m[key] = stored
+	bumpVersionLocked(key)
+	if expiresAt.IsZero() {
+		delete(store.expiry, key)
+	} else {
+		store.expiry[key] = expiresAt
+	}
+	if compressed {
+		store.compressed[key] = true
+	} else {
+		delete(store.compressed, key)
+	}
+	store.index.add(key, value)
+
+	// The value is logged verbatim, since trimming it would make the log diverge from the store.
+	return value, Event{EventType: EventPut, Key: key, Value: stored, Expiry: unixNano(expiresAt), Compressed: compressed, ContentType: store.contentType[key], Pinned: store.pinned[key], verbatim: true}, nil
+}
+
+// AppendHandler is a handler function for the endpoint appending to a value.
+func AppendHandler(rw http.ResponseWriter, r *http.Request) {
+	var body AppendBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := decodeKey(binary, body.Key)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	suffix, err := decodeWire(binary, body.Value)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	suffix = stripNewlines(binary, suffix)
+
+	value, err := Append(key, suffix)
+	var schemaErr *SchemaError
+	if errors.Is(err, ErrorEmptyKey) || errors.As(err, &schemaErr) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrorValueTooLarge) {
+		writeError(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, errStoreFull) {
+		writeError(rw, err.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	if errors.Is(err, errKeyExists) {
+		writeError(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("appended %d bytes to key \"%s\"\n", len(suffix), key)
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(struct {
+		Length int `json:"length"`
+	}{len(value)}); err != nil {
+		log.Println(err)
+	}
+}
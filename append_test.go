@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that appending creates missing keys, extends existing ones and keeps their expiry.
+func TestAppend(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-append.log")()
+	defer resetStores()
+
+	if value, err := Append("log:1", "first"); err != nil || value != "first" {
+		t.Fatalf("Expected a missing key to be created, got %q %v", value, err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	PutWithExpiry("log:2", "start", expiresAt)
+	if value, err := Append("log:2", " more"); err != nil || value != "start more" {
+		t.Errorf("Expected the suffix to be appended, got %q %v", value, err)
+	}
+	if store.expiry["log:2"] != expiresAt {
+		t.Errorf("Expected the key to keep its expiry, got %v", store.expiry["log:2"])
+	}
+
+	// Keys which have expired start over.
+	PutWithExpiry("log:3", "stale", time.Now().Add(-time.Second))
+	if value, err := Append("log:3", "fresh"); err != nil || value != "fresh" || !store.expiry["log:3"].IsZero() {
+		t.Errorf("Expected an expired key to start over without an expiry, got %q %v", value, err)
+	}
+
+	if _, err := Append("", "suffix"); !errors.Is(err, ErrorEmptyKey) {
+		t.Errorf("Expected ErrorEmptyKey, got %v", err)
+	}
+}
+
+// Function for testing that concurrent appends are atomic, and replaying the log gives the same value.
+func TestAppendConcurrent(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-append-concurrent.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Append("log:1", "x ")
+		}()
+	}
+	wg.Wait()
+
+	// Values are logged verbatim, the trailing space included.
+	expected := strings.Repeat("x ", 50)
+	if value, _ := Get("log:1"); value != expected {
+		t.Fatalf("Expected 50 appends, got %q", value)
+	}
+
+	logger.Wait()
+	reader, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	events, errs := reader.ReadEvents()
+	replayed, n, err := replayState(events, errs, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 50 || replayed["log:1"] != expected {
+		t.Errorf("Expected 50 puts replaying to the same value, got %d events and %q", n, replayed["log:1"])
+	}
+}
+
+// Function for testing the append endpoint and the maximum value size.
+func TestAppendHandler(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-append-handler.log")()
+	defer resetStores()
+	defer func(size int) { config.maxValueSize = size }(config.maxValueSize)
+	config.maxValueSize = 10
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	appendValue := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/append", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := appendValue(`{"key": "log:1", "value": "hello"}`); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"length":5}` {
+		t.Errorf("Expected the new length, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// The resulting value is limited, not only the suffix.
+	if rec := appendValue(`{"key": "log:1", "value": ", yakv!"}`); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a value growing past the limit, got %d", rec.Code)
+	}
+	if value, _ := Get("log:1"); value != "hello" {
+		t.Errorf("Expected a rejected append to change nothing, got %q", value)
+	}
+
+	// Suffixes can be base64-encoded, "IQ==" being "!".
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/append?encoding=base64", strings.NewReader(`{"key": "bG9nOjE=", "value": "IQ=="}`)))
+	if value, _ := Get("log:1"); rec.Code != http.StatusOK || value != "hello!" {
+		t.Errorf("Expected a base64-encoded suffix to be appended, got %d %q", rec.Code, value)
+	}
+
+	if rec := appendValue(`{"key": "", "value": "hello"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for the empty key, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Paths which don't require an API key.
+var authExempt = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// APIKeyMiddleware rejects requests with 401 Unauthorized unless their X-API-Key header matches apiKey, or the
+// API key of a tenant, in which case the request is scoped to the tenant's prefix. An empty apiKey only lets tenants in.
+// The authenticated key is made available to later handlers as "api_key".
+func APIKeyMiddleware(apiKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authExempt[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		header := c.GetHeader("X-API-Key")
+		if prefix, ok := lookupTenant(header); ok {
+			c.Request = withTenant(c.Request, prefix)
+			c.Set("api_key", header)
+			c.Next()
+			return
+		}
+
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(header), []byte(apiKey)) != 1 {
+			writeError(c.Writer, "missing or invalid API key", http.StatusUnauthorized)
+			c.Abort()
+			return
+		}
+
+		c.Set("api_key", header)
+		c.Next()
+	}
+}
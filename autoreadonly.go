@@ -0,0 +1,114 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default duration writes are rejected for once the transaction log failed too often, before trying again.
+const defaultAutoReadOnlyCooldown = 30 * time.Second
+
+// Automatic read-only mode, entered once -auto-read-only-after consecutive batches failed to be written to the
+// transaction log. Like a circuit breaker, writes are rejected for -auto-read-only-cooldown, then let through
+// again: the first batch written successfully leaves the mode, and another failed batch enters it again.
+var autoReadOnly = struct {
+	sync.Mutex
+	failures int       // Number of consecutive batches which failed to be written.
+	since    time.Time // When the mode was entered last, zero while writes are accepted.
+	reason   string    // Error of the failed batch which entered the mode.
+}{}
+
+// AutoReadOnly is the state of the automatic read-only mode, as reported by the health and stats endpoints.
+type AutoReadOnly struct {
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"` // When writes are let through again, to find out whether the log recovered.
+	Reason string    `json:"reason"`
+}
+
+// recordLogWrite records the result of writing a batch to the transaction log for the automatic read-only mode.
+func recordLogWrite(err error) {
+	if config.autoReadOnlyAfter <= 0 {
+		return
+	}
+
+	autoReadOnly.Lock()
+	defer autoReadOnly.Unlock()
+
+	if err == nil {
+		if !autoReadOnly.since.IsZero() {
+			log.Printf("The transaction log was written again, yakv accepts writes again")
+		}
+		autoReadOnly.failures, autoReadOnly.since, autoReadOnly.reason = 0, time.Time{}, ""
+		return
+	}
+
+	autoReadOnly.failures++
+	if autoReadOnly.failures >= config.autoReadOnlyAfter {
+		log.Printf("The transaction log failed %d times in a row, yakv rejects writes for %v: %v", autoReadOnly.failures, config.autoReadOnlyCooldown, err)
+		autoReadOnly.since, autoReadOnly.reason = time.Now(), err.Error()
+	}
+}
+
+// autoReadOnlyState returns the state of the automatic read-only mode, and whether writes are currently rejected.
+// A nil state means the mode isn't entered. Once the cooldown is over, writes are let through while the state
+// is still reported, until a batch is written.
+func autoReadOnlyState() (*AutoReadOnly, bool) {
+	autoReadOnly.Lock()
+	defer autoReadOnly.Unlock()
+
+	if autoReadOnly.since.IsZero() {
+		return nil, false
+	}
+
+	state := &AutoReadOnly{Since: autoReadOnly.since, Until: autoReadOnly.since.Add(config.autoReadOnlyCooldown), Reason: autoReadOnly.reason}
+	return state, time.Now().Before(state.Until)
+}
+
+// autoReadOnlyStats returns the state of the automatic read-only mode, nil unless it is entered.
+func autoReadOnlyStats() *AutoReadOnly {
+	state, _ := autoReadOnlyState()
+	return state
+}
+
+// AutoReadOnlyMiddleware rejects every request which could modify the store with 503 Service Unavailable while
+// the transaction log fails, except for the routes in exempt. The prefix of the group is stripped from the route
+// before checking exempt.
+func AutoReadOnlyMiddleware(prefix string, exempt map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if state, rejected := autoReadOnlyState(); rejected && !exempt[strings.TrimPrefix(c.FullPath(), prefix)] {
+			retryAfter := int(time.Until(state.Until).Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			writeError(c.Writer, "writes are rejected since the transaction log is failing: "+state.Reason, http.StatusServiceUnavailable)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
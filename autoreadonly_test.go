@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that writes are rejected once the transaction log failed often enough in a row, let
+// through again after the cooldown, and accepted again once the log recovers.
+func TestAutoReadOnly(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-auto-read-only.log")()
+	defer resetStores()
+	defer func(after int, cooldown time.Duration) {
+		config.autoReadOnlyAfter, config.autoReadOnlyCooldown = after, cooldown
+		recordLogWrite(nil)
+	}(config.autoReadOnlyAfter, config.autoReadOnlyCooldown)
+	config.autoReadOnlyAfter, config.autoReadOnlyCooldown = 2, time.Hour
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	put := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(`{"key": "yakv", "value": "hello, yakv!"}`)))
+		return rec
+	}
+
+	// A single failed batch isn't enough, and a successful one resets the count.
+	recordLogWrite(errors.New("disk full"))
+	recordLogWrite(nil)
+	recordLogWrite(errors.New("disk full"))
+	if rec := put(); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 before reaching the threshold, got %d", rec.Code)
+	}
+
+	// Two in a row reject writes, but not reads.
+	recordLogWrite(errors.New("disk full"))
+	rec := put()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" || !strings.Contains(rec.Body.String(), "disk full") {
+		t.Errorf("Expected 503 with Retry-After and the reason, got %d %q %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body.String())
+	}
+	if status, _ := handleBinRequest(binOpPut, "yakv", "hello, yakv!"); status != binStatusUnavailable {
+		t.Errorf("Expected the binary protocol to reject writes, got status %d", status)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/get", strings.NewReader(`{"key": "yakv"}`)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected reads to keep working, got %d", rec.Code)
+	}
+
+	// The state is reported by the health endpoint.
+	rec = httptest.NewRecorder()
+	HealthHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var health Health
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if health.AutoReadOnly == nil || health.AutoReadOnly.Reason != "disk full" {
+		t.Errorf("Expected the health to report the reason, got %+v", health.AutoReadOnly)
+	}
+
+	// The readiness check reports it too, without taking the instance out of rotation.
+	rec = httptest.NewRecorder()
+	ReadyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var ready struct {
+		Ready        bool          `json:"ready"`
+		AutoReadOnly *AutoReadOnly `json:"auto_read_only"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &ready); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || !ready.Ready || ready.AutoReadOnly == nil || ready.AutoReadOnly.Reason != "disk full" {
+		t.Errorf("Expected the readiness to report the reason with 200, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Once the cooldown is over, writes are let through while the state is still reported.
+	config.autoReadOnlyCooldown = 0
+	if rec := put(); rec.Code != http.StatusCreated {
+		t.Errorf("Expected 201 after the cooldown, got %d", rec.Code)
+	}
+	if state, rejected := autoReadOnlyState(); state == nil || rejected {
+		t.Errorf("Expected the state to be kept until a batch is written, got %+v %v", state, rejected)
+	}
+
+	// A successful batch leaves the mode.
+	recordLogWrite(nil)
+	if state, _ := autoReadOnlyState(); state != nil {
+		t.Errorf("Expected the mode to be left, got %+v", state)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default backpressure parameters for the transaction logger.
+const (
+	defaultLogBufferSize         = 16
+	defaultBackpressureThreshold = time.Second
+)
+
+// Time in Unix nanoseconds since which writes have been blocked on a full events channel, 0 while
+// the channel keeps up. Accessed atomically.
+var logBlockedSince int64
+
+// Number of writes which found the events channel full and had to wait. Accessed atomically.
+var logBlockedWrites uint64
+
+// Number of events sent to the transaction logger which haven't been flushed yet. Accessed atomically.
+var logPendingEvents int64
+
+// blockedLogWrites returns the number of writes which had to wait for the events channel.
+func blockedLogWrites() uint64 {
+	return atomic.LoadUint64(&logBlockedWrites)
+}
+
+// pendingLogEvents returns the number of events sent to the transaction logger which haven't been flushed yet.
+func pendingLogEvents() int64 {
+	return atomic.LoadInt64(&logPendingEvents)
+}
+
+// markLogBlocked records a write finding the events channel full.
+func markLogBlocked() {
+	atomic.AddUint64(&logBlockedWrites, 1)
+	atomic.CompareAndSwapInt64(&logBlockedSince, 0, time.Now().UnixNano())
+}
+
+// clearLogBlocked records the events channel being drained.
+func clearLogBlocked() {
+	if atomic.LoadInt64(&logBlockedSince) != 0 {
+		atomic.StoreInt64(&logBlockedSince, 0)
+	}
+}
+
+// underBackpressure reports whether the events channel hasn't been drained for at least the
+// backpressure threshold, i.e. writes are arriving faster than the transaction log is written.
+func underBackpressure() bool {
+	since := atomic.LoadInt64(&logBlockedSince)
+	return since != 0 && time.Since(time.Unix(0, since)) >= config.backpressureThreshold
+}
+
+// BackpressureMiddleware rejects every request which could modify the store with 503 Service Unavailable
+// while the transaction log is under backpressure, so that clients back off instead of queueing writes.
+func BackpressureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if underBackpressure() {
+			c.Header("Retry-After", "1")
+			writeError(c.Writer, "transaction log is falling behind, try again later", http.StatusServiceUnavailable)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that writes waiting on a full events channel are reported as backpressure,
+// and rejected when enabled, until the transaction log catches up.
+func TestBackpressure(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-backpressure.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer func() { writeLogFile = (*os.File).Write }()
+	defer func(size, batch int, threshold time.Duration) {
+		config.logBufferSize, config.logBatchSize, config.backpressureThreshold = size, batch, threshold
+	}(config.logBufferSize, config.logBatchSize, config.backpressureThreshold)
+	defer func() { logBlockedSince, logBlockedWrites = 0, 0 }()
+	config.logBufferSize, config.logBatchSize, config.backpressureThreshold = 1, 1, 10*time.Millisecond
+
+	// Writing to the file blocks until released, so the events channel fills up.
+	release := make(chan struct{})
+	writeLogFile = func(f *os.File, b []byte) (int, error) {
+		<-release
+		return f.Write(b)
+	}
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactionLogger.Log()
+	defer transactionLogger.Close()
+
+	// The first event is stuck being written, the second fills the channel and the third waits.
+	go func() {
+		for _, key := range []string{"yakv1", "yakv2", "yakv3"} {
+			transactionLogger.WritePut(key, "yak")
+		}
+	}()
+
+	for blockedLogWrites() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if underBackpressure() {
+		t.Error("Expected no backpressure before the threshold")
+	}
+	time.Sleep(config.backpressureThreshold)
+
+	// The channel drains once more if the first event is picked up after the second write, which restarts
+	// the backpressure until the third write waits.
+	for deadline := time.Now().Add(time.Second); !underBackpressure() && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if !underBackpressure() {
+		t.Error("Expected backpressure once writes waited past the threshold")
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(BackpressureMiddleware())
+	r.Any("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Writes are rejected, while reads keep working.
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected a write to be rejected with 503 and Retry-After, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected a read to be served, got %d", rec.Code)
+	}
+
+	// Once the log catches up, the backpressure is gone.
+	close(release)
+	transactionLogger.Wait()
+	if underBackpressure() {
+		t.Error("Expected no backpressure once the transaction log caught up")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Value of the encoding query parameter for base64-encoded keys and values.
+const base64Encoding = "base64"
+
+// errUnknownEncoding is raised when a request asks for an encoding other than base64.
+var errUnknownEncoding = errors.New("encoding must be base64 or empty")
+
+// errInvalidUTF8 is raised when a key or value which isn't base64-encoded isn't valid UTF-8.
+var errInvalidUTF8 = errors.New("keys and values must be valid UTF-8, or base64-encoded with encoding=base64")
+
+// wireEncoding reports whether the keys and values of a request and its response are base64-encoded,
+// which lets clients use arbitrary bytes that JSON strings can't hold.
+func wireEncoding(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("encoding") {
+	case "":
+		return false, nil
+	case base64Encoding:
+		return true, nil
+	}
+
+	return false, errUnknownEncoding
+}
+
+// decodeWire decodes a key or value received from a client. Keys and values which aren't base64-encoded
+// have to be valid UTF-8, since they're logged and sent back as text.
+func decodeWire(binary bool, s string) (string, error) {
+	if !binary {
+		if !utf8.ValidString(s) {
+			return "", errInvalidUTF8
+		}
+		return s, nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", errors.New("keys and values must be base64-encoded")
+	}
+
+	return string(b), nil
+}
+
+// stripNewlines strips the newlines from a value received from a client like PUT does. Only text values are
+// stripped, binary values are stored as they are.
+func stripNewlines(binary bool, value string) string {
+	if binary {
+		return value
+	}
+
+	return strings.Replace(value, "\n", "", -1)
+}
+
+// decodeKey decodes a key or key prefix received from a client, and normalizes it.
+func decodeKey(binary bool, s string) (string, error) {
+	key, err := decodeWire(binary, s)
+	return normalizeKey(key), err
+}
+
+// encodeWire encodes a key or value sent to a client.
+func encodeWire(binary bool, s string) string {
+	if !binary {
+		return s
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
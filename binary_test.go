@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that binary keys and values survive the API and a replay of the transaction log.
+func TestBinaryRoundTrip(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-binary.log"
+
+	// Sample data, which isn't valid UTF-8 and would be corrupted by trimming or stripping newlines.
+	key := "\x00bin\xff"
+	value := " \n\tyakv\x00\xfe\n"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	resetStores()
+
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	b64 := base64.StdEncoding.EncodeToString
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put?encoding=base64", strings.NewReader(`{"key": "`+b64([]byte(key))+`", "value": "`+b64([]byte(value))+`"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/get?encoding=base64", strings.NewReader(`{"key": "`+b64([]byte(key))+`"}`)))
+	if rec.Body.String() != b64([]byte(value)) {
+		t.Errorf("Expected the base64-encoded value, got %q", rec.Body.String())
+	}
+	logger.Close()
+
+	// The value is replayed byte for byte.
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+
+	if val, err := Get(key); err != nil || val != value {
+		t.Errorf("Expected %q after replay, got %q %v", value, val, err)
+	}
+}
+
+// Function for testing that invalid encodings are rejected with 400.
+func TestBinaryInvalidEncoding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	for _, path := range []string{"/yakv/v0/get?encoding=base64", "/yakv/v0/get?encoding=hex"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, strings.NewReader(`{"key": "not base64!"}`)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", path, rec.Code)
+		}
+	}
+}
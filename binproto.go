@@ -0,0 +1,325 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Ops of requests of the binary protocol.
+const (
+	binOpGet    byte = 1
+	binOpPut    byte = 2
+	binOpDelete byte = 3
+)
+
+// Statuses of responses of the binary protocol.
+const (
+	binStatusOK          byte = 0
+	binStatusNotFound    byte = 1
+	binStatusBadRequest  byte = 2
+	binStatusTooLarge    byte = 3
+	binStatusUnavailable byte = 4
+	binStatusError       byte = 5
+	binStatusConflict    byte = 6
+	binStatusFull        byte = 7
+)
+
+// Maximum length of a key or value of a binary request. Connections sending longer ones are closed,
+// since the rest of the stream can't be trusted.
+const binMaxLength = 64 << 20
+
+// errBinTooLong is raised when a key or value of a binary request is longer than binMaxLength.
+var errBinTooLong = fmt.Errorf("keys and values are limited to %d bytes", binMaxLength)
+
+// binServer serves the binary protocol on a TCP listener. Every connection is served by a goroutine of its own,
+// which serves its requests one after the other.
+type binServer struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// checkBinPort checks that the binary protocol isn't combined with API keys or tenants, since it doesn't
+// authenticate its clients.
+func checkBinPort() error {
+	if config.binPort > 0 && (config.apiKey != "" || hasTenants()) {
+		return errors.New("-bin-port can't be combined with -api-key or tenants, the binary protocol has no authentication")
+	}
+
+	return nil
+}
+
+// serveBinary serves the binary protocol on ln in the background, until the server is closed.
+func serveBinary(ln net.Listener) *binServer {
+	s := &binServer{ln: ln, conns: make(map[net.Conn]struct{})}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("Error occurred while accepting a binary protocol connection: %v", err)
+				}
+				return
+			}
+
+			s.mu.Lock()
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go s.serveConn(conn)
+		}
+	}()
+
+	return s
+}
+
+// Close stops accepting connections, closes the open ones and waits for their requests to finish,
+// so that every write is logged before the transaction log is closed.
+func (s *binServer) Close() error {
+	err := s.ln.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// serveConn serves the requests of a connection until it is closed, idle for too long, or sends a malformed request.
+func (s *binServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	for {
+		if config.idleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(config.idleTimeout))
+		}
+
+		op, key, value, err := readBinRequest(r)
+		if errors.Is(err, errBinTooLong) {
+			writeBinResponse(w, binStatusTooLarge, err.Error())
+			w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		status, payload := handleBinRequest(op, key, value)
+		if config.writeTimeout > 0 {
+			conn.SetWriteDeadline(time.Now().Add(config.writeTimeout))
+		}
+		if err := writeBinResponse(w, status, payload); err != nil {
+			return
+		}
+
+		// Responses are flushed once no pipelined request is waiting.
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readBinRequest reads a request: a 1-byte op, followed by a key and a value prefixed with their lengths
+// as 4-byte big-endian integers. The value is empty for GET and DELETE.
+func readBinRequest(r *bufio.Reader) (byte, string, string, error) {
+	op, err := r.ReadByte()
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	key, err := readBinString(r)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	value, err := readBinString(r)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	return op, key, value, nil
+}
+
+// readBinString reads a string prefixed with its length.
+func readBinString(r io.Reader) (string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+
+	if n > binMaxLength {
+		return "", errBinTooLong
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+// writeBinResponse writes a response: a 1-byte status, followed by a payload prefixed with its length.
+// The payload is the value for a successful GET, an error message for a failed request, and empty otherwise.
+func writeBinResponse(w io.Writer, status byte, payload string) error {
+	header := make([]byte, 5)
+	header[0] = status
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, payload)
+	return err
+}
+
+// handleBinRequest runs a request, and returns the status and payload of its response.
+func handleBinRequest(op byte, key, value string) (byte, string) {
+	switch op {
+	case binOpGet:
+		return binGet(key)
+	case binOpPut, binOpDelete:
+		// Writes are rejected like they are over HTTP while the store can't be modified.
+		if config.replayUntil > 0 {
+			return binStatusUnavailable, "yakv is in read-only mode"
+		}
+		if isWritesPaused() {
+			return binStatusUnavailable, "writes are paused for maintenance, try again later"
+		}
+		if state, rejected := autoReadOnlyState(); rejected {
+			return binStatusUnavailable, "writes are rejected since the transaction log is failing: " + state.Reason
+		}
+		if config.rejectOnBackpressure && underBackpressure() {
+			return binStatusUnavailable, "transaction log is falling behind, try again later"
+		}
+
+		if op == binOpPut {
+			return binPut(key, value)
+		}
+		return binDelete(key)
+	default:
+		return binStatusBadRequest, fmt.Sprintf("unknown op %d", op)
+	}
+}
+
+// binGet gets the value of a key.
+func binGet(key string) (byte, string) {
+	value, err := Get(key)
+	switch {
+	case errors.Is(err, ErrorEmptyKey):
+		return binStatusBadRequest, err.Error()
+	case errors.Is(err, ErrorNoSuchKey):
+		return binStatusNotFound, err.Error()
+	case err != nil:
+		return binStatusError, err.Error()
+	}
+
+	return binStatusOK, value
+}
+
+// binPut sets the value of a key, which expires according to the default TTL of its prefix. Values are binary,
+// so they are stored and logged as they are.
+func binPut(key, value string) (byte, string) {
+	key = normalizeKey(key)
+	expiresAt := defaultExpiry(key, time.Now())
+
+	stored, compressed, err := encodeValue(key, value)
+	var schemaErr *SchemaError
+	switch {
+	case errors.Is(err, ErrorEmptyKey) || errors.As(err, &schemaErr):
+		return binStatusBadRequest, err.Error()
+	case errors.Is(err, ErrorValueTooLarge):
+		return binStatusTooLarge, err.Error()
+	case err != nil:
+		return binStatusError, err.Error()
+	}
+
+	changes.RLock()
+	defer changes.RUnlock()
+
+	var logErr error
+	_, err = putVersioned(key, stored, compressed, expiresAt, "", false, nil, func() {
+		logErr = logger.WriteEvent(Event{EventType: EventPut, Key: key, Value: stored, Expiry: unixNano(expiresAt), Compressed: compressed, verbatim: true})
+	})
+	if err == nil {
+		err = logErr
+	}
+	switch {
+	case errors.Is(err, ErrorNoSuchKey):
+		return binStatusNotFound, err.Error()
+	case errors.Is(err, errKeyExists):
+		return binStatusConflict, err.Error()
+	case errors.Is(err, errStoreFull):
+		return binStatusFull, err.Error()
+	case err != nil:
+		return binStatusError, err.Error()
+	}
+
+	return binStatusOK, ""
+}
+
+// binDelete deletes a key.
+func binDelete(key string) (byte, string) {
+	key = normalizeKey(key)
+
+	changes.RLock()
+	defer changes.RUnlock()
+
+	var logErr error
+	err := deleteLogged(key, func() { logErr = logger.WriteDelete(key) })
+	if err == nil {
+		err = logErr
+	}
+	switch {
+	case errors.Is(err, ErrorEmptyKey):
+		return binStatusBadRequest, err.Error()
+	case errors.Is(err, ErrorNoSuchKey):
+		return binStatusNotFound, err.Error()
+	case err != nil:
+		return binStatusError, err.Error()
+	}
+
+	return binStatusOK, ""
+}
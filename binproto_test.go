@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/burntcarrot/yakv/client"
+)
+
+// Function for testing that rejected PUTs get the statuses matching their HTTP status codes, and that writes are
+// rejected under backpressure like over HTTP.
+func TestBinaryProtocolStatuses(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-binproto-statuses.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(mode string) { config.putMode = mode }(config.putMode)
+	defer func(maxKeys int, reject bool) { config.maxKeys, config.maxKeysReject = maxKeys, reject }(config.maxKeys, config.maxKeysReject)
+	defer func(reject bool) { config.rejectOnBackpressure = reject }(config.rejectOnBackpressure)
+	defer atomic.StoreInt64(&logBlockedSince, 0)
+
+	config.putMode = putModeRejectExisting
+	if status, msg := handleBinRequest(binOpPut, "yakv", "v1"); status != binStatusOK {
+		t.Fatalf("Expected the new key to be put, got %d %s", status, msg)
+	}
+	if status, _ := handleBinRequest(binOpPut, "yakv", "v2"); status != binStatusConflict {
+		t.Errorf("Expected status %d for an existing key, got %d", binStatusConflict, status)
+	}
+
+	config.putMode = putModeOverwrite
+	config.maxKeys, config.maxKeysReject = 1, true
+	if status, _ := handleBinRequest(binOpPut, "other", "v1"); status != binStatusFull {
+		t.Errorf("Expected status %d for a full store, got %d", binStatusFull, status)
+	}
+
+	// The log has been backed up for longer than the threshold.
+	config.rejectOnBackpressure = true
+	atomic.StoreInt64(&logBlockedSince, time.Now().Add(-time.Minute).UnixNano())
+	if status, _ := handleBinRequest(binOpDelete, "yakv", ""); status != binStatusUnavailable {
+		t.Errorf("Expected status %d under backpressure, got %d", binStatusUnavailable, status)
+	}
+	if status, _ := handleBinRequest(binOpGet, "yakv", ""); status != binStatusOK {
+		t.Errorf("Expected reads to be served under backpressure, got %d", status)
+	}
+}
+
+// Function for testing that keys round-trip over the binary protocol, and that the writes are logged.
+func TestBinaryProtocol(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-binproto.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer setWritesPaused(false)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := serveBinary(ln)
+	defer s.Close()
+
+	c, err := client.DialBinary(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Values are binary, newlines and NUL bytes included.
+	const value = "hello,\nyakv!\x00"
+	if err := c.Put("yakv", value); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.Get("yakv"); err != nil || got != value {
+		t.Errorf("Expected %q, got %q %v", value, got, err)
+	}
+	if got, _ := Get("yakv"); got != value {
+		t.Errorf("Expected the value to be stored as it is, got %q", got)
+	}
+
+	if err := c.Put("deleted", "soon"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("deleted"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("deleted"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted key, got %v", err)
+	}
+
+	// Errors are reported with the HTTP status codes of the same errors.
+	var clientErr *client.Error
+	if err := c.Put("", "value"); !errors.As(err, &clientErr) || clientErr.Status != http.StatusBadRequest {
+		t.Errorf("Expected a 400 error for an empty key, got %v", err)
+	}
+
+	setWritesPaused(true)
+	if err := c.Put("paused", "value"); !errors.As(err, &clientErr) || clientErr.Status != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503 error while writes are paused, got %v", err)
+	}
+	setWritesPaused(false)
+
+	// Replaying the log gives the same store.
+	logger.Wait()
+	reader, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	events, errs := reader.ReadEvents()
+	replayed, n, err := replayState(events, errs, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := replayed["deleted"]; n != 3 || replayed["yakv"] != value || ok {
+		t.Errorf("Expected 3 events replaying to the same store, got %d events and %q", n, replayed)
+	}
+}
+
+// Function for testing that connections sending a key longer than the maximum length are closed.
+func TestBinaryProtocolTooLong(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := serveBinary(ln)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte{binOpGet, 0xff, 0xff, 0xff, 0xff})
+
+	// The error message is responded before the connection is closed.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(conn, header); err != nil || header[0] != binStatusTooLarge {
+		t.Fatalf("Expected a too large response, got %v %v", header, err)
+	}
+	if msg, err := io.ReadAll(conn); err != nil || string(msg) != errBinTooLong.Error() {
+		t.Errorf("Expected the connection to be closed after the error message, got %q %v", msg, err)
+	}
+}
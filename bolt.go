@@ -0,0 +1,441 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Backends persisting the store.
+const (
+	logBackend  = "log"  // Append-only transaction log, replayed on start-up.
+	boltBackend = "bolt" // BoltDB file holding the current state of the store.
+)
+
+// Default filename of the BoltDB file, unless -filename is set.
+const defaultBoltFilename = "yakv.db"
+
+// Buckets and keys of the BoltDB file.
+var (
+	boltKeysBucket       = []byte("keys")       // Keys of the default store.
+	boltNamespacesBucket = []byte("namespaces") // A nested bucket of keys for every namespace.
+	boltMetaBucket       = []byte("meta")
+	boltLastIDKey        = []byte("last_id") // Last used event ID, in the meta bucket.
+)
+
+// errBoltSnapshot is raised when a snapshot is requested with the bolt backend, which keeps no log to shorten.
+var errBoltSnapshot = errors.New("snapshots aren't needed with the bolt backend")
+
+// boltLogger is a transaction logger persisting every event directly to a BoltDB file instead of appending it
+// to a log, so that the file holds the current state of the store. Events queued while a transaction is
+// committed are committed together in the next one.
+type boltLogger struct {
+	db         *bolt.DB
+	events     chan<- Event // Write-only channel for sending events.
+	errors     <-chan error // Read-only channel for receiving errors.
+	lastID     uint64       // Last used event ID, persisted along with the events.
+	wg         *sync.WaitGroup
+	batchSize  int           // Maximum number of events committed in a single transaction.
+	bufferSize int           // Capacity of the events channel.
+	done       chan struct{} // Closed once the Log() goroutine has committed and exited.
+}
+
+// checkBackend checks that the backend is known, and that the flags depending on a transaction log aren't used without one.
+func checkBackend() error {
+	switch config.backend {
+	case logBackend:
+		return nil
+	case boltBackend:
+	default:
+		return fmt.Errorf("unknown backend %q, must be %s or %s", config.backend, logBackend, boltBackend)
+	}
+
+	switch {
+	case config.logShards > 1:
+		return errors.New("-log-shards requires -backend=log")
+	case config.replayUntil > 0:
+		return errors.New("-replay-until requires -backend=log")
+	case config.snapshotInterval > 0 || config.snapshotEveryNEvents > 0:
+		return errors.New("snapshots require -backend=log")
+	}
+
+	return nil
+}
+
+// newBoltLogger opens the BoltDB file at filename, creating it if needed. A read-only logger neither creates
+// nor modifies the file, and can only read its events.
+func newBoltLogger(filename string, readOnly bool) (*boltLogger, error) {
+	mode := config.logFileMode
+	if mode == 0 {
+		mode = defaultLogFileMode
+	}
+
+	// Another process holding the file would block opening it forever.
+	db, err := bolt.Open(filename, mode, &bolt.Options{Timeout: time.Second, ReadOnly: readOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open BoltDB file %q. %w", filename, err)
+	}
+
+	bl := &boltLogger{db: db, wg: &sync.WaitGroup{}, batchSize: config.logBatchSize, bufferSize: config.logBufferSize}
+	if bl.batchSize <= 0 {
+		bl.batchSize = defaultLogBatchSize
+	}
+	if bl.bufferSize <= 0 {
+		bl.bufferSize = defaultLogBufferSize
+	}
+
+	init := func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltKeysBucket, boltNamespacesBucket, boltMetaBucket} {
+			if !readOnly {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			} else if tx.Bucket(name) == nil {
+				return fmt.Errorf("bucket %q is missing", name)
+			}
+		}
+
+		if id := tx.Bucket(boltMetaBucket).Get(boltLastIDKey); len(id) == 8 {
+			bl.lastID = binary.BigEndian.Uint64(id)
+		}
+
+		return nil
+	}
+	if readOnly {
+		err = db.View(init)
+	} else {
+		err = db.Update(init)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize BoltDB file %q. %w", filename, err)
+	}
+
+	return bl, nil
+}
+
+// encodeBoltRecord encodes the stored value of a key along with its metadata: a byte of flags (compressed and
+// pinned), the expiry, the length of the content type as a uvarint, the content type and the value.
+func encodeBoltRecord(e Event) []byte {
+	b := make([]byte, 9, 9+binary.MaxVarintLen64+len(e.ContentType)+len(e.Value))
+	if e.Compressed {
+		b[0] |= 1
+	}
+	if e.Pinned {
+		b[0] |= 2
+	}
+	binary.BigEndian.PutUint64(b[1:9], uint64(e.Expiry))
+
+	var n [binary.MaxVarintLen64]byte
+	b = append(b, n[:binary.PutUvarint(n[:], uint64(len(e.ContentType)))]...)
+	b = append(b, e.ContentType...)
+
+	return append(b, e.Value...)
+}
+
+// decodeBoltRecord decodes a record encoded by encodeBoltRecord into a put of key. The record is copied, as
+// the memory of BoltDB is only valid during its transaction.
+func decodeBoltRecord(namespace, key string, b []byte) (Event, error) {
+	if len(b) < 9 {
+		return Event{}, fmt.Errorf("record of key %q is truncated", key)
+	}
+
+	e := Event{EventType: EventPut, Namespace: namespace, Key: key, Compressed: b[0]&1 != 0, Pinned: b[0]&2 != 0, Expiry: int64(binary.BigEndian.Uint64(b[1:9]))}
+
+	n, size := binary.Uvarint(b[9:])
+	if size <= 0 || uint64(len(b)-9-size) < n {
+		return Event{}, fmt.Errorf("record of key %q is truncated", key)
+	}
+
+	b = b[9+size:]
+	e.ContentType, e.Value = string(b[:n]), string(b[n:])
+
+	return e, nil
+}
+
+// applyBoltEvent applies an event to the buckets of a BoltDB transaction.
+func applyBoltEvent(tx *bolt.Tx, e Event) error {
+	namespaces := tx.Bucket(boltNamespacesBucket)
+	if e.EventType == EventDropNamespace {
+		if err := namespaces.DeleteBucket([]byte(e.Namespace)); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		return nil
+	}
+
+	keys := tx.Bucket(boltKeysBucket)
+	if e.Namespace != "" {
+		var err error
+		if keys, err = namespaces.CreateBucketIfNotExists([]byte(e.Namespace)); err != nil {
+			return err
+		}
+	}
+
+	switch e.EventType {
+	case EventPut:
+		return keys.Put([]byte(e.Key), encodeBoltRecord(e))
+	case EventDelete:
+		return keys.Delete([]byte(e.Key))
+	case EventTouch:
+		// Only the expiry of an existing key changes.
+		record := keys.Get([]byte(e.Key))
+		if record == nil {
+			return nil
+		}
+
+		put, err := decodeBoltRecord(e.Namespace, e.Key, record)
+		if err != nil {
+			return err
+		}
+		put.Expiry = e.Expiry
+
+		return keys.Put([]byte(e.Key), encodeBoltRecord(put))
+	}
+
+	return nil
+}
+
+// WritePut sends events of type EventPut to the BoltDB logger's events channel.
+func (bl *boltLogger) WritePut(key, value string) error {
+	return bl.WriteEvent(Event{EventType: EventPut, Key: key, Value: value})
+}
+
+// WriteDelete sends events of type EventDelete to the BoltDB logger's events channel.
+func (bl *boltLogger) WriteDelete(key string) error {
+	return bl.WriteEvent(Event{EventType: EventDelete, Key: key})
+}
+
+// WriteNamespacePut sends events of type EventPut for a namespaced key to the BoltDB logger's events channel.
+func (bl *boltLogger) WriteNamespacePut(namespace, key, value string) error {
+	return bl.WriteEvent(Event{EventType: EventPut, Namespace: namespace, Key: key, Value: value})
+}
+
+// WriteNamespaceDelete sends events of type EventDelete for a namespaced key to the BoltDB logger's events channel.
+func (bl *boltLogger) WriteNamespaceDelete(namespace, key string) error {
+	return bl.WriteEvent(Event{EventType: EventDelete, Namespace: namespace, Key: key})
+}
+
+// WriteDropNamespace sends events of type EventDropNamespace to the BoltDB logger's events channel.
+func (bl *boltLogger) WriteDropNamespace(namespace string) error {
+	return bl.WriteEvent(Event{EventType: EventDropNamespace, Namespace: namespace})
+}
+
+// WriteEvent sends an arbitrary event to the BoltDB logger's events channel.
+func (bl *boltLogger) WriteEvent(e Event) error {
+	return queueEvent(bl.events, bl.wg, e)
+}
+
+// Close commits any queued events and closes the BoltDB file.
+func (bl *boltLogger) Close() error {
+	if bl.events != nil {
+		close(bl.events)
+
+		// Wait for the Log() goroutine to commit the remaining events.
+		<-bl.done
+	}
+
+	return bl.db.Close()
+}
+
+// Reopen does nothing, as BoltDB files aren't rotated.
+func (bl *boltLogger) Reopen() error {
+	return nil
+}
+
+// Compact fails, as the BoltDB file already holds only the current state of the store.
+func (bl *boltLogger) Compact(events []Event, taken func()) (int64, error) {
+	return 0, errBoltSnapshot
+}
+
+// Wait blocks until every queued event has been committed.
+func (bl *boltLogger) Wait() {
+	bl.wg.Wait()
+}
+
+// Err returns the channel receiving errors committing events.
+func (bl *boltLogger) Err() <-chan error {
+	return bl.errors
+}
+
+// LastID returns the ID of the last committed event.
+func (bl *boltLogger) LastID() uint64 {
+	return atomic.LoadUint64(&bl.lastID)
+}
+
+// Log commits the queued events to the BoltDB file until the events channel is closed.
+func (bl *boltLogger) Log() {
+	events := make(chan Event, bl.bufferSize)
+	bl.events = events
+
+	// Buffer for sending errors, which are dropped rather than blocking the logger once it's full.
+	errors := make(chan error, logErrorBuffer)
+	bl.errors = errors
+
+	bl.done = make(chan struct{})
+
+	go func() {
+		defer close(bl.done)
+
+		batch := make([]Event, 0, bl.batchSize)
+		for e := range events {
+			// Events queued while the last transaction was committed go into the next one.
+			batch = append(batch[:0], e)
+			for len(batch) < bl.batchSize && len(events) > 0 {
+				batch = append(batch, <-events)
+			}
+
+			// Writes are only backed up as long as the channel doesn't drain.
+			if len(events) == 0 {
+				clearLogBlocked()
+			}
+
+			if err := bl.commit(batch); err != nil {
+				err = fmt.Errorf("%d events were lost. %w", len(batch), err)
+
+				// Send the error to errors channel, without blocking when nobody reads it.
+				select {
+				case errors <- err:
+				default:
+					log.Printf("Error occurred while writing the BoltDB file: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// commit applies a batch of events to the BoltDB file in a single transaction, which BoltDB syncs to disk.
+// The events are done with afterwards, whether they were committed or not.
+func (bl *boltLogger) commit(batch []Event) error {
+	id := bl.LastID()
+	start := time.Now()
+	err := bl.db.Update(func(tx *bolt.Tx) error {
+		for i := range batch {
+			batch[i].ID = id + uint64(i) + 1
+			if err := applyBoltEvent(tx, batch[i]); err != nil {
+				return err
+			}
+		}
+
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], id+uint64(len(batch)))
+		return tx.Bucket(boltMetaBucket).Put(boltLastIDKey, b[:])
+	})
+	recordLatency("log.flush", time.Since(start))
+
+	setLogHealth(err, len(batch))
+	if err == nil {
+		atomic.StoreUint64(&bl.lastID, id+uint64(len(batch)))
+		publishEvents(batch)
+		recordModifications(batch)
+		countLoggedEvents(len(batch))
+	}
+
+	for _, e := range batch {
+		if e.written != nil {
+			e.written <- err
+		}
+	}
+	bl.wg.Add(-len(batch))
+	atomic.AddInt64(&logPendingEvents, int64(-len(batch)))
+
+	return err
+}
+
+// ReadEvents reads the current state of the store from the BoltDB file, as a put for every key.
+func (bl *boltLogger) ReadEvents() (<-chan Event, <-chan error) {
+	outEvent := make(chan Event, 256) // Buffered channel for events, so reading runs ahead of loading.
+	outError := make(chan error, 1)   // Buffered channel for errors.
+
+	go func() {
+		defer close(outEvent)
+		defer close(outError)
+
+		// Sends every key of a bucket.
+		send := func(namespace string, keys *bolt.Bucket) error {
+			return keys.ForEach(func(key, record []byte) error {
+				e, err := decodeBoltRecord(namespace, string(key), record)
+				if err != nil {
+					return err
+				}
+
+				outEvent <- e
+				return nil
+			})
+		}
+
+		err := bl.db.View(func(tx *bolt.Tx) error {
+			if err := send("", tx.Bucket(boltKeysBucket)); err != nil {
+				return err
+			}
+
+			namespaces := tx.Bucket(boltNamespacesBucket)
+			return namespaces.ForEach(func(name, _ []byte) error {
+				return send(string(name), namespaces.Bucket(name))
+			})
+		})
+		if err != nil {
+			outError <- fmt.Errorf("failed reading BoltDB file. %w", err)
+		}
+	}()
+
+	return outEvent, outError
+}
+
+// InitBolt opens the BoltDB file and loads the state of the key-value store from it, replacing InitLog
+// with the bolt backend. No transactions are replayed, only the current value of every key is read.
+func InitBolt(filename string) error {
+	bl, err := newBoltLogger(filename, false)
+	if err != nil {
+		return fmt.Errorf("failed to create logger! %w", err)
+	}
+	logger = bl
+	transactionLogFilename = filename
+
+	fmt.Println("yakv is loading the store from the BoltDB file.... 🔎")
+	replaying = true
+	defer func() { replaying = false }()
+
+	// The file keeps no events, so every key counts as modified by the last committed one.
+	events, errors := bl.ReadEvents()
+	for e := range events {
+		if err == nil {
+			e.ID = bl.LastID()
+			err = applyEvent(e)
+		}
+	}
+	if err == nil {
+		err = <-errors
+	}
+	replayedID = bl.LastID()
+
+	// Spill files which no value references are left over.
+	if err == nil && config.spillThreshold > 0 {
+		var n int
+		if n, err = removeOrphanedSpills(time.Time{}); n > 0 {
+			fmt.Printf("yakv removed %d orphaned spill files\n", n)
+		}
+	}
+
+	bl.Log()
+	return err
+}
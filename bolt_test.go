@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that the bolt backend persists writes and loads them again without a log to replay.
+func TestBoltBackend(t *testing.T) {
+	// Temporary BoltDB filename.
+	const filename = "temp-bolt.db"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	defer func(backend string) { config.backend = backend }(config.backend)
+	config.backend = boltBackend
+
+	if err := InitBolt(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(method, path, body string) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if rec.Code >= 300 {
+			t.Fatalf("Expected %s %s to succeed, got %d %s", method, path, rec.Code, rec.Body.String())
+		}
+	}
+
+	serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "hello, yakv!", "content_type": "text/plain"}`)
+	serve(http.MethodPut, "/yakv/v0/put", `{"key": "expiring", "value": "soon"}`)
+	serve(http.MethodPost, "/yakv/v0/touch", `{"key": "expiring", "ttl_seconds": 3600}`)
+	serve(http.MethodPut, "/yakv/v0/put", `{"key": "deleted", "value": "gone"}`)
+	serve(http.MethodDelete, "/yakv/v0/delete", `{"key": "deleted"}`)
+	serve(http.MethodPut, "/yakv/v0/put?encoding=base64", `{"key": "YmluYXJ5", "value": "AAEKDQ=="}`)
+	serve(http.MethodPut, "/yakv/v0/ns/users/keys/alice", `{"value": "admin"}`)
+	serve(http.MethodPut, "/yakv/v0/ns/dropped/keys/bob", `{"value": "user"}`)
+	serve(http.MethodDelete, "/yakv/v0/ns/dropped", ``)
+	logger.Wait()
+
+	if d, err := VerifyLog(); err != nil || !d.Consistent {
+		t.Errorf("Expected the BoltDB file to match the store, got %+v %v", d, err)
+	}
+
+	expected := snapshotStores()
+	expiry, contentType, lastID := store.expiry["expiring"], store.contentType["yakv"], logger.LastID()
+	logger.Close()
+
+	resetStores()
+	if err := InitBolt(filename); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	if actual := snapshotStores(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v after loading the BoltDB file, got %v", expected, actual)
+	}
+	if !store.expiry["expiring"].Equal(expiry) || store.contentType["yakv"] != contentType {
+		t.Errorf("Expected the expiry and content type to be kept, got %v %q", store.expiry["expiring"], store.contentType["yakv"])
+	}
+	if value, _ := Get("binary"); value != "\x00\x01\n\r" {
+		t.Errorf("Expected the binary value to be kept verbatim, got %q", value)
+	}
+	if logger.LastID() != lastID {
+		t.Errorf("Expected event IDs to continue after %d, got %d", lastID, logger.LastID())
+	}
+
+	if _, err := Snapshot(); err != errBoltSnapshot {
+		t.Errorf("Expected snapshots to be refused, got %v", err)
+	}
+}
+
+// Function for testing that records round-trip with their metadata.
+func TestBoltRecord(t *testing.T) {
+	for _, e := range []Event{
+		{EventType: EventPut, Key: "plain", Value: "hello, yakv!"},
+		{EventType: EventPut, Namespace: "ns", Key: "full", Value: "\x00\xff\n", Compressed: true, Expiry: 1760450400000000000, ContentType: "image/png"},
+		{EventType: EventPut, Key: "empty"},
+		{EventType: EventPut, Key: "pinned", Value: "hello, yakv!", Compressed: true, Pinned: true},
+	} {
+		actual, err := decodeBoltRecord(e.Namespace, e.Key, encodeBoltRecord(e))
+		if err != nil || !reflect.DeepEqual(actual, e) {
+			t.Errorf("Expected %+v, got %+v %v", e, actual, err)
+		}
+	}
+
+	if _, err := decodeBoltRecord("", "truncated", []byte{0, 1, 2}); err == nil {
+		t.Error("Expected an error for a truncated record")
+	}
+}
+
+// Function for testing that flags depending on a transaction log are rejected with the bolt backend.
+func TestCheckBackend(t *testing.T) {
+	// Restore to original state after test.
+	defer func(backend string, shards int) { config.backend, config.logShards = backend, shards }(config.backend, config.logShards)
+
+	config.backend = "sqlite"
+	if err := checkBackend(); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+
+	config.backend, config.logShards = boltBackend, 2
+	if err := checkBackend(); err == nil {
+		t.Error("Expected an error for -log-shards with the bolt backend")
+	}
+
+	config.backend = logBackend
+	if err := checkBackend(); err != nil {
+		t.Errorf("Expected -log-shards to be accepted with the log backend, got %v", err)
+	}
+}
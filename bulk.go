@@ -0,0 +1,152 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Default maximum number of keys in a single bulk request.
+const defaultMaxBulkKeys = 1000
+
+// MDeleteBody is a struct for defining the bulk DELETE request body structure.
+type MDeleteBody struct {
+	Keys []string `json:"keys"`
+}
+
+// MDelete deletes all of the given keys under a single lock, logging a delete for each key which was removed.
+// It returns the number of deleted keys and the keys which didn't exist. No key is deleted if any key is invalid.
+func MDelete(keys []string) (int, []string, error) {
+	for i, key := range keys {
+		if err := validateKey(key); err != nil {
+			return 0, nil, fmt.Errorf("keys[%d]: %w", i, err)
+		}
+	}
+
+	normalized := make([]string, len(keys))
+	for i, key := range keys {
+		normalized[i] = normalizeKey(key)
+	}
+
+	now := time.Now()
+	missing := []string{}
+	removed := []string{}
+	deleted := 0
+
+	changes.RLock()
+	defer changes.RUnlock()
+	unlock := lockKeys(normalized...)
+	defer unlock()
+
+	store.Lock()
+	for _, key := range normalized {
+		if _, ok := store.m[key]; !ok {
+			missing = append(missing, key)
+			continue
+		}
+
+		expiresAt, expires := store.expiry[key]
+
+		removeLocked(key)
+		removed = append(removed, key)
+
+		// Keys which have expired but haven't been swept yet are removed, but reported as missing.
+		if expires && hasExpired(key, expiresAt, now) {
+			missing = append(missing, key)
+			continue
+		}
+
+		deleted++
+	}
+	store.Unlock()
+
+	var logErr error
+	for _, key := range removed {
+		if err := logger.WriteDelete(key); err != nil && logErr == nil {
+			logErr = err
+		}
+	}
+
+	return deleted, missing, logErr
+}
+
+// MDeleteHandler is a handler function for the bulk DELETE endpoint.
+func MDeleteHandler(rw http.ResponseWriter, r *http.Request) {
+	var body MDeleteBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if config.maxBulkKeys > 0 && len(body.Keys) > config.maxBulkKeys {
+		writeError(rw, fmt.Sprintf("keys must not contain more than %d keys", config.maxBulkKeys), http.StatusBadRequest)
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	keys := make([]string, len(body.Keys))
+	for i, key := range body.Keys {
+		if keys[i], err = decodeKey(binary, key); err != nil {
+			writeError(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	deleted, missing, err := MDelete(keys)
+	if errors.Is(err, ErrorEmptyKey) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range missing {
+		missing[i] = encodeWire(binary, missing[i])
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(struct {
+		Deleted int      `json:"deleted"`
+		Missing []string `json:"missing"`
+	}{deleted, missing}); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that a bulk delete removes the existing keys and reports the missing ones.
+func TestMDelete(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-mdelete.log")()
+	defer resetStores()
+	defer func() { config.maxBulkKeys = 0 }()
+
+	Put("a", "value")
+	Put("b", "value")
+	Put("kept", "value")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/mdelete", strings.NewReader(`{"keys": ["a", "b", "c"]}`)))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"deleted":2,"missing":["c"]}` {
+		t.Errorf("Unexpected response: %d %q", rec.Code, rec.Body.String())
+	}
+	if len(store.m) != 1 {
+		t.Errorf("Expected only the key which wasn't listed to be kept, got %v", store.m)
+	}
+
+	// One delete is logged for each removed key.
+	logger.Wait()
+	checkLastID(t, logger, 2)
+
+	// Empty keys are reported without deleting anything.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/mdelete", strings.NewReader(`{"keys": ["kept", ""]}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "keys[1]") || len(store.m) != 1 {
+		t.Errorf("Expected the empty key to be reported, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Lists longer than the limit are rejected.
+	config.maxBulkKeys = 1
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/mdelete", strings.NewReader(`{"keys": ["kept", "other"]}`)))
+	if rec.Code != http.StatusBadRequest || len(store.m) != 1 {
+		t.Errorf("Expected the list to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyETag returns a weak ETag for a response body. The ETag is weak since the body may be sent
+// gzip-compressed or not, which is the same representation semantically.
+func bodyETag(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using the weak comparison.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeCacheHeaders sets the ETag and, with -get-cache-ttl, the Cache-Control headers of a read returning body.
+// If the client already has the body, it answers with 304 Not Modified and returns true.
+func writeCacheHeaders(rw http.ResponseWriter, r *http.Request, body string) bool {
+	etag := bodyETag(body)
+	rw.Header().Set("ETag", etag)
+
+	if config.getCacheTTL > 0 {
+		rw.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int64(config.getCacheTTL.Seconds())))
+	}
+
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag) {
+		rw.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+// NoStoreMiddleware keeps responses to requests which modify the store from being cached.
+func NoStoreMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			c.Header("Cache-Control", "no-store")
+		}
+
+		c.Next()
+	}
+}
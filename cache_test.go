@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing the caching headers of GET and the 304 response to a matching If-None-Match.
+func TestGetCacheHeaders(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-cache.log")()
+	defer resetStores()
+	defer func() { config.getCacheTTL = 0 }()
+
+	config.getCacheTTL = time.Minute
+	Put("yakv", "hello, yakv!")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/get", strings.NewReader(`{"key": "yakv"}`)))
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get("Cache-Control") != "max-age=60" {
+		t.Fatalf("Expected caching headers, got %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/yakv/v0/get", strings.NewReader(`{"key": "yakv"}`))
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("Expected 304 without a body, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// A changed value doesn't match the old ETag anymore.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(`{"key": "yakv", "value": "changed"}`)))
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected writes not to be cached, got %q", rec.Header().Get("Cache-Control"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/yakv/v0/get", strings.NewReader(`{"key": "yakv"}`))
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "changed" {
+		t.Errorf("Expected the changed value, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+// Function for testing conditional reads: a matching ETag gets 304 without the value being printed,
+// a stale one gets the value, and a missing key still gets 404.
+func TestGetIfNoneMatch(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-if-none-match.log")()
+	defer resetStores()
+
+	Put("yakv", "hello, yakv!")
+	etag := bodyETag("hello, yakv!")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	get := func(key, ifNoneMatch string) (*httptest.ResponseRecorder, string) {
+		req := httptest.NewRequest(http.MethodGet, "/yakv/v0/get", strings.NewReader(`{"key": "`+key+`"}`))
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		rec := httptest.NewRecorder()
+
+		printed := captureStdout(t, func() { r.ServeHTTP(rec, req) })
+		return rec, printed
+	}
+
+	// A matching ETag, also among several or as a strong ETag, gets 304 without a body.
+	for _, header := range []string{etag, `W/"stale", ` + etag, strings.TrimPrefix(etag, "W/")} {
+		rec, printed := get("yakv", header)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("Expected 304 without a body for %s, got %d %q", header, rec.Code, rec.Body.String())
+		}
+		if strings.Contains(printed, "hello, yakv!") {
+			t.Errorf("Expected the value not to be printed for a 304, got %q", printed)
+		}
+	}
+
+	// A stale ETag gets the value along with its current ETag.
+	rec, printed := get("yakv", `W/"stale"`)
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello, yakv!" || rec.Header().Get("ETag") != etag {
+		t.Errorf("Expected 200 with the value and its ETag, got %d %q %q", rec.Code, rec.Body.String(), rec.Header().Get("ETag"))
+	}
+	if !strings.Contains(printed, "hello, yakv!") {
+		t.Errorf("Expected the value sent to be printed, got %q", printed)
+	}
+
+	// A missing key is never matched, not even by a wildcard.
+	for _, header := range []string{etag, "*"} {
+		if rec, _ := get("missing", header); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for a missing key with %s, got %d", header, rec.Code)
+		}
+	}
+}
+
+// Helper function for capturing what fn prints to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	w.Close()
+
+	printed, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(printed)
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Limits holds the limits a server enforces on requests, 0 meaning no limit.
+type Limits struct {
+	MaxValueSize   int     `json:"max_value_size"`
+	MaxBodySize    int     `json:"max_body_size"`
+	MaxBulkKeys    int     `json:"max_bulk_keys"`
+	MaxGetAllKeys  int     `json:"max_getall_keys"`
+	MaxExportKeys  int     `json:"max_export_keys"`
+	MaxDumpSize    int     `json:"max_dump_size"`
+	PageLimit      int     `json:"page_limit"`
+	MaxConcurrency int     `json:"max_concurrency"`
+	MaxConnsPerIP  int     `json:"max_conns_per_ip"`
+	RateLimit      float64 `json:"rate_limit"`
+	RateBurst      int     `json:"rate_burst"`
+}
+
+// Features holds whether the features of a server are available.
+type Features struct {
+	TTL                 bool `json:"ttl"`
+	CAS                 bool `json:"cas"`
+	Binary              bool `json:"binary"`
+	ContentTypes        bool `json:"content_types"`
+	Namespaces          bool `json:"namespaces"`
+	JSONPatch           bool `json:"json_patch"`
+	Compression         bool `json:"compression"`
+	GzipResponses       bool `json:"gzip_responses"`
+	ValueIndex          bool `json:"value_index"`
+	HotKeys             bool `json:"hot_keys"`
+	CaseInsensitiveKeys bool `json:"case_insensitive_keys"`
+	SyncWrites          bool `json:"sync_writes"`
+	LenientJSON         bool `json:"lenient_json"`
+	Flush               bool `json:"flush"`
+	Dump                bool `json:"dump"`
+	ReadOnly            bool `json:"read_only"`
+}
+
+// Capabilities describes the features and limits of a server, so that clients can adapt to them.
+type Capabilities struct {
+	Limits   Limits   `json:"limits"`
+	Features Features `json:"features"`
+}
+
+// currentCapabilities returns the capabilities of the server, derived from its configuration.
+// Compare-and-swap is always available, through the version of a PUT.
+func currentCapabilities() Capabilities {
+	return Capabilities{
+		Limits: Limits{
+			MaxValueSize:   config.maxValueSize,
+			MaxBodySize:    maxBodySize,
+			MaxBulkKeys:    config.maxBulkKeys,
+			MaxGetAllKeys:  config.maxGetAllKeys,
+			MaxExportKeys:  config.maxExportKeys,
+			MaxDumpSize:    config.maxDumpSize,
+			PageLimit:      defaultKeysLimit,
+			MaxConcurrency: config.maxConcurrency,
+			MaxConnsPerIP:  config.maxConnsPerIP,
+			RateLimit:      config.rateLimit,
+			RateBurst:      config.rateBurst,
+		},
+		Features: Features{
+			TTL:                 true,
+			CAS:                 true,
+			Binary:              true,
+			ContentTypes:        true,
+			Namespaces:          true,
+			JSONPatch:           true,
+			Compression:         config.compressThreshold > 0,
+			GzipResponses:       config.gzipResponses,
+			ValueIndex:          config.enableValueIndex,
+			HotKeys:             config.trackAccess,
+			CaseInsensitiveKeys: config.caseInsensitiveKeys,
+			SyncWrites:          config.syncWrites,
+			LenientJSON:         config.lenientJSON,
+			Flush:               config.allowFlush,
+			Dump:                config.allowDump,
+			ReadOnly:            config.replayUntil > 0 || isWritesPaused(),
+		},
+	}
+}
+
+// CapabilitiesHandler is a handler function for the endpoint describing the capabilities of the server.
+func CapabilitiesHandler(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(currentCapabilities()); err != nil {
+		log.Println(err)
+	}
+}
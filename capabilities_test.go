@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that the capabilities follow the configuration of the server.
+func TestCapabilities(t *testing.T) {
+	// Restore to original state after test.
+	defer func(maxValueSize int, enableValueIndex bool) {
+		config.maxValueSize, config.enableValueIndex = maxValueSize, enableValueIndex
+	}(config.maxValueSize, config.enableValueIndex)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	capabilities := func() Capabilities {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/capabilities", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+
+		var c Capabilities
+		if err := json.NewDecoder(rec.Body).Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+
+		return c
+	}
+
+	config.maxValueSize, config.enableValueIndex = 1024, false
+	c := capabilities()
+	if c.Limits.MaxValueSize != 1024 || c.Limits.MaxBodySize != maxBodySize || c.Features.ValueIndex {
+		t.Errorf("Unexpected capabilities: %+v", c)
+	}
+	if !c.Features.TTL || !c.Features.CAS {
+		t.Errorf("Expected TTLs and compare-and-swap, got %+v", c.Features)
+	}
+
+	// Changes to the configuration show up right away.
+	config.maxValueSize, config.enableValueIndex = 0, true
+	if c := capabilities(); c.Limits.MaxValueSize != 0 || !c.Features.ValueIndex {
+		t.Errorf("Expected the changed configuration, got %+v", c)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Default maximum number of keys returned when listing the keys changed since an event.
+const defaultChangesLimit = 1000
+
+// Maximum number of deleted keys whose deletes are kept for listing. Beyond it, the oldest deletes are forgotten
+// until half of it are left.
+var maxTombstones = 100000
+
+// errChangesForgotten is raised when listing the changes since an ID before the last forgotten delete.
+var errChangesForgotten = errors.New("deletes since id were forgotten, list every key again with an empty id")
+
+// Modification is the last modification of a key, as listed by the endpoint listing changed keys.
+type Modification struct {
+	ID      uint64 `json:"id"`
+	Key     string `json:"key"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// Last modification of each key of the default store, by the ID of its event in the transaction log. Deleted
+// keys are kept up to maxTombstones, so that they are listed as deleted. Modifications are appended in ID order,
+// and a modification superseded by a later one of its key is only removed once they make up half of them, so
+// that listing the changes since an ID doesn't scan every key.
+var modifications = struct {
+	sync.RWMutex
+	latest    map[string]Modification // Last modification of each key.
+	order     []Modification          // Modifications in ID order, including superseded ones.
+	last      uint64                  // ID of the last modification.
+	live      int                     // Number of keys whose last modification didn't delete them.
+	forgotten uint64                  // ID of the last forgotten delete.
+}{latest: make(map[string]Modification)}
+
+// recordModification records an event written to or replayed from the transaction log as the last modification
+// of its key. Events of namespaces and of databases other than 0 aren't recorded. Touching a key which doesn't
+// exist leaves it deleted.
+func recordModification(e Event) {
+	if e.Namespace != "" || e.Database != 0 || e.EventType == EventDropNamespace {
+		return
+	}
+
+	modifications.Lock()
+	defer modifications.Unlock()
+
+	prev, ok := modifications.latest[e.Key]
+	m := Modification{ID: e.ID, Key: e.Key, Deleted: e.EventType == EventDelete}
+	if e.EventType == EventTouch {
+		m.Deleted = !ok || prev.Deleted
+	}
+
+	if ok && !prev.Deleted {
+		modifications.live--
+	}
+	if !m.Deleted {
+		modifications.live++
+	}
+
+	modifications.latest[m.Key] = m
+	if m.ID > modifications.last {
+		modifications.last = m.ID
+	}
+
+	modifications.order = append(modifications.order, m)
+
+	if m.Deleted && len(modifications.latest)-modifications.live > maxTombstones {
+		forgetTombstonesLocked(maxTombstones / 2)
+	}
+
+	if len(modifications.order) > 2*len(modifications.latest) {
+		order := make([]Modification, 0, len(modifications.latest))
+		for _, m := range modifications.order {
+			if modifications.latest[m.Key].ID == m.ID {
+				order = append(order, m)
+			}
+		}
+		modifications.order = order
+	}
+}
+
+// forgetTombstonesLocked forgets the oldest deletes until n deleted keys are left. The caller must hold the lock
+// of the modifications.
+func forgetTombstonesLocked(n int) {
+	for _, m := range modifications.order {
+		if len(modifications.latest)-modifications.live <= n {
+			return
+		}
+
+		if latest, ok := modifications.latest[m.Key]; ok && latest.ID == m.ID && latest.Deleted {
+			delete(modifications.latest, m.Key)
+			modifications.forgotten = m.ID
+		}
+	}
+}
+
+// recordModifications records a batch of events written to the transaction log.
+func recordModifications(events []Event) {
+	for _, e := range events {
+		recordModification(e)
+	}
+}
+
+// ChangedSince returns up to limit of the keys whose last modification came after the event with the given ID,
+// in the order they were modified, along with the ID to list the next changes since: the ID of the last
+// modification if every change was returned, and of the last returned one otherwise. Listing the changes since
+// an ID before the last forgotten delete fails with errChangesForgotten, except for 0, which lists every key
+// there is.
+func ChangedSince(id uint64, limit int) ([]Modification, uint64, bool, error) {
+	modifications.RLock()
+	defer modifications.RUnlock()
+
+	if id > 0 && id < modifications.forgotten {
+		return nil, 0, false, errChangesForgotten
+	}
+
+	order := modifications.order
+	i := sort.Search(len(order), func(i int) bool { return order[i].ID > id })
+
+	changed := make([]Modification, 0)
+	for ; i < len(order); i++ {
+		if latest, ok := modifications.latest[order[i].Key]; !ok || latest.ID != order[i].ID {
+			continue
+		}
+		if len(changed) == limit {
+			return changed, changed[len(changed)-1].ID, true, nil
+		}
+
+		changed = append(changed, order[i])
+	}
+
+	last := modifications.last
+	if last < id {
+		last = id
+	}
+
+	return changed, last, false, nil
+}
+
+// ChangedSinceHandler is a handler function for the endpoint listing the keys modified since an event.
+func ChangedSinceHandler(rw http.ResponseWriter, r *http.Request) {
+	if config.logShards > 1 {
+		writeError(rw, errShardedLog.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+
+	var id uint64
+	if value := query.Get("id"); value != "" {
+		if id, err = strconv.ParseUint(value, 10, 64); err != nil {
+			writeError(rw, "id must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := defaultChangesLimit
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			writeError(rw, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	changed, last, more, err := ChangedSince(id, limit)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusGone)
+		return
+	}
+	for i := range changed {
+		changed[i].Key = encodeWire(binary, changed[i].Key)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(struct {
+		Changes []Modification `json:"changes"`
+		LastID  uint64         `json:"last_id"`
+		More    bool           `json:"more"`
+	}{changed, last, more}); err != nil {
+		log.Println(err)
+	}
+}
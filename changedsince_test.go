@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Helper function for describing modifications as key@id, with a minus for deleted keys.
+func describeModifications(changes []Modification) string {
+	var s []string
+	for _, m := range changes {
+		if m.Deleted {
+			s = append(s, fmt.Sprintf("-%s@%d", m.Key, m.ID))
+		} else {
+			s = append(s, fmt.Sprintf("%s@%d", m.Key, m.ID))
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+// Function for testing that the keys changed since an event are listed in order, paginated, and survive a replay.
+func TestChangedSinceHandler(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-changed-since.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	logger.WritePut("yakv1", "v1")
+	logger.WritePut("yakv2", "v1")
+	logger.WritePut("yakv1", "v2")
+	logger.WriteDelete("yakv2")
+	logger.WriteNamespacePut("users", "alice", "v1")
+	logger.WritePut("yakv3", "v1")
+	logger.Wait()
+
+	changedSince := func(query string) (int, string, uint64, bool) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/changed-since?"+query, nil))
+
+		var resp struct {
+			Changes []Modification `json:"changes"`
+			LastID  uint64         `json:"last_id"`
+			More    bool           `json:"more"`
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, describeModifications(resp.Changes), resp.LastID, resp.More
+	}
+
+	// Only the last modification of each key is listed, and namespaced keys aren't.
+	if code, changes, last, more := changedSince(""); code != http.StatusOK || changes != "yakv1@3,-yakv2@4,yakv3@6" || last != 6 || more {
+		t.Errorf("Expected every change up to 6, got %d %s %d %v", code, changes, last, more)
+	}
+	if _, changes, last, _ := changedSince("id=3"); changes != "-yakv2@4,yakv3@6" || last != 6 {
+		t.Errorf("Expected the changes after 3, got %s %d", changes, last)
+	}
+
+	// Nothing changed since the last ID, which is kept for the next poll.
+	if _, changes, last, more := changedSince("id=6"); changes != "" || last != 6 || more {
+		t.Errorf("Expected no changes after 6, got %s %d %v", changes, last, more)
+	}
+
+	// A page ends at its last change.
+	if _, changes, last, more := changedSince("limit=2"); changes != "yakv1@3,-yakv2@4" || last != 4 || !more {
+		t.Errorf("Expected the first page up to 4, got %s %d %v", changes, last, more)
+	}
+	if _, changes, last, more := changedSince("id=4&limit=2"); changes != "yakv3@6" || last != 6 || more {
+		t.Errorf("Expected the last page up to 6, got %s %d %v", changes, last, more)
+	}
+
+	for _, query := range []string{"id=-1", "id=yakv", "limit=0"} {
+		if code, _, _, _ := changedSince(query); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, code)
+		}
+	}
+
+	// Replaying the log records the IDs of its events.
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, changes, last, _ := changedSince(""); changes != "yakv1@3,-yakv2@4,yakv3@6" || last != 6 {
+		t.Errorf("Expected the same changes after a replay, got %s %d", changes, last)
+	}
+}
+
+// Function for testing that modifications are listed in ID order, and that superseded modifications are removed.
+func TestRecordModification(t *testing.T) {
+	// Restore to original state after test.
+	defer resetStores()
+	resetStores()
+
+	for _, e := range []Event{
+		{ID: 1, EventType: EventPut, Key: "yakv1"},
+		{ID: 2, EventType: EventPut, Key: "yakv3"},
+		{ID: 3, EventType: EventPut, Key: "yakv2"},
+		{ID: 4, EventType: EventPut, Key: "yakv1"},
+		{ID: 5, EventType: EventPut, Key: "yakv1"},
+		{ID: 6, EventType: EventDelete, Key: "yakv1"},
+		{ID: 7, EventType: EventPut, Key: "yakv1"},
+	} {
+		recordModification(e)
+	}
+
+	if changes, last, _, _ := ChangedSince(0, defaultChangesLimit); describeModifications(changes) != "yakv3@2,yakv2@3,yakv1@7" || last != 7 {
+		t.Errorf("Expected the changes in ID order, got %s %d", describeModifications(changes), last)
+	}
+	if n := len(modifications.order); n > 2*len(modifications.latest) {
+		t.Errorf("Expected superseded modifications to be removed, got %d for %d keys", n, len(modifications.latest))
+	}
+}
+
+// Function for testing that the oldest deletes are forgotten beyond maxTombstones, failing listings since before them.
+func TestForgetTombstones(t *testing.T) {
+	// Restore to original state after test.
+	defer resetStores()
+	defer func(n int) { maxTombstones = n }(maxTombstones)
+	resetStores()
+	maxTombstones = 2
+
+	for _, e := range []Event{
+		{ID: 1, EventType: EventPut, Key: "live"},
+		{ID: 2, EventType: EventDelete, Key: "yakv1"},
+		{ID: 3, EventType: EventDelete, Key: "yakv2"},
+		{ID: 4, EventType: EventDelete, Key: "yakv3"},
+	} {
+		recordModification(e)
+	}
+
+	// The third delete forgets the oldest ones until one is left.
+	if _, _, _, err := ChangedSince(1, defaultChangesLimit); !errors.Is(err, errChangesForgotten) {
+		t.Errorf("Expected errChangesForgotten since before a forgotten delete, got %v", err)
+	}
+	if changes, last, _, err := ChangedSince(3, defaultChangesLimit); err != nil || describeModifications(changes) != "-yakv3@4" || last != 4 {
+		t.Errorf("Expected the delete after the forgotten ones, got %s %d %v", describeModifications(changes), last, err)
+	}
+	if changes, _, _, err := ChangedSince(0, defaultChangesLimit); err != nil || describeModifications(changes) != "live@1,-yakv3@4" {
+		t.Errorf("Expected every key there is since 0, got %s %v", describeModifications(changes), err)
+	}
+}
+
+// Function for testing that streaming events and listing changed keys are refused with a sharded log.
+func TestShardedLogStreams(t *testing.T) {
+	// Restore to original state after test.
+	defer func(shards int) { config.logShards = shards }(config.logShards)
+	config.logShards = 2
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	for _, path := range []string{"/yakv/v0/admin/events", "/yakv/v0/changed-since"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("Expected 501 for %s with a sharded log, got %d", path, rec.Code)
+		}
+	}
+}
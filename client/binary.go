@@ -0,0 +1,145 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Ops of requests of the binary protocol.
+const (
+	binOpGet    byte = 1
+	binOpPut    byte = 2
+	binOpDelete byte = 3
+)
+
+// Statuses of responses of the binary protocol.
+const (
+	binStatusOK          byte = 0
+	binStatusNotFound    byte = 1
+	binStatusBadRequest  byte = 2
+	binStatusTooLarge    byte = 3
+	binStatusUnavailable byte = 4
+	binStatusError       byte = 5
+	binStatusConflict    byte = 6
+	binStatusFull        byte = 7
+)
+
+// HTTP status codes the errors of the binary protocol are reported with.
+var binStatusCodes = map[byte]int{
+	binStatusBadRequest:  http.StatusBadRequest,
+	binStatusTooLarge:    http.StatusRequestEntityTooLarge,
+	binStatusUnavailable: http.StatusServiceUnavailable,
+	binStatusError:       http.StatusInternalServerError,
+	binStatusConflict:    http.StatusConflict,
+	binStatusFull:        http.StatusInsufficientStorage,
+}
+
+// BinaryClient is a client for the binary protocol of a yakv server, served with -bin-port.
+// It sends requests over a single connection, one at a time.
+type BinaryClient struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// DialBinary connects to the binary protocol of the yakv server at addr, e.g. 127.0.0.1:8081.
+func DialBinary(addr string) (*BinaryClient, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BinaryClient{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}, nil
+}
+
+// Get gets the value assigned to a key.
+func (c *BinaryClient) Get(key string) (string, error) {
+	return c.do(binOpGet, key, "")
+}
+
+// Put sets the value of a key.
+func (c *BinaryClient) Put(key, value string) error {
+	_, err := c.do(binOpPut, key, value)
+	return err
+}
+
+// Delete deletes a key.
+func (c *BinaryClient) Delete(key string) error {
+	_, err := c.do(binOpDelete, key, "")
+	return err
+}
+
+// Close closes the connection.
+func (c *BinaryClient) Close() error {
+	return c.conn.Close()
+}
+
+// do sends a request and returns the payload of a successful response. Errors of the server are returned as
+// an *Error with the HTTP status code matching the status of the response.
+func (c *BinaryClient) do(op byte, key, value string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.w.WriteByte(op); err != nil {
+		return "", err
+	}
+	for _, s := range []string{key, value} {
+		if err := binary.Write(c.w, binary.BigEndian, uint32(len(s))); err != nil {
+			return "", err
+		}
+		if _, err := c.w.WriteString(s); err != nil {
+			return "", err
+		}
+	}
+	if err := c.w.Flush(); err != nil {
+		return "", err
+	}
+
+	status, err := c.r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	var n uint32
+	if err := binary.Read(c.r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return "", err
+	}
+
+	switch status {
+	case binStatusOK:
+		return string(payload), nil
+	case binStatusNotFound:
+		return "", ErrNotFound
+	}
+
+	code, ok := binStatusCodes[status]
+	if !ok {
+		return "", fmt.Errorf("yakv: unknown status %d", status)
+	}
+
+	return "", &Error{Status: code, Message: string(payload)}
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client provides a typed Go client for the yakv HTTP API.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNotFound is returned when a key doesn't exist on the server.
+var ErrNotFound = errors.New("key doesn't exist")
+
+// Error is an error responded by the server, other than a missing key.
+type Error struct {
+	Status  int    // HTTP status code of the response.
+	Code    string // Machine-readable code of the error, e.g. BAD_REQUEST, empty for servers without codes.
+	Message string // Human-readable message of the error.
+}
+
+// Error returns the message along with the status and the code of the error.
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("yakv: %s (%d)", e.Message, e.Status)
+	}
+
+	return fmt.Sprintf("yakv: %s (%d %s)", e.Message, e.Status, e.Code)
+}
+
+// Client is a client for a yakv server.
+type Client struct {
+	addr   string       // Base address of the server, e.g. http://127.0.0.1:8080.
+	apiKey string       // API key sent with every request, if set.
+	http   *http.Client // Underlying HTTP client.
+}
+
+// New creates a client for the yakv server at addr. The API key is only sent if it isn't empty.
+func New(addr, apiKey string) *Client {
+	return &Client{addr: strings.TrimRight(addr, "/"), apiKey: apiKey, http: http.DefaultClient}
+}
+
+// Get gets the value assigned to a key.
+func (c *Client) Get(key string) (string, error) {
+	body, err := c.do(http.MethodGet, "/yakv/v0/get", map[string]string{"key": key})
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// Put sets the value of a key.
+func (c *Client) Put(key, value string) error {
+	_, err := c.do(http.MethodPut, "/yakv/v0/put", map[string]string{"key": key, "value": value})
+	return err
+}
+
+// Delete deletes a key.
+func (c *Client) Delete(key string) error {
+	_, err := c.do(http.MethodDelete, "/yakv/v0/delete", map[string]string{"key": key})
+	return err
+}
+
+// Keys lists the keys starting with prefix, in sorted order. It follows the server's cursors until all keys are listed.
+func (c *Client) Keys(prefix string) ([]string, error) {
+	var keys []string
+	var after string
+
+	for {
+		body, err := c.do(http.MethodGet, "/yakv/v0/keys?prefix="+url.QueryEscape(prefix)+"&after="+url.QueryEscape(after), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp struct {
+			Keys []string `json:"keys"`
+			Next string   `json:"next"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("yakv: failed to decode keys. %w", err)
+		}
+
+		keys = append(keys, resp.Keys...)
+		if resp.Next == "" {
+			return keys, nil
+		}
+		after = resp.Next
+	}
+}
+
+// do sends a request with an optional JSON body, and returns the response body of a successful request.
+func (c *Client) do(method, path string, payload interface{}) ([]byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		buf, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, c.addr+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, decodeError(resp.StatusCode, body)
+	}
+
+	return body, nil
+}
+
+// decodeError decodes an error response, falling back to its body as the message for servers which
+// don't respond with JSON errors.
+func decodeError(status int, body []byte) *Error {
+	var resp struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Error.Code == "" {
+		return &Error{Status: status, Message: strings.TrimSpace(string(body))}
+	}
+
+	return &Error{Status: status, Code: resp.Error.Code, Message: resp.Error.Message}
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+)
+
+// compressValue gzip-compresses values of at least config.compressThreshold bytes. It returns
+// the value as it should be stored, and whether it was compressed. Values below the threshold,
+// or which wouldn't get any smaller, are returned unchanged.
+func compressValue(value string) (string, bool, error) {
+	if config.compressThreshold <= 0 || len(value) < config.compressThreshold {
+		return value, false, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+
+	if _, err := zw.Write([]byte(value)); err != nil {
+		return "", false, err
+	}
+	if err := zw.Close(); err != nil {
+		return "", false, err
+	}
+
+	if buf.Len() >= len(value) {
+		return value, false, nil
+	}
+
+	return buf.String(), true, nil
+}
+
+// decompressValue decompresses a value stored by compressValue, or reads it back from its file if it was spilled.
+func decompressValue(stored string) (string, error) {
+	if name, ok := spillName(stored); ok {
+		return readSpill(name)
+	}
+
+	zr, err := gzip.NewReader(strings.NewReader(stored))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	value, err := io.ReadAll(zr)
+	if err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// Function for testing that values above the threshold are stored compressed.
+func TestCompressedPut(t *testing.T) {
+	// Sample data
+	large := strings.Repeat("hello, yakv! ", 100)
+	const small = "hello, yakv!"
+
+	config.compressThreshold = 64
+	defer func() { config.compressThreshold = 0 }()
+
+	// Restore to original state after test.
+	defer Delete("large")
+	defer Delete("small")
+
+	if err := Put("large", large); err != nil {
+		t.Fatal(err)
+	}
+	if err := Put("small", small); err != nil {
+		t.Fatal(err)
+	}
+
+	store.RLock()
+	stored, compressed := store.m["large"], store.compressed["large"]
+	smallCompressed := store.compressed["small"]
+	store.RUnlock()
+
+	if !compressed || len(stored) >= len(large) {
+		t.Error("Expected the large value to be stored compressed.")
+	}
+	if smallCompressed {
+		t.Error("Expected the small value to be stored uncompressed.")
+	}
+
+	// Get returns the decompressed value.
+	if val, err := Get("large"); err != nil || val != large {
+		t.Error("Decompressed value doesn't match the original value.", err)
+	}
+	if val, err := Get("small"); err != nil || val != small {
+		t.Error("Small value doesn't match the original value.", err)
+	}
+}
+
+// Function for testing that compressed values survive a replay.
+func TestCompressedReplay(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-compress.log"
+
+	// Sample data
+	large := strings.Repeat("hello, yakv!\t", 100)
+
+	config.compressThreshold = 64
+	defer func() { config.compressThreshold = 0 }()
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer Delete("large")
+
+	stored, compressed, err := compressValue(large)
+	if err != nil || !compressed {
+		t.Fatal("Failed to compress the value.", err)
+	}
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactionLogger.Log()
+	transactionLogger.WriteEvent(Event{EventType: EventPut, Key: "large", Value: stored, Compressed: true})
+	transactionLogger.Close()
+
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	store.RLock()
+	replayed, replayedCompressed := store.m["large"], store.compressed["large"]
+	store.RUnlock()
+
+	// The compressed bytes must pass through the transaction log unchanged.
+	if replayed != stored || !replayedCompressed {
+		t.Error("Compressed value was altered by the transaction log.")
+	}
+
+	if val, err := Get("large"); err != nil || val != large {
+		t.Error("Replayed value doesn't match the original value.", err)
+	}
+}
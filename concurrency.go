@@ -0,0 +1,71 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Paths which are never rejected for exceeding the maximum concurrency.
+var concurrencyExempt = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// Number of requests currently being served, reported by the stats endpoint.
+var inFlight int64
+
+// inFlightRequests returns the number of requests currently being served.
+func inFlightRequests() int64 {
+	return atomic.LoadInt64(&inFlight)
+}
+
+// ConcurrencyMiddleware counts the requests being served, and rejects requests with 503 Service Unavailable
+// once max requests are already being served. Requests are rejected rather than queued, since they would only
+// pile up on the store's lock. A max of 0 disables the limit.
+func ConcurrencyMiddleware(max int) gin.HandlerFunc {
+	var slots chan struct{}
+	if max > 0 {
+		slots = make(chan struct{}, max)
+	}
+
+	return func(c *gin.Context) {
+		if concurrencyExempt[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			default:
+				c.Header("Retry-After", "1")
+				writeError(c.Writer, "too many concurrent requests", http.StatusServiceUnavailable)
+				c.Abort()
+				return
+			}
+		}
+
+		atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that requests past the maximum concurrency are rejected with 503.
+func TestConcurrencyMiddleware(t *testing.T) {
+	const max = 3
+
+	gin.SetMode(gin.TestMode)
+
+	// Requests to /block are held until release is closed.
+	release := make(chan struct{})
+	r := gin.New()
+	r.Use(ConcurrencyMiddleware(max))
+	r.GET("/block", func(c *gin.Context) {
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Saturate the limit.
+	var wg sync.WaitGroup
+	codes := make(chan int, max)
+	for i := 0; i < max; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/block", nil))
+			codes <- rec.Code
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for inFlightRequests() < max {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d requests in flight, got %d", max, inFlightRequests())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Excess requests are rejected right away.
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/block", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 past the limit, got %d", rec.Code)
+		}
+	}
+
+	// Health checks are exempt.
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected exempt path to pass, got %d", rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("Expected the requests within the limit to succeed, got %d", code)
+		}
+	}
+
+	if n := inFlightRequests(); n != 0 {
+		t.Errorf("Expected no requests in flight, got %d", n)
+	}
+
+	// The freed slots can be used again.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/block", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected a request to succeed once the slots are freed, got %d", rec.Code)
+	}
+}
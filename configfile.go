@@ -0,0 +1,173 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileConfig is the structure of the configuration file.
+type fileConfig struct {
+	Flags       map[string]interface{} `json:"flags"`              // Values of command-line flags, keyed by the flag name.
+	TTLDefaults map[string]string      `json:"ttl_defaults"`       // Default TTLs of keys, keyed by key prefix.
+	Webhooks    []webhookTarget        `json:"webhooks"`           // URLs notified of the changes to keys.
+	Tenants     map[string]string      `json:"tenants"`            // Key prefixes of tenants, keyed by the API key of the tenant.
+	Protected   []string               `json:"protected_prefixes"` // Prefixes of keys which never expire.
+}
+
+// ttlRule is the default TTL of the keys starting with prefix, zero meaning the keys never expire.
+type ttlRule struct {
+	prefix string
+	ttl    time.Duration
+}
+
+// Default TTL rules, sorted by descending prefix length so that the longest matching prefix wins.
+var ttlDefaults = struct {
+	sync.RWMutex
+	rules []ttlRule
+}{}
+
+// setTTLDefaults replaces the default TTL rules.
+func setTTLDefaults(defaults map[string]time.Duration) {
+	rules := make([]ttlRule, 0, len(defaults))
+	for prefix, ttl := range defaults {
+		rules = append(rules, ttlRule{prefix: prefix, ttl: ttl})
+	}
+	sort.Slice(rules, func(i, j int) bool { return len(rules[i].prefix) > len(rules[j].prefix) })
+
+	ttlDefaults.Lock()
+	ttlDefaults.rules = rules
+	ttlDefaults.Unlock()
+}
+
+// Prefixes of keys which never expire, even when they have an expiry.
+var protectedPrefixes = struct {
+	sync.RWMutex
+	prefixes []string
+}{}
+
+// setProtectedPrefixes replaces the protected prefixes.
+func setProtectedPrefixes(prefixes []string) {
+	protectedPrefixes.Lock()
+	protectedPrefixes.prefixes = prefixes
+	protectedPrefixes.Unlock()
+}
+
+// isProtected returns whether key starts with a protected prefix.
+func isProtected(key string) bool {
+	protectedPrefixes.RLock()
+	defer protectedPrefixes.RUnlock()
+
+	for _, prefix := range protectedPrefixes.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// defaultExpiry returns when a key written at now without an explicit TTL expires, according to the
+// rule with the longest matching prefix. A zero time means the key never expires.
+func defaultExpiry(key string, now time.Time) time.Time {
+	ttlDefaults.RLock()
+	defer ttlDefaults.RUnlock()
+
+	for _, rule := range ttlDefaults.rules {
+		if strings.HasPrefix(key, rule.prefix) {
+			if rule.ttl == 0 {
+				return time.Time{}
+			}
+			return expiryAfter(now, rule.ttl)
+		}
+	}
+
+	return time.Time{}
+}
+
+// loadConfigFile applies a JSON configuration file. Flags which were set on the command line take
+// precedence over the flags of the file.
+func loadConfigFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var file fileConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid config file %q. %w", filename, err)
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for name, value := range file.Flags {
+		if set[name] {
+			continue
+		}
+
+		if err := flag.Set(name, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("invalid flag %q in config file %q. %w", name, filename, err)
+		}
+	}
+
+	defaults := make(map[string]time.Duration, len(file.TTLDefaults))
+	for prefix, value := range file.TTLDefaults {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return fmt.Errorf("invalid default TTL %q for prefix %q in config file %q", value, prefix, filename)
+		}
+		defaults[prefix] = ttl
+	}
+	setTTLDefaults(defaults)
+
+	for _, prefix := range file.Protected {
+		if prefix == "" {
+			return fmt.Errorf("protected prefixes must not be empty in config file %q", filename)
+		}
+
+		ttlDefaults.RLock()
+		for _, rule := range ttlDefaults.rules {
+			if rule.ttl > 0 && (strings.HasPrefix(rule.prefix, prefix) || strings.HasPrefix(prefix, rule.prefix)) {
+				log.Printf("keys starting with %q get a default TTL of %v, but keys starting with %q are protected and never expire", rule.prefix, rule.ttl, prefix)
+			}
+		}
+		ttlDefaults.RUnlock()
+	}
+	setProtectedPrefixes(file.Protected)
+
+	for i, target := range file.Webhooks {
+		if u, err := url.Parse(target.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid URL %q for webhook %d in config file %q", target.URL, i, filename)
+		}
+	}
+	setWebhooks(file.Webhooks)
+
+	if err := checkTenants(file.Tenants); err != nil {
+		return fmt.Errorf("invalid tenants in config file %q. %w", filename, err)
+	}
+	setTenants(file.Tenants)
+
+	return nil
+}
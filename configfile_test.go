@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function for testing that the default TTL of the longest matching prefix applies.
+func TestDefaultExpiry(t *testing.T) {
+	// Restore to original state after test.
+	defer setTTLDefaults(nil)
+
+	setTTLDefaults(map[string]time.Duration{
+		"session:":       30 * time.Minute,
+		"session:admin:": 0,
+	})
+
+	now := time.Now()
+	expected := map[string]time.Time{
+		"session:1":       now.Add(30 * time.Minute),
+		"session:admin:1": {},
+		"config:1":        {},
+	}
+
+	for key, expiresAt := range expected {
+		if got := defaultExpiry(key, now); !got.Equal(expiresAt) {
+			t.Errorf("Expected %q to expire at %v, got %v", key, expiresAt, got)
+		}
+	}
+}
+
+// Function for testing that the config file sets the protected prefixes, which must not be empty.
+func TestConfigFileProtectedPrefixes(t *testing.T) {
+	// Temporary config filename.
+	const filename = "temp-config-protected.json"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer setProtectedPrefixes(nil)
+
+	if err := os.WriteFile(filename, []byte(`{"protected_prefixes": ["config:", "billing:"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfigFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	if !isProtected("billing:invoice:1") || isProtected("session:1") {
+		t.Error("Expected only the keys under the prefixes of the config file to be protected.")
+	}
+
+	if err := os.WriteFile(filename, []byte(`{"protected_prefixes": [""]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfigFile(filename); err == nil {
+		t.Error("Expected an empty protected prefix to be rejected.")
+	}
+}
+
+// Function for testing that Put applies the default TTLs of the config file.
+func TestConfigFileTTLDefaults(t *testing.T) {
+	// Temporary config filename.
+	const filename = "temp-config.json"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer setTTLDefaults(nil)
+	defer resetStores()
+
+	if err := os.WriteFile(filename, []byte(`{"ttl_defaults": {"session:": "30m", "config:": "0s"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfigFile(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	Put("session:1", "value")
+	Put("config:1", "value")
+
+	store.RLock()
+	_, sessionExpires := store.expiry["session:1"]
+	_, configExpires := store.expiry["config:1"]
+	store.RUnlock()
+
+	if !sessionExpires || configExpires {
+		t.Errorf("Expected only the session to expire, got session %t and config %t", sessionExpires, configExpires)
+	}
+
+	// An explicit TTL overrides the default, even when it is zero.
+	defer useTempLogger(t, "temp-config.log")()
+	rec := httptest.NewRecorder()
+	PutHandler(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(`{"key": "session:2", "value": "value", "ttl_seconds": 0}`)))
+	store.RLock()
+	_, explicitExpires := store.expiry["session:2"]
+	store.RUnlock()
+	if rec.Code != http.StatusCreated || explicitExpires {
+		t.Errorf("Expected the explicit TTL to apply, got %d with expiry %t", rec.Code, explicitExpires)
+	}
+
+	// Invalid TTLs and unknown flags are rejected.
+	for _, content := range []string{`{"ttl_defaults": {"session:": "-1m"}}`, `{"flags": {"no-such-flag": 1}}`} {
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadConfigFile(filename); err == nil {
+			t.Errorf("Expected an error for %s", content)
+		}
+	}
+}
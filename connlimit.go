@@ -0,0 +1,109 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Number of connections rejected for exceeding -max-conns-per-ip.
+var rejectedConns uint64
+
+// rejectedConnections returns the number of connections rejected for exceeding -max-conns-per-ip.
+func rejectedConnections() uint64 {
+	return atomic.LoadUint64(&rejectedConns)
+}
+
+// connLimitListener is a listener which accepts at most max simultaneous connections from each IP address.
+// Connections past the limit are closed right after accepting them, before reading a request.
+type connLimitListener struct {
+	net.Listener
+	max int
+
+	sync.Mutex
+	conns map[string]int // Number of open connections by IP address, without addresses which have none.
+}
+
+// limitConnsPerIP wraps a listener to accept at most max simultaneous connections from each IP address.
+func limitConnsPerIP(l net.Listener, max int) net.Listener {
+	return &connLimitListener{Listener: l, max: max, conns: make(map[string]int)}
+}
+
+// Accept waits for the next connection from an IP address which is below the limit.
+func (l *connLimitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip := remoteIP(conn.RemoteAddr())
+		if !l.acquire(ip) {
+			atomic.AddUint64(&rejectedConns, 1)
+			conn.Close()
+			continue
+		}
+
+		return &limitedConn{Conn: conn, release: func() { l.release(ip) }}, nil
+	}
+}
+
+// acquire counts a new connection from ip, unless ip already has the maximum number of connections.
+func (l *connLimitListener) acquire(ip string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.conns[ip] >= l.max {
+		return false
+	}
+
+	l.conns[ip]++
+	return true
+}
+
+// release counts a closed connection from ip, forgetting ip once it has no connections left.
+func (l *connLimitListener) release(ip string) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.conns[ip]--; l.conns[ip] <= 0 {
+		delete(l.conns, ip)
+	}
+}
+
+// limitedConn is a connection counted by a connLimitListener until it's closed.
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+// Close closes the connection, releasing it from the limit of its IP address only once.
+func (c *limitedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+// remoteIP returns the IP address of a remote address, without the port.
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+
+	return host
+}
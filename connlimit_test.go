@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// Function for testing that connections from one address past the limit are closed, and that closed
+// connections free their slot and their counter.
+func TestConnLimit(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limited := limitConnsPerIP(ln, 2).(*connLimitListener)
+	defer limited.Close()
+
+	// Accepted connections are handed over as they come.
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+
+	// Helper for checking whether the server closed a connection, which reads EOF instead of timing out.
+	closedByServer := func(conn net.Conn) bool {
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		_, err := conn.Read(make([]byte, 1))
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return false
+		}
+		return true
+	}
+
+	rejectedBefore := rejectedConnections()
+
+	// Many connections from the same address, only the first two of which are kept.
+	var clients []net.Conn
+	for i := 0; i < 5; i++ {
+		clients = append(clients, dial())
+	}
+	defer func() {
+		for _, conn := range clients {
+			conn.Close()
+		}
+	}()
+
+	first, second := <-accepted, <-accepted
+	for i, conn := range clients {
+		if closed := closedByServer(conn); closed != (i >= 2) {
+			t.Errorf("Expected connection %d to be closed: %t, got %t", i, i >= 2, closed)
+		}
+	}
+	if rejected := rejectedConnections() - rejectedBefore; rejected != 3 {
+		t.Errorf("Expected 3 rejected connections, got %d", rejected)
+	}
+
+	// Closing a connection makes room for another, closing it twice doesn't make room twice.
+	first.Close()
+	first.Close()
+
+	third := dial()
+	defer third.Close()
+	thirdAccepted := <-accepted
+	if closedByServer(third) {
+		t.Error("Expected a new connection to be accepted after closing one")
+	}
+
+	fourth := dial()
+	defer fourth.Close()
+	if !closedByServer(fourth) {
+		t.Error("Expected the limit to still apply after closing a connection twice")
+	}
+
+	// Addresses without connections are forgotten.
+	second.Close()
+	thirdAccepted.Close()
+	limited.Lock()
+	n := len(limited.conns)
+	limited.Unlock()
+	if n != 0 {
+		t.Errorf("Expected no counters once every connection is closed, got %d", n)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// LogConsistency compares the number of keys of the default store with the number of keys the transaction log
+// leaves behind, i.e. the keys whose last event in the log didn't delete them. Writes are applied to the store
+// before their events are written, so both differ by the writes in flight, and a divergence which doesn't go
+// away points at a write which never made it to the log, or a delete which never made it to the store.
+type LogConsistency struct {
+	StoreKeys  int `json:"store_keys"`
+	LogKeys    int `json:"log_keys"`
+	Divergence int `json:"divergence"` // StoreKeys minus LogKeys.
+}
+
+// logConsistency returns the number of keys of the default store and of the transaction log. The keys of the
+// log are counted as their events are written, so this is cheap enough to run with every request of the stats,
+// unlike verifying the log.
+func logConsistency() LogConsistency {
+	store.RLock()
+	storeKeys := len(store.m)
+	store.RUnlock()
+
+	modifications.RLock()
+	logKeys := modifications.live
+	modifications.RUnlock()
+
+	return LogConsistency{StoreKeys: storeKeys, LogKeys: logKeys, Divergence: storeKeys - logKeys}
+}
+
+// MetricsHandler is a handler function for the metrics endpoint, which serves gauges in the Prometheus
+// text format.
+func MetricsHandler(rw http.ResponseWriter, r *http.Request) {
+	c := logConsistency()
+
+	gauges := []struct {
+		name, help string
+		value      int
+	}{
+		{"yakv_store_keys", "Number of keys of the default store.", c.StoreKeys},
+		{"yakv_log_keys", "Number of keys left behind by the events of the transaction log.", c.LogKeys},
+		{"yakv_log_divergence", "Number of keys of the default store minus the number of keys of the transaction log, 0 when both agree.", c.Divergence},
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, g := range gauges {
+		if _, err := fmt.Fprintf(rw, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", g.name, g.help, g.name, g.name, g.value); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+}
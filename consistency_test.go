@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function for testing that the keys of the store and of the transaction log agree, unless a write skips the log.
+func TestLogConsistency(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-consistency.log")()
+	defer resetStores()
+	resetStores()
+
+	serve := func(handler http.HandlerFunc, method, body string) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, "/yakv/v0/", strings.NewReader(body)))
+		if rec.Code >= http.StatusBadRequest {
+			t.Fatalf("Unexpected status %d for %s", rec.Code, body)
+		}
+	}
+
+	// Overwrites, deletes and touches of missing keys don't throw off the count of the log.
+	serve(PutHandler, http.MethodPut, `{"key": "yakv1", "value": "hello"}`)
+	serve(PutHandler, http.MethodPut, `{"key": "yakv1", "value": "hello, yakv!"}`)
+	serve(PutHandler, http.MethodPut, `{"key": "yakv2", "value": "hello"}`)
+	serve(DeleteHandler, http.MethodDelete, `{"key": "yakv2"}`)
+	logger.WriteEvent(Event{EventType: EventTouch, Key: "missing"})
+	logger.Wait()
+
+	if c := logConsistency(); c != (LogConsistency{StoreKeys: 1, LogKeys: 1}) {
+		t.Errorf("Expected a consistent store, got %+v", c)
+	}
+
+	// A write which never makes it to the log shows up as a divergence, in the stats and the metrics.
+	if err := putStored("yakv3", "hello", false, time.Time{}, "", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := logConsistency(); c.Divergence != 1 {
+		t.Errorf("Expected a divergence of 1, got %+v", c)
+	}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "# TYPE yakv_log_divergence gauge\nyakv_log_divergence 1\n") {
+		t.Errorf("Expected the divergence in the metrics, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+)
+
+// writePIDFile writes the ID of the process to filename.
+func writePIDFile(filename string) error {
+	return os.WriteFile(filename, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644)
+}
+
+// reopenOnSignal reopens the transaction log for every signal received until the context is done,
+// so that external tools like logrotate can move the log away and signal yakv to continue in a new file.
+func reopenOnSignal(ctx context.Context, signals <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if err := logger.Reopen(); err != nil {
+				log.Printf("Error occurred while reopening the transaction log: %v", err)
+				continue
+			}
+			fmt.Println("yakv reopened the transaction log 🔄")
+		}
+	}
+}
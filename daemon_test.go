@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Function for testing that the PID file holds the process ID.
+func TestWritePIDFile(t *testing.T) {
+	// Temporary PID filename.
+	const filename = "temp-yakv.pid"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+
+	if err := writePIDFile(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err != nil || pid != os.Getpid() {
+		t.Errorf("Expected PID %d, got %q", os.Getpid(), data)
+	}
+}
+
+// Function for testing that a rotated transaction log is continued in a new file.
+func TestReopen(t *testing.T) {
+	// Temporary log filenames.
+	const filename = "temp-reopen.log"
+	const rotated = "temp-reopen.log.1"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer os.Remove(rotated)
+
+	ftl, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ftl.Log()
+
+	// The buffered event must end up in the rotated file.
+	ftl.WritePut("yakv1", "yak1")
+	if err := os.Rename(filename, rotated); err != nil {
+		t.Fatal(err)
+	}
+	if err := ftl.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	ftl.WritePut("yakv2", "yak2")
+	ftl.Close()
+
+	for name, key := range map[string]string{rotated: "yakv1", filename: "yakv2"} {
+		reader, err := NewFileTransactionLogger(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var keys []string
+		events, errors := reader.ReadEvents()
+		for e := range events {
+			keys = append(keys, e.Key)
+		}
+		if err := <-errors; err != nil {
+			t.Error(err)
+		}
+		reader.Close()
+
+		if len(keys) != 1 || keys[0] != key {
+			t.Errorf("Expected only %s in %s, got %v", key, name, keys)
+		}
+	}
+
+	// A closed logger can't be reopened.
+	if err := ftl.Reopen(); err == nil {
+		t.Error("Expected an error reopening a closed logger.")
+	}
+}
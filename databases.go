@@ -0,0 +1,321 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header selecting the database a request is served from, see -num-databases.
+const databaseHeader = "X-YAKV-DB"
+
+// errBoltDatabases is raised when numbered databases are combined with the bolt backend.
+var errBoltDatabases = errors.New("the bolt backend only holds the default database, so it can't serve -num-databases")
+
+// errMaxKeysDatabases is raised when numbered databases are combined with -max-keys, which only bounds database 0.
+var errMaxKeysDatabases = errors.New("-max-keys only bounds database 0, so it can't be combined with -num-databases")
+
+// errDatabaseOptions is raised when a put to a numbered database asks for what only the default database supports.
+var errDatabaseOptions = errors.New("ttl_seconds, content_type, pinned and version are only supported by database 0")
+
+// Stores of the numbered databases, indexed by their number. Database 0 is the default store, with everything
+// it supports, so databases[0] is nil; the other databases only hold plain values. All databases share the
+// transaction log, their events are told apart by the event's database field.
+var databases = []*keyValueStore{nil}
+
+// Routes, relative to their group, which serve databases other than 0.
+var databaseRoutes = map[string]bool{
+	"/get":    true,
+	"/put":    true,
+	"/delete": true,
+}
+
+// databaseContextKey is the key of the selected database in the context of a request.
+type databaseContextKey struct{}
+
+// initDatabases creates the stores of n databases, database 0 included.
+func initDatabases(n int) {
+	databases = make([]*keyValueStore, n)
+	for i := 1; i < n; i++ {
+		databases[i] = newKeyValueStore()
+	}
+}
+
+// parseDatabase returns the database selected by the header of a request, 0 when it has none.
+func parseDatabase(r *http.Request) (int, error) {
+	value := r.Header.Get(databaseHeader)
+	if value == "" {
+		return 0, nil
+	}
+
+	db, err := strconv.Atoi(value)
+	if err != nil || db < 0 || db >= len(databases) {
+		return 0, fmt.Errorf("%s must be a database from 0 to %d", databaseHeader, len(databases)-1)
+	}
+
+	return db, nil
+}
+
+// requestDatabase returns the database selected by a request which went through DatabaseMiddleware.
+func requestDatabase(r *http.Request) int {
+	db, _ := r.Context().Value(databaseContextKey{}).(int)
+	return db
+}
+
+// DatabaseMiddleware rejects requests selecting a database which doesn't exist, and requests selecting a database
+// other than 0 for routes which aren't in allowed, relative to prefix, instead of serving them from database 0.
+func DatabaseMiddleware(prefix string, allowed map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db, err := parseDatabase(c.Request)
+		if err != nil {
+			writeError(c.Writer, err.Error(), http.StatusBadRequest)
+			c.Abort()
+			return
+		}
+
+		if db > 0 {
+			if !allowed[strings.TrimPrefix(c.FullPath(), prefix)] {
+				writeError(c.Writer, "route only serves database 0", http.StatusBadRequest)
+				c.Abort()
+				return
+			}
+
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), databaseContextKey{}, db))
+		}
+
+		c.Next()
+	}
+}
+
+// DatabasePut sets the value of a key in a database other than 0, calling logged, unless it's nil, to write
+// the put to the transaction log while the database's lock is still held.
+func DatabasePut(db int, key, value string, logged func()) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := checkValueSize(value); err != nil {
+		return err
+	}
+
+	s := databases[db]
+	s.Lock()
+	defer s.Unlock()
+
+	_, exists := s.m[key]
+	if err := checkPut(exists); err != nil {
+		return err
+	}
+	s.m[key] = value
+	if logged != nil {
+		logged()
+	}
+
+	return nil
+}
+
+// DatabaseGet gets the value assigned to a key in a database other than 0.
+func DatabaseGet(db int, key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	s := databases[db]
+	s.RLock()
+	value, ok := s.m[key]
+	s.RUnlock()
+
+	if !ok {
+		return "", ErrorNoSuchKey
+	}
+
+	return value, nil
+}
+
+// DatabaseDelete deletes a key from a database other than 0, calling logged like DatabasePut.
+func DatabaseDelete(db int, key string, logged func()) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	s := databases[db]
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.m[key]; !ok {
+		return ErrorNoSuchKey
+	}
+
+	delete(s.m, key)
+	if logged != nil {
+		logged()
+	}
+
+	return nil
+}
+
+// replayDatabaseEvent applies an event of a database other than 0 read from the transaction log. Events of
+// databases beyond -num-databases fail the replay, rather than losing their keys.
+func replayDatabaseEvent(e Event) error {
+	if e.Database >= len(databases) {
+		return fmt.Errorf("transaction %d belongs to database %d, but there are only %d databases, see -num-databases", e.ID, e.Database, len(databases))
+	}
+
+	var err error
+
+	switch e.EventType {
+	case EventPut:
+		err = DatabasePut(e.Database, e.Key, e.Value, nil)
+	case EventDelete:
+		err = DatabaseDelete(e.Database, e.Key, nil)
+	}
+
+	// Replaying a delete for something which is already gone leaves the database in the same state.
+	if errors.Is(err, ErrorNoSuchKey) {
+		return nil
+	}
+
+	return err
+}
+
+// databaseEvents returns the events recreating the databases other than 0.
+func databaseEvents() []Event {
+	var events []Event
+	for db := 1; db < len(databases); db++ {
+		s := databases[db]
+		s.RLock()
+		keys := make([]string, 0, len(s.m))
+		for key := range s.m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			events = append(events, Event{EventType: EventPut, Database: db, Key: key, Value: s.m[key], verbatim: true})
+		}
+		s.RUnlock()
+	}
+
+	return events
+}
+
+// serveDatabaseGet answers a GET of a key in a database other than 0.
+func serveDatabaseGet(rw http.ResponseWriter, r *http.Request, db int, key string, binary bool) {
+	value, err := DatabaseGet(db, key)
+	if errors.Is(err, ErrorEmptyKey) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Clients can request a single field of a JSON value instead of the whole value.
+	var ok bool
+	if value, ok = projectRequest(rw, r, value, binary); !ok {
+		return
+	}
+
+	// Clients which already have the value are answered with 304 Not Modified.
+	encoded := encodeWire(binary, value)
+	if writeCacheHeaders(rw, r, encoded) {
+		return
+	}
+
+	if err := writeValue(rw, r, encoded); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// serveDatabasePut answers a PUT of a key in a database other than 0, logging it along with its database.
+func serveDatabasePut(rw http.ResponseWriter, db int, key, value string, body PutBody, binary bool) {
+	if body.TTLSeconds != nil || body.ContentType != "" || body.Pinned || body.Version != nil {
+		writeError(rw, errDatabaseOptions.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changes.RLock()
+	defer changes.RUnlock()
+
+	var logErr error
+	err := DatabasePut(db, key, value, func() {
+		logErr = logger.WriteEvent(Event{EventType: EventPut, Database: db, Key: key, Value: value, verbatim: binary})
+	})
+	if err == nil {
+		err = logErr
+	}
+	if errors.Is(err, ErrorEmptyKey) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrorValueTooLarge) {
+		writeError(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, errKeyExists) {
+		writeError(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("added value: \"%s\" to key \"%s\" in database %d\n", value, key, db)
+	rw.WriteHeader(http.StatusCreated)
+}
+
+// serveDatabaseDelete answers a DELETE of a key in a database other than 0, logging it along with its database.
+func serveDatabaseDelete(rw http.ResponseWriter, db int, key string) {
+	changes.RLock()
+	defer changes.RUnlock()
+
+	var logErr error
+	err := DatabaseDelete(db, key, func() { logErr = logger.WriteEvent(Event{EventType: EventDelete, Database: db, Key: key}) })
+	if err == nil {
+		err = logErr
+	}
+	if errors.Is(err, ErrorEmptyKey) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("deleting key: %s in database: %d\n", key, db)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that the X-YAKV-DB header selects an isolated database, and that requests which can't
+// be served from the selected database are rejected instead of being served from database 0.
+func TestDatabases(t *testing.T) {
+	// Restore to original state after test.
+	defer initDatabases(len(databases))
+	initDatabases(4)
+	defer useTempLogger(t, "temp-databases.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(db, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if db != "" {
+			req.Header.Set(databaseHeader, db)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The same key holds a value of its own in every database.
+	for _, db := range []string{"", "3"} {
+		if rec := serve(db, http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "db`+db+`"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 for database %q, got %d %s", db, rec.Code, rec.Body.String())
+		}
+	}
+	for db, expected := range map[string]string{"": "db", "0": "db", "3": "db3"} {
+		if rec := serve(db, http.MethodGet, "/yakv/v0/get?key=yakv", ""); rec.Code != http.StatusOK || rec.Body.String() != expected {
+			t.Errorf("Expected %q from database %q, got %d %q", expected, db, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := serve("1", http.MethodGet, "/yakv/v0/get?key=yakv", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 from an empty database, got %d", rec.Code)
+	}
+
+	// Deleting the key from one database leaves the others alone.
+	if rec := serve("3", http.MethodDelete, "/yakv/v0/delete", `{"key": "yakv"}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for the delete, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve("3", http.MethodDelete, "/yakv/v0/delete", `{"key": "yakv"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for deleting the key again, got %d", rec.Code)
+	}
+	if value, err := Get("yakv"); err != nil || value != "db" {
+		t.Errorf("Expected database 0 to keep the key, got %q %v", value, err)
+	}
+
+	tests := []struct {
+		name, db, method, path, body string
+	}{
+		{"a database which doesn't exist", "4", http.MethodGet, "/yakv/v0/get?key=yakv", ""},
+		{"a database which isn't a number", "three", http.MethodGet, "/yakv/v0/get?key=yakv", ""},
+		{"a negative database", "-1", http.MethodGet, "/yakv/v0/get?key=yakv", ""},
+		{"a route which only serves database 0", "3", http.MethodGet, "/yakv/v0/keys", ""},
+		{"a put with a TTL", "3", http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "v", "ttl_seconds": 60}`},
+		{"a put with a version", "3", http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "v", "version": 0}`},
+	}
+	for _, test := range tests {
+		if rec := serve(test.db, test.method, test.path, test.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d %s", test.name, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Database 0 can still be selected explicitly on every route.
+	if rec := serve("0", http.MethodGet, "/yakv/v0/keys", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for database 0, got %d", rec.Code)
+	}
+}
+
+// Function for testing that the keys of every database survive replaying the log, whether it was replayed
+// as it is, collapsed, snapshotted or compacted, and that a log with more databases than configured isn't replayed.
+func TestDatabaseReplay(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-database-replay.log"
+
+	// Restore to original state after test.
+	defer initDatabases(len(databases))
+	initDatabases(3)
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(filename string) { transactionLogFilename = filename }(transactionLogFilename)
+	transactionLogFilename = filename
+	defer func(collapse bool, n int) { config.collapseReplay, config.keepVersions = collapse, n }(config.collapseReplay, config.keepVersions)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(db, method, path, body string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(databaseHeader, db)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code >= http.StatusBadRequest {
+			t.Fatalf("Unexpected %d for %s %s in database %s", rec.Code, method, path, db)
+		}
+	}
+
+	serve("0", http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "db0"}`)
+	serve("1", http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "db1"}`)
+	serve("2", http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "db2"}`)
+	serve("2", http.MethodPut, "/yakv/v0/put", `{"key": "gone", "value": "db2"}`)
+	serve("2", http.MethodDelete, "/yakv/v0/delete", `{"key": "gone"}`)
+
+	replay := func(name string) {
+		logger.Close()
+		resetStores()
+		if err := InitLog(filename); err != nil {
+			t.Fatalf("Unexpected error replaying %s: %v", name, err)
+		}
+
+		if value, err := Get("yakv"); err != nil || value != "db0" {
+			t.Errorf("Expected database 0 to be restored by %s, got %q %v", name, value, err)
+		}
+		for db, expected := range []string{"", "db1", "db2"} {
+			if db == 0 {
+				continue
+			}
+			if value, err := DatabaseGet(db, "yakv"); err != nil || value != expected {
+				t.Errorf("Expected database %d to be restored by %s, got %q %v", db, name, value, err)
+			}
+		}
+		if _, err := DatabaseGet(2, "gone"); err != ErrorNoSuchKey {
+			t.Errorf("Expected the deleted key to stay deleted after %s, got %v", name, err)
+		}
+		if _, err := Get("gone"); err != ErrorNoSuchKey {
+			t.Errorf("Expected database 0 not to get the key of database 2 after %s, got %v", name, err)
+		}
+	}
+
+	replay("replaying the log")
+
+	config.collapseReplay = true
+	replay("a collapsed replay")
+	config.collapseReplay = false
+
+	config.keepVersions = 2
+	if _, err := Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	replay("a compaction")
+	config.keepVersions = 0
+
+	if _, err := Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	replay("a snapshot")
+
+	// Transactions of database 2 can't be applied with only 2 databases.
+	logger.Close()
+	initDatabases(2)
+	resetStores()
+	if err := InitLog(filename); err == nil || !strings.Contains(err.Error(), "database 2") {
+		t.Errorf("Expected replaying database 2 into 2 databases to fail, got %v", err)
+	}
+}
+
+// Function for testing that the database of an event is logged after the other optional fields, and left
+// out of events of database 0.
+func TestDatabaseLogField(t *testing.T) {
+	line := formatEvent(ftlVersion, Event{ID: 1, EventType: EventPut, Key: "yakv", Value: "hello, yakv!", Database: 3})
+	e, err := parseEvent(ftlVersion, line)
+	if err != nil || e.Database != 3 || e.Version != 0 || e.Pinned || e.ContentType != "" {
+		t.Errorf("Expected database 3 to survive %q, got %+v %v", line, e, err)
+	}
+
+	if line := formatEvent(ftlVersion, Event{ID: 1, EventType: EventPut, Key: "yakv", Value: "hello, yakv!", Version: 2}); strings.Count(line, "\t") != 10 {
+		t.Errorf("Expected no database after the version, got %q", line)
+	}
+}
+
+// Function for testing that streamed events tell their database apart, leaving it out for database 0.
+func TestDatabaseStreamEvent(t *testing.T) {
+	for db, expected := range map[int]string{0: `{"id":1,"type":"put","key":"yakv","value":"v"}`, 3: `{"id":1,"type":"put","key":"yakv","value":"v","database":3}`} {
+		se, err := streamEvent(Event{ID: 1, EventType: EventPut, Key: "yakv", Value: "v", Database: db}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := json.Marshal(se)
+		if err != nil || string(data) != expected {
+			t.Errorf("Expected %s for database %d, got %s %v", expected, db, data, err)
+		}
+	}
+}
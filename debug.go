@@ -0,0 +1,114 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default number of bytes of request and response bodies logged with -debug-bodies.
+const defaultDebugBodyLimit = 4096
+
+// bodyCapture keeps the first limit bytes written to it, and counts all of them.
+type bodyCapture struct {
+	limit int
+	buf   []byte
+	n     int
+}
+
+// capture records p.
+func (b *bodyCapture) capture(p []byte) {
+	b.n += len(p)
+	if room := b.limit - len(b.buf); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		b.buf = append(b.buf, p...)
+	}
+}
+
+// String returns the captured bytes quoted, so that binary bodies can't break up the log, along with the
+// total size when the body was truncated.
+func (b *bodyCapture) String() string {
+	if b.n > len(b.buf) {
+		return fmt.Sprintf("%q... (truncated, %d bytes)", b.buf, b.n)
+	}
+
+	return fmt.Sprintf("%q", b.buf)
+}
+
+// teeBody captures a request body as the handler reads it. Nothing is read ahead of the handler, so that
+// the MaxBytesReader of the JSON decoder still enforces the size limit on what the client sends.
+type teeBody struct {
+	io.ReadCloser
+	capture *bodyCapture
+}
+
+// Read reads from the request body, capturing what is read.
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	t.capture.capture(p[:n])
+	return n, err
+}
+
+// debugResponseWriter captures a response body as it is written.
+type debugResponseWriter struct {
+	gin.ResponseWriter
+	capture *bodyCapture
+}
+
+// Write writes to the response, capturing what is written.
+func (w *debugResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.capture.capture(p[:n])
+	return n, err
+}
+
+// WriteString writes a string to the response.
+func (w *debugResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// DebugBodiesMiddleware logs the request and response bodies of every request, truncated to limit bytes each.
+// Bodies are logged as far as they were read and written, so a request body the handler didn't read isn't logged.
+// Responses compressed by GzipMiddleware are captured compressed, and only logged by size.
+func DebugBodiesMiddleware(limit int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := &bodyCapture{limit: limit}
+		if c.Request.Body != nil {
+			c.Request.Body = &teeBody{ReadCloser: c.Request.Body, capture: request}
+		}
+
+		w := &debugResponseWriter{ResponseWriter: c.Writer, capture: &bodyCapture{limit: limit}}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+		}()
+
+		c.Next()
+
+		response := w.capture.String()
+		if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+			response = fmt.Sprintf("(%s-encoded, %d bytes)", encoding, w.capture.n)
+		}
+
+		log.Printf("%s %s request body: %s", c.Request.Method, c.Request.URL.RequestURI(), request)
+		log.Printf("%s %s response %d body: %s", c.Request.Method, c.Request.URL.RequestURI(), w.Status(), response)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that request and response bodies are logged, truncated to the limit.
+func TestDebugBodiesMiddleware(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-debug-bodies.log")()
+	defer resetStores()
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(DebugBodiesMiddleware(32))
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	// The handler still reads the whole body, even though only the start of it is logged.
+	value := strings.Repeat("x", 100)
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "`+value+`"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rec.Code)
+	}
+	if got, _ := Get("yakv"); got != value {
+		t.Fatalf("Expected the whole value to be stored, got %q", got)
+	}
+	if !strings.Contains(out.String(), `PUT /yakv/v0/put request body: "{\"key\": \"yakv\", \"value\": \"xxxxxx"... (truncated, 128 bytes)`) {
+		t.Errorf("Expected the truncated request body to be logged, got %s", out.String())
+	}
+
+	// Responses are logged along with their status.
+	out.Reset()
+	if rec := serve(http.MethodGet, "/yakv/v0/get", `{"key": "missing"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404, got %d", rec.Code)
+	}
+	if !strings.Contains(out.String(), `GET /yakv/v0/get response 404 body: "{\"error\":`) {
+		t.Errorf("Expected the response body to be logged, got %s", out.String())
+	}
+
+	// The size limit of the JSON decoder still applies.
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "big", "value": "`+strings.Repeat("x", maxBodySize)+`"}`); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a body larger than the limit, got %d", rec.Code)
+	}
+}
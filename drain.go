@@ -0,0 +1,120 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default grace period between draining and shutting down, see -drain-grace.
+const defaultDrainGrace = 30 * time.Second
+
+// State of draining, set once the admin drain endpoint was called.
+var drain = struct {
+	sync.RWMutex
+	since      time.Time // When draining started, zero while the instance is in rotation.
+	shutdownAt time.Time // When the instance shuts itself down, zero if it waits to be stopped.
+	timer      *time.Timer
+}{}
+
+// shutdownAfterDrain shuts yakv down gracefully like SIGTERM does, once the grace period of draining is over.
+// It's set by main, and replaced in tests.
+var shutdownAfterDrain = func() {}
+
+// DrainState describes the draining of an instance, reported by /readyz and /stats.
+type DrainState struct {
+	Since      time.Time  `json:"since"`
+	ShutdownAt *time.Time `json:"shutdown_at,omitempty"` // When the instance shuts itself down, see -drain-grace.
+}
+
+// startDrain takes the instance out of rotation, shutting it down after grace unless it's 0. Draining can't be
+// undone, so draining again keeps the state of the first drain.
+func startDrain(grace time.Duration) DrainState {
+	drain.Lock()
+	defer drain.Unlock()
+
+	if drain.since.IsZero() {
+		drain.since = time.Now()
+		if grace > 0 {
+			drain.shutdownAt = drain.since.Add(grace)
+			drain.timer = time.AfterFunc(grace, func() {
+				log.Println("drain grace period is over, shutting down")
+				shutdownAfterDrain()
+			})
+		}
+	}
+
+	return drainStateLocked()
+}
+
+// drainStateLocked returns the state of draining. The caller must hold the drain lock.
+func drainStateLocked() DrainState {
+	state := DrainState{Since: drain.since}
+	if !drain.shutdownAt.IsZero() {
+		shutdownAt := drain.shutdownAt
+		state.ShutdownAt = &shutdownAt
+	}
+
+	return state
+}
+
+// drainStats returns the state of draining, nil while the instance is in rotation.
+func drainStats() *DrainState {
+	drain.RLock()
+	defer drain.RUnlock()
+
+	if drain.since.IsZero() {
+		return nil
+	}
+
+	state := drainStateLocked()
+	return &state
+}
+
+// ReadyHandler is a handler function for the readiness endpoint. It responds with 503 Service Unavailable
+// once the instance is draining, so that load balancers take it out of rotation, while requests keep being served.
+// The automatic read-only mode is reported without failing the check, since reads are still served.
+func ReadyHandler(rw http.ResponseWriter, r *http.Request) {
+	state := drainStats()
+
+	rw.Header().Set("Content-Type", "application/json")
+	if state != nil {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(rw).Encode(struct {
+		Ready        bool          `json:"ready"`
+		Draining     *DrainState   `json:"draining,omitempty"`
+		AutoReadOnly *AutoReadOnly `json:"auto_read_only,omitempty"`
+	}{state == nil, state, autoReadOnlyStats()}); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// DrainHandler is a handler function for the admin endpoint taking the instance out of rotation before a deploy.
+func DrainHandler(rw http.ResponseWriter, r *http.Request) {
+	state := startDrain(config.drainGrace)
+	log.Printf("draining since %v", state.Since)
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(rw).Encode(state); err != nil {
+		log.Println(err.Error())
+	}
+}
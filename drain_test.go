@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Helper function for taking the instance back into rotation after a test drained it.
+func resetDrain() {
+	drain.Lock()
+	defer drain.Unlock()
+
+	if drain.timer != nil {
+		drain.timer.Stop()
+	}
+	drain.since, drain.shutdownAt, drain.timer = time.Time{}, time.Time{}, nil
+}
+
+// Function for testing that draining makes /readyz fail while requests keep being served, is reported by
+// /stats, and shuts the instance down once the grace period is over.
+func TestDrain(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-drain.log")()
+	defer resetStores()
+	defer resetDrain()
+	defer func(grace time.Duration, shutdown func()) { config.drainGrace, shutdownAfterDrain = grace, shutdown }(config.drainGrace, shutdownAfterDrain)
+
+	shutdown := make(chan struct{})
+	config.drainGrace = 50 * time.Millisecond
+	shutdownAfterDrain = func() { close(shutdown) }
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+	r.GET("/readyz", gin.WrapF(ReadyHandler))
+	r.GET("/stats", gin.WrapF(StatsHandler))
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := serve(http.MethodGet, "/readyz", ""); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"ready":true}` {
+		t.Fatalf("Expected 200 before draining, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec := serve(http.MethodPost, "/yakv/v0/admin/drain", "")
+	var state DrainState
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusAccepted || state.Since.IsZero() || state.ShutdownAt == nil || state.ShutdownAt.Sub(state.Since) != config.drainGrace {
+		t.Fatalf("Expected 202 with the time of the shutdown, got %d %+v", rec.Code, state)
+	}
+
+	// Draining again doesn't postpone the shutdown.
+	var again DrainState
+	if err := json.NewDecoder(serve(http.MethodPost, "/yakv/v0/admin/drain", "").Body).Decode(&again); err != nil {
+		t.Fatal(err)
+	}
+	if !again.Since.Equal(state.Since) || !again.ShutdownAt.Equal(*state.ShutdownAt) {
+		t.Errorf("Expected the state of the first drain, got %+v", again)
+	}
+
+	// The load balancer is told to route elsewhere, while requests still reaching the instance are served.
+	if rec := serve(http.MethodGet, "/readyz", ""); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"ready":false`) {
+		t.Errorf("Expected 503 while draining, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "hello, yakv!"}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected writes to be served while draining, got %d", rec.Code)
+	}
+
+	var stats struct {
+		Draining *DrainState `json:"draining"`
+	}
+	if err := json.NewDecoder(serve(http.MethodGet, "/stats", "").Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Draining == nil || !stats.Draining.Since.Equal(state.Since) {
+		t.Errorf("Expected /stats to report the drain, got %+v", stats.Draining)
+	}
+
+	select {
+	case <-shutdown:
+	case <-time.After(time.Second):
+		t.Error("Expected the instance to shut down after the grace period")
+	}
+}
+
+// Function for testing that without a grace period, a draining instance waits to be stopped.
+func TestDrainWithoutGrace(t *testing.T) {
+	// Restore to original state after test.
+	defer resetDrain()
+	defer func(shutdown func()) { shutdownAfterDrain = shutdown }(shutdownAfterDrain)
+	shutdownAfterDrain = func() { t.Error("Expected the instance not to shut itself down") }
+
+	if state := startDrain(0); state.Since.IsZero() || state.ShutdownAt != nil {
+		t.Errorf("Expected a drain without a shutdown, got %+v", state)
+	}
+	if drainStats() == nil {
+		t.Error("Expected the instance to be draining")
+	}
+}
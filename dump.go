@@ -0,0 +1,231 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Default maximum size in bytes of the keys and values of a dump, which matches the maximum size of request
+// bodies so that dumps can be loaded again.
+const defaultMaxDumpSize = 1 << 20
+
+// errDumpTooLarge is raised when the store is too large to be dumped.
+var errDumpTooLarge = errors.New("the store is too large to be dumped, use /export instead")
+
+// Dump returns every key-value pair of the default store from a snapshot. It fails with errDumpTooLarge once
+// the keys and values add up to more than maxSize bytes, a maxSize of 0 disabling the limit.
+func Dump(maxSize int) (map[string]string, error) {
+	entries, _ := copyEntries("", "", 0)
+
+	size := 0
+	dump := make(map[string]string, len(entries))
+	for _, e := range entries {
+		item, err := e.decode()
+		if err != nil {
+			return nil, err
+		}
+
+		size += len(item.Key) + len(item.Value)
+		if maxSize > 0 && size > maxSize {
+			return nil, errDumpTooLarge
+		}
+
+		dump[item.Key] = item.Value
+	}
+
+	return dump, nil
+}
+
+// Load sets every key-value pair of items under a single lock, logging a put for each of them, and returns
+// the number of set keys. Keys get the default TTL of their prefix. No key is set if any key or value is invalid.
+func Load(items map[string]string) (int, error) {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Every value is encoded before any is stored, so that an invalid item changes nothing.
+	now := time.Now()
+	events := make([]Event, 0, len(keys))
+	for _, key := range keys {
+		value := items[key]
+		key = normalizeKey(key)
+
+		stored, compressed, err := encodeValue(key, value)
+		if err != nil {
+			return 0, fmt.Errorf("key %q: %w", key, err)
+		}
+
+		events = append(events, Event{EventType: EventPut, Key: key, Value: stored, Expiry: unixNano(defaultExpiry(key, now)), Compressed: compressed, verbatim: true})
+	}
+
+	changes.RLock()
+	defer changes.RUnlock()
+	unlock := lockAllKeys()
+	defer unlock()
+
+	store.Lock()
+	for _, e := range events {
+		if err := checkPutLocked(e.Key); err != nil {
+			store.Unlock()
+			return 0, fmt.Errorf("key %q: %w", e.Key, err)
+		}
+	}
+
+	for i, e := range events {
+		store.m[e.Key] = e.Value
+		bumpVersionLocked(e.Key)
+		if e.Expiry == 0 {
+			delete(store.expiry, e.Key)
+		} else {
+			store.expiry[e.Key] = expiryTime(e.Expiry)
+		}
+		if e.Compressed {
+			store.compressed[e.Key] = true
+		} else {
+			delete(store.compressed, e.Key)
+		}
+		delete(store.contentType, e.Key)
+		delete(store.pinned, e.Key)
+		touchKey(e.Key, false)
+		store.index.add(e.Key, items[keys[i]])
+	}
+	store.Unlock()
+
+	var logErr error
+	for _, e := range events {
+		if err := logger.WriteEvent(e); err != nil && logErr == nil {
+			logErr = err
+		}
+	}
+
+	return len(events), logErr
+}
+
+// DumpHandler is a handler function for the endpoint returning the whole default store as a single JSON object.
+func DumpHandler(rw http.ResponseWriter, r *http.Request) {
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dump, err := Dump(config.maxDumpSize)
+	if errors.Is(err, errDumpTooLarge) {
+		writeError(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if binary {
+		encoded := make(map[string]string, len(dump))
+		for key, value := range dump {
+			encoded[encodeWire(binary, key)] = encodeWire(binary, value)
+		}
+		dump = encoded
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(dump); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// LoadHandler is a handler function for the endpoint setting every key-value pair of a JSON object.
+func LoadHandler(rw http.ResponseWriter, r *http.Request) {
+	var body map[string]string
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	items := make(map[string]string, len(body))
+	for key, value := range body {
+		k, err := decodeWire(binary, key)
+		if err == nil {
+			value, err = decodeWire(binary, value)
+		}
+		if err != nil {
+			writeError(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		items[k] = value
+	}
+
+	loaded, err := Load(items)
+	var schemaErr *SchemaError
+	if errors.Is(err, ErrorEmptyKey) || errors.As(err, &schemaErr) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrorValueTooLarge) {
+		writeError(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, errKeyExists) {
+		writeError(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("loaded %d keys\n", loaded)
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(rw).Encode(struct {
+		Loaded int `json:"loaded"`
+	}{loaded}); err != nil {
+		log.Println(err.Error())
+	}
+}
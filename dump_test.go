@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that a dump can be loaded into an empty store again.
+func TestDumpAndLoad(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-dump.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func() { config.allowDump = false }()
+	config.allowDump = true
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/admin/load", strings.NewReader(`{"a": "one", "b": "two", "c": "three"}`)))
+	if rec.Code != http.StatusCreated || strings.TrimSpace(rec.Body.String()) != `{"loaded":3}` {
+		t.Fatalf("Expected 3 loaded keys, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Every loaded key is logged.
+	logger.Wait()
+	checkLastID(t, logger, 3)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/admin/dump", nil))
+
+	var dump map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &dump); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"a": "one", "b": "two", "c": "three"}
+	if !reflect.DeepEqual(dump, expected) {
+		t.Errorf("Expected %v, got %v", expected, dump)
+	}
+
+	// An invalid item loads nothing.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/admin/load", strings.NewReader(`{"d": "four", "": "empty"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty key, got %d", rec.Code)
+	}
+	if _, err := Get("d"); err == nil {
+		t.Error("Expected no key to be loaded from an invalid object.")
+	}
+}
+
+// Function for testing that stores larger than the maximum size aren't dumped.
+func TestDumpTooLarge(t *testing.T) {
+	// Restore to original state after test.
+	defer resetStores()
+	resetStores()
+
+	Put("yakv", strings.Repeat("y", 100))
+
+	if _, err := Dump(50); err != errDumpTooLarge {
+		t.Errorf("Expected errDumpTooLarge, got %v", err)
+	}
+
+	if dump, err := Dump(0); err != nil || len(dump) != 1 {
+		t.Errorf("Expected the store without a limit, got %v %v", dump, err)
+	}
+}
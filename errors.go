@@ -0,0 +1,97 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Codes of error responses, which stay the same across versions unlike the messages.
+const (
+	CodeBadRequest           = "BAD_REQUEST"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeForbidden            = "FORBIDDEN"
+	CodeNotFound             = "NOT_FOUND"
+	CodeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	CodeConflict             = "CONFLICT"
+	CodeGone                 = "GONE"
+	CodeTooLarge             = "TOO_LARGE"
+	CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	CodeRangeNotSatisfiable  = "RANGE_NOT_SATISFIABLE"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeInternal             = "INTERNAL"
+	CodeNotImplemented       = "NOT_IMPLEMENTED"
+	CodeUnavailable          = "UNAVAILABLE"
+	CodeInsufficientStorage  = "INSUFFICIENT_STORAGE"
+)
+
+// Codes of the HTTP status codes yakv responds with on errors.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:                   CodeBadRequest,
+	http.StatusUnauthorized:                 CodeUnauthorized,
+	http.StatusForbidden:                    CodeForbidden,
+	http.StatusNotFound:                     CodeNotFound,
+	http.StatusMethodNotAllowed:             CodeMethodNotAllowed,
+	http.StatusConflict:                     CodeConflict,
+	http.StatusGone:                         CodeGone,
+	http.StatusRequestEntityTooLarge:        CodeTooLarge,
+	http.StatusUnsupportedMediaType:         CodeUnsupportedMediaType,
+	http.StatusRequestedRangeNotSatisfiable: CodeRangeNotSatisfiable,
+	http.StatusTooManyRequests:              CodeRateLimited,
+	http.StatusInternalServerError:          CodeInternal,
+	http.StatusNotImplemented:               CodeNotImplemented,
+	http.StatusServiceUnavailable:           CodeUnavailable,
+	http.StatusInsufficientStorage:          CodeInsufficientStorage,
+}
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Error struct {
+		Code    string  `json:"code"`
+		Message string  `json:"message"`
+		Version *uint64 `json:"version,omitempty"` // Current version of the key, on version conflicts.
+	} `json:"error"`
+}
+
+// errorCode returns the code of an HTTP status code, falling back to the code of its class.
+func errorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+
+	if status < http.StatusInternalServerError {
+		return CodeBadRequest
+	}
+
+	return CodeInternal
+}
+
+// writeError replies to the request with an error response holding the message and the code of the
+// status. It takes the same arguments as http.Error, which it replaces in every handler.
+func writeError(rw http.ResponseWriter, message string, status int) {
+	var resp ErrorResponse
+	resp.Error.Code = errorCode(status)
+	resp.Error.Message = message
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(status)
+
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		log.Println(err.Error())
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that errors are responded with as JSON holding the code of their status.
+func TestErrorResponses(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-errors.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := newEngine(context.Background(), false)
+	registerRoutes(r, defaultRoutePrefix)
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{http.MethodGet, "/yakv/v0/get", `{"key": "missing"}`, http.StatusNotFound, CodeNotFound},
+		{http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": 1}`, http.StatusBadRequest, CodeBadRequest},
+		{http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "hello, yakv!", "content_type": "not a type"}`, http.StatusBadRequest, CodeBadRequest},
+		{http.MethodGet, "/yakv/v0/missing", ``, http.StatusNotFound, CodeNotFound},
+	}
+
+	for _, req := range requests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+
+		if rec.Code != req.status {
+			t.Errorf("Expected %d for %s %s, got %d", req.status, req.method, req.path, rec.Code)
+		}
+		if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected a JSON error for %s %s, got %q", req.method, req.path, contentType)
+		}
+
+		var resp ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode the error for %s %s: %v", req.method, req.path, err)
+		}
+		if resp.Error.Code != req.code || resp.Error.Message == "" {
+			t.Errorf("Expected code %s with a message for %s %s, got %+v", req.code, req.method, req.path, resp.Error)
+		}
+	}
+}
+
+// Function for testing that statuses without a code of their own fall back to the code of their class.
+func TestErrorCode(t *testing.T) {
+	codes := map[int]string{
+		http.StatusRequestEntityTooLarge: CodeTooLarge,
+		http.StatusTeapot:                CodeBadRequest,
+		http.StatusBadGateway:            CodeInternal,
+	}
+
+	for status, code := range codes {
+		if got := errorCode(status); got != code {
+			t.Errorf("Expected %s for %d, got %s", code, status, got)
+		}
+	}
+}
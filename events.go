@@ -0,0 +1,252 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Error returned when streaming events or listing changed keys of a sharded transaction log. The shards flush
+// their batches independently, so their events are published out of ID order, and a reader following the last
+// ID it received would miss the earlier events of other shards.
+var errShardedLog = errors.New("not available with -log-shards greater than 1")
+
+// Number of written events a subscriber can lag behind before it's dropped.
+const subscriberBuffer = 1024
+
+// Subscribers of the written events. A subscriber which can't keep up has its channel closed,
+// since writing the log must never wait for it.
+var subscribers = struct {
+	sync.Mutex
+	m map[chan Event]bool
+}{m: make(map[chan Event]bool)}
+
+// subscribeEvents returns a channel receiving every event once it's written to the transaction log,
+// and a function for unsubscribing. The channel is closed if the subscriber falls too far behind.
+func subscribeEvents() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	subscribers.Lock()
+	subscribers.m[ch] = true
+	subscribers.Unlock()
+
+	return ch, func() {
+		subscribers.Lock()
+		defer subscribers.Unlock()
+
+		if subscribers.m[ch] {
+			delete(subscribers.m, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishEvents sends events which were written to the transaction log to every subscriber.
+func publishEvents(events []Event) {
+	subscribers.Lock()
+	defer subscribers.Unlock()
+
+	for ch := range subscribers.m {
+		for _, e := range events {
+			select {
+			case ch <- e:
+			default:
+				// The subscriber is too slow, dropping it lets it resume from the last event it got.
+				delete(subscribers.m, ch)
+				close(ch)
+			}
+
+			if !subscribers.m[ch] {
+				break
+			}
+		}
+	}
+}
+
+// Names of the event types in streamed events.
+var eventTypeNames = map[EventType]string{
+	EventDelete:        "delete",
+	EventPut:           "put",
+	EventDropNamespace: "drop_namespace",
+	EventTouch:         "touch",
+}
+
+// StreamEvent is an event of the transaction log, as it is streamed to clients.
+type StreamEvent struct {
+	ID        uint64 `json:"id"`
+	Type      string `json:"type"`
+	Key       string `json:"key,omitempty"`
+	Value     string `json:"value,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Database  int    `json:"database,omitempty"` // Database of the key, left out for database 0.
+	Expiry    int64  `json:"expiry,omitempty"`   // Expiration time of the key in Unix nanoseconds.
+}
+
+// streamEvent converts an event for streaming, decompressing its value.
+func streamEvent(e Event, binary bool) (StreamEvent, error) {
+	value := e.Value
+	if e.Compressed {
+		var err error
+		if value, err = decompressValue(value); err != nil {
+			return StreamEvent{}, err
+		}
+	}
+
+	return StreamEvent{
+		ID:        e.ID,
+		Type:      eventTypeNames[e.EventType],
+		Key:       encodeWire(binary, e.Key),
+		Value:     encodeWire(binary, value),
+		Namespace: e.Namespace,
+		Database:  e.Database,
+		Expiry:    e.Expiry,
+	}, nil
+}
+
+// EventsHandler is a handler function for the endpoint streaming the events of the transaction log with
+// IDs greater than the from query parameter, as one JSON object per line. Events already in the log
+// are read from it, and the stream then continues with new events as they're written, until the client
+// disconnects or falls too far behind.
+func EventsHandler(rw http.ResponseWriter, r *http.Request) {
+	if config.logShards > 1 {
+		writeError(rw, errShardedLog.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var from uint64
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = strconv.ParseUint(value, 10, 64); err != nil {
+			writeError(rw, "from must be an event ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Subscribing while no write is in flight splits the events without a gap: events up to lastID
+	// are in the file, and every later event is sent to the subscriber.
+	changes.Lock()
+	live, unsubscribe := subscribeEvents()
+	logger.Wait()
+	lastID := logger.LastID()
+	changes.Unlock()
+	defer unsubscribe()
+
+	var past <-chan Event
+	var first Event
+	// The BoltDB file keeps no transactions, only the state they led to.
+	if from < lastID && config.backend == boltBackend {
+		writeError(rw, fmt.Sprintf("the events after %d are not kept by the bolt backend", from), http.StatusGone)
+		return
+	}
+
+	if from < lastID {
+		reader, err := NewTransactionLogger(transactionLogFilename)
+		if err != nil {
+			writeError(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer reader.Close()
+
+		events, errs := reader.ReadEvents()
+		defer func() {
+			// The rest of the file is read in the background, it may end with a partial write.
+			go func() {
+				for range events {
+				}
+				<-errs
+			}()
+		}()
+
+		// Events of rotated logs can't be streamed anymore.
+		var ok bool
+		if first, ok = <-events; !ok || first.ID > from+1 {
+			writeError(rw, fmt.Sprintf("the events after %d are no longer in the transaction log", from), http.StatusGone)
+			return
+		}
+
+		past = events
+	}
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	rw.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(rw)
+	flusher, _ := rw.(http.Flusher)
+	sent := from
+
+	send := func(e Event) bool {
+		if e.ID <= sent {
+			return true
+		}
+
+		se, err := streamEvent(e, binary)
+		if err == nil {
+			err = enc.Encode(se)
+		}
+		if err != nil {
+			log.Println(err.Error())
+			return false
+		}
+
+		sent = e.ID
+		return true
+	}
+
+	if past != nil {
+		if !send(first) {
+			return
+		}
+		for e := range past {
+			if e.ID > lastID || !send(e) {
+				break
+			}
+		}
+	}
+
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-live:
+			if !ok {
+				// Dropped for falling behind, the client resumes from the last event it got.
+				return
+			}
+			if !send(e) {
+				return
+			}
+
+			// Events are sent right away, as long as no more are waiting.
+			if flusher != nil && len(live) == 0 {
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that events are streamed from the log, then live, without a gap.
+func TestEventsHandler(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-events.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(filename string) { transactionLogFilename = filename }(transactionLogFilename)
+	transactionLogFilename = filename
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	put := func(key string) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(fmt.Sprintf(`{"key": %q, "value": "hello, yakv!"}`, key))))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", rec.Code)
+		}
+	}
+
+	put("yakv1")
+	put("yakv2")
+	put("yakv3")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/yakv/v0/admin/events?from=1", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	// Events written after subscribing are streamed live.
+	put("yakv4")
+
+	scanner := bufio.NewScanner(resp.Body)
+	for _, expected := range []StreamEvent{
+		{ID: 2, Type: "put", Key: "yakv2", Value: "hello, yakv!"},
+		{ID: 3, Type: "put", Key: "yakv3", Value: "hello, yakv!"},
+		{ID: 4, Type: "put", Key: "yakv4", Value: "hello, yakv!"},
+	} {
+		if !scanner.Scan() {
+			t.Fatalf("Expected event %d, got %v", expected.ID, scanner.Err())
+		}
+
+		var e StreamEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e != expected {
+			t.Errorf("Expected %+v, got %+v", expected, e)
+		}
+	}
+
+	// Disconnecting unsubscribes the client.
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		subscribers.Lock()
+		n := len(subscribers.m)
+		subscribers.Unlock()
+
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the disconnected client to be unsubscribed.")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Events of a rotated log are gone.
+	os.Rename(filename, filename+".1")
+	defer os.Remove(filename + ".1")
+	if err := logger.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	put("yakv5")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/admin/events?from=1", nil))
+	if rec.Code != http.StatusGone {
+		t.Errorf("Expected 410 for events of a rotated log, got %d", rec.Code)
+	}
+}
+
+// Function for testing that a subscriber which falls behind is dropped instead of blocking the logger.
+func TestSlowSubscriber(t *testing.T) {
+	events, unsubscribe := subscribeEvents()
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		publishEvents([]Event{{ID: uint64(i + 1), EventType: EventPut, Key: "yakv"}})
+	}
+
+	n := 0
+	for range events {
+		n++
+	}
+
+	if n != subscriberBuffer {
+		t.Errorf("Expected the %d buffered events before the channel was closed, got %d", subscriberBuffer, n)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// errStoreFull is raised when a new key would exceed -max-keys.
+var errStoreFull = errors.New("the store is full")
+
+// errEveryKeyPinned is raised when a new key would exceed -max-keys while every key is pinned.
+var errEveryKeyPinned = fmt.Errorf("%w and every key is pinned", errStoreFull)
+
+// errNewKeyRejected is raised when a new key would exceed -max-keys with -max-keys-reject.
+var errNewKeyRejected = fmt.Errorf("%w, only existing keys can be written", errStoreFull)
+
+// Number of keys evicted since start-up. Accessed atomically.
+var evictedKeys uint64
+
+// Keys of the default store which can be evicted, i.e. which aren't pinned, least recently used first. Only
+// tracked with -max-keys, and without -max-keys-reject. Reads move keys to the back after releasing the store's lock, so the list can hold
+// keys which were deleted or pinned since, which are skipped when evicting. Locked after the store.
+var lru = struct {
+	sync.Mutex
+	order *list.List               // Keys, least recently used first.
+	elems map[string]*list.Element // Element of each key in order.
+}{order: list.New(), elems: make(map[string]*list.Element)}
+
+// touchKey marks key as the most recently used, or forgets it if it's pinned.
+func touchKey(key string, pinned bool) {
+	if config.maxKeys <= 0 || config.maxKeysReject {
+		return
+	}
+
+	lru.Lock()
+	defer lru.Unlock()
+
+	elem, ok := lru.elems[key]
+	switch {
+	case pinned && ok:
+		lru.order.Remove(elem)
+		delete(lru.elems, key)
+	case pinned:
+	case ok:
+		lru.order.MoveToBack(elem)
+	default:
+		lru.elems[key] = lru.order.PushBack(key)
+	}
+}
+
+// forgetKey stops tracking a deleted key.
+func forgetKey(key string) {
+	if config.maxKeys <= 0 || config.maxKeysReject {
+		return
+	}
+
+	lru.Lock()
+	defer lru.Unlock()
+
+	if elem, ok := lru.elems[key]; ok {
+		lru.order.Remove(elem)
+		delete(lru.elems, key)
+	}
+}
+
+// oldestKey returns the least recently used key of the store which isn't pinned or being written by a write other
+// than the one of current, see keyBeingWritten, and whether there is one. Keys the list holds but the store doesn't
+// are dropped along the way. The caller must hold the store's lock.
+func oldestKey(current string) (string, bool) {
+	lru.Lock()
+	defer lru.Unlock()
+
+	for elem := lru.order.Front(); elem != nil; {
+		key := elem.Value.(string)
+		next := elem.Next()
+		if _, ok := store.m[key]; ok && !store.pinned[key] {
+			if !keyBeingWritten(key, current) {
+				return key, true
+			}
+		} else {
+			lru.order.Remove(elem)
+			delete(lru.elems, key)
+		}
+		elem = next
+	}
+
+	return "", false
+}
+
+// admitKey makes room for key if it would be a new key of a store holding -max-keys keys, by evicting the least
+// recently used keys which aren't pinned. It fails with errEveryKeyPinned once only pinned keys, or keys being
+// written, are left, and right away with errNewKeyRejected with -max-keys-reject. Evictions are logged as deletes
+// without waiting for -sync-writes, and keys aren't evicted or rejected while replaying. The caller must hold the store's lock, so that the key can't be created
+// by another write between checking that it's new and writing it.
+func admitKey(key string) error {
+	if config.maxKeys <= 0 || replaying {
+		return nil
+	}
+	if _, ok := store.m[key]; ok {
+		return nil
+	}
+
+	if config.maxKeysReject && len(store.m) >= config.maxKeys {
+		return errNewKeyRejected
+	}
+
+	for len(store.m) >= config.maxKeys {
+		victim, ok := oldestKey(key)
+		if !ok {
+			return errEveryKeyPinned
+		}
+
+		removeLocked(victim)
+		atomic.AddUint64(&evictedKeys, 1)
+
+		// Logging under the lock keeps the delete ordered before the write which evicted the key.
+		logger.WriteEvent(Event{EventType: EventDelete, Key: victim, queued: true})
+	}
+
+	return nil
+}
+
+// evictedCount returns the number of keys evicted since start-up.
+func evictedCount() uint64 {
+	return atomic.LoadUint64(&evictedKeys)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that new keys evict the least recently used keys which aren't pinned, and are rejected
+// with 507 once only pinned keys are left.
+func TestMaxKeys(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-max-keys.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(maxKeys int) { config.maxKeys = maxKeys }(config.maxKeys)
+	config.maxKeys = 3
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	put := func(key string, pinned bool) int {
+		body := `{"key": "` + key + `", "value": "hello, yakv!"}`
+		if pinned {
+			body = `{"key": "` + key + `", "value": "hello, yakv!", "pinned": true}`
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	keys := func() string {
+		k, _ := Keys("", "", defaultKeysLimit)
+		return strings.Join(k, ",")
+	}
+
+	put("a", false)
+	put("b", false)
+	put("c", true)
+
+	// Reading a makes b the least recently used key.
+	if _, err := Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if code := put("d", false); code != http.StatusCreated || keys() != "a,c,d" {
+		t.Errorf("Expected b to be evicted, got %d %s", code, keys())
+	}
+
+	// Overwriting a key doesn't evict anything, and pinned keys are never evicted.
+	if code := put("d", false); code != http.StatusCreated || keys() != "a,c,d" {
+		t.Errorf("Expected an overwrite not to evict, got %d %s", code, keys())
+	}
+	put("e", true)
+	put("f", true)
+	if keys() != "c,e,f" || evictedCount() < 3 {
+		t.Errorf("Expected only the pinned keys to be left, got %s after %d evictions", keys(), evictedCount())
+	}
+
+	// Once every key is pinned, new keys are rejected while existing keys can still be written.
+	if code := put("g", false); code != http.StatusInsufficientStorage {
+		t.Errorf("Expected 507 for a new key of a full store, got %d", code)
+	}
+	if code := put("c", false); code != http.StatusCreated {
+		t.Errorf("Expected an existing key to be written, got %d", code)
+	}
+
+	// Unpinning c made it evictable.
+	if code := put("g", false); code != http.StatusCreated || keys() != "e,f,g" {
+		t.Errorf("Expected the unpinned key to be evicted, got %d %s", code, keys())
+	}
+
+	// Evictions are logged, so that they aren't resurrected by a replay, and pins survive it.
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	if keys() != "e,f,g" || !store.pinned["e"] || !store.pinned["f"] || store.pinned["g"] {
+		t.Errorf("Expected the same keys and pins after a replay, got %s %v", keys(), store.pinned)
+	}
+}
+
+// Function for testing that -max-keys-reject rejects new keys at the cap, while overwrites still succeed and
+// nothing is evicted.
+func TestMaxKeysReject(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-max-keys-reject.log")()
+	defer resetStores()
+	defer func(maxKeys int, reject bool) { config.maxKeys, config.maxKeysReject = maxKeys, reject }(config.maxKeys, config.maxKeysReject)
+	config.maxKeys, config.maxKeysReject = 2, true
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(path, body string) *httptest.ResponseRecorder {
+		method := http.MethodPost
+		if path == "/yakv/v0/put" {
+			method = http.MethodPut
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	// Keys are admitted up to the cap.
+	for _, key := range []string{"a", "b"} {
+		if rec := serve("/yakv/v0/put", `{"key": "`+key+`", "value": "hello, yakv!"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected %s to be admitted below the cap, got %d", key, rec.Code)
+		}
+	}
+
+	// At the cap, every write creating a key is rejected, and nothing is evicted.
+	evicted := evictedCount()
+	writes := []struct{ path, body string }{
+		{"/yakv/v0/put", `{"key": "c", "value": "hello, yakv!"}`},
+		{"/yakv/v0/setnx", `{"key": "c", "value": "hello, yakv!"}`},
+		{"/yakv/v0/append", `{"key": "c", "value": "hello, yakv!"}`},
+	}
+	for _, w := range writes {
+		if rec := serve(w.path, w.body); rec.Code != http.StatusInsufficientStorage || !strings.Contains(rec.Body.String(), "INSUFFICIENT_STORAGE") {
+			t.Errorf("Expected 507 for a new key through %s, got %d %s", w.path, rec.Code, rec.Body.String())
+		}
+	}
+	if k, _ := Keys("", "", defaultKeysLimit); strings.Join(k, ",") != "a,b" || evictedCount() != evicted {
+		t.Errorf("Expected a and b to be kept, got %v after %d evictions", k, evictedCount()-evicted)
+	}
+
+	// Overwriting an existing key still works.
+	if rec := serve("/yakv/v0/put", `{"key": "a", "value": "hello again"}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected an overwrite at the cap to succeed, got %d", rec.Code)
+	}
+	if value, err := Get("a"); err != nil || value != "hello again" {
+		t.Errorf("Expected the overwritten value, got %q %v", value, err)
+	}
+
+	// Deleting a key makes room for a new one.
+	if err := Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve("/yakv/v0/put", `{"key": "c", "value": "hello, yakv!"}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected a new key below the cap to be admitted, got %d", rec.Code)
+	}
+}
+
+// Function for testing that the pinned flag is written to the log only for pinned keys.
+func TestPinnedEventFormat(t *testing.T) {
+	line := formatEvent(ftlVersion, Event{ID: 1, EventType: EventPut, Key: "yakv", Value: "hello, yakv!", Pinned: true})
+	if e, err := parseEvent(ftlVersion, line); err != nil || !e.Pinned || e.ContentType != "" {
+		t.Errorf("Expected a pinned event without a content type, got %+v %v", e, err)
+	}
+
+	// Unpinned events keep the format older versions of yakv read.
+	if line := formatEvent(ftlVersion, Event{ID: 1, EventType: EventPut, Key: "yakv", Value: "hello, yakv!"}); strings.Count(line, "\t") != 7 {
+		t.Errorf("Expected no pinned field for an unpinned key, got %q", line)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Default maximum number of keys copied and streamed by a single /export.
+const defaultMaxExportKeys = 100000
+
+// changes orders logged writes against export snapshots. Every write holds a read lock from modifying
+// the store until its event is handed to the logger, so while the lock is held exclusively, every write
+// already in the store has been handed to the logger, and no other write can start.
+var changes sync.RWMutex
+
+// snapshotEntries copies up to max of the entries starting with prefix, like copyEntries, along with the ID of
+// the last event they include: the entries contain the effects of every event up to the ID, and of no event
+// after it. It fails with errTooManyKeys when more entries match.
+func snapshotEntries(prefix string, max int) ([]storeEntry, uint64, error) {
+	changes.Lock()
+	defer changes.Unlock()
+
+	entries, more := copyEntries(prefix, "", max)
+	if more {
+		return nil, 0, errTooManyKeys
+	}
+
+	// A store restored to a point in time only contains the events replayed up to replayedID.
+	if config.replayUntil > 0 {
+		return entries, replayedID, nil
+	}
+
+	// Wait for the logger to number the events it was handed.
+	logger.Wait()
+
+	return entries, logger.LastID(), nil
+}
+
+// ExportHandler is a handler function for the endpoint exporting all key-value pairs starting with
+// the prefix query parameter, as one JSON object per line. Exports can be slow, so they always stream
+// from a copy of the entries instead of holding the lock. The ID of the last event included in the
+// export is sent in the X-Last-Event-ID header.
+func ExportHandler(rw http.ResponseWriter, r *http.Request) {
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prefix, err := decodeKey(binary, r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, lastID, err := snapshotEntries(prefix, config.maxExportKeys)
+	if errors.Is(err, errTooManyKeys) {
+		writeError(rw, fmt.Sprintf("more than %d keys start with the prefix, use /scan instead", config.maxExportKeys), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	rw.Header().Set("X-Last-Event-ID", strconv.FormatUint(lastID, 10))
+
+	enc := json.NewEncoder(rw)
+	for _, e := range entries {
+		item, err := e.decode()
+		if err != nil {
+			// The status has already been sent, so the export is cut short.
+			log.Println(err.Error())
+			return
+		}
+
+		if err := enc.Encode(KeyValue{Key: encodeWire(binary, item.Key), Value: encodeWire(binary, item.Value)}); err != nil {
+			log.Println(err.Error())
+			return
+		}
+	}
+}
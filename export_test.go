@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that the export contains every pair of the prefix, one per line in sorted order.
+func TestExportHandler(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-export.log")()
+	defer resetStores()
+	resetStores()
+
+	Put("export:b", "two")
+	Put("export:a", "one")
+	Put("other", "three")
+
+	rec := httptest.NewRecorder()
+	ExportHandler(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/export?prefix=export:", nil))
+
+	var items []KeyValue
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var item KeyValue
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+
+	expected := []KeyValue{{"export:a", "one"}, {"export:b", "two"}}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected %v, got %v", expected, items)
+	}
+
+	// An export of more than -max-export-keys keys is refused.
+	defer func(n int) { config.maxExportKeys = n }(config.maxExportKeys)
+	config.maxExportKeys = 1
+	rec = httptest.NewRecorder()
+	ExportHandler(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/export?prefix=export:", nil))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 beyond -max-export-keys, got %d", rec.Code)
+	}
+}
+
+// Function for testing that the last event ID of an export matches its entries, while writes are in progress.
+func TestExportLastEventID(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-export-id.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	// Keep writing and deleting keys during the export.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				key := fmt.Sprintf("w%d:%d", w, i%10)
+				body := fmt.Sprintf(`{"key": %q, "value": "%d"}`, key, i)
+				r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(body)))
+				if i%3 == 0 {
+					r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/yakv/v0/delete", strings.NewReader(fmt.Sprintf(`{"key": %q}`, key))))
+				}
+			}
+		}(w)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/export", nil))
+	close(stop)
+	wg.Wait()
+
+	lastID, err := strconv.ParseUint(rec.Header().Get("X-Last-Event-ID"), 10, 64)
+	if err != nil || lastID == 0 {
+		t.Fatalf("Expected the ID of the last exported event, got %q", rec.Header().Get("X-Last-Event-ID"))
+	}
+
+	exported := make(map[string]string)
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var item KeyValue
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatal(err)
+		}
+		exported[item.Key] = item.Value
+	}
+
+	// Replaying the log up to the ID results in exactly the exported entries.
+	logger.Wait()
+	replayLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayLogger.Close()
+
+	replayed := make(map[string]string)
+	events, errs := replayLogger.ReadEvents()
+	for e := range events {
+		if e.ID > lastID {
+			continue
+		}
+		switch e.EventType {
+		case EventPut:
+			replayed[e.Key] = e.Value
+		case EventDelete:
+			delete(replayed, e.Key)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(exported, replayed) {
+		t.Errorf("Export diverged from the log up to event %d.\nexported: %v\nreplayed: %v", lastID, exported, replayed)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// errNotCounter is raised when the value of a key read as a counter isn't an integer.
+var errNotCounter = errors.New("value of the key isn't an integer")
+
+// GetResetBody is a struct for defining the request body structure for reading and resetting a counter.
+type GetResetBody struct {
+	Key string `json:"key"`
+}
+
+// GetReset reads the integer value of key and sets it to 0 under a single lock, so that no write is lost
+// between reading and resetting it, and returns the previous value. The key isn't deleted: it keeps its
+// expiry and content type, and the reset is logged as a put of 0.
+func GetReset(key string) (int64, error) {
+	key = normalizeKey(key)
+	if err := validateKey(key); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+
+	changes.RLock()
+	defer changes.RUnlock()
+	stripe := keyLock(key)
+	stripe.Lock()
+	defer stripe.Unlock()
+
+	store.Lock()
+	count, e, err := getResetLocked(key, now)
+	store.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := logger.WriteEvent(e); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// getResetLocked resets the counter of key for GetReset, returning its previous value and the event logging the
+// reset. The caller must hold the store's lock.
+func getResetLocked(key string, now time.Time) (int64, Event, error) {
+	// Keys which have expired but haven't been swept yet are treated as missing.
+	stored, ok := store.m[key]
+	expiresAt, expires := store.expiry[key]
+	if !ok || (expires && hasExpired(key, expiresAt, now)) {
+		return 0, Event{}, ErrorNoSuchKey
+	}
+	if err := checkPutLocked(key); err != nil {
+		return 0, Event{}, err
+	}
+
+	item, err := storeEntry{key: key, value: stored, compressed: store.compressed[key]}.decode()
+	if err != nil {
+		return 0, Event{}, err
+	}
+
+	count, err := strconv.ParseInt(item.Value, 10, 64)
+	if err != nil {
+		return 0, Event{}, errNotCounter
+	}
+
+	stored, compressed, err := encodeValue(key, "0")
+	if err != nil {
+		return 0, Event{}, err
+	}
+
+	store.m[key] = stored
+	bumpVersionLocked(key)
+	if compressed {
+		store.compressed[key] = true
+	} else {
+		delete(store.compressed, key)
+	}
+	store.index.add(key, "0")
+
+	return count, Event{EventType: EventPut, Key: key, Value: stored, Expiry: unixNano(store.expiry[key]), Compressed: compressed, ContentType: store.contentType[key], Pinned: store.pinned[key]}, nil
+}
+
+// GetResetHandler is a handler function for the endpoint reading and resetting a counter.
+func GetResetHandler(rw http.ResponseWriter, r *http.Request) {
+	var body GetResetBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := decodeKey(binary, body.Key)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count, err := GetReset(key)
+	var schemaErr *SchemaError
+	if errors.Is(err, ErrorEmptyKey) || errors.Is(err, errNotCounter) || errors.As(err, &schemaErr) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, errKeyExists) {
+		writeError(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("reset key \"%s\" from %d\n", key, count)
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(struct {
+		Value int64 `json:"value"`
+	}{count}); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that a counter is read and reset to 0, keeping its expiry.
+func TestGetReset(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-getreset.log")()
+	defer resetStores()
+
+	expiresAt := time.Now().Add(time.Hour)
+	PutWithExpiry("hits", "41", expiresAt)
+
+	if count, err := GetReset("hits"); err != nil || count != 41 {
+		t.Fatalf("Expected the previous count of 41, got %d %v", count, err)
+	}
+	if value, _ := Get("hits"); value != "0" || !store.expiry["hits"].Equal(expiresAt) {
+		t.Errorf("Expected the counter to be reset to 0 keeping its expiry, got %q %v", value, store.expiry["hits"])
+	}
+	if count, err := GetReset("hits"); err != nil || count != 0 {
+		t.Errorf("Expected a reset counter to read 0, got %d %v", count, err)
+	}
+
+	PutWithExpiry("stale", "7", time.Now().Add(-time.Second))
+	if _, err := GetReset("stale"); !errors.Is(err, ErrorNoSuchKey) {
+		t.Errorf("Expected ErrorNoSuchKey for an expired key, got %v", err)
+	}
+
+	Put("name", "yakv")
+	if _, err := GetReset("name"); !errors.Is(err, errNotCounter) {
+		t.Errorf("Expected errNotCounter for a value which isn't an integer, got %v", err)
+	}
+	if value, _ := Get("name"); value != "yakv" {
+		t.Errorf("Expected a value which isn't an integer to be kept, got %q", value)
+	}
+}
+
+// Function for testing the getreset endpoint.
+func TestGetResetHandler(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-getreset-handler.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	getReset := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/getreset", strings.NewReader(body)))
+		return rec
+	}
+
+	Put("hits", "-12")
+	if rec := getReset(`{"key": "hits"}`); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"value":-12}` {
+		t.Errorf("Expected the previous count, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := getReset(`{"key": "missing"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing key, got %d", rec.Code)
+	}
+	Put("name", "yakv")
+	if rec := getReset(`{"key": "name"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a value which isn't an integer, got %d", rec.Code)
+	}
+
+	// Replaying the log gives the reset counter.
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := Get("hits"); value != "0" {
+		t.Errorf("Expected the replayed counter to be 0, got %q", value)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetSetBody is a struct for defining the request body structure for setting a value and getting the previous one.
+type GetSetBody struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	TTLSeconds *int64 `json:"ttl_seconds"` // Optional lifetime of the key, zero means the key never expires. Defaults to the TTL of the key's prefix.
+}
+
+// GetSet sets the value of key and returns its previous value, along with whether the key existed. The key
+// expires according to the default TTL of its prefix, if any.
+func GetSet(key, value string) (string, bool, error) {
+	key = normalizeKey(key)
+	return getSet(key, value, defaultExpiry(key, time.Now()))
+}
+
+// getSet sets the value of key, which expires at expiresAt, and returns its previous value along with whether
+// the key existed, under a single lock so that no other write comes in between. Keys which have expired but
+// haven't been swept yet didn't exist anymore. Like a PUT, the new value drops the content type of the previous one.
+func getSet(key, value string, expiresAt time.Time) (string, bool, error) {
+	if err := validateKey(key); err != nil {
+		return "", false, err
+	}
+
+	stored, compressed, err := encodeValue(key, value)
+	if err != nil {
+		return "", false, err
+	}
+
+	now := time.Now()
+
+	changes.RLock()
+	defer changes.RUnlock()
+	stripe := keyLock(key)
+	stripe.Lock()
+	defer stripe.Unlock()
+	store.Lock()
+
+	if err := checkPutLocked(key); err != nil {
+		store.Unlock()
+		return "", false, err
+	}
+
+	var previous string
+	previousStored, existed := store.m[key]
+	if current, expires := store.expiry[key]; existed && expires && hasExpired(key, current, now) {
+		existed = false
+	}
+
+	if existed {
+		item, err := storeEntry{key: key, value: previousStored, compressed: store.compressed[key]}.decode()
+		if err != nil {
+			store.Unlock()
+			return "", false, err
+		}
+		previous = item.Value
+	}
+
+	err = setLocked(key, value, stored, compressed, expiresAt, "")
+	pinned := store.pinned[key]
+	store.Unlock()
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := logger.WriteEvent(Event{EventType: EventPut, Key: key, Value: stored, Expiry: unixNano(expiresAt), Compressed: compressed, Pinned: pinned, verbatim: true}); err != nil {
+		return "", false, err
+	}
+
+	return previous, existed, nil
+}
+
+// GetSetHandler is a handler function for the endpoint setting a value and getting the previous one.
+func GetSetHandler(rw http.ResponseWriter, r *http.Request) {
+	var body GetSetBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := decodeKey(binary, body.Key)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value, err := decodeWire(binary, body.Value)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value = stripNewlines(binary, value)
+
+	expiresAt, err := requestExpiry(key, body.TTLSeconds)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	previous, existed, err := getSet(key, value, expiresAt)
+	var schemaErr *SchemaError
+	if errors.Is(err, ErrorEmptyKey) || errors.As(err, &schemaErr) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrorValueTooLarge) {
+		writeError(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, errStoreFull) {
+		writeError(rw, err.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	if errors.Is(err, errKeyExists) {
+		writeError(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("replaced value of key \"%s\"\n", key)
+
+	// A missing key has a null previous value, so that it can be told apart from an empty one.
+	resp := struct {
+		Existed  bool    `json:"existed"`
+		Previous *string `json:"previous"`
+	}{Existed: existed}
+	if existed {
+		encoded := encodeWire(binary, previous)
+		resp.Previous = &encoded
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that a missing key, an empty previous value and an expired key are told apart.
+func TestGetSet(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-getset.log")()
+	defer resetStores()
+
+	if previous, existed, err := GetSet("yakv", ""); err != nil || existed || previous != "" {
+		t.Fatalf("Expected a missing key, got %q %t %v", previous, existed, err)
+	}
+	if previous, existed, err := GetSet("yakv", "hello, yakv!"); err != nil || !existed || previous != "" {
+		t.Errorf("Expected the empty previous value, got %q %t %v", previous, existed, err)
+	}
+	if previous, existed, err := GetSet("yakv", "bye"); err != nil || !existed || previous != "hello, yakv!" {
+		t.Errorf("Expected the previous value, got %q %t %v", previous, existed, err)
+	}
+	if value, _ := Get("yakv"); value != "bye" {
+		t.Errorf("Expected the new value to be stored, got %q", value)
+	}
+
+	// Keys which have expired don't exist anymore.
+	PutWithExpiry("stale", "old", time.Now().Add(-time.Second))
+	if previous, existed, err := GetSet("stale", "new"); err != nil || existed || previous != "" {
+		t.Errorf("Expected an expired key to be missing, got %q %t %v", previous, existed, err)
+	}
+	if _, ok := store.expiry["stale"]; ok {
+		t.Errorf("Expected the new value not to expire")
+	}
+}
+
+// Function for testing the getset endpoint, and that the new values are logged.
+func TestGetSetHandler(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-getset-handler.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	getSet := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/getset", strings.NewReader(body)))
+		return rec
+	}
+
+	tests := []struct {
+		body, resp string
+	}{
+		{`{"key": "yakv", "value": ""}`, `{"existed":false,"previous":null}`},
+		{`{"key": "yakv", "value": "hello, yakv!"}`, `{"existed":true,"previous":""}`},
+		{`{"key": "yakv", "value": "bye", "ttl_seconds": 60}`, `{"existed":true,"previous":"hello, yakv!"}`},
+	}
+
+	for _, test := range tests {
+		if rec := getSet(test.body); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != test.resp {
+			t.Errorf("Expected 200 %s for %s, got %d %s", test.resp, test.body, rec.Code, rec.Body.String())
+		}
+	}
+
+	if rec := getSet(`{"key": "", "value": "bye"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty key, got %d", rec.Code)
+	}
+
+	// Replaying the log gives the last value along with its expiry.
+	expiresAt := store.expiry["yakv"]
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := Get("yakv"); value != "bye" || !store.expiry["yakv"].Equal(expiresAt) {
+		t.Errorf("Expected the replayed value to be \"bye\" expiring at %v, got %q %v", expiresAt, value, store.expiry["yakv"])
+	}
+}
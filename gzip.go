@@ -0,0 +1,159 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compress/gzip"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default minimum size in bytes of the responses which are gzip-compressed.
+const defaultGzipMinSize = 1024
+
+// gzipResponseWriter buffers the start of a response until it reaches minSize, then compresses the
+// rest of it on the fly. Responses which end before reaching minSize are sent uncompressed.
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	minSize     int
+	buf         []byte
+	gz          *gzip.Writer
+	passthrough bool
+}
+
+// Write buffers or compresses the response, depending on how much of it has been written.
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+
+	// Responses which are already encoded are sent as they are.
+	if w.Header().Get("Content-Encoding") != "" {
+		w.passthrough = true
+		if err := w.writeBuffer(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) < w.minSize {
+		return len(p), nil
+	}
+
+	// The response is large enough, so everything from now on is compressed.
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length")
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	if _, err := w.gz.Write(w.buf); err != nil {
+		return 0, err
+	}
+	w.buf = nil
+
+	return len(p), nil
+}
+
+// WriteString writes a string to the response.
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends whatever has been written so far. Streamed responses which are flushed before reaching
+// minSize are sent uncompressed, so that they keep streaming.
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	} else {
+		w.passthrough = true
+		w.writeBuffer()
+	}
+
+	w.ResponseWriter.Flush()
+}
+
+// writeBuffer sends the buffered start of the response uncompressed.
+func (w *gzipResponseWriter) writeBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	_, err := w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+
+	return err
+}
+
+// close finishes the response once the handler has returned.
+func (w *gzipResponseWriter) close() error {
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+
+	return w.writeBuffer()
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip-compressed responses.
+func acceptsGzip(header string) bool {
+	for _, encoding := range strings.Split(header, ",") {
+		parts := strings.Split(encoding, ";")
+
+		name := strings.TrimSpace(parts[0])
+		if name != "gzip" && name != "*" {
+			continue
+		}
+
+		// An encoding with a quality of 0 is explicitly refused.
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); strings.HasPrefix(param, "q=") && err == nil && q == 0 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// GzipMiddleware gzip-compresses responses of at least minSize bytes for clients accepting gzip.
+func GzipMiddleware(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		// Ranges are ranges of the uncompressed value, so partial responses aren't compressed.
+		if c.Request.Method == "HEAD" || c.GetHeader("Range") != "" || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		w := &gzipResponseWriter{ResponseWriter: c.Writer, minSize: minSize}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+		}()
+
+		c.Next()
+
+		if err := w.close(); err != nil {
+			c.Error(err)
+		}
+	}
+}
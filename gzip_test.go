@@ -0,0 +1,81 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Helper function for serving a response body through the gzip middleware.
+func serveGzip(t *testing.T, body string, header http.Header) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GzipMiddleware(64))
+	r.GET("/", func(c *gin.Context) {
+		for name, values := range header {
+			c.Header(name, values[0])
+		}
+		c.String(http.StatusOK, body)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// Function for testing that only large responses are compressed.
+func TestGzipMiddleware(t *testing.T) {
+	large := strings.Repeat("hello, yakv! ", 100)
+
+	rec := serveGzip(t, large, nil)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("Expected a large response to be compressed.")
+	}
+
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(gz); string(body) != large {
+		t.Error("Decompressed response doesn't match the original response.")
+	}
+
+	rec = serveGzip(t, "hello, yakv!", nil)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "hello, yakv!" {
+		t.Errorf("Expected a small response to be sent as it is, got %q", rec.Body.String())
+	}
+
+	// Responses which are already encoded aren't compressed again.
+	rec = serveGzip(t, large, http.Header{"Content-Encoding": {"br"}})
+	if rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != large {
+		t.Error("Expected an encoded response to be sent as it is.")
+	}
+}
+
+// Function for testing the parsing of Accept-Encoding headers.
+func TestAcceptsGzip(t *testing.T) {
+	headers := map[string]bool{
+		"":                  false,
+		"gzip":              true,
+		"deflate, gzip;q=1": true,
+		"br, *":             true,
+		"gzip;q=0":          false,
+		"gzip; q=0.000, br": false,
+		"identity, deflate": false,
+	}
+
+	for header, expected := range headers {
+		if acceptsGzip(header) != expected {
+			t.Errorf("Expected acceptsGzip(%q) to be %t", header, expected)
+		}
+	}
+}
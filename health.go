@@ -0,0 +1,156 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Maximum number of attempts to write a batch of events to the transaction log.
+const logWriteMaxAttempts = 5
+
+// Number of errors of the transaction log buffered for drainLogErrors.
+const logErrorBuffer = 16
+
+// Number of errors of the transaction log drained by drainLogErrors.
+var logErrors uint64
+
+// Delay before the first retry of a failed write to the transaction log, doubled after every attempt.
+var logWriteRetryDelay = 50 * time.Millisecond
+
+// writeLogFile writes to the transaction log file. It is replaced in tests to simulate failing disks.
+var writeLogFile = (*os.File).Write
+
+// Health of the transaction log, unhealthy once a batch of events couldn't be written.
+var logHealth = struct {
+	sync.RWMutex
+	err  error     // Error of the last failed write, nil while healthy.
+	lost uint64    // Number of events which couldn't be written.
+	at   time.Time // Time of the last failed write.
+}{}
+
+// setLogHealth records the result of writing a batch of n events to the transaction log.
+func setLogHealth(err error, n int) {
+	recordLogWrite(err)
+
+	logHealth.Lock()
+	defer logHealth.Unlock()
+
+	if err == nil {
+		logHealth.err = nil
+		return
+	}
+
+	logHealth.err = err
+	logHealth.lost += uint64(n)
+	logHealth.at = time.Now()
+}
+
+// writeWithRetry writes b to the transaction log file, retrying failed writes with an exponential
+// backoff, and continuing partial writes where they stopped. It returns the number of bytes written.
+func writeWithRetry(file *os.File, b []byte) (int, error) {
+	written := 0
+	delay := logWriteRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		n, err := writeLogFile(file, b[written:])
+		written += n
+		if err == nil {
+			return written, nil
+		}
+
+		if attempt == logWriteMaxAttempts {
+			return written, fmt.Errorf("giving up after %d attempts. %w", attempt, err)
+		}
+
+		log.Printf("Error occurred while writing the transaction log, retrying in %v: %v", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// drainLogErrors logs and counts the errors of the transaction log until the context is done, so that they
+// don't go unnoticed and the logger never has to drop them.
+func drainLogErrors(ctx context.Context, errs <-chan error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errs:
+			n := atomic.AddUint64(&logErrors, 1)
+			log.Printf("Error occurred while writing the transaction log: %v", err)
+
+			if config.maxLogErrors > 0 && n == uint64(config.maxLogErrors) {
+				log.Printf("The transaction log failed %d times, yakv stays unhealthy until it's restarted", n)
+			}
+		}
+	}
+}
+
+// tooManyLogErrors returns whether the transaction log failed at least -max-log-errors times.
+func tooManyLogErrors() bool {
+	return config.maxLogErrors > 0 && atomic.LoadUint64(&logErrors) >= uint64(config.maxLogErrors)
+}
+
+// Health is the health of yakv, as reported by the health endpoint.
+type Health struct {
+	Healthy    bool       `json:"healthy"`
+	LogError   string     `json:"log_error,omitempty"`
+	LostEvents uint64     `json:"lost_events,omitempty"`
+	LogErrors  uint64     `json:"log_errors,omitempty"`
+	FailedAt   *time.Time `json:"failed_at,omitempty"`
+
+	AutoReadOnly *AutoReadOnly `json:"auto_read_only,omitempty"`
+}
+
+// currentHealth returns the current health of yakv. yakv is unhealthy while the transaction log can't be
+// written, and for good once it failed -max-log-errors times.
+func currentHealth() Health {
+	logHealth.RLock()
+	defer logHealth.RUnlock()
+
+	h := Health{Healthy: logHealth.err == nil && !tooManyLogErrors(), LostEvents: logHealth.lost, LogErrors: atomic.LoadUint64(&logErrors)}
+	h.AutoReadOnly, _ = autoReadOnlyState()
+	if logHealth.err != nil {
+		h.LogError = logHealth.err.Error()
+		at := logHealth.at
+		h.FailedAt = &at
+	}
+
+	return h
+}
+
+// HealthHandler is a handler function for the health endpoint. It responds with 503 Service Unavailable
+// while the transaction log can't be written.
+func HealthHandler(rw http.ResponseWriter, r *http.Request) {
+	h := currentHealth()
+
+	rw.Header().Set("Content-Type", "application/json")
+	if !h.Healthy {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(rw).Encode(h); err != nil {
+		log.Println(err.Error())
+	}
+}
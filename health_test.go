@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Helper function for failing the given number of writes to the transaction log, a negative number
+// failing every write.
+func failLogWrites(failures int) {
+	writeLogFile = func(f *os.File, b []byte) (int, error) {
+		if failures == 0 {
+			return f.Write(b)
+		}
+		failures--
+
+		// A single byte of the batch makes it to the disk before it fills up.
+		n, _ := f.Write(b[:1])
+		return n, syscall.ENOSPC
+	}
+}
+
+// Function for testing that transient write errors are retried, and persistent ones make yakv unhealthy.
+func TestLogWriteRetry(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-retry.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer func() { writeLogFile = (*os.File).Write }()
+	defer func(delay time.Duration) { logWriteRetryDelay = delay }(logWriteRetryDelay)
+	defer setLogHealth(nil, 0)
+	logWriteRetryDelay = time.Millisecond
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactionLogger.Log()
+
+	// A transient error is retried, completing the partial write.
+	failLogWrites(logWriteMaxAttempts - 1)
+	transactionLogger.WritePut("yakv1", "yak1")
+	transactionLogger.Wait()
+
+	if h := currentHealth(); !h.Healthy {
+		t.Errorf("Expected yakv to stay healthy after a transient error, got %+v", h)
+	}
+
+	// A persistent error gives up on the event, without wedging the logger.
+	failLogWrites(-1)
+	transactionLogger.WritePut("yakv2", "yak2")
+	transactionLogger.WritePut("yakv3", "yak3")
+	transactionLogger.Wait()
+
+	if err := <-transactionLogger.Err(); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("Expected the write error to be surfaced, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	HealthHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while the log can't be written, got %d", rec.Code)
+	}
+
+	// Writes recover once the disk does.
+	failLogWrites(0)
+	transactionLogger.WritePut("yakv4", "yak4")
+	transactionLogger.Wait()
+
+	if h := currentHealth(); !h.Healthy || h.LostEvents != 2 {
+		t.Errorf("Expected yakv to recover with 2 lost events, got %+v", h)
+	}
+
+	if err := transactionLogger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The torn line is skipped in repair mode, and every other event is read back.
+	config.repairLog = true
+	defer func() { config.repairLog = false }()
+
+	transactionLogger2, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transactionLogger2.Close()
+
+	var keys []string
+	events, errs := transactionLogger2.ReadEvents()
+	for e := range events {
+		keys = append(keys, e.Key)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2 || keys[0] != "yakv1" || keys[1] != "yakv4" {
+		t.Errorf("Expected the events which were written, got %v", keys)
+	}
+}
+
+// Function for testing that drained errors are counted, and that yakv stays unhealthy once too many were drained.
+func TestDrainLogErrors(t *testing.T) {
+	// Restore to original state after test.
+	defer func(max int) { config.maxLogErrors = max }(config.maxLogErrors)
+	defer atomic.StoreUint64(&logErrors, 0)
+	config.maxLogErrors = 2
+	atomic.StoreUint64(&logErrors, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		drainLogErrors(ctx, errs)
+		close(done)
+	}()
+
+	// The drainer reads the configuration, so it has to stop before the configuration is restored.
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The unbuffered sends only return once the drainer received the errors.
+	errs <- syscall.ENOSPC
+	errs <- syscall.EIO
+	errs <- syscall.EIO
+
+	// Reading a fourth error makes sure the third one was counted.
+	select {
+	case errs <- syscall.EIO:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the drainer to keep draining")
+	}
+
+	// The log itself is healthy again, but failed too often.
+	if h := currentHealth(); h.Healthy || h.LogErrors < 3 {
+		t.Errorf("Expected yakv to stay unhealthy after 3 errors, got %+v", h)
+	}
+}
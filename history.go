@@ -0,0 +1,153 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Default maximum number of events returned by the history of a key.
+const defaultHistoryLimit = 100
+
+// Maximum number of events the history of a key is limited to, whatever the requested limit.
+const maxHistoryLimit = 1000
+
+// errBoltHistory is raised when the history of a key is requested from the BoltDB backend.
+var errBoltHistory = errors.New("the bolt backend keeps no history of keys")
+
+// History returns up to limit of the latest events of key in namespace, oldest first, and whether earlier events
+// were left out. Dropping the namespace is part of the history of its keys. The whole transaction log is read,
+// so it takes time proportional to the size of the log, and events compacted away are gone.
+func History(namespace, key string, limit int) ([]Event, bool, error) {
+	if config.backend == boltBackend {
+		return nil, false, errBoltHistory
+	}
+
+	// Pending events have to be in the file before reading it.
+	logger.Wait()
+
+	reader, err := NewTransactionLogger(transactionLogFilename)
+	if err != nil {
+		return nil, false, err
+	}
+	defer reader.Close()
+
+	// The latest events are kept in a ring, so that a hot key doesn't hold every one of its events in memory.
+	// The ring grows with the events, so that a large limit doesn't allocate it up front.
+	var ring []Event
+	n := 0
+
+	events, errs := reader.ReadEvents()
+	for e := range events {
+		if !inHistory(e, namespace, key) {
+			continue
+		}
+
+		if len(ring) < limit {
+			ring = append(ring, e)
+		} else {
+			ring[n%limit] = e
+		}
+		n++
+	}
+	if err := <-errs; err != nil {
+		return nil, false, err
+	}
+
+	if n <= limit {
+		return ring[:n], false, nil
+	}
+
+	history := make([]Event, 0, limit)
+	history = append(history, ring[n%limit:]...)
+	history = append(history, ring[:n%limit]...)
+
+	return history, true, nil
+}
+
+// inHistory reports whether an event is part of the history of key in namespace.
+func inHistory(e Event, namespace, key string) bool {
+	if e.Namespace != namespace || e.Database != 0 {
+		return false
+	}
+	if e.EventType == EventDropNamespace {
+		return namespace != ""
+	}
+
+	return e.Key == key
+}
+
+// HistoryHandler is a handler function for the admin endpoint listing the events of a key in the transaction log.
+func HistoryHandler(rw http.ResponseWriter, r *http.Request) {
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	key, err := decodeKey(binary, query.Get("key"))
+	if err == nil {
+		err = validateKey(key)
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			writeError(rw, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	history, truncated, err := History(query.Get("namespace"), key, limit)
+	if errors.Is(err, errBoltHistory) {
+		writeError(rw, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := struct {
+		Events    []StreamEvent `json:"events"`
+		Truncated bool          `json:"truncated"`
+	}{Events: make([]StreamEvent, 0, len(history)), Truncated: truncated}
+
+	for _, e := range history {
+		se, err := streamEvent(e, binary)
+		if err != nil {
+			writeError(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Events = append(resp.Events, se)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		log.Println(err)
+	}
+}
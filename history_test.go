@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that the history of a key lists its events in order, limited to the latest ones.
+func TestHistoryHandler(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-history.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(filename string) { transactionLogFilename = filename }(transactionLogFilename)
+	transactionLogFilename = filename
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	logger.WritePut("yakv", "v1")
+	logger.WritePut("other", "v1")
+	logger.WritePut("yakv", "v2")
+	logger.WriteDelete("yakv")
+	logger.WriteNamespacePut("users", "yakv", "alice")
+	logger.WriteDropNamespace("users")
+
+	history := func(query string) (int, []StreamEvent, bool) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/admin/history?"+query, nil))
+
+		var resp struct {
+			Events    []StreamEvent `json:"events"`
+			Truncated bool          `json:"truncated"`
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, resp.Events, resp.Truncated
+	}
+
+	describe := func(events []StreamEvent) string {
+		var s []string
+		for _, e := range events {
+			s = append(s, e.Type+":"+e.Value)
+		}
+		return strings.Join(s, ",")
+	}
+
+	code, events, truncated := history("key=yakv")
+	if code != http.StatusOK || describe(events) != "put:v1,put:v2,delete:" || truncated {
+		t.Fatalf("Expected the events of the key in the default store, got %d %s %t", code, describe(events), truncated)
+	}
+	if events[0].ID != 1 || events[1].ID != 3 || events[2].ID != 4 {
+		t.Errorf("Expected the IDs 1, 3 and 4, got %d, %d and %d", events[0].ID, events[1].ID, events[2].ID)
+	}
+
+	// Only the latest events are returned with a limit, oldest first.
+	if code, events, truncated := history("key=yakv&limit=2"); code != http.StatusOK || describe(events) != "put:v2,delete:" || !truncated {
+		t.Errorf("Expected the latest 2 events, got %d %s %t", code, describe(events), truncated)
+	}
+
+	// A huge limit is capped instead of being allocated.
+	if code, events, truncated := history("key=yakv&limit=1000000000000"); code != http.StatusOK || describe(events) != "put:v1,put:v2,delete:" || truncated {
+		t.Errorf("Expected every event with a huge limit, got %d %s %t", code, describe(events), truncated)
+	}
+
+	// Dropping the namespace is part of the history of its keys.
+	if code, events, _ := history("key=yakv&namespace=users"); code != http.StatusOK || describe(events) != "put:alice,drop_namespace:" {
+		t.Errorf("Expected the events of the key in the namespace, got %d %s", code, describe(events))
+	}
+
+	if code, events, _ := history("key=missing"); code != http.StatusOK || len(events) != 0 {
+		t.Errorf("Expected no events for a key which never existed, got %d %s", code, describe(events))
+	}
+	if code, _, _ := history("key="); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty key, got %d", code)
+	}
+	if code, _, _ := history("key=yakv&limit=0"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a limit of 0, got %d", code)
+	}
+}
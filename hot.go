@@ -0,0 +1,132 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default number of keys returned by the hot keys endpoint.
+const defaultHotLimit = 20
+
+// Number of successful reads of each key since start-up, when tracking accesses. Values are *uint64,
+// incremented atomically so that reads don't contend on a lock.
+var accessCounts sync.Map
+
+// ErrorAccessTrackingDisabled is returned when listing hot keys without -track-access.
+var ErrorAccessTrackingDisabled = errors.New("access tracking is disabled, see -track-access")
+
+// recordAccess counts a successful read of key, and records when it happened, when tracking accesses.
+func recordAccess(key string) {
+	if !config.trackAccess {
+		return
+	}
+
+	count, ok := accessCounts.Load(key)
+	if !ok {
+		count, _ = accessCounts.LoadOrStore(key, new(uint64))
+	}
+	atomic.AddUint64(count.(*uint64), 1)
+
+	recordLastAccess(key, time.Now())
+}
+
+// KeyCount is a key along with the number of times it was read.
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+}
+
+// Hot returns up to limit of the most read keys of the default store, most read first. Counters of
+// keys which don't exist anymore are removed along the way.
+func Hot(limit int) ([]KeyCount, error) {
+	if !config.trackAccess {
+		return nil, ErrorAccessTrackingDisabled
+	}
+
+	now := time.Now()
+	counts := make([]KeyCount, 0)
+
+	store.RLock()
+	accessCounts.Range(func(key, count interface{}) bool {
+		k := key.(string)
+
+		_, ok := store.m[k]
+		if expiresAt, expires := store.expiry[k]; !ok || (expires && hasExpired(k, expiresAt, now)) {
+			accessCounts.Delete(k)
+			return true
+		}
+
+		counts = append(counts, KeyCount{Key: k, Count: atomic.LoadUint64(count.(*uint64))})
+		return true
+	})
+	store.RUnlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Key < counts[j].Key
+	})
+
+	if len(counts) > limit {
+		counts = counts[:limit]
+	}
+
+	return counts, nil
+}
+
+// HotHandler is a handler function for the endpoint listing the most read keys.
+func HotHandler(rw http.ResponseWriter, r *http.Request) {
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultHotLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			writeError(rw, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	counts, err := Hot(limit)
+	if errors.Is(err, ErrorAccessTrackingDisabled) {
+		writeError(rw, err.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	for i := range counts {
+		counts[i].Key = encodeWire(binary, counts[i].Key)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(struct {
+		Keys []KeyCount `json:"keys"`
+	}{counts}); err != nil {
+		log.Println(err.Error())
+	}
+}
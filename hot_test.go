@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that the most read keys are listed first, with their number of reads.
+func TestHot(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-hot.log")()
+	defer resetStores()
+	defer accessCounts.Range(func(key, _ interface{}) bool {
+		accessCounts.Delete(key)
+		return true
+	})
+	defer func() { config.trackAccess = false }()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	// Without tracking, hot keys aren't available.
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/hot", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without access tracking, got %d", rec.Code)
+	}
+
+	config.trackAccess = true
+	for _, key := range []string{"a", "b", "c", "gone"} {
+		Put(key, "hello, yakv!")
+	}
+
+	// Reads are counted concurrently.
+	var wg sync.WaitGroup
+	for key, reads := range map[string]int{"a": 30, "b": 10, "c": 20, "gone": 50} {
+		for i := 0; i < reads; i++ {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				Get(key)
+			}(key)
+		}
+	}
+	wg.Wait()
+
+	// Missing keys aren't counted, and deleted keys aren't listed.
+	Get("missing")
+	Delete("gone")
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/hot?limit=2", nil))
+	expected := `{"keys":[{"key":"a","count":30},{"key":"c","count":20}]}`
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != expected {
+		t.Errorf("Expected %s, got %d %q", expected, rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default maximum number of keys returned by the idle keys endpoint.
+const defaultIdleLimit = 1000
+
+// Time of the last successful read of each key since start-up, when tracking accesses. Values are *int64
+// holding Unix nanoseconds, stored atomically so that reads don't contend on a lock.
+var lastAccess sync.Map
+
+// Time accesses are tracked since. Keys which weren't read since count as idle since then.
+var accessTrackingSince = time.Now()
+
+// recordLastAccess records now as the time of the last read of key.
+func recordLastAccess(key string, now time.Time) {
+	at, ok := lastAccess.Load(key)
+	if !ok {
+		at, _ = lastAccess.LoadOrStore(key, new(int64))
+	}
+	atomic.StoreInt64(at.(*int64), now.UnixNano())
+}
+
+// IdleKey is a key along with the time it was last read, if it was read since start-up.
+type IdleKey struct {
+	Key        string     `json:"key"`
+	LastAccess *time.Time `json:"last_access,omitempty"`
+}
+
+// Idle returns up to limit of the keys of the default store which weren't read since cutoff, least recently read
+// first, and whether more keys are idle. Keys which weren't read since start-up come first. Timestamps of keys
+// which don't exist anymore are removed along the way.
+func Idle(cutoff time.Time, limit int) ([]IdleKey, bool, error) {
+	if !config.trackAccess {
+		return nil, false, ErrorAccessTrackingDisabled
+	}
+
+	now := time.Now()
+	idle := make([]IdleKey, 0)
+
+	store.RLock()
+	for key := range store.m {
+		// Skip keys which have expired but haven't been swept yet.
+		if expiresAt, ok := store.expiry[key]; ok && hasExpired(key, expiresAt, now) {
+			continue
+		}
+
+		at, ok := lastAccess.Load(key)
+		if !ok {
+			if accessTrackingSince.Before(cutoff) {
+				idle = append(idle, IdleKey{Key: key})
+			}
+			continue
+		}
+
+		lastRead := time.Unix(0, atomic.LoadInt64(at.(*int64)))
+		if lastRead.Before(cutoff) {
+			idle = append(idle, IdleKey{Key: key, LastAccess: &lastRead})
+		}
+	}
+
+	lastAccess.Range(func(key, _ interface{}) bool {
+		if _, ok := store.m[key.(string)]; !ok {
+			lastAccess.Delete(key)
+		}
+		return true
+	})
+	store.RUnlock()
+
+	sort.Slice(idle, func(i, j int) bool {
+		a, b := idle[i].LastAccess, idle[j].LastAccess
+		switch {
+		case a == nil && b == nil:
+			return idle[i].Key < idle[j].Key
+		case a == nil || b == nil:
+			return a == nil
+		case !a.Equal(*b):
+			return a.Before(*b)
+		}
+		return idle[i].Key < idle[j].Key
+	})
+
+	if len(idle) > limit {
+		return idle[:limit], true, nil
+	}
+
+	return idle, false, nil
+}
+
+// IdleHandler is a handler function for the endpoint listing the keys which weren't read for a while.
+func IdleHandler(rw http.ResponseWriter, r *http.Request) {
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+
+	olderThan, err := time.ParseDuration(query.Get("older-than"))
+	if err != nil || olderThan <= 0 {
+		writeError(rw, "older-than must be a positive duration, e.g. 24h", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultIdleLimit
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			writeError(rw, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	keys, more, err := Idle(time.Now().Add(-olderThan), limit)
+	if errors.Is(err, ErrorAccessTrackingDisabled) {
+		writeError(rw, err.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	for i := range keys {
+		keys[i].Key = encodeWire(binary, keys[i].Key)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(struct {
+		Keys  []IdleKey `json:"keys"`
+		Since time.Time `json:"since"`
+		More  bool      `json:"more"`
+	}{keys, accessTrackingSince, more}); err != nil {
+		log.Println(err.Error())
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Helper function for forgetting the last reads of every key.
+func resetLastAccess() {
+	lastAccess.Range(func(key, _ interface{}) bool {
+		lastAccess.Delete(key)
+		return true
+	})
+}
+
+// Function for testing that keys which weren't read since the cutoff are listed, least recently read first.
+func TestIdle(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-idle.log")()
+	defer resetStores()
+	defer resetLastAccess()
+	defer func(since time.Time) { accessTrackingSince = since }(accessTrackingSince)
+	defer func() { config.trackAccess = false }()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	type response struct {
+		Keys []IdleKey `json:"keys"`
+		More bool      `json:"more"`
+	}
+	idle := func(query string) (int, response) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/idle?"+query, nil))
+
+		var resp response
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, resp
+	}
+
+	// Without tracking, idle keys aren't available.
+	if code, _ := idle("older-than=1h"); code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without access tracking, got %d", code)
+	}
+
+	config.trackAccess = true
+	resetLastAccess()
+	now := time.Now()
+	accessTrackingSince = now.Add(-2 * time.Hour)
+	for _, key := range []string{"a", "b", "c", "gone"} {
+		Put(key, "hello, yakv!")
+	}
+
+	// a was read a while ago, b and gone just now, and c wasn't read since tracking started.
+	recordLastAccess("a", now.Add(-90*time.Minute))
+	Get("b")
+	Get("gone")
+	Delete("gone")
+
+	code, resp := idle("older-than=1h")
+	if code != http.StatusOK || len(resp.Keys) != 2 || resp.More {
+		t.Fatalf("Expected 2 idle keys, got %d %+v", code, resp)
+	}
+	if resp.Keys[0].Key != "c" || resp.Keys[0].LastAccess != nil {
+		t.Errorf("Expected c, which wasn't read, to come first, got %+v", resp.Keys[0])
+	}
+	if resp.Keys[1].Key != "a" || resp.Keys[1].LastAccess == nil || !resp.Keys[1].LastAccess.Equal(now.Add(-90*time.Minute)) {
+		t.Errorf("Expected a along with its last read, got %+v", resp.Keys[1])
+	}
+
+	// Timestamps of deleted keys are dropped when listing.
+	if _, ok := lastAccess.Load("gone"); ok {
+		t.Error("Expected the timestamp of a deleted key to be dropped.")
+	}
+
+	// Pages are limited, and keys read since the cutoff or since tracking started aren't idle.
+	if code, resp := idle("older-than=1h&limit=1"); code != http.StatusOK || len(resp.Keys) != 1 || resp.Keys[0].Key != "c" || !resp.More {
+		t.Errorf("Expected a page with c only, got %d %+v", code, resp)
+	}
+	if code, resp := idle("older-than=3h"); code != http.StatusOK || len(resp.Keys) != 0 {
+		t.Errorf("Expected no keys idle for 3h, got %d %+v", code, resp)
+	}
+
+	for _, query := range []string{"", "older-than=soon", "older-than=-1h", "older-than=1h&limit=0"} {
+		if code, _ := idle(query); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, code)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that keys differing only in case address the same entry with -case-insensitive-keys.
+func TestCaseInsensitiveKeys(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-case.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func() { config.caseInsensitiveKeys = false }()
+	config.caseInsensitiveKeys = true
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(`{"key": "User:Alice", "value": "hello, yakv!"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/get", strings.NewReader(`{"key": "USER:ALICE"}`)))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello, yakv!" {
+		t.Errorf("Expected the value regardless of case, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	if _, err := Get("user:alice"); err != nil {
+		t.Errorf("Expected the key to be stored lowercased, got %v", err)
+	}
+
+	// The event is logged with the normalized key, so replays address the same entry.
+	logger.Wait()
+	logger2, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger2.Close()
+
+	events, errs := logger2.ReadEvents()
+	for e := range events {
+		if e.Key != "user:alice" {
+			t.Errorf("Expected the logged key to be lowercased, got %q", e.Key)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/yakv/v0/delete", strings.NewReader(`{"key": "user:ALICE"}`)))
+	if _, err := Get("User:Alice"); err == nil {
+		t.Error("Expected the key to be deleted regardless of case.")
+	}
+}
+
+// Function for testing that only ASCII letters are lowercased in keys which aren't valid UTF-8.
+func TestNormalizeBinaryKey(t *testing.T) {
+	// Restore to original state after test.
+	defer func() { config.caseInsensitiveKeys = false }()
+	config.caseInsensitiveKeys = true
+
+	if key := normalizeKey("Ab\xffÉ"); key != "ab\xffÉ" {
+		t.Errorf("Expected only ASCII letters to be lowercased, got %q", key)
+	}
+
+	if key := normalizeKey("ÀB"); key != "àb" {
+		t.Errorf("Expected UTF-8 keys to be lowercased, got %q", key)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// Number of locks writes of keys are spread across.
+const keyLockStripes = 256
+
+// keyStripe is a lock serializing the writes of the keys hashing to it, from modifying the store until logging
+// the change, which is done after releasing the store's lock.
+type keyStripe struct {
+	mu   sync.Mutex
+	held int32 // Whether a write of a single key holds the stripe, and may not have logged its change yet.
+}
+
+// Lock locks the stripe for a write of one of its keys.
+func (s *keyStripe) Lock() {
+	s.mu.Lock()
+	atomic.StoreInt32(&s.held, 1)
+}
+
+// Unlock unlocks the stripe once the write has logged its change.
+func (s *keyStripe) Unlock() {
+	atomic.StoreInt32(&s.held, 0)
+	s.mu.Unlock()
+}
+
+// Striped locks serializing writes of the same key, while writes of different keys mostly take different locks
+// and proceed in parallel.
+var keyLocks [keyLockStripes]keyStripe
+
+// keyLock returns the stripe key belongs to. The stripe is locked after changes and before the store's lock,
+// and never while holding another stripe, except through lockKeys and lockAllKeys.
+func keyLock(key string) *keyStripe {
+	return &keyLocks[keyStripeIndex(key)]
+}
+
+// keyStripeIndex returns the index of the stripe key belongs to.
+func keyStripeIndex(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return int(h.Sum32() % keyLockStripes)
+}
+
+// lockKeys locks the stripes of a write of several keys, in the order of the stripes so that two such writes
+// can't deadlock, and returns the function unlocking them.
+func lockKeys(keys ...string) func() {
+	var locked [keyLockStripes]bool
+	for _, key := range keys {
+		locked[keyStripeIndex(key)] = true
+	}
+
+	for i := range keyLocks {
+		if locked[i] {
+			keyLocks[i].Lock()
+		}
+	}
+
+	return func() {
+		for i := len(keyLocks) - 1; i >= 0; i-- {
+			if locked[i] {
+				keyLocks[i].Unlock()
+			}
+		}
+	}
+}
+
+// lockAllKeys locks every stripe, for writes spanning the whole store like flushes, bulk deletes, loads and
+// transforms, and returns the function unlocking them. No write of a single key is in progress until then.
+func lockAllKeys() func() {
+	for i := range keyLocks {
+		keyLocks[i].mu.Lock()
+	}
+
+	return func() {
+		for i := len(keyLocks) - 1; i >= 0; i-- {
+			keyLocks[i].mu.Unlock()
+		}
+	}
+}
+
+// keyBeingWritten reports whether a write of a single key other than the current one may have modified key
+// without logging the change yet. Such keys aren't evicted or swept, since their delete would be logged before
+// the change and a restart would bring them back. The caller must hold the store's lock, and the stripe of
+// current unless it's empty.
+func keyBeingWritten(key, current string) bool {
+	s := keyLock(key)
+	if current != "" && s == keyLock(current) {
+		return false
+	}
+
+	return atomic.LoadInt32(&s.held) == 1
+}
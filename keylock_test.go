@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Function for testing that keys are spread across the stripes, and a key always maps to the same stripe.
+func TestKeyLockStripes(t *testing.T) {
+	stripes := make(map[*keyStripe]bool)
+	for i := 0; i < 1000; i++ {
+		key := "yakv" + strconv.Itoa(i)
+		if keyLock(key) != keyLock(key) {
+			t.Fatalf("Expected %q to always map to the same stripe", key)
+		}
+		stripes[keyLock(key)] = true
+	}
+
+	if len(stripes) < keyLockStripes/2 {
+		t.Errorf("Expected 1000 keys to be spread across most of the %d stripes, got %d", keyLockStripes, len(stripes))
+	}
+}
+
+// Function for testing that keys being written by another write aren't evicted, since their delete could be
+// logged before the write.
+func TestEvictionSkipsKeysBeingWritten(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-evict-written.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(maxKeys int) { config.maxKeys = maxKeys }(config.maxKeys)
+	config.maxKeys = 2
+
+	if keyLock("a") == keyLock("c") {
+		t.Fatal("Expected a and c to belong to different stripes")
+	}
+
+	for _, key := range []string{"a", "b"} {
+		if err := putStored(key, "hello, yakv!", false, time.Time{}, "", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a is the least recently used key, but a write of it holds its stripe.
+	stripe := keyLock("a")
+	stripe.Lock()
+	if !keyBeingWritten("a", "") || keyBeingWritten("a", "a") {
+		t.Error("Expected a to be reported as being written, except by a write of a")
+	}
+	err := putStored("c", "hello, yakv!", false, time.Time{}, "", false)
+	stripe.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, _ := Keys("", "", defaultKeysLimit); strings.Join(keys, ",") != "a,c" {
+		t.Errorf("Expected b to be evicted instead of a, got %v", keys)
+	}
+	if keyBeingWritten("a", "") {
+		t.Error("Expected a not to be reported as being written once its stripe is unlocked")
+	}
+}
+
+// Helper function for benchmarking concurrent writes of distinct keys, the way PutHandler writes them,
+// each write holding the lock returned by lockFor from modifying the store until logging the change.
+func benchmarkConcurrentPuts(b *testing.B, lockFor func(key string) sync.Locker) {
+	// Temporary log filename.
+	const filename = "temp-bench-puts.log"
+
+	// Restore to original state after benchmark.
+	defer os.Remove(filename)
+	defer resetStores()
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+	transactionLogger.Log()
+	defer transactionLogger.Close()
+
+	var next uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			key := "yakv" + strconv.FormatUint(atomic.AddUint64(&next, 1)%100000, 10)
+
+			lock := lockFor(key)
+			lock.Lock()
+			if err := putStored(key, "hello, yakv!", false, time.Time{}, "", false); err != nil {
+				b.Error(err)
+			}
+			transactionLogger.WritePut(key, "hello, yakv!")
+			lock.Unlock()
+		}
+	})
+	transactionLogger.Wait()
+}
+
+// Benchmark for concurrent writes of distinct keys serialized by a single lock.
+func BenchmarkConcurrentPutsSingleLock(b *testing.B) {
+	var mu sync.Mutex
+	benchmarkConcurrentPuts(b, func(string) sync.Locker { return &mu })
+}
+
+// Benchmark for concurrent writes of distinct keys serialized by the stripe of their key.
+func BenchmarkConcurrentPutsStriped(b *testing.B) {
+	benchmarkConcurrentPuts(b, func(key string) sync.Locker { return keyLock(key) })
+}
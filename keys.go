@@ -0,0 +1,430 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default number of keys returned when listing keys.
+const defaultKeysLimit = 1000
+
+// Default maximum number of keys whose values are returned by a single /getall.
+const defaultMaxGetAllKeys = 1000
+
+// errTooManyKeys is raised when more keys match a /getall than allowed.
+var errTooManyKeys = errors.New("too many keys start with the prefix")
+
+// KeyValue is a key along with its value.
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// pageKeys returns up to limit keys of the store starting with prefix and sorting strictly after
+// the key after, in sorted order, and whether more keys follow. The caller must hold the store's lock.
+func pageKeys(prefix, after string, limit int) ([]string, bool) {
+	now := time.Now()
+
+	keys := make([]string, 0)
+	for key := range store.m {
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			continue
+		}
+
+		// Skip keys which have expired but haven't been swept yet.
+		if expiresAt, ok := store.expiry[key]; ok && hasExpired(key, expiresAt, now) {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	if len(keys) > limit {
+		return keys[:limit], true
+	}
+
+	return keys, false
+}
+
+// storeEntry is a key along with its value as it is stored, i.e. compressed or not.
+type storeEntry struct {
+	key        string
+	value      string
+	compressed bool
+}
+
+// copyEntries copies up to max of the entries of the store whose keys start with prefix and sort strictly
+// after the key after, in sorted order, and returns whether more entries match. A max of 0 copies every
+// matching entry. The lock is only held while copying, and the copy is shallow since strings are immutable,
+// so the entries are a snapshot of the store at the time of the copy.
+func copyEntries(prefix, after string, max int) ([]storeEntry, bool) {
+	now := time.Now()
+	more := false
+
+	store.RLock()
+	entries := make([]storeEntry, 0)
+	for key, value := range store.m {
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			continue
+		}
+
+		// Skip keys which have expired but haven't been swept yet.
+		if expiresAt, ok := store.expiry[key]; ok && hasExpired(key, expiresAt, now) {
+			continue
+		}
+
+		entries = append(entries, storeEntry{key: key, value: value, compressed: store.compressed[key]})
+
+		// Only the entries sorting first are kept, so that the copy doesn't grow with the store.
+		if max > 0 && len(entries) == 2*max {
+			sortEntries(entries)
+			entries, more = entries[:max], true
+		}
+	}
+	store.RUnlock()
+
+	sortEntries(entries)
+	if max > 0 && len(entries) > max {
+		entries, more = entries[:max], true
+	}
+
+	return entries, more
+}
+
+// sortEntries sorts entries by key.
+func sortEntries(entries []storeEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+}
+
+// decode returns the entry as a key-value pair, decompressing its value if needed.
+func (e storeEntry) decode() (KeyValue, error) {
+	if !e.compressed {
+		return KeyValue{Key: e.key, Value: e.value}, nil
+	}
+
+	value, err := decompressValue(e.value)
+	return KeyValue{Key: e.key, Value: value}, err
+}
+
+// Keys returns up to limit keys starting with prefix and sorting after the key after, in sorted order,
+// and whether more keys follow.
+func Keys(prefix, after string, limit int) ([]string, bool) {
+	if config.copyOnRead {
+		entries, more := copyEntries(prefix, after, limit)
+
+		keys := make([]string, 0, len(entries))
+		for _, e := range entries {
+			keys = append(keys, e.key)
+		}
+
+		return keys, more
+	}
+
+	store.RLock()
+	defer store.RUnlock()
+
+	return pageKeys(prefix, after, limit)
+}
+
+// Scan returns up to limit key-value pairs whose keys start with prefix and sort after the key after,
+// in sorted order, and whether more pairs follow.
+func Scan(prefix, after string, limit int) ([]KeyValue, bool, error) {
+	if config.copyOnRead {
+		entries, more := copyEntries(prefix, after, limit)
+
+		// Values are decompressed without holding the lock.
+		items := make([]KeyValue, 0, len(entries))
+		for _, e := range entries {
+			item, err := e.decode()
+			if err != nil {
+				return nil, false, err
+			}
+			items = append(items, item)
+		}
+
+		return items, more, nil
+	}
+
+	store.RLock()
+	defer store.RUnlock()
+
+	keys, more := pageKeys(prefix, after, limit)
+
+	items := make([]KeyValue, 0, len(keys))
+	for _, key := range keys {
+		value := store.m[key]
+
+		if store.compressed[key] {
+			var err error
+			if value, err = decompressValue(value); err != nil {
+				return nil, false, err
+			}
+		}
+
+		items = append(items, KeyValue{Key: key, Value: value})
+	}
+
+	return items, more, nil
+}
+
+// GetAll returns the values of every key starting with prefix, read under a single lock so that they are
+// consistent with each other. It fails with errTooManyKeys once more than limit keys match, 0 meaning no limit.
+func GetAll(prefix string, limit int) (map[string]string, error) {
+	now := time.Now()
+
+	store.RLock()
+	defer store.RUnlock()
+
+	values := make(map[string]string)
+	for key, value := range store.m {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		// Skip keys which have expired but haven't been swept yet.
+		if expiresAt, ok := store.expiry[key]; ok && hasExpired(key, expiresAt, now) {
+			continue
+		}
+
+		if limit > 0 && len(values) >= limit {
+			return nil, errTooManyKeys
+		}
+
+		if store.compressed[key] {
+			var err error
+			if value, err = decompressValue(value); err != nil {
+				return nil, err
+			}
+		}
+
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// Count returns the number of keys starting with prefix. It scans every key of the store.
+func Count(prefix string) int {
+	now := time.Now()
+
+	store.RLock()
+	defer store.RUnlock()
+
+	n := 0
+	for key := range store.m {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		// Skip keys which have expired but haven't been swept yet.
+		if expiresAt, ok := store.expiry[key]; ok && hasExpired(key, expiresAt, now) {
+			continue
+		}
+
+		n++
+	}
+
+	return n
+}
+
+// encodeCursor encodes the last key of a page into an opaque cursor.
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// decodeCursor decodes a cursor created by encodeCursor, an empty cursor meaning the first page.
+func decodeCursor(cursor string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errors.New("after must be a cursor returned by a previous request")
+	}
+
+	return string(key), nil
+}
+
+// parsePageQuery parses the prefix, after and limit query parameters of a paginated request.
+// With base64 encoding, the prefix is base64-encoded. For tenants, both are scoped to the tenant's prefix.
+func parsePageQuery(r *http.Request, binary bool) (prefix, after string, limit int, err error) {
+	query := r.URL.Query()
+
+	limit = defaultKeysLimit
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			return "", "", 0, errors.New("limit must be a positive integer")
+		}
+	}
+
+	if after, err = decodeCursor(query.Get("after")); err != nil {
+		return "", "", 0, err
+	}
+
+	if prefix, err = decodeKey(binary, query.Get("prefix")); err != nil {
+		return "", "", 0, err
+	}
+
+	return tenantPrefix(r) + prefix, scopeKey(r, after), limit, nil
+}
+
+// KeysHandler is a handler function for the endpoint listing keys.
+func KeysHandler(rw http.ResponseWriter, r *http.Request) {
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prefix, after, limit, err := parsePageQuery(r, binary)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	keys, more := Keys(prefix, after, limit)
+	for i := range keys {
+		keys[i] = unscopeKey(r, keys[i])
+	}
+
+	resp := struct {
+		Keys []string `json:"keys"`
+		Next string   `json:"next,omitempty"`
+	}{Keys: keys}
+
+	if more {
+		resp.Next = encodeCursor(keys[len(keys)-1])
+	}
+
+	for i := range resp.Keys {
+		resp.Keys[i] = encodeWire(binary, resp.Keys[i])
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// ScanHandler is a handler function for the endpoint listing key-value pairs.
+func ScanHandler(rw http.ResponseWriter, r *http.Request) {
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prefix, after, limit, err := parsePageQuery(r, binary)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	items, more, err := Scan(prefix, after, limit)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range items {
+		items[i].Key = unscopeKey(r, items[i].Key)
+	}
+
+	resp := struct {
+		Items []KeyValue `json:"items"`
+		Next  string     `json:"next,omitempty"`
+	}{Items: items}
+
+	if more {
+		resp.Next = encodeCursor(items[len(items)-1].Key)
+	}
+
+	for i := range resp.Items {
+		resp.Items[i] = KeyValue{Key: encodeWire(binary, resp.Items[i].Key), Value: encodeWire(binary, resp.Items[i].Value)}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// CountHandler is a handler function for the endpoint counting keys.
+func CountHandler(rw http.ResponseWriter, r *http.Request) {
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prefix, err := decodeKey(binary, r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(struct {
+		Count int `json:"count"`
+	}{Count(tenantPrefix(r) + prefix)}); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// GetAllHandler is a handler function for the endpoint getting the values of every key starting with a prefix.
+func GetAllHandler(rw http.ResponseWriter, r *http.Request) {
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prefix, err := decodeKey(binary, r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values, err := GetAll(tenantPrefix(r)+prefix, config.maxGetAllKeys)
+	if errors.Is(err, errTooManyKeys) {
+		writeError(rw, fmt.Sprintf("more than %d keys start with the prefix, use /scan instead", config.maxGetAllKeys), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make(map[string]string, len(values))
+	for key, value := range values {
+		resp[encodeWire(binary, unscopeKey(r, key))] = encodeWire(binary, value)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		log.Println(err.Error())
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Function for testing that following the cursors of /keys visits every key exactly once.
+func TestKeysPagination(t *testing.T) {
+	// Restore to original state after test.
+	defer resetStores()
+	resetStores()
+
+	var expected []string
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("page:%02d", i)
+		expected = append(expected, key)
+		Put(key, "value")
+	}
+	Put("other", "value")
+
+	var keys []string
+	url := "/yakv/v0/keys?prefix=page:&limit=10"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Pagination didn't terminate.")
+		}
+
+		rec := httptest.NewRecorder()
+		KeysHandler(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+		var resp struct {
+			Keys []string `json:"keys"`
+			Next string   `json:"next"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		keys = append(keys, resp.Keys...)
+		if resp.Next == "" {
+			break
+		}
+		url = "/yakv/v0/keys?prefix=page:&limit=10&after=" + resp.Next
+	}
+
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected %v, got %v", expected, keys)
+	}
+}
+
+// Function for testing a page of /scan.
+func TestScan(t *testing.T) {
+	// Restore to original state after test.
+	defer resetStores()
+	resetStores()
+
+	Put("scan:a", "1")
+	Put("scan:b", "2")
+	Put("scan:c", "3")
+
+	items, more, err := Scan("scan:", "scan:a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !more || len(items) != 1 || items[0] != (KeyValue{Key: "scan:b", Value: "2"}) {
+		t.Errorf("Unexpected page: %v, more: %t", items, more)
+	}
+
+	// Invalid cursors are rejected.
+	rec := httptest.NewRecorder()
+	ScanHandler(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/scan?after=***", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid cursor, got %d", rec.Code)
+	}
+}
+
+// Function for testing that copy-on-read listings match locked listings and are a snapshot of the store.
+func TestCopyOnRead(t *testing.T) {
+	// Restore to original state after test.
+	defer resetStores()
+	defer func() { config.copyOnRead = false }()
+	resetStores()
+
+	for i := 0; i < 10; i++ {
+		Put(fmt.Sprintf("cor:%02d", i), fmt.Sprint(i))
+	}
+
+	lockedKeys, lockedMore := Keys("cor:", "cor:03", 4)
+	lockedItems, _, _ := Scan("cor:", "", 5)
+
+	config.copyOnRead = true
+	copiedKeys, copiedMore := Keys("cor:", "cor:03", 4)
+	copiedItems, _, _ := Scan("cor:", "", 5)
+
+	if !reflect.DeepEqual(lockedKeys, copiedKeys) || lockedMore != copiedMore {
+		t.Errorf("Keys diverged: %v %t != %v %t", lockedKeys, lockedMore, copiedKeys, copiedMore)
+	}
+	if !reflect.DeepEqual(lockedItems, copiedItems) {
+		t.Errorf("Scan diverged: %v != %v", lockedItems, copiedItems)
+	}
+
+	// Writes after the copy don't show up in it.
+	entries, _ := copyEntries("cor:", "", 0)
+	Put("cor:00", "changed")
+	Delete("cor:01")
+	if entries[0].value != "0" || entries[1].key != "cor:01" {
+		t.Errorf("Copy was modified by later writes: %v", entries[:2])
+	}
+
+	// A bounded copy keeps the entries sorting first.
+	if entries, more := copyEntries("cor:", "", 3); len(entries) != 3 || entries[0].key != "cor:00" || entries[2].key != "cor:03" || !more {
+		t.Errorf("Expected the first 3 entries and more, got %v %t", entries, more)
+	}
+}
+
+// Function for testing counting the keys of a prefix.
+func TestCountHandler(t *testing.T) {
+	// Restore to original state after test.
+	defer resetStores()
+	resetStores()
+
+	Put("user:1", "value")
+	Put("user:2", "value")
+	Put("order:1", "value")
+	PutWithExpiry("user:3", "value", time.Now().Add(-time.Second))
+
+	for prefix, expected := range map[string]int{"user:": 2, "": 3, "none:": 0} {
+		rec := httptest.NewRecorder()
+		CountHandler(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/count?prefix="+prefix, nil))
+
+		var resp struct {
+			Count int `json:"count"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Count != expected {
+			t.Errorf("Expected %d keys for prefix %q, got %d", expected, prefix, resp.Count)
+		}
+	}
+}
+
+// Function for testing that /getall returns the values under a prefix as an object, up to a maximum number of keys.
+func TestGetAll(t *testing.T) {
+	// Restore to original state after test.
+	defer resetStores()
+	defer func(max int) { config.maxGetAllKeys = max }(config.maxGetAllKeys)
+	resetStores()
+
+	Put("user:123:name", "Ann")
+	Put("user:123:age", "30")
+	Put("user:1234:name", "Bob")
+	PutWithExpiry("user:123:session", "expired", time.Now().Add(-time.Second))
+
+	getAll := func(prefix string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		GetAllHandler(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/getall?prefix="+prefix, nil))
+		return rec
+	}
+
+	config.maxGetAllKeys = 2
+	rec := getAll("user:123:")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	var values map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&values); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"user:123:name": "Ann", "user:123:age": "30"}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	// A prefix matching more keys than allowed is rejected rather than truncated.
+	if rec := getAll("user:"); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for too many keys, got %d", rec.Code)
+	}
+
+	// Without matches, the object is empty.
+	if rec := getAll("order:"); rec.Code != http.StatusOK || rec.Body.String() != "{}\n" {
+		t.Errorf("Expected an empty object, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// VersionMismatchError is raised when a PUT expects another version of the key than its current one.
+type VersionMismatchError struct {
+	Expected uint64
+	Current  uint64
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("the key is at version %d, not %d", e.Current, e.Expected)
+}
+
+// bumpVersionLocked increments the version of a key which was just written, a new key getting version 1.
+// Every write of a value counts, whether it's a PUT or not, so that a conditional PUT never misses one.
+// The caller must hold the store's lock.
+func bumpVersionLocked(key string) uint64 {
+	store.version[key]++
+	return store.version[key]
+}
+
+// checkVersionLocked fails with a VersionMismatchError unless the key is at the expected version, 0 meaning
+// that the key doesn't exist. The caller must hold the store's lock.
+func checkVersionLocked(key string, expected uint64) error {
+	if current := store.version[key]; current != expected {
+		return &VersionMismatchError{Expected: expected, Current: current}
+	}
+
+	return nil
+}
+
+// restoreVersion sets the version of a key replayed from a put which carries it, like the puts of a snapshot.
+// Other puts were counted by putStored already.
+func restoreVersion(e Event) {
+	if e.Version == 0 {
+		return
+	}
+
+	store.Lock()
+	if _, ok := store.m[e.Key]; ok {
+		store.version[e.Key] = e.Version
+	}
+	store.Unlock()
+}
+
+// eventVersion returns the version a put leaves its key at, given the version of the key before it.
+// Puts only carry their version when the events before them were compacted away.
+func eventVersion(e Event, previous uint64) uint64 {
+	if e.Version > 0 {
+		return e.Version
+	}
+
+	return previous + 1
+}
+
+// writeVersionConflict replies to a PUT expecting another version with 409 Conflict along with the current
+// version, so that the client can read the key again and retry.
+func writeVersionConflict(rw http.ResponseWriter, err *VersionMismatchError) {
+	var resp ErrorResponse
+	resp.Error.Code = CodeConflict
+	resp.Error.Message = err.Error()
+	resp.Error.Version = &err.Current
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(http.StatusConflict)
+
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		log.Println(err.Error())
+	}
+}
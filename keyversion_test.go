@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that a PUT with a version only succeeds while the key is at that version, and that
+// every write of the key moves it to the next version.
+func TestPutVersion(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-put-version.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	// Version 0 creates the key, but only while it doesn't exist.
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "v1", "version": 0}`); rec.Code != http.StatusCreated || strings.TrimSpace(rec.Body.String()) != `{"version":1}` {
+		t.Fatalf("Expected the key to be created at version 1, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "again", "version": 0}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for creating an existing key, got %d", rec.Code)
+	}
+
+	// Reads expose the version, and a PUT of it moves the key to the next one.
+	if rec := serve(http.MethodGet, "/yakv/v0/get?key=yakv", ""); rec.Header().Get("X-Version") != "1" {
+		t.Errorf("Expected version 1, got %q", rec.Header().Get("X-Version"))
+	}
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "v2", "version": 1}`); rec.Code != http.StatusCreated || strings.TrimSpace(rec.Body.String()) != `{"version":2}` {
+		t.Errorf("Expected version 2, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Writes without a version count too, so a stale version is rejected along with the current one.
+	if rec := serve(http.MethodPost, "/yakv/v0/append", `{"key": "yakv", "value": "+"}`); rec.Code >= http.StatusBadRequest {
+		t.Fatalf("Unexpected %d for the append", rec.Code)
+	}
+	rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "lost", "version": 2}`)
+	var resp ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusConflict || resp.Error.Code != CodeConflict || resp.Error.Version == nil || *resp.Error.Version != 3 {
+		t.Errorf("Expected 409 with version 3, got %d %+v", rec.Code, resp)
+	}
+	if value, _ := Get("yakv"); value != "v2+" {
+		t.Errorf("Expected the value to be left as it is, got %q", value)
+	}
+
+	// A PUT without a version still answers with an empty body.
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "v4"}`); rec.Code != http.StatusCreated || rec.Body.Len() != 0 || rec.Header().Get("X-Version") != "4" {
+		t.Errorf("Expected 201 at version 4 without a body, got %d %q %s", rec.Code, rec.Header().Get("X-Version"), rec.Body.String())
+	}
+
+	// A deleted key starts over.
+	serve(http.MethodDelete, "/yakv/v0/delete", `{"key": "yakv"}`)
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "v1", "version": 0}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected a deleted key to be created again, got %d", rec.Code)
+	}
+}
+
+// Function for testing that versions survive replaying the log, whether it was replayed as it is, collapsed,
+// snapshotted or compacted.
+func TestVersionReplay(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-version-replay.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(filename string) { transactionLogFilename = filename }(transactionLogFilename)
+	transactionLogFilename = filename
+	defer func(collapse bool, n int) { config.collapseReplay, config.keepVersions = collapse, n }(config.collapseReplay, config.keepVersions)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	put := func(n int) {
+		for i := 0; i < n; i++ {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(`{"key": "yakv", "value": "hello, yakv!"}`)))
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("Unexpected %d for the put", rec.Code)
+			}
+		}
+	}
+
+	replay := func(name string, expected uint64) {
+		logger.Close()
+		resetStores()
+		if err := InitLog(filename); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, version, err := getVersioned("yakv"); err != nil || version != expected {
+			t.Errorf("Expected version %d after %s, got %d %v", expected, name, version, err)
+		}
+	}
+
+	put(4)
+	replay("replaying the log", 4)
+
+	config.collapseReplay = true
+	replay("a collapsed replay", 4)
+	config.collapseReplay = false
+
+	// The snapshot leaves a single put behind.
+	if _, err := Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	replay("a snapshot", 4)
+
+	// The compaction keeps the last 2 of the snapshotted put and the puts after it.
+	put(3)
+	config.keepVersions = 2
+	if _, err := Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	replay("a compaction", 7)
+}
+
+// Function for testing that the version of a put is logged after the content type and pinned flag, and
+// left out of puts without one.
+func TestVersionLogField(t *testing.T) {
+	line := formatEvent(ftlVersion, Event{ID: 1, EventType: EventPut, Key: "yakv", Value: "hello, yakv!", Version: 5})
+	e, err := parseEvent(ftlVersion, line)
+	if err != nil || e.Version != 5 || e.Pinned || e.ContentType != "" {
+		t.Errorf("Expected version 5 to survive %q, got %+v %v", line, e, err)
+	}
+
+	if line := formatEvent(ftlVersion, Event{ID: 1, EventType: EventPut, Key: "yakv", Value: "hello, yakv!"}); strings.Count(line, "\t") != 7 {
+		t.Errorf("Expected no optional fields after the compressed flag, got %q", line)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// TransactionLogger appends transactions to a transaction log file. Unlike the yakv server's logger, every
+// event is written before WriteEvent returns, so there is no batching and no background goroutine.
+type TransactionLogger struct {
+	mu      sync.Mutex
+	file    *os.File // File the transactions are appended to.
+	version int      // Format version of the file.
+	lastID  uint64   // Last used event ID.
+}
+
+// NewTransactionLogger opens the transaction log in filename for reading and appending, creating it if needed.
+func NewTransactionLogger(filename string) (*TransactionLogger, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction log file. %w", err)
+	}
+
+	version, err := DetectLogVersion(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &TransactionLogger{file: file, version: version}, nil
+}
+
+// ReadEvents calls fn with every transaction of the log in order, stopping at the first error. It has to be
+// called before writing, since the IDs of new events continue after the last one read.
+func (l *TransactionLogger) ReadEvents(fn func(Event) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	scanner := bufio.NewScanner(l.file)
+	scanner.Buffer(make([]byte, 0, 1<<20), MaxLineSize)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		// The header was already read when the logger was created.
+		if lineNumber == 1 && l.version >= 1 {
+			continue
+		}
+
+		e, err := ParseEvent(l.version, scanner.Text())
+		if err != nil {
+			return fmt.Errorf("failed while parsing line %d. %w", lineNumber, err)
+		}
+
+		// Abnormal sequences are not suitable for replaying transactions.
+		if l.lastID >= e.ID {
+			return fmt.Errorf("transaction IDs out of sequence. %d != %d", l.lastID, e.ID)
+		}
+		l.lastID = e.ID
+
+		if err := fn(e); err != nil {
+			return fmt.Errorf("failed while replaying line %d. %w", lineNumber, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// WriteEvent assigns the next ID to an event and appends it to the log. The file is written but not synced.
+func (l *TransactionLogger) WriteEvent(e Event) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.ID = l.lastID + 1
+	if _, err := l.file.WriteString(FormatEvent(l.version, e) + "\n"); err != nil {
+		return 0, err
+	}
+	l.lastID = e.ID
+
+	return e.ID, nil
+}
+
+// LastID returns the ID of the last event read or written.
+func (l *TransactionLogger) LastID() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lastID
+}
+
+// Close closes the log file.
+func (l *TransactionLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
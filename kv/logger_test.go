@@ -0,0 +1,62 @@
+package kv
+
+import (
+	"os"
+	"testing"
+)
+
+// Function for testing that written transactions are read back in order, and that new transactions of a
+// reopened log continue after the ones read.
+func TestTransactionLogger(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-logger.log"
+	defer os.Remove(filename)
+
+	l, err := NewTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := []Event{
+		{EventType: EventPut, Key: "yakv1", Value: " padded ", Verbatim: true},
+		{EventType: EventPut, Key: "yakv2", Value: "v1"},
+		{EventType: EventDelete, Key: "yakv1"},
+	}
+	for i, e := range written {
+		if id, err := l.WriteEvent(e); err != nil || id != uint64(i+1) {
+			t.Fatalf("Expected transaction %d to be written, got %d %v", i+1, id, err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = NewTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	var read []Event
+	if err := l.ReadEvents(func(e Event) error {
+		read = append(read, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(written) {
+		t.Fatalf("Expected %d transactions, got %+v", len(written), read)
+	}
+	for i, e := range read {
+		if e.ID != uint64(i+1) || e.EventType != written[i].EventType || e.Key != written[i].Key || e.Value != written[i].Value {
+			t.Errorf("Expected transaction %d to be read verbatim, got %+v", i+1, e)
+		}
+	}
+
+	// New transactions continue after the read ones.
+	if id, err := l.WriteEvent(Event{EventType: EventPut, Key: "yakv1", Value: "v2"}); err != nil || id != 4 {
+		t.Errorf("Expected the next transaction to get ID 4, got %d %v", id, err)
+	}
+	if id := l.LastID(); id != 4 {
+		t.Errorf("Expected the last ID to be 4, got %d", id)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+
+	"github.com/burntcarrot/yakv/kv"
+)
+
+// The transaction log format lives in package kv, so that other programs can read and write the server's logs.
+
+// Version of the transaction log format written to new logs.
+const ftlVersion = kv.LogVersion
+
+// Maximum length of a line of the transaction log.
+const ftlMaxLineSize = kv.MaxLineSize
+
+// errChecksumMismatch is raised when a transaction doesn't match its checksum.
+var errChecksumMismatch = kv.ErrChecksumMismatch
+
+// formatEvent formats an event as a line of a transaction log of the given version, without the newline.
+func formatEvent(version int, e Event) string {
+	return kv.FormatEvent(version, kv.Event{ID: e.ID, EventType: e.EventType, Key: e.Key, Value: e.Value, Namespace: e.Namespace, Expiry: e.Expiry, Compressed: e.Compressed, ContentType: e.ContentType, Pinned: e.Pinned, Version: e.Version, Database: e.Database, Verbatim: e.verbatim})
+}
+
+// parseEvent parses a line of a transaction log of the given version.
+func parseEvent(version int, text string) (Event, error) {
+	e, err := kv.ParseEvent(version, text)
+	return Event{ID: e.ID, EventType: e.EventType, Key: e.Key, Value: e.Value, Namespace: e.Namespace, Expiry: e.Expiry, Compressed: e.Compressed, ContentType: e.ContentType, Pinned: e.Pinned, Version: e.Version, Database: e.Database}, err
+}
+
+// formatHeader formats the header line of a transaction log of the given version, without the newline.
+func formatHeader(version int) string {
+	return kv.FormatHeader(version)
+}
+
+// detectLogVersion returns the version of the transaction log in file. Empty logs are initialized
+// with the header of the current version, so that new logs always use the current format.
+func detectLogVersion(file *os.File) (int, error) {
+	return kv.DetectLogVersion(file)
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/burntcarrot/yakv/kv"
+)
+
+// Helper function for writing a transaction log with two transactions, then corrupting the first one.
+func writeCorruptLog(t *testing.T, filename string) {
+	ftl, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ftl.Log()
+	ftl.WritePut("yakv1", "yak1")
+	ftl.WritePut("yakv2", "yak2")
+	if err := ftl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip the value of the first transaction without updating its checksum.
+	corrupt := strings.Replace(string(data), "yak1", "yaK1", 1)
+	if err := os.WriteFile(filename, []byte(corrupt), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Function for testing that new logs start with a version header.
+func TestLogHeader(t *testing.T) {
+	const filename = "temp-header.log"
+	defer os.Remove(filename)
+
+	ftl, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ftl.Close()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header := formatHeader(ftlVersion) + "\n"; string(data) != header {
+		t.Errorf("Expected log %q, got %q", header, data)
+	}
+}
+
+// Function for testing that a corrupt transaction fails the replay with its line number.
+func TestLogChecksumMismatch(t *testing.T) {
+	const filename = "temp-corrupt.log"
+	defer os.Remove(filename)
+
+	writeCorruptLog(t, filename)
+
+	ftl, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ftl.Close()
+
+	events, errors := ftl.ReadEvents()
+	for range events {
+		t.Error("Expected the corrupt transaction to stop the replay.")
+	}
+
+	// The header is line 1, so the first transaction is line 2.
+	err = <-errors
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+}
+
+// Function for testing that repair mode skips corrupt transactions.
+func TestLogRepair(t *testing.T) {
+	const filename = "temp-repair.log"
+	defer os.Remove(filename)
+
+	writeCorruptLog(t, filename)
+
+	config.repairLog = true
+	defer func() { config.repairLog = false }()
+
+	ftl, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ftl.Close()
+
+	var keys []string
+	events, errors := ftl.ReadEvents()
+	for e := range events {
+		keys = append(keys, e.Key)
+	}
+
+	if err := <-errors; err != nil {
+		t.Error(err)
+	}
+
+	if len(keys) != 1 || keys[0] != "yakv2" {
+		t.Errorf("Expected only yakv2 to be replayed, got %v", keys)
+	}
+}
+
+// Function for testing that a partial last line left behind by an interrupted write is told apart from corruption,
+// and dropped and cut off by repair mode, so that the log reads cleanly once new transactions are appended.
+func TestLogPartialLine(t *testing.T) {
+	const filename = "temp-partial.log"
+	defer os.Remove(filename)
+
+	event := func(id uint64, key string) string {
+		return formatEvent(ftlVersion, Event{ID: id, EventType: EventPut, Key: key, Value: "hello, yakv!"})
+	}
+	complete := formatHeader(ftlVersion) + "\n" + event(1, "yakv1") + "\n" + event(2, "yakv2") + "\n"
+	partial := event(3, "yakv3")
+	partial = partial[:len(partial)-5]
+
+	read := func() ([]string, error) {
+		ftl, err := NewFileTransactionLogger(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ftl.Close()
+
+		var keys []string
+		events, errors := ftl.ReadEvents()
+		for e := range events {
+			keys = append(keys, e.Key)
+		}
+		return keys, <-errors
+	}
+
+	if err := os.WriteFile(filename, []byte(complete+partial), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without repair mode, the partial line stops the replay, and is reported as such.
+	if _, err := read(); err == nil || !strings.Contains(err.Error(), "line 4") || !strings.Contains(err.Error(), "interrupted write") {
+		t.Errorf("Expected a partial line on line 4, got %v", err)
+	}
+
+	// A corrupt line which ends with a newline isn't mistaken for a partial write.
+	if err := os.WriteFile(filename, []byte(complete+partial+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read(); err == nil || strings.Contains(err.Error(), "interrupted write") {
+		t.Errorf("Expected a corrupt line, got %v", err)
+	}
+
+	// In repair mode, the partial line is dropped, and cut off before the next transaction is written.
+	config.repairLog = true
+	defer func() { config.repairLog = false }()
+
+	if err := os.WriteFile(filename, []byte(complete+partial), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ftl, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, errors := ftl.ReadEvents()
+	for range events {
+	}
+	if err := <-errors; err != nil {
+		t.Fatal(err)
+	}
+	ftl.Log()
+	ftl.WritePut("yakv4", "hello, yakv!")
+	if err := ftl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	config.repairLog = false
+	if keys, err := read(); err != nil || strings.Join(keys, ",") != "yakv1,yakv2,yakv4" {
+		t.Errorf("Expected the partial line to be gone, got %v %v", keys, err)
+	}
+
+	// A last line which only misses its newline is complete, and the next transaction starts on a line of its own.
+	if err := os.WriteFile(filename, []byte(strings.TrimSuffix(complete, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if ftl, err = NewFileTransactionLogger(filename); err != nil {
+		t.Fatal(err)
+	}
+	events, errors = ftl.ReadEvents()
+	for range events {
+	}
+	if err := <-errors; err != nil {
+		t.Fatal(err)
+	}
+	ftl.Log()
+	ftl.WritePut("yakv3", "hello, yakv!")
+	if err := ftl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, err := read(); err != nil || strings.Join(keys, ",") != "yakv1,yakv2,yakv3" {
+		t.Errorf("Expected the unterminated line to be kept, got %v %v", keys, err)
+	}
+}
+
+// Function for testing that logs without a header are still read and written without checksums.
+func TestLogVersion0(t *testing.T) {
+	const filename = "temp-v0.log"
+	defer os.Remove(filename)
+
+	if err := os.WriteFile(filename, []byte("1\t2\t\"yakv1\"\t\"yak1\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ftl, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, errors := ftl.ReadEvents()
+	for range events {
+	}
+	if err := <-errors; err != nil {
+		t.Fatal(err)
+	}
+
+	// New transactions keep the format of the existing log.
+	ftl.Log()
+	ftl.WritePut("yakv2", "yak2")
+	ftl.Close()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if last := lines[len(lines)-1]; last != formatEvent(0, Event{ID: 2, EventType: EventPut, Key: "yakv2", Value: "yak2"}) {
+		t.Errorf("Expected a version 0 transaction, got %q", last)
+	}
+}
+
+// Function for testing that logs newer than the supported version are rejected.
+func TestLogUnknownVersion(t *testing.T) {
+	const filename = "temp-unknown.log"
+	defer os.Remove(filename)
+
+	if err := os.WriteFile(filename, []byte(formatHeader(ftlVersion+1)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if ftl, err := NewFileTransactionLogger(filename); err == nil {
+		ftl.Close()
+		t.Error("Expected an error for an unsupported log version.")
+	}
+}
+
+// Helper function parsing a line with fmt.Sscanf, the way transaction logs were parsed before parseEvent
+// split lines by hand. It is the reference parseEvent is checked and benchmarked against.
+func parseEventScanf(version int, text string) (Event, error) {
+	var e Event
+
+	if version >= 1 {
+		i := strings.LastIndexByte(text, '\t')
+		if i < 0 {
+			return e, errChecksumMismatch
+		}
+
+		var sum uint32
+		if _, err := fmt.Sscanf(text[i:], "\t%08x", &sum); err != nil || sum != crc32.ChecksumIEEE([]byte(text[:i])) {
+			return e, errChecksumMismatch
+		}
+
+		text = text[:i]
+	}
+
+	line := strings.NewReader(text)
+	if _, err := fmt.Fscanf(line, "%d\t%d\t%q\t%q", &e.ID, &e.EventType, &e.Key, &e.Value); err != nil {
+		return e, err
+	}
+
+	formats := []string{"\t%q", "\t%d", "\t%t"}
+	optional := []interface{}{&e.Namespace, &e.Expiry, &e.Compressed}
+	for i := 0; i < len(optional) && line.Len() > 0; i++ {
+		if _, err := fmt.Fscanf(line, formats[i], optional[i]); err != nil {
+			return e, err
+		}
+	}
+
+	return e, nil
+}
+
+// Sample events covering every field, along with keys and values which need quoting.
+var sampleEvents = []Event{
+	{ID: 1, EventType: EventPut, Key: "yakv", Value: "hello, yakv!"},
+	{ID: 2, EventType: EventDelete, Key: "yakv"},
+	{ID: 3, EventType: EventPut, Key: "tab\tkey", Value: "quote \" and backslash \\", Namespace: "ns"},
+	{ID: 4, EventType: EventPut, Key: "ünïcödé", Value: "\x00\xff binary", Expiry: 1700000000000000000, verbatim: true},
+	{ID: 5, EventType: EventPut, Key: "compressed", Value: "H4sIAAAAAAAA", Compressed: true},
+	{ID: 6, EventType: EventTouch, Key: "yakv", Expiry: -1},
+	{ID: 7, EventType: EventDropNamespace, Namespace: "ns"},
+}
+
+// Function for testing that parseEvent parses every line exactly like the Sscanf-based parser.
+func TestParseEventMatchesScanf(t *testing.T) {
+	lines := []struct {
+		version int
+		text    string
+	}{
+		// Lines written by older versions of yakv, with fewer fields.
+		{0, "1\t2\t\"yakv\"\t\"hello, yakv!\""},
+		{0, "2\t2\t\"yakv\"\t\"hello\"\t\"ns\""},
+		{0, "3\t2\t\"yakv\"\t\"hello\"\t\"\"\t42"},
+	}
+	for _, e := range sampleEvents {
+		for version := 0; version <= ftlVersion; version++ {
+			lines = append(lines, struct {
+				version int
+				text    string
+			}{version, formatEvent(version, e)})
+		}
+	}
+
+	for _, line := range lines {
+		expected, expectedErr := parseEventScanf(line.version, line.text)
+		got, err := parseEvent(line.version, line.text)
+
+		if (err != nil) != (expectedErr != nil) {
+			t.Errorf("Expected error %v for %q, got %v", expectedErr, line.text, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("Parsers diverged for %q.\nSscanf:    %+v\nparseEvent: %+v", line.text, expected, got)
+		}
+	}
+
+	// Malformed lines are rejected by both parsers.
+	for _, text := range []string{"", "1\t2\t\"yakv\"", "x\t2\t\"yakv\"\t\"v\"", "1\t2\tyakv\t\"v\"", "1\t2\t\"yakv\"\t\"v\"\t\"ns\"\tsoon"} {
+		if _, err := parseEvent(0, text); err == nil {
+			t.Errorf("Expected an error for %q", text)
+		}
+		if _, err := parseEventScanf(0, text); err == nil {
+			t.Errorf("Expected the Sscanf-based parser to reject %q", text)
+		}
+	}
+}
+
+// Helper function for benchmarking a parser on the lines of the sample events.
+func benchmarkParse(b *testing.B, parse func(int, string) (Event, error)) {
+	lines := make([]string, len(sampleEvents))
+	for i, e := range sampleEvents {
+		lines[i] = formatEvent(ftlVersion, e)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parse(ftlVersion, lines[i%len(lines)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Benchmark for parsing transactions with fmt.Sscanf.
+func BenchmarkParseEventScanf(b *testing.B) {
+	benchmarkParse(b, parseEventScanf)
+}
+
+// Benchmark for parsing transactions by splitting them by hand.
+func BenchmarkParseEvent(b *testing.B) {
+	benchmarkParse(b, parseEvent)
+}
+
+// Function for testing that the server replays a transaction log written through package kv.
+func TestLibraryLog(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-library.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+
+	l, err := kv.NewTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []kv.Event{
+		{EventType: kv.EventPut, Key: "yakv1", Value: "hello, yakv!"},
+		{EventType: kv.EventPut, Key: "yakv2", Value: "v1"},
+		{EventType: kv.EventDelete, Key: "yakv2"},
+	} {
+		if _, err := l.WriteEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+
+	if len(store.m) != 1 || store.m["yakv1"] != "hello, yakv!" {
+		t.Errorf("Expected the state written through package kv, got %v", store.m)
+	}
+}
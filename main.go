@@ -16,126 +16,585 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode/utf8"
 
+	"github.com/burntcarrot/yakv/client"
+	"github.com/burntcarrot/yakv/kv"
 	"github.com/gin-gonic/gin"
 )
 
-// Globally-available key-value store.
-var store = struct {
+// keyValueStore is a concurrency-safe map of keys to values.
+type keyValueStore struct {
 	sync.RWMutex
-	m map[string]string
-}{m: make(map[string]string)}
+	m           map[string]string
+	expiry      map[string]time.Time // Expiration time of keys which have a TTL.
+	compressed  map[string]bool      // Keys whose values are stored gzip-compressed.
+	contentType map[string]string    // Content type of keys which were put with one.
+	pinned      map[string]bool      // Keys which are never evicted, see -max-keys.
+	version     map[string]uint64    // Number of times every key was written since it was created.
+	index       *valueIndex          // Keys by value, nil unless the value index is enabled.
+}
+
+// newKeyValueStore creates an empty key-value store.
+func newKeyValueStore() *keyValueStore {
+	return &keyValueStore{m: make(map[string]string), expiry: make(map[string]time.Time), compressed: make(map[string]bool), contentType: make(map[string]string), pinned: make(map[string]bool), version: make(map[string]uint64)}
+}
 
-// Logger format strings.
-var ftlWriteFormat = "%d\t%d\t%q\t%q\n"
-var ftlReadFormat = "%d\t%d\t%q\t%q"
+// presize recreates the values of an empty store with room for hint keys, so that replaying a large log
+// doesn't grow and rehash the map over and over. The other maps only hold the keys with a TTL, a compressed
+// value or a content type, so they are left to grow.
+func (s *keyValueStore) presize(hint int) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.m = make(map[string]string, hint)
+}
+
+// Globally-available key-value store.
+var store = newKeyValueStore()
 
 // Initializing logger.
 var logger TransactionLogger
 
+// Default batching parameters for the transaction logger.
+const (
+	defaultLogBatchSize     = 64
+	defaultLogBatchInterval = 5 * time.Millisecond
+)
+
+// Default maximum time for in-flight requests to finish during shutdown.
+const defaultShutdownTimeout = 5 * time.Second
+
+// Default maximum time for the transaction log to be flushed during shutdown, once in-flight requests finished.
+const defaultLogFlushTimeout = 5 * time.Second
+
+// Default permissions for the transaction log file.
+const defaultLogFileMode os.FileMode = 0644
+
 // ErrorNoSuchKey is raised when a key is not found in the store.
 var ErrorNoSuchKey = errors.New("key doesn't exist")
 
+// ErrorEmptyKey is raised when a request is made for the empty key.
+var ErrorEmptyKey = errors.New("key must not be empty")
+
+// errNotLogged is raised by a synchronous write whose event couldn't be written to the transaction log. The write
+// is applied to the store, but won't survive a restart.
+var errNotLogged = errors.New("the write was applied, but couldn't be written to the transaction log")
+
+// ErrorValueTooLarge is raised when a value is larger than the maximum value size.
+var ErrorValueTooLarge = errors.New("value is larger than the maximum value size")
+
+// Default maximum size of a value in bytes, the same as the maximum size of a request body.
+const defaultMaxValueSize = 1 << 20
+
 // TransactionLogger is the interface for a transaction logger.
 type TransactionLogger interface {
-	WriteDelete(key string)
-	WritePut(key, value string)
+	WriteDelete(key string) error
+	WritePut(key, value string) error
+	WriteNamespaceDelete(namespace, key string) error
+	WriteNamespacePut(namespace, key, value string) error
+	WriteDropNamespace(namespace string) error
+	WriteEvent(e Event) error
 	Close() error
 	Wait()
 	Err() <-chan error
 	LastID() uint64
 	ReadEvents() (<-chan Event, <-chan error)
+	SkipEvents() error
 	Log()
+	Reopen() error
+	Compact(events []Event, taken func()) (int64, error)
 }
 
 // FileTransactionLogger is a struct for the file-based transaction logger.
 type FileTransactionLogger struct {
-	events chan<- Event // Write-only channel for sending events.
-	errors <-chan error // Read-only channel for receiving errors.
-	lastID uint64       // Last used event ID.
-	file   *os.File     // Path for the transaction log.
-	wg     *sync.WaitGroup
+	events        chan<- Event // Write-only channel for sending events.
+	errors        <-chan error // Read-only channel for receiving errors.
+	lastID        uint64       // Last used event ID.
+	file          *os.File     // Path for the transaction log.
+	wg            *sync.WaitGroup
+	batchSize     int             // Number of buffered events that triggers a flush.
+	batchInterval time.Duration   // Maximum time an event stays buffered before a flush.
+	bufferSize    int             // Capacity of the events channel.
+	done          chan struct{}   // Closed once the Log() goroutine has flushed and exited.
+	version       int             // Format version of the transaction log.
+	reopen        chan chan error // Requests for the Log() goroutine to reopen the file, answered with the result.
+	compact       chan compaction // Requests for the Log() goroutine to replace the file with a snapshot.
+	ids           *uint64         // Counter assigning event IDs, shared by the shards of a sharded log, nil for a single log.
+	unterminated  bool            // Whether the last line read doesn't end with a newline, which is written before the next event.
+	partial       bool            // Whether a partial last line was dropped while reading, which is cut off before writing.
+	partialAt     int64           // Offset of the partial last line.
+	readEnd       int64           // Offset at which ReadEvents stops reading, 0 reading to the end of the file.
 }
 
 // Event holds the basic information for an event.
 type Event struct {
-	ID        uint64    // ID assigned to the event.
-	EventType EventType // The type of event assigned to the event.
-	Key       string    // The key assigned to the event.
-	Value     string    // The value assigned to the event.
+	ID          uint64    // ID assigned to the event.
+	EventType   EventType // The type of event assigned to the event.
+	Key         string    // The key assigned to the event.
+	Value       string    // The value assigned to the event.
+	Namespace   string    // The namespace of the key, empty for the default store.
+	Expiry      int64     // Expiration time of the key in Unix nanoseconds, zero if the key doesn't expire.
+	Compressed  bool      // Whether the value is gzip-compressed.
+	ContentType string    // Content type of the value, empty if it was put without one.
+	Pinned      bool      // Whether the key is never evicted, see -max-keys.
+	Version     uint64    // Version of the key after a put, only logged by snapshots and compactions.
+	Database    int       // Number of the database of the key, see -num-databases. Zero for the default database.
+
+	verbatim bool       // Whether the value is binary and written without trimming it, not part of the log.
+	written  chan error // Receives the error of the flush of the event, nil unless writes are synchronous.
+	queued   bool       // Whether writing the event doesn't wait for -sync-writes, for events logged under the store's lock.
 }
 
 // EventType denotes the type of event occurred.
-type EventType byte
+type EventType = kv.EventType
 
-// Assigns a constant value for each event.
+// Assigns a constant value for each event, shared with the transaction log of package kv.
 const (
-	_                     = iota
-	EventDelete EventType = iota
-	EventPut    EventType = iota
+	EventDelete        = kv.EventDelete
+	EventPut           = kv.EventPut
+	EventDropNamespace = kv.EventDropNamespace
+	EventTouch         = kv.EventTouch
 )
 
 // DeleteBody is a struct for defining DELETE request body structure.
 type DeleteBody struct {
-	Key string
+	Key string `json:"key"`
 }
 
 // GetBody is a struct for defining GET request body structure.
 type GetBody struct {
-	Key string
+	Key string `json:"key"`
 }
 
 // PutBody is a struct for defining PUT request body structure.
 type PutBody struct {
-	Key   string
-	Value string
+	Key         string  `json:"key"`
+	Value       string  `json:"value"`
+	TTLSeconds  *int64  `json:"ttl_seconds"`  // Optional lifetime of the key, zero means the key never expires. Defaults to the TTL of the key's prefix.
+	ContentType string  `json:"content_type"` // Optional content type GET responds with, e.g. image/png.
+	Pinned      bool    `json:"pinned"`       // Whether the key is never evicted, see -max-keys.
+	Version     *uint64 `json:"version"`      // Optional version the key must be at for the put to succeed, 0 meaning it must not exist.
+}
+
+// TouchBody is a struct for defining TOUCH request body structure.
+type TouchBody struct {
+	Key        string `json:"key"`
+	TTLSeconds int64  `json:"ttl_seconds"` // New lifetime of the key, zero means the key never expires.
 }
 
 // Config struct for connections.
 var config struct {
 	port int
 	host string
+
+	backend string
+
+	logBatchSize     int
+	logBatchInterval time.Duration
+	logFileMode      os.FileMode
+	syncWrites       bool
+	logShards        int
+
+	snapshotInterval     time.Duration
+	snapshotEveryNEvents int
+	keepVersions         int
+
+	logBufferSize         int
+	backpressureThreshold time.Duration
+	rejectOnBackpressure  bool
+
+	expirySweepInterval time.Duration
+
+	ttlJitter float64
+
+	compressThreshold int
+
+	spillThreshold int
+	spillDir       string
+
+	rateLimit float64
+	rateBurst int
+
+	apiKey string
+
+	collapseReplay bool
+	noReplay       bool
+
+	otelEndpoint string
+
+	routePrefix string
+
+	redirectTrailingSlash bool
+
+	adminPrefix string
+	adminPort   int
+	adminHost   string
+
+	binPort int
+
+	repairLog bool
+
+	allowPartialReplay bool
+
+	allowFlush bool
+
+	slowThreshold time.Duration
+
+	replayUntil uint64
+
+	schemaFile string
+
+	copyOnRead bool
+
+	gzipResponses bool
+	gzipMinSize   int
+
+	debugBodies    bool
+	debugBodyLimit int
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+
+	h2c bool
+
+	shutdownTimeout time.Duration
+	logFlushTimeout time.Duration
+	drainGrace      time.Duration
+
+	maxBulkKeys int
+
+	maxGetAllKeys int
+	maxExportKeys int
+
+	maxValueSize int
+
+	maxKeys       int
+	maxKeysReject bool
+
+	putMode string
+
+	lenientJSON bool
+
+	configFile string
+
+	pidFile string
+
+	getCacheTTL time.Duration
+
+	enableValueIndex bool
+
+	storeHint int
+
+	numDatabases int
+
+	maxConcurrency int
+
+	maxConnsPerIP int
+
+	maxLogErrors int
+
+	autoReadOnlyAfter    int
+	autoReadOnlyCooldown time.Duration
+
+	caseInsensitiveKeys bool
+
+	secureHeaders bool
+
+	trackAccess bool
+
+	allowDump   bool
+	maxDumpSize int
 }
 
 // Put takes a key and a value as arguments, and sets the value to the given key.
+// The key expires according to the default TTL of its prefix, if any.
 func Put(key string, value string) error {
+	key = normalizeKey(key)
+	return PutWithExpiry(key, value, defaultExpiry(key, time.Now()))
+}
+
+// PutWithExpiry sets the value to the given key, which expires at expiresAt. A zero expiresAt means the key never expires.
+func PutWithExpiry(key string, value string, expiresAt time.Time) error {
+	key = normalizeKey(key)
+	stored, compressed, err := encodeValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	return putStored(key, stored, compressed, expiresAt, "", false)
+}
+
+// encodeValue validates key and a new value of it against its schema, and returns the value as it is stored, i.e. compressed or not.
+func encodeValue(key string, value string) (string, bool, error) {
+	if err := validateKey(key); err != nil {
+		return "", false, err
+	}
+
+	if err := checkValueSize(value); err != nil {
+		return "", false, err
+	}
+
+	if err := validateValue(key, value); err != nil {
+		return "", false, err
+	}
+
+	return encodeStored(value)
+}
+
+// encodeStored returns a value as it is stored, i.e. compressed, spilled to a file, or as it is.
+func encodeStored(value string) (string, bool, error) {
+	stored, compressed, err := compressValue(value)
+	if err != nil {
+		return "", false, err
+	}
+
+	return spillValue(stored, compressed)
+}
+
+// checkValueSize checks that a new value isn't larger than the maximum value size.
+func checkValueSize(value string) error {
+	if config.maxValueSize > 0 && len(value) > config.maxValueSize {
+		return ErrorValueTooLarge
+	}
+
+	return nil
+}
+
+// putStored sets the value to the given key as it is stored, i.e. compressed or not, along with its content type
+// and whether it's pinned. An empty content type drops the content type of the previous value. Whether the key may
+// be written depends on -put-mode, and a new key may evict another one, see admitKey.
+func putStored(key string, stored string, compressed bool, expiresAt time.Time, contentType string, pinned bool) error {
+	_, err := putVersioned(key, stored, compressed, expiresAt, contentType, pinned, nil, nil)
+	return err
+}
+
+// putVersioned is putStored for a PUT which only succeeds if the key is at the expected version, when there is
+// one, failing with a VersionMismatchError otherwise. It returns the version the key is at after the put. Unless
+// it's nil, logged is called to write the put to the transaction log once the store's lock is released, while the
+// stripe of the key is still held, which keeps it ordered before any later write of the key without holding up
+// writes of other keys. Writes logging events of their own hold the stripes of their keys for the same reason.
+func putVersioned(key string, stored string, compressed bool, expiresAt time.Time, contentType string, pinned bool, expected *uint64, logged func()) (uint64, error) {
+	// The index holds uncompressed values, which are decompressed before taking the lock.
+	value := stored
+	if compressed && store.index != nil {
+		var err error
+		if value, err = decompressValue(stored); err != nil {
+			return 0, err
+		}
+	}
+
+	if logged != nil {
+		stripe := keyLock(key)
+		stripe.Lock()
+		defer stripe.Unlock()
+	}
+
+	start := time.Now()
 	store.Lock()
-	store.m[key] = value
+	locked := time.Now()
+	if expected != nil {
+		if err := checkVersionLocked(key, *expected); err != nil {
+			store.Unlock()
+			return 0, err
+		}
+	}
+	if err := checkPutLocked(key); err != nil {
+		store.Unlock()
+		return 0, err
+	}
+	if err := admitKey(key); err != nil {
+		store.Unlock()
+		return 0, err
+	}
+	store.m[key] = stored
+	version := bumpVersionLocked(key)
+	if expiresAt.IsZero() {
+		delete(store.expiry, key)
+	} else {
+		store.expiry[key] = expiresAt
+	}
+	if compressed {
+		store.compressed[key] = true
+	} else {
+		delete(store.compressed, key)
+	}
+	if contentType != "" {
+		store.contentType[key] = contentType
+	} else {
+		delete(store.contentType, key)
+	}
+	if pinned {
+		store.pinned[key] = true
+	} else {
+		delete(store.pinned, key)
+	}
+	touchKey(key, pinned)
+	store.index.add(key, value)
 	store.Unlock()
+	recordLockedLatency("put", time.Since(start), time.Since(locked))
+	if logged != nil {
+		logged()
+	}
+
+	return version, nil
+}
+
+// normalizeKey returns the key as it is stored and logged: lowercased with -case-insensitive-keys,
+// and unchanged otherwise. Only ASCII letters are lowercased in keys which aren't valid UTF-8, so that
+// binary keys aren't mangled.
+func normalizeKey(key string) string {
+	if !config.caseInsensitiveKeys {
+		return key
+	}
+
+	if utf8.ValidString(key) {
+		return strings.ToLower(key)
+	}
+
+	b := []byte(key)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+
+	return string(b)
+}
+
+// validateKey checks that a key can be used for reading or writing the store.
+func validateKey(key string) error {
+	if key == "" {
+		return ErrorEmptyKey
+	}
 
 	return nil
 }
 
 // Get takes a key as an argument, and gets the value assigned to the key.
 func Get(key string) (string, error) {
+	value, _, err := GetWithContentType(key)
+	return value, err
+}
+
+// GetWithContentType gets the value assigned to the key along with its content type, which is empty
+// unless the value was put with one.
+func GetWithContentType(key string) (string, string, error) {
+	value, contentType, _, err := getVersioned(key)
+	return value, contentType, err
+}
+
+// getVersioned returns the value of a key along with its content type and the version of the value, which are
+// read together, so that a conditional PUT of the version never overwrites a write the reader hasn't seen.
+func getVersioned(key string) (string, string, uint64, error) {
+	key = normalizeKey(key)
+	if err := validateKey(key); err != nil {
+		return "", "", 0, err
+	}
+
+	start := time.Now()
 	store.RLock()
+	locked := time.Now()
 	value, ok := store.m[key]
+	expiresAt, expires := store.expiry[key]
+	compressed := store.compressed[key]
+	contentType := store.contentType[key]
+	pinned := store.pinned[key]
+	version := store.version[key]
 	store.RUnlock()
+	held := time.Since(locked)
+	defer func() { recordLockedLatency("get", time.Since(start), held) }()
+
+	// Keys which have expired but haven't been swept yet are treated as missing.
+	if !ok || (expires && hasExpired(key, expiresAt, time.Now())) {
+		return "", "", 0, ErrorNoSuchKey
+	}
+
+	recordAccess(key)
+	touchKey(key, pinned)
 
-	if !ok {
-		return "", ErrorNoSuchKey
+	if compressed {
+		value, err := decompressValue(value)
+		return value, contentType, version, err
 	}
 
-	return value, nil
+	return value, contentType, version, nil
 }
 
 // Delete takes a key as an argument, and deletes it from the store.
 func Delete(key string) error {
-	delete(store.m, key)
+	return deleteLogged(key, nil)
+}
+
+// deleteLogged is Delete calling logged, unless it's nil, to write the delete to the transaction log under the
+// stripe of the key, see putVersioned.
+func deleteLogged(key string, logged func()) error {
+	key = normalizeKey(key)
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	return deleteStored(key, logged)
+}
+
+// deleteStored deletes a key from the store without validating it, for replaying the transaction log, calling
+// logged like deleteLogged.
+func deleteStored(key string, logged func()) error {
+	if logged != nil {
+		stripe := keyLock(key)
+		stripe.Lock()
+		defer stripe.Unlock()
+	}
+
+	start := time.Now()
+	store.Lock()
+	locked := time.Now()
+	removeLocked(key)
+	store.Unlock()
+	recordLockedLatency("delete", time.Since(start), time.Since(locked))
+	if logged != nil {
+		logged()
+	}
 
 	return nil
 }
 
+// removeLocked removes a key and everything stored along with it from the store. The caller must hold the store's lock.
+func removeLocked(key string) {
+	delete(store.m, key)
+	delete(store.expiry, key)
+	delete(store.compressed, key)
+	delete(store.contentType, key)
+	delete(store.pinned, key)
+	delete(store.version, key)
+	store.index.remove(key)
+	forgetKey(key)
+}
+
+// Maximum size in bytes of a JSON request body.
+const maxBodySize = 1 << 20
+
 // Malformed request struct
 type malformedRequest struct {
 	status int
@@ -157,12 +616,30 @@ func DecodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) err
 	}
 
 	// Limit size of incoming request body
-	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		if err.Error() == "http: request body too large" {
+			msg := "Request body must not be larger than 1MB"
+			return &malformedRequest{status: http.StatusRequestEntityTooLarge, msg: msg}
+		}
+		return err
+	}
+
+	// The JSON decoder would silently replace invalid bytes with U+FFFD, mangling the keys and values.
+	if !utf8.Valid(data) {
+		msg := "Request body must be valid UTF-8, binary keys and values have to be base64-encoded with encoding=base64"
+		return &malformedRequest{status: http.StatusBadRequest, msg: msg}
+	}
 
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Unknown fields are rejected, unless they are ignored for clients newer than the server.
+	if !config.lenientJSON {
+		dec.DisallowUnknownFields()
+	}
 
-	err := dec.Decode(&dst)
+	err = dec.Decode(&dst)
 	if err != nil {
 		var syntaxError *json.SyntaxError
 		var unmarshalTypeError *json.UnmarshalTypeError
@@ -189,10 +666,6 @@ func DecodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) err
 			msg := "Request body must not be empty"
 			return &malformedRequest{status: http.StatusBadRequest, msg: msg}
 
-		case err.Error() == "http: request body too large":
-			msg := "Request body must not be larger than 1MB"
-			return &malformedRequest{status: http.StatusRequestEntityTooLarge, msg: msg}
-
 		default:
 			return err
 		}
@@ -220,81 +693,172 @@ func DeleteHandler(rw http.ResponseWriter, r *http.Request) {
 
 		// Match errors with malformed requests
 		if errors.As(decodeErr, &mr) {
-			http.Error(rw, mr.msg, mr.status)
+			writeError(rw, mr.msg, mr.status)
 		} else {
 			log.Println(decodeErr.Error())
-			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		}
 		return
 	}
 
 	// Get key from DeleteBody struct
-	key := body.Key
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Calls Delete for deleting a key-value pair
-	err := Delete(key)
+	key, err := decodeKey(binary, body.Key)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key = scopeKey(r, key)
+
+	if db := requestDatabase(r); db > 0 {
+		serveDatabaseDelete(rw, db, key)
+		return
+	}
+
+	// Calls deleteLogged for deleting a key-value pair, writing the DELETE event to the log.
+	changes.RLock()
+	defer changes.RUnlock()
+	ctx, span := startOperationSpan(r.Context(), "delete", key)
+	var logErr error
+	err = deleteLogged(key, func() {
+		_, span := startOperationSpan(ctx, "log.delete", key)
+		logErr = logger.WriteDelete(key)
+		endOperationSpan(span, logErr)
+	})
+	if err == nil {
+		err = logErr
+	}
+	endOperationSpan(span, err)
 
 	fmt.Println("deleting key:", key)
+	if errors.Is(err, ErrorEmptyKey) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if errors.Is(err, ErrorNoSuchKey) {
-		http.Error(rw, err.Error(), http.StatusNotFound)
+		writeError(rw, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	// Any other error that can't be handled
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeError(rw, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	// Write the DELETE event to the log.
-	logger.WriteDelete(key)
 }
 
 // GetHandler is a handler function for GET endpoint.
 func GetHandler(rw http.ResponseWriter, r *http.Request) {
 	var body GetBody
-
-	// Use custom JSON decoder
-	decodeErr := DecodeJSONBody(rw, r, &body)
 	defer r.Body.Close()
 
-	if decodeErr != nil {
+	// The key is read from the query string when it's there, e.g. ?key=foo, and from the body otherwise.
+	if keys, ok := r.URL.Query()["key"]; ok {
+		body.Key = keys[0]
+	} else if decodeErr := DecodeJSONBody(rw, r, &body); decodeErr != nil {
 		var mr *malformedRequest
 
 		// Match errors with malformed requests
 		if errors.As(decodeErr, &mr) {
-			http.Error(rw, mr.msg, mr.status)
+			writeError(rw, mr.msg, mr.status)
 		} else {
 			log.Println(decodeErr.Error())
-			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		}
 		return
 	}
 
 	// Get key from GetBody struct
-	key := body.Key
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := decodeKey(binary, body.Key)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key = scopeKey(r, key)
+
+	if db := requestDatabase(r); db > 0 {
+		serveDatabaseGet(rw, r, db, key, binary)
+		return
+	}
+
+	// A missing key is answered with the default value when there is one, which may be empty.
+	defaults, hasDefault := r.URL.Query()["default"]
+	var defaultValue string
+	if hasDefault {
+		if defaultValue, err = decodeWire(binary, defaults[0]); err != nil {
+			writeError(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Calls Get to get the value assigned to the key
-	value, err := Get(key)
+	_, span := startOperationSpan(r.Context(), "get", key)
+	value, contentType, version, err := getVersioned(key)
+	endOperationSpan(span, err)
+
+	if errors.Is(err, ErrorEmptyKey) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errors.Is(err, ErrorNoSuchKey) && hasDefault {
+		// The default isn't stored, and isn't cacheable since the key may be set at any time.
+		rw.Header().Set("X-Yakv-Default", "true")
+		if _, err := rw.Write([]byte(encodeWire(binary, defaultValue))); err != nil {
+			log.Println(err.Error())
+		}
+		return
+	}
 
-	fmt.Printf("value found for key \"%s\", value: %s\n", key, string(value))
 	if errors.Is(err, ErrorNoSuchKey) {
-		http.Error(rw, err.Error(), http.StatusNotFound)
+		writeError(rw, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	// Any other error that can't be handled
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeError(rw, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// ResponseWriter takes byte as argument
-	_, err = rw.Write([]byte(value))
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	// Values put with a content type are served as such, unless they're sent base64-encoded.
+	// Other values keep the content type detected from the value.
+	if contentType != "" && !binary {
+		rw.Header().Set("Content-Type", contentType)
+	}
+
+	// Clients can request a single field of a JSON value instead of the whole value.
+	var ok bool
+	if value, ok = projectRequest(rw, r, value, binary); !ok {
+		return
+	}
+	rw.Header().Set("X-Version", strconv.FormatUint(version, 10))
+
+	// Clients which already have the value are answered with 304 Not Modified.
+	encoded := encodeWire(binary, value)
+	if writeCacheHeaders(rw, r, encoded) {
 		return
 	}
+
+	// Only values which are actually sent are logged.
+	fmt.Printf("value found for key \"%s\", value: %s\n", key, string(value))
+
+	// Clients can request a range of the value, e.g. to resume a download.
+	if err := writeValue(rw, r, encoded); err != nil {
+		log.Println(err.Error())
+	}
 }
 
 // PutHandler is a handler function for PUT endpoint.
@@ -310,67 +874,276 @@ func PutHandler(rw http.ResponseWriter, r *http.Request) {
 
 		// Match errors with malformed requests
 		if errors.As(decodeErr, &mr) {
-			http.Error(rw, mr.msg, mr.status)
+			writeError(rw, mr.msg, mr.status)
 		} else {
 			log.Println(decodeErr.Error())
-			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		}
 		return
 	}
 
 	// Get key and value from PutBody struct
-	key := body.Key
-	value := body.Value
-
-	if decodeErr != nil {
-		http.Error(rw, decodeErr.Error(), http.StatusInternalServerError)
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Call the Put function to add a key-value pair.
-	err := Put(key, strings.Replace(string(value), "\n", "", -1))
-
-	fmt.Printf("added value: \"%s\" to key \"%s\"\n", string(value), key)
-
+	key, err := decodeKey(binary, body.Key)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeError(rw, err.Error(), http.StatusBadRequest)
 		return
 	}
+	key = scopeKey(r, key)
 
-	// Write the PUT event to the log.
-	logger.WritePut(key, string(value))
-	rw.WriteHeader(http.StatusCreated)
-}
+	value, err := decodeWire(binary, body.Value)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-// WritePut sends events of type EventPut to the file-based transaction logger's events channel.
-func (ftl *FileTransactionLogger) WritePut(key, value string) {
-	ftl.wg.Add(1)
-	ftl.events <- Event{EventType: EventPut, Key: key, Value: value}
-}
+	storedValue := stripNewlines(binary, value)
 
-// WriteDelete sends events of type EventDelete to the file-based transaction logger's events channel.
-func (ftl *FileTransactionLogger) WriteDelete(key string) {
-	ftl.wg.Add(1)
-	ftl.events <- Event{EventType: EventDelete, Key: key}
-}
+	if db := requestDatabase(r); db > 0 {
+		serveDatabasePut(rw, db, key, storedValue, body, binary)
+		return
+	}
 
-// Close closes the events channel and the file descriptor for the transaction log.
-func (ftl *FileTransactionLogger) Close() error {
-	ftl.wg.Wait()
+	// Keys with a TTL expire relative to now, keys without one get the default TTL of their prefix.
+	expiresAt, err := requestExpiry(key, body.TTLSeconds)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if ftl.events != nil {
-		close(ftl.events)
+	if body.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(body.ContentType); err != nil {
+			writeError(rw, "content_type must be a media type, e.g. image/png", http.StatusBadRequest)
+			return
+		}
 	}
 
-	return ftl.file.Close()
-}
+	// Values are validated, and large values are compressed before they are stored and logged.
+	stored, compressed, err := encodeValue(key, storedValue)
+	var schemaErr *SchemaError
+	if errors.Is(err, ErrorEmptyKey) || errors.As(err, &schemaErr) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrorValueTooLarge) {
+		writeError(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-// Wait blocks until the WaitGroup counter for FileTransactionLogger is zero.
-func (ftl *FileTransactionLogger) Wait() {
-	ftl.wg.Wait()
-}
+	// Call the putVersioned function to add a key-value pair, writing the PUT event to the log.
+	changes.RLock()
+	defer changes.RUnlock()
+	ctx, span := startOperationSpan(r.Context(), "put", key)
+	var logErr error
+	version, err := putVersioned(key, stored, compressed, expiresAt, body.ContentType, body.Pinned, body.Version, func() {
+		_, span := startOperationSpan(ctx, "log.put", key)
 
-// Err sends errors to the FileTransactionLogger's errors channel.
+		switch {
+		case compressed:
+			logErr = logger.WriteEvent(Event{EventType: EventPut, Key: key, Value: stored, Expiry: unixNano(expiresAt), Compressed: true, ContentType: body.ContentType, Pinned: body.Pinned})
+		case binary || !expiresAt.IsZero() || body.ContentType != "" || body.Pinned:
+			logErr = logger.WriteEvent(Event{EventType: EventPut, Key: key, Value: storedValue, Expiry: unixNano(expiresAt), ContentType: body.ContentType, Pinned: body.Pinned, verbatim: binary})
+		default:
+			logErr = logger.WritePut(key, string(value))
+		}
+		endOperationSpan(span, logErr)
+	})
+	if err == nil {
+		err = logErr
+	}
+	endOperationSpan(span, err)
+
+	var mismatch *VersionMismatchError
+	if errors.As(err, &mismatch) {
+		writeVersionConflict(rw, mismatch)
+		return
+	}
+	if errors.Is(err, errStoreFull) {
+		writeError(rw, err.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	if errors.Is(err, errKeyExists) {
+		writeError(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	fmt.Printf("added value: \"%s\" to key \"%s\"\n", string(value), key)
+
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Conditional puts get the new version back, for the next one.
+	rw.Header().Set("X-Version", strconv.FormatUint(version, 10))
+	if body.Version == nil {
+		rw.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(rw).Encode(struct {
+		Version uint64 `json:"version"`
+	}{version}); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// WritePut sends events of type EventPut to the file-based transaction logger's events channel.
+func (ftl *FileTransactionLogger) WritePut(key, value string) error {
+	return ftl.WriteEvent(Event{EventType: EventPut, Key: key, Value: value})
+}
+
+// WriteDelete sends events of type EventDelete to the file-based transaction logger's events channel.
+func (ftl *FileTransactionLogger) WriteDelete(key string) error {
+	return ftl.WriteEvent(Event{EventType: EventDelete, Key: key})
+}
+
+// WriteNamespacePut sends events of type EventPut for a namespaced key to the file-based transaction logger's events channel.
+func (ftl *FileTransactionLogger) WriteNamespacePut(namespace, key, value string) error {
+	return ftl.WriteEvent(Event{EventType: EventPut, Namespace: namespace, Key: key, Value: value})
+}
+
+// WriteNamespaceDelete sends events of type EventDelete for a namespaced key to the file-based transaction logger's events channel.
+func (ftl *FileTransactionLogger) WriteNamespaceDelete(namespace, key string) error {
+	return ftl.WriteEvent(Event{EventType: EventDelete, Namespace: namespace, Key: key})
+}
+
+// WriteDropNamespace sends events of type EventDropNamespace to the file-based transaction logger's events channel.
+func (ftl *FileTransactionLogger) WriteDropNamespace(namespace string) error {
+	return ftl.WriteEvent(Event{EventType: EventDropNamespace, Namespace: namespace})
+}
+
+// WriteEvent sends an arbitrary event to the file-based transaction logger's events channel.
+// Sending blocks while the channel is full, which is recorded as the latency of the write and as backpressure.
+// With -sync-writes, it also blocks until the event has been written to the file, and fails if it couldn't be.
+func (ftl *FileTransactionLogger) WriteEvent(e Event) error {
+	return queueEvent(ftl.events, ftl.wg, e)
+}
+
+// queueEvent sends an event to the events channel of a logger, adding it to the logger's WaitGroup. With
+// -sync-writes, it returns errNotLogged once the event failed to be written, unless the event is only queued.
+func queueEvent(events chan<- Event, wg *sync.WaitGroup, e Event) error {
+	if config.syncWrites && !e.queued {
+		e.written = make(chan error, 1)
+	}
+
+	start := time.Now()
+	wg.Add(1)
+	atomic.AddInt64(&logPendingEvents, 1)
+	select {
+	case events <- e:
+	default:
+		markLogBlocked()
+		events <- e
+	}
+	var err error
+	if e.written != nil {
+		err = <-e.written
+	}
+	recordLatency("log.write", time.Since(start))
+	if err != nil {
+		return fmt.Errorf("%w. %v", errNotLogged, err)
+	}
+
+	// Logged changes are also the changes webhooks are notified of.
+	notifyWebhooks(e)
+	return nil
+}
+
+// Close closes the events channel, flushes any buffered events and closes the file descriptor for the transaction log.
+func (ftl *FileTransactionLogger) Close() error {
+	if ftl.events != nil {
+		close(ftl.events)
+
+		// Wait for the Log() goroutine to flush the remaining events.
+		<-ftl.done
+	}
+
+	return ftl.file.Close()
+}
+
+// Reopen closes the transaction log file and opens it again by its name, so that a log moved away by
+// logrotate is continued in a new file. Buffered events are flushed to the old file first. If the file
+// can't be opened again, the logger keeps writing to the old file.
+func (ftl *FileTransactionLogger) Reopen() error {
+	if ftl.reopen == nil {
+		return ftl.reopenFile()
+	}
+
+	// The Log() goroutine owns the file while it runs.
+	result := make(chan error)
+	select {
+	case ftl.reopen <- result:
+		return <-result
+	case <-ftl.done:
+		return errors.New("transaction log is closed")
+	}
+}
+
+// reopenFile replaces the file of the logger with a newly opened file of the same name.
+func (ftl *FileTransactionLogger) reopenFile() error {
+	file, version, err := openLogFile(ftl.file.Name())
+	if err != nil {
+		return err
+	}
+
+	ftl.file.Close()
+	ftl.file, ftl.version = file, version
+
+	return nil
+}
+
+// Compact replaces the transaction log with the given events, which must hold the state of the store
+// after every event sent so far. The events get IDs following the last ID, so that the IDs keep increasing.
+// taken is called once every earlier event is flushed, and events sent after it go to the new file.
+// It returns the size of the new file. If the new file can't be written, the logger keeps the old one.
+func (ftl *FileTransactionLogger) Compact(events []Event, taken func()) (int64, error) {
+	if ftl.compact == nil {
+		taken()
+		return ftl.replaceFile(events)
+	}
+
+	// The Log() goroutine owns the file while it runs.
+	c := compaction{events: events, taken: taken, result: make(chan compactionResult)}
+	select {
+	case ftl.compact <- c:
+		r := <-c.result
+		return r.size, r.err
+	case <-ftl.done:
+		return 0, errors.New("transaction log is closed")
+	}
+}
+
+// nextID returns the ID following last, or the next ID of the shared counter for a shard of a sharded log.
+func (ftl *FileTransactionLogger) nextID(last uint64) uint64 {
+	if ftl.ids == nil {
+		return last + 1
+	}
+
+	return atomic.AddUint64(ftl.ids, 1)
+}
+
+// Wait blocks until the WaitGroup counter for FileTransactionLogger is zero.
+func (ftl *FileTransactionLogger) Wait() {
+	ftl.wg.Wait()
+}
+
+// Err sends errors to the FileTransactionLogger's errors channel.
 func (ftl *FileTransactionLogger) Err() <-chan error {
 	return ftl.errors
 }
@@ -381,73 +1154,259 @@ func (ftl *FileTransactionLogger) LastID() uint64 {
 }
 
 // Log logs transactions to the transaction log.
+//
+// Events are buffered and flushed to the file once batchSize events are pending or
+// batchInterval has elapsed, whichever comes first. The WaitGroup is only released
+// for an event after the batch containing it has been flushed.
 func (ftl *FileTransactionLogger) Log() {
 	// Buffered channel for events.
-	events := make(chan Event, 16)
+	events := make(chan Event, ftl.bufferSize)
 	ftl.events = events
 
-	// Non-blocking buffer for sending errors.
-	errors := make(chan error, 1)
+	// Buffer for sending errors, which are dropped rather than blocking the logger once it's full.
+	errors := make(chan error, logErrorBuffer)
 	ftl.errors = errors
 
+	ftl.done = make(chan struct{})
+
+	reopen := make(chan chan error)
+	ftl.reopen = reopen
+
+	compact := make(chan compaction)
+	ftl.compact = compact
+
 	// Goroutine retrieves events from the events channel.
 	go func() {
-		for e := range events {
-			ftl.lastID++
+		defer close(ftl.done)
+
+		var buf bytes.Buffer
+		var batch []Event
+		ticker := time.NewTicker(ftl.batchInterval)
+		defer ticker.Stop()
+
+		// Number of events written to the buffer but not yet flushed.
+		pending := 0
+
+		// Whether the last failed flush left a partial line behind in the file. A partial line dropped
+		// while reading is cut off instead, so that it doesn't turn into a corrupt line in the middle of the log.
+		torn := ftl.unterminated
+		if ftl.partial {
+			if err := ftl.file.Truncate(ftl.partialAt); err != nil {
+				log.Printf("Error occurred while cutting off the partial transaction of the transaction log: %v", err)
+				torn = true
+			}
+		}
+
+		flush := func() {
+			if pending == 0 {
+				return
+			}
+
+			// A partial line is terminated, so that it doesn't corrupt the next event.
+			b := buf.Bytes()
+			if torn {
+				b = append([]byte("\n"), b...)
+			}
 
-			// Log the transaction in the log file.
-			_, err := fmt.Fprintf(ftl.file, ftlWriteFormat, ftl.lastID, e.EventType, e.Key, strings.TrimSpace(e.Value))
+			start := time.Now()
+			n, err := writeWithRetry(ftl.file, b)
+			recordLatency("log.flush", time.Since(start))
 
+			setLogHealth(err, pending)
+			if err == nil {
+				publishEvents(batch)
+				recordModifications(batch)
+				countLoggedEvents(pending)
+			}
+			torn = err != nil && n > 0
 			if err != nil {
-				// Send the error to errors channel.
-				errors <- err
-				// return
+				err = fmt.Errorf("%d events were lost. %w", pending, err)
+
+				// Send the error to errors channel, without blocking when nobody reads it.
+				select {
+				case errors <- err:
+				default:
+					log.Printf("Error occurred while writing the transaction log: %v", err)
+				}
 			}
 
-			ftl.wg.Done()
+			// The events are done with, whether they were written or not.
+			for _, e := range batch {
+				if e.written != nil {
+					e.written <- err
+				}
+			}
+			buf.Reset()
+			batch = batch[:0]
+			ftl.wg.Add(-pending)
+			atomic.AddInt64(&logPendingEvents, int64(-pending))
+			pending = 0
+		}
+
+		write := func(e Event) {
+			ftl.lastID = ftl.nextID(ftl.lastID)
+			e.ID = ftl.lastID
+
+			// Log the transaction in the buffer, which can't fail.
+			buf.WriteString(formatEvent(ftl.version, e))
+			buf.WriteByte('\n')
+			batch = append(batch, e)
+
+			pending++
+			if pending >= ftl.batchSize {
+				flush()
+			}
+		}
+
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					// Events channel is closed, flush whatever is left.
+					flush()
+					return
+				}
+
+				// Writes are only backed up as long as the channel doesn't drain.
+				if len(events) == 0 {
+					clearLogBlocked()
+				}
+
+				write(e)
+
+				// Synchronous writes are waiting, so they're flushed as soon as no more events are queued
+				// instead of after the batch interval.
+				if e.written != nil && len(events) == 0 {
+					flush()
+				}
+
+			case <-ticker.C:
+				flush()
+
+			case result := <-reopen:
+				// Events sent before the reopen still belong to the old file.
+				for n := len(events); n > 0; n-- {
+					write(<-events)
+				}
+				flush()
+				err := ftl.reopenFile()
+				if err == nil {
+					// A partial line left behind in the old file doesn't affect the new one.
+					torn = false
+				}
+				result <- err
+
+			case c := <-compact:
+				// Events sent before the snapshot was taken are part of it, but are still flushed to
+				// the old file in case writing the snapshot fails.
+				for n := len(events); n > 0; n-- {
+					write(<-events)
+				}
+				flush()
+				c.taken()
+				size, err := ftl.replaceFile(c.events)
+				if err == nil {
+					torn = false
+				}
+				c.result <- compactionResult{size, err}
+			}
 		}
 	}()
 }
 
 // ReadEvents reads all transactions from the transaction log.
+// Reading errors other than lines which are too long are retried with an exponential backoff, continuing
+// after the last complete line, and reported as a LogReadError once every attempt failed.
 func (ftl *FileTransactionLogger) ReadEvents() (<-chan Event, <-chan error) {
-	scanner := bufio.NewScanner(ftl.file) // Scanner for transaction log
-	outEvent := make(chan Event)          // Unbuffered channel for events.
-	outError := make(chan error, 1)       // Buffered channel for errors.
+	outEvent := make(chan Event, 256) // Buffered channel for events, so parsing runs ahead of replaying.
+	outError := make(chan error, 1)   // Buffered channel for errors.
 
 	// Goroutine for parsing transactions.
 	go func() {
-		var e Event
-
 		defer close(outEvent)
 		defer close(outError)
 
-		for scanner.Scan() {
-			line := scanner.Text()
+		// Offset of the end of the last complete line, where reading continues after an error.
+		offset, err := ftl.file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			outError <- &LogReadError{Err: err}
+			return
+		}
+		start := offset
+
+		// Offset of the last line when the file doesn't end with a newline.
+		unterminated := int64(-1)
+
+		lineNumber := 0
+		delay := logReadRetryDelay
+		for attempt := 1; ; attempt++ {
+			scanner := newLogScanner(ftl.file, ftl.readEnd, &offset, &unterminated) // Scanner for transaction log
+
+			for scanner.Scan() {
+				lineNumber++
+
+				// The header was already read when the logger was created, and reading only starts over at it
+				// from the start of the file.
+				if lineNumber == 1 && start == 0 && ftl.version >= 1 {
+					continue
+				}
+
+				e, err := parseEvent(ftl.version, scanner.Text())
+
+				// Every transaction ends with a newline, so a last line without one which doesn't parse was
+				// cut short by an interrupted write, rather than corrupted.
+				if err != nil && unterminated >= 0 && config.repairLog {
+					log.Printf("dropping the partial transaction on line %d of the transaction log, left behind by an interrupted write: %v", lineNumber, err)
+					ftl.partial, ftl.partialAt = true, unterminated
+					continue
+				}
+				if err != nil && unterminated >= 0 {
+					outError <- fmt.Errorf("failed while parsing line %d, which was cut short by an interrupted write, start with -repair-log to drop it. %w", lineNumber, err)
+					return
+				}
+				if err != nil && config.repairLog {
+					// Corrupt transactions are skipped rather than applied in repair mode.
+					log.Printf("skipping corrupt transaction on line %d of the transaction log: %v", lineNumber, err)
+					continue
+				}
+				if err != nil {
+					outError <- fmt.Errorf("failed while parsing line %d. %w", lineNumber, err)
+					return
+				}
+
+				// Checks for seqeuence. Abnormal sequences are not suitable for replaying transactions.
+				if ftl.lastID >= e.ID {
+					outError <- fmt.Errorf("transaction IDs out of sequence. %d != %d", ftl.lastID, e.ID)
+					return
+				}
+
+				// Last used ID is updated to current value.
+				ftl.lastID = e.ID
+
+				// Sends the event to the outEvent channel.
+				outEvent <- e
+			}
 
-			// Scans the transaction from the log.
-			if _, err := fmt.Sscanf(line, ftlReadFormat, &e.ID, &e.EventType, &e.Key, &e.Value); err != nil {
-				outError <- fmt.Errorf("failed while parsing input. %w", err)
+			err := scanner.Err()
+			if err == nil {
+				ftl.unterminated = unterminated >= 0 && !ftl.partial
 				return
 			}
 
-			// Checks for seqeuence. Abnormal sequences are not suitable for replaying transactions.
-			if ftl.lastID >= e.ID {
-				outError <- fmt.Errorf("transaction IDs out of sequence. %d != %d", ftl.lastID, e.ID)
+			// Send the error to the outError channel once retrying can't help.
+			if errors.Is(err, bufio.ErrTooLong) || attempt == logReadMaxAttempts {
+				outError <- &LogReadError{Line: lineNumber, Err: err}
 				return
 			}
 
-			// Last used ID is updated to current value.
-			ftl.lastID = e.ID
+			log.Printf("Error occurred while reading the transaction log after line %d, retrying in %v: %v", lineNumber, delay, err)
+			time.Sleep(delay)
+			delay *= 2
 
-			// Sends the event to the outEvent channel.
-			outEvent <- e
-		}
-
-		// Send any error to the outError channel.
-		if err := scanner.Err(); err != nil {
-			outError <- fmt.Errorf("failed reading transaction log. %w", err)
-			return
+			if _, err := ftl.file.Seek(offset, io.SeekStart); err != nil {
+				outError <- &LogReadError{Line: lineNumber, Err: err}
+				return
+			}
 		}
 	}()
 
@@ -456,13 +1415,120 @@ func (ftl *FileTransactionLogger) ReadEvents() (<-chan Event, <-chan error) {
 
 // NewFileTransactionLogger creates a new file-based transaction logger.
 func NewFileTransactionLogger(filename string) (TransactionLogger, error) {
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0755)
+	file, version, err := openLogFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fall back to the defaults when batching isn't configured.
+	batchSize := config.logBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultLogBatchSize
+	}
+
+	batchInterval := config.logBatchInterval
+	if batchInterval <= 0 {
+		batchInterval = defaultLogBatchInterval
+	}
 
+	bufferSize := config.logBufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultLogBufferSize
+	}
+
+	return &FileTransactionLogger{file: file, wg: &sync.WaitGroup{}, batchSize: batchSize, batchInterval: batchInterval, bufferSize: bufferSize, version: version}, nil
+}
+
+// newFileLogReader opens the transaction log read-only, so that its events can be read without writing to it.
+func newFileLogReader(filename string) (TransactionLogger, error) {
+	file, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read transaction log file. %w", err)
 	}
 
-	return &FileTransactionLogger{file: file, wg: &sync.WaitGroup{}}, nil
+	// An empty log doesn't have the header a new log gets.
+	version := ftlVersion
+	if info, err := file.Stat(); err != nil || info.Size() > 0 {
+		if version, err = detectLogVersion(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &FileTransactionLogger{file: file, wg: &sync.WaitGroup{}, version: version}, nil
+}
+
+// openLogFile opens the transaction log for reading and appending, creating it if needed, and returns it along with its format version.
+func openLogFile(filename string) (*os.File, int, error) {
+	// Fail early if events could never be written to the transaction log.
+	if err := checkLogWritable(filename); err != nil {
+		return nil, 0, err
+	}
+
+	mode := config.logFileMode
+	if mode == 0 {
+		mode = defaultLogFileMode
+	}
+
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, mode)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read transaction log file. %w", err)
+	}
+
+	version, err := detectLogVersion(file)
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+
+	return file, version, nil
+}
+
+// checkLogWritable makes sure the transaction log path can be written to, so that a bad path fails at startup
+// instead of silently dropping every event later on.
+func checkLogWritable(filename string) error {
+	info, err := os.Stat(filename)
+	if err == nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("transaction log %q is not a regular file, use the -filename flag to choose another path", filename)
+	}
+
+	// Probe with a throwaway file, as the transaction log itself is append-only.
+	probe, err := os.CreateTemp(filepath.Dir(filename), ".yakv-probe-*")
+	if err != nil {
+		return fmt.Errorf("directory of transaction log %q is not writable. %w", filename, err)
+	}
+	defer os.Remove(probe.Name())
+
+	if _, err = probe.Write([]byte("yakv")); err != nil {
+		probe.Close()
+		return fmt.Errorf("directory of transaction log %q is not writable. %w", filename, err)
+	}
+
+	return probe.Close()
+}
+
+// applyEvent performs a transaction read from the transaction log on the store.
+func applyEvent(e Event) error {
+	recordModification(e)
+
+	switch {
+	case e.Database != 0:
+		return replayDatabaseEvent(e)
+	case e.Namespace != "" || e.EventType == EventDropNamespace:
+		return replayNamespaceEvent(e)
+	case e.EventType == EventDelete:
+		return deleteStored(e.Key, nil)
+	case e.EventType == EventPut:
+		if err := putStored(e.Key, e.Value, e.Compressed, expiryTime(e.Expiry), e.ContentType, e.Pinned); err != nil {
+			return err
+		}
+		restoreVersion(e)
+	case e.EventType == EventTouch:
+		return replayTouch(e)
+	}
+
+	return nil
 }
 
 // InitLog initializes the transaction log and mutates the state of the key-value store by replaying previously stored transactions.
@@ -470,36 +1536,142 @@ func InitLog(filename string) error {
 	var err error
 
 	// Filename for logs is "transaction.log" by default.
-	logger, err = NewFileTransactionLogger(filename)
+	logger, err = NewTransactionLogger(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create logger! %w", err)
 	}
+	transactionLogFilename = filename
+
+	// A store which is only a cache starts empty, and new transactions follow the ones in the log.
+	if config.noReplay {
+		fmt.Println("yakv is skipping the previous transactions of the log.... ⏭")
+		if err := logger.SkipEvents(); err != nil {
+			return fmt.Errorf("failed to read the last transaction of the log! %w", err)
+		}
+
+		logger.Log()
+		return nil
+	}
 
 	// Reads all events and errors.
 	fmt.Println("yakv is reading previous transactions from the log.... 🔎")
 	events, errors := logger.ReadEvents()
+	events, errors = replayUntil(events, errors, config.replayUntil)
 	e, ok := Event{}, true
 
 	// Checks each transaction and performs it (i.e. replaying).
 	fmt.Println("yakv is replaying all previous transactions.... ⏯")
-	for ok && err == nil {
+	replaying = true
+	defer func() { replaying = false }()
+	if config.collapseReplay {
+		err = replayCollapsed(events, errors)
+	}
+	for ok && err == nil && !config.collapseReplay {
 		select {
 		case err, ok = <-errors:
 		case e, ok = <-events:
-			switch e.EventType {
-			case EventDelete:
-				err = Delete(e.Key)
-			case EventPut:
-				err = Put(e.Key, e.Value)
+			if ok {
+				err = applyEvent(e)
 			}
 		}
 	}
 
+	// The events channel might have been closed before a pending error was received.
+	if err == nil && !config.collapseReplay {
+		err = <-errors
+	}
+
+	// A log which couldn't be read to its end leaves the store incomplete, which is only accepted on request.
+	readErr, partial := asLogReadError(err)
+	partial = partial && config.allowPartialReplay
+	if partial {
+		log.Printf("Starting with the transactions up to line %d of the transaction log only, as requested by -allow-partial-replay: %v", readErr.Line, err)
+		err = nil
+	}
+
+	// A replay which failed may have stopped while the log was still being read, and the reader is waited for
+	// before giving up, since it still uses the logger. The logger isn't started then.
+	if err != nil {
+		for range events {
+		}
+		for range errors {
+		}
+		return err
+	}
+
+	// Spill files which neither a replayed value nor a transaction references are left over. After a partial
+	// replay, the transactions which weren't read might still reference them.
+	if err == nil && config.spillThreshold > 0 && config.replayUntil == 0 && !partial {
+		var n int
+		if n, err = removeOrphanedSpills(time.Time{}); n > 0 {
+			fmt.Printf("yakv removed %d orphaned spill files\n", n)
+		}
+	}
+
 	// Actively call Log() to log transactions to the transaction log.
 	logger.Log()
 	return err
 }
 
+// newEngine returns an engine with the middleware shared by the public and the admin listener, but no routes.
+// With auditClients, the clients authenticated with certificates are logged.
+func newEngine(ctx context.Context, auditClients bool) *gin.Engine {
+	r := gin.Default()
+	r.RedirectTrailingSlash = config.redirectTrailingSlash
+
+	// Security headers are set first, so that they are sent along with every error.
+	if config.secureHeaders {
+		r.Use(SecureHeadersMiddleware())
+	}
+
+	// Log bodies before any middleware can respond, so that rejected requests are logged too.
+	if config.debugBodies {
+		r.Use(DebugBodiesMiddleware(config.debugBodyLimit))
+	}
+
+	// Count the requests being served, rejecting them before any other work once too many are.
+	r.Use(ConcurrencyMiddleware(config.maxConcurrency))
+
+	// Audit the clients authenticated with certificates.
+	if auditClients {
+		r.Use(ClientCertMiddleware())
+	}
+
+	// Trace requests when tracing is enabled.
+	if config.otelEndpoint != "" {
+		r.Use(TracingMiddleware())
+	}
+
+	// A store restored to a point in time is only inspected, never modified.
+	if config.replayUntil > 0 {
+		r.Use(ReadOnlyMiddleware())
+	}
+
+	// Authenticate requests when an API key or tenants are configured.
+	if config.apiKey != "" || hasTenants() {
+		r.Use(APIKeyMiddleware(config.apiKey))
+	}
+
+	// Compress large responses.
+	if config.gzipResponses {
+		r.Use(GzipMiddleware(config.gzipMinSize))
+	}
+
+	// Limit the rate of requests for each client.
+	if config.rateLimit > 0 {
+		limiter := newRateLimiter(config.rateLimit, config.rateBurst)
+		go limiter.runCleanup(ctx)
+		r.Use(RateLimitMiddleware(limiter))
+	}
+
+	// Unknown routes get an error response like every other error.
+	r.NoRoute(func(c *gin.Context) {
+		writeError(c.Writer, "route not found", http.StatusNotFound)
+	})
+
+	return r
+}
+
 func main() {
 	// Filename for the transaction log extracted from the -filename flag.
 	var logFilename string
@@ -513,39 +1685,473 @@ func main() {
 	flag.IntVar(&config.port, "port", 8080, "Port Number.")
 	flag.StringVar(&config.host, "host", "127.0.0.1", "Host Address.")
 
+	// REPL client mode, connecting to a running server
+	var clientMode bool
+	var serverAddr string
+
 	// default connections are not secured using TLS
 	flag.BoolVar(&secure, "secure", false, "TLS-secured connection.")
 	flag.StringVar(&certFilename, "cert", "cert.pem", "Filename for certificate.")
 	flag.StringVar(&keyFilename, "key", "key.pem", "Filename for private key.")
 
+	// clients aren't authenticated with certificates by default
+	var clientCAFilename string
+	flag.StringVar(&clientCAFilename, "client-ca", "", "PEM file of the certificate authorities client certificates must be signed by, enabling mutual TLS.")
+
+	// HTTPS can be served on a separate port next to plaintext HTTP
+	var tlsPort int
+	var redirectHTTPS bool
+	flag.IntVar(&tlsPort, "tls-port", 0, "Port Number for serving HTTPS next to HTTP on -port, 0 disables it.")
+	flag.BoolVar(&redirectHTTPS, "redirect-https", false, "Redirect plaintext requests (except health checks) to HTTPS on -tls-port.")
+	flag.BoolVar(&config.secureHeaders, "secure-headers", false, "Send X-Content-Type-Options, X-Frame-Options and, over TLS, Strict-Transport-Security headers.")
+
+	// connections are closed after the timeouts, 0 disables a timeout
+	flag.DurationVar(&config.readTimeout, "read-timeout", defaultReadTimeout, "Maximum duration for reading a request, including its body, 0 disables the timeout.")
+	flag.DurationVar(&config.writeTimeout, "write-timeout", defaultWriteTimeout, "Maximum duration for writing a response, 0 disables the timeout.")
+	flag.DurationVar(&config.idleTimeout, "idle-timeout", defaultIdleTimeout, "Maximum duration a keep-alive connection stays idle, 0 disables the timeout.")
+
+	// plaintext listeners only speak HTTP/1.1 by default
+	flag.BoolVar(&config.h2c, "h2c", false, "Accept HTTP/2 over cleartext (h2c) on the plaintext listeners, along with HTTP/1.1.")
+	flag.DurationVar(&config.shutdownTimeout, "shutdown-timeout", defaultShutdownTimeout, "Maximum duration for in-flight requests to finish on shutdown.")
+	flag.DurationVar(&config.logFlushTimeout, "log-flush-timeout", defaultLogFlushTimeout, "Maximum duration for the transaction log to be flushed on shutdown, after in-flight requests finished.")
+	flag.DurationVar(&config.drainGrace, "drain-grace", defaultDrainGrace, "Duration a draining instance keeps serving requests before it shuts down, 0 keeps it serving until it's stopped.")
+
 	// default transaction log filename is "transaction.log"
-	flag.StringVar(&logFilename, "filename", "transaction.log", "Filename for the transaction log.")
+	flag.StringVar(&logFilename, "filename", "transaction.log", "Filename for the transaction log, or for the BoltDB file with -backend=bolt (default \"yakv.db\").")
+
+	// the store is persisted to the transaction log by default
+	flag.StringVar(&config.backend, "backend", logBackend, "Backend persisting the store, log for the transaction log or bolt for a BoltDB file.")
+
+	// transaction log writes are batched to reduce the number of syscalls
+	flag.IntVar(&config.logBatchSize, "log-batch-size", defaultLogBatchSize, "Number of events buffered before flushing the transaction log.")
+	flag.DurationVar(&config.logBatchInterval, "log-batch-interval", defaultLogBatchInterval, "Maximum time an event stays buffered before flushing the transaction log.")
+
+	// the transaction log is only snapshotted on request by default
+	flag.DurationVar(&config.snapshotInterval, "snapshot-interval", 0, "Interval for replacing the transaction log with a snapshot of the store, 0 disables timed snapshots.")
+	flag.IntVar(&config.snapshotEveryNEvents, "snapshot-every-n-events", 0, "Number of transactions after which the transaction log is replaced with a snapshot of the store, 0 disables it.")
+	flag.IntVar(&config.keepVersions, "keep-versions", 0, "Number of the latest versions of every key kept by snapshots, which compact the transaction log instead of replacing it with the store, 0 only keeps the store.")
+
+	// writes return before their events are written by default, synchronous writes wait for the transaction log
+	flag.BoolVar(&config.syncWrites, "sync-writes", false, "Wait for each write's event to be written to the transaction log before responding.")
+
+	// the transaction log is a single file by default, shards spread the writes of different keys across files
+	flag.IntVar(&config.logShards, "log-shards", defaultLogShards, "Number of files the transaction log is sharded across by key.")
+
+	// failing writes to the transaction log only make yakv unhealthy until a write succeeds by default
+	flag.IntVar(&config.maxLogErrors, "max-log-errors", 0, "Number of failed writes to the transaction log after which yakv stays unhealthy until restarted, 0 disables it.")
+
+	// writes are accepted while the transaction log fails by default
+	flag.IntVar(&config.autoReadOnlyAfter, "auto-read-only-after", 0, "Number of consecutive failed writes to the transaction log after which writes are rejected until the log recovers, 0 disables it.")
+	flag.DurationVar(&config.autoReadOnlyCooldown, "auto-read-only-cooldown", defaultAutoReadOnlyCooldown, "Duration writes are rejected for with -auto-read-only-after, before they're let through to find out whether the log recovered.")
+
+	// writes wait for the transaction log once its buffer is full, and can be rejected instead once they wait for too long
+	flag.IntVar(&config.logBufferSize, "log-buffer-size", defaultLogBufferSize, "Number of events queued for the transaction log before writes wait.")
+	flag.DurationVar(&config.backpressureThreshold, "backpressure-threshold", defaultBackpressureThreshold, "Time the transaction log's queue has to stay backed up before reporting backpressure.")
+	flag.BoolVar(&config.rejectOnBackpressure, "reject-on-backpressure", false, "Reject writes with 503 while the transaction log is under backpressure.")
+
+	// default permissions for a new transaction log are 0644
+	config.logFileMode = defaultLogFileMode
+	flag.Func("log-mode", "Octal file permissions for a newly created transaction log. (default 0644)", func(value string) error {
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid file mode %q. %w", value, err)
+		}
+
+		config.logFileMode = os.FileMode(mode)
+		return nil
+	})
+
+	// expired keys are swept every second by default
+	flag.DurationVar(&config.expirySweepInterval, "expiry-sweep-interval", defaultExpirySweepInterval, "Interval for sweeping expired keys, 0 disables the sweeper.")
+
+	// TTLs are applied exactly by default
+	flag.Float64Var(&config.ttlJitter, "ttl-jitter", 0, "Percentage by which TTLs are randomly shortened or lengthened, so that keys written together don't expire together, 0 disables it.")
+
+	// rate limiting is disabled by default
+	flag.Float64Var(&config.rateLimit, "rate-limit", 0, "Requests per second allowed for each client, 0 disables rate limiting.")
+	flag.IntVar(&config.rateBurst, "rate-burst", 10, "Maximum burst of requests allowed for each client.")
+
+	// concurrent requests aren't limited by default
+	flag.IntVar(&config.maxConcurrency, "max-concurrency", 0, "Maximum number of requests served at once, excess requests get 503, 0 disables the limit.")
+	flag.IntVar(&config.maxConnsPerIP, "max-conns-per-ip", 0, "Maximum number of open connections from a single IP address, excess connections are closed, 0 disables the limit.")
+
+	// requests are not authenticated by default
+	flag.StringVar(&config.apiKey, "api-key", "", "API key required in the X-API-Key header of requests, and sent by the client.")
+
+	flag.BoolVar(&clientMode, "client", false, "Start a REPL connected to a running server instead of starting a server.")
+	flag.StringVar(&serverAddr, "server", "http://127.0.0.1:8080", "Address of the server the REPL connects to.")
+
+	// the transaction log can be checked without starting a server
+	var selfTest bool
+	flag.BoolVar(&selfTest, "selftest", false, "Check that the transaction log is readable and in sequence, print a summary and exit.")
+
+	var migrateLog bool
+	flag.BoolVar(&migrateLog, "migrate-log", false, "Rewrite the transaction log in the current format, keeping the old log next to it, and exit.")
+
+	// transactions are replayed one by one by default
+	flag.BoolVar(&config.collapseReplay, "collapse-replay", false, "Collapse the transaction log to the final state of each key before replaying it, using memory for the whole log.")
+	flag.BoolVar(&config.noReplay, "no-replay", false, "Start with an empty store instead of replaying the transaction log, appending new transactions to it.")
+
+	// values are never compressed by default
+	flag.IntVar(&config.compressThreshold, "compress-threshold", 0, "Minimum size in bytes of values which are stored gzip-compressed, 0 disables compression.")
+
+	// values are kept in memory by default, large values can be spilled to files instead
+	flag.IntVar(&config.spillThreshold, "spill-threshold", 0, "Minimum size in bytes of stored values which are written to files of their own instead of memory, 0 disables spilling.")
+	flag.StringVar(&config.spillDir, "spill-dir", defaultSpillDir, "Directory spilled values are written to.")
+
+	// tracing is disabled by default
+	flag.StringVar(&config.otelEndpoint, "otel-endpoint", "", "OTLP/HTTP endpoint (host:port) for exporting traces, tracing is disabled if unset.")
+
+	// routes are mounted under yakv/v0 by default
+	flag.StringVar(&config.routePrefix, "route-prefix", defaultRoutePrefix, "Comma-separated list of prefixes the routes are mounted under, e.g. yakv/v0,api/v1.")
+
+	// paths with an extra or a missing trailing slash are redirected by default
+	flag.BoolVar(&config.redirectTrailingSlash, "redirect-trailing-slash", true, "Redirect requests whose path only differs from a route by a trailing slash to the route, instead of responding with 404.")
+
+	// admin routes are served next to the other routes, unless they get a listener of their own
+	flag.StringVar(&config.adminPrefix, "admin-prefix", defaultAdminPrefix, "Prefix the admin routes are mounted under, relative to the route prefix.")
+	flag.IntVar(&config.adminPort, "admin-port", 0, "Port Number for serving the admin routes on a separate listener, 0 serves them next to the other routes.")
+	flag.StringVar(&config.adminHost, "admin-host", "127.0.0.1", "Host address for the admin listener.")
+
+	// the binary protocol is disabled by default
+	flag.IntVar(&config.binPort, "bin-port", 0, "Port Number for serving GET, PUT and DELETE over the binary TCP protocol, 0 disables it.")
+
+	// corrupt transactions stop the replay by default
+	flag.BoolVar(&config.repairLog, "repair-log", false, "Skip corrupt transactions while replaying the transaction log instead of failing.")
+
+	// a transaction log which can't be read to its end stops the start-up by default
+	flag.BoolVar(&config.allowPartialReplay, "allow-partial-replay", false, "Start with the transactions read so far when the transaction log can't be read to its end after retrying, instead of failing.")
+
+	// the flush endpoint is disabled by default
+	flag.BoolVar(&config.allowFlush, "allow-flush", false, "Enable the admin endpoint which deletes every key of the store.")
+
+	// dumping and loading the whole store is disabled by default
+	flag.BoolVar(&config.allowDump, "allow-dump", false, "Enable the endpoints dumping the whole store as a single JSON object, and loading such an object.")
+	flag.IntVar(&config.maxDumpSize, "max-dump-size", defaultMaxDumpSize, "Maximum size in bytes of the keys and values of a dump, 0 disables the limit.")
+
+	// slow operations are not logged by default
+	flag.DurationVar(&config.slowThreshold, "slow-threshold", 0, "Log a warning for operations slower than this duration, 0 disables the warnings.")
+
+	// every transaction is replayed by default
+	flag.Uint64Var(&config.replayUntil, "replay-until", 0, "Replay the transaction log only up to this event ID and serve the store read-only, 0 replays every transaction.")
+
+	// values aren't validated by default
+	flag.StringVar(&config.schemaFile, "schema-file", "", "JSON file mapping key prefixes to the JSON schemas their values are validated against.")
+
+	// listing keys holds the lock for the whole listing by default
+	flag.BoolVar(&config.copyOnRead, "copy-on-read", false, "Copy the matching entries when listing keys, holding the lock only while copying.")
+
+	// large responses are gzip-compressed by default
+	flag.BoolVar(&config.gzipResponses, "gzip-responses", true, "Gzip-compress large responses for clients accepting gzip.")
+	flag.IntVar(&config.gzipMinSize, "gzip-min-size", defaultGzipMinSize, "Minimum size in bytes of the responses which are gzip-compressed.")
+
+	// bodies aren't logged by default, for privacy and performance
+	flag.BoolVar(&config.debugBodies, "debug-bodies", false, "Log the request and response bodies of every request, for debugging clients.")
+	flag.IntVar(&config.debugBodyLimit, "debug-body-limit", defaultDebugBodyLimit, "Maximum number of bytes logged of each request and response body with -debug-bodies.")
+
+	// unknown fields in request bodies are rejected by default
+	flag.BoolVar(&config.lenientJSON, "lenient-json", false, "Ignore unknown fields in request bodies instead of rejecting them.")
+
+	// bulk requests are limited to 1000 keys by default
+	flag.IntVar(&config.maxBulkKeys, "max-bulk-keys", defaultMaxBulkKeys, "Maximum number of keys in a single bulk request, 0 disables the limit.")
+
+	// reads of every key under a prefix are limited to 1000 keys by default
+	flag.IntVar(&config.maxGetAllKeys, "max-getall-keys", defaultMaxGetAllKeys, "Maximum number of keys whose values a single /getall returns, 0 disables the limit.")
+
+	// exports are limited to 100000 keys by default
+	flag.IntVar(&config.maxExportKeys, "max-export-keys", defaultMaxExportKeys, "Maximum number of keys a single /export copies and streams, 0 disables the limit.")
+	flag.IntVar(&config.maxValueSize, "max-value-size", defaultMaxValueSize, "Maximum size of a value in bytes, e.g. after appending to it, 0 disables the limit.")
+
+	// the number of keys is unlimited by default
+	flag.IntVar(&config.maxKeys, "max-keys", 0, "Maximum number of keys of the default store, new keys evict the least recently used keys which aren't pinned, 0 disables the limit.")
+	flag.BoolVar(&config.maxKeysReject, "max-keys-reject", false, "Reject new keys once the store holds -max-keys keys instead of evicting keys.")
+
+	// PUT creates and overwrites keys by default
+	flag.StringVar(&config.putMode, "put-mode", putModeOverwrite, "Keys a PUT may write: overwrite, reject-existing to never overwrite keys, or create-only-via-setnx to only overwrite keys created with /setnx.")
+
+	// responses to reads aren't cacheable by default
+	flag.DurationVar(&config.getCacheTTL, "get-cache-ttl", 0, "Duration responses to reads may be cached for by clients and proxies, sent as Cache-Control: max-age, 0 disables it.")
+
+	// keys are case-sensitive by default
+	flag.BoolVar(&config.caseInsensitiveKeys, "case-insensitive-keys", false, "Lowercase keys before storing and logging them, so keys differing only in case address the same entry.")
+
+	// reads aren't counted by default
+	flag.BoolVar(&config.trackAccess, "track-access", false, "Count the reads of every key to list the most read keys with /hot and the idle keys with /idle, at the cost of slower reads.")
+
+	// values aren't indexed by default
+	flag.BoolVar(&config.enableValueIndex, "enable-value-index", false, "Index values to look up the keys holding a value with /find, at the cost of memory and slower writes.")
+
+	// the store grows as keys are replayed by default
+	flag.IntVar(&config.storeHint, "store-hint", 0, "Expected number of keys, the store is allocated with room for them before replaying, 0 lets it grow instead.")
+
+	// only the default database exists by default
+	flag.IntVar(&config.numDatabases, "num-databases", 1, "Number of independent databases requests select with the X-YAKV-DB header, database 0 being the default store.")
+
+	// no PID file is written by default
+	flag.StringVar(&config.pidFile, "pidfile", "", "File the process ID is written to on start-up, and removed from on shutdown.")
+
+	// flags and default TTLs can also be set in a config file
+	flag.StringVar(&config.configFile, "config", "", "JSON config file with flags and default TTLs of key prefixes, flags on the command line take precedence.")
 
 	flag.Parse()
 
+	if config.configFile != "" {
+		if err := loadConfigFile(config.configFile); err != nil {
+			log.Fatalf("Error occurred while loading the config file: %v", err)
+		}
+	}
+
+	if err := checkBackend(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := checkBinPort(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := checkTTLJitter(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := checkPutMode(); err != nil {
+		log.Fatal(err)
+	}
+
+	if config.storeHint < 0 {
+		log.Fatal("-store-hint must not be negative")
+	}
+	if config.maxKeys < 0 {
+		log.Fatal("-max-keys must not be negative")
+	}
+	if config.keepVersions < 0 {
+		log.Fatal("-keep-versions must not be negative")
+	}
+	if config.numDatabases < 1 {
+		log.Fatal("-num-databases must be at least 1")
+	}
+	if config.numDatabases > 1 && config.backend == boltBackend {
+		log.Fatal(errBoltDatabases)
+	}
+	if config.numDatabases > 1 && config.maxKeys > 0 {
+		log.Fatal(errMaxKeysDatabases)
+	}
+	if config.noReplay && config.replayUntil > 0 {
+		log.Fatal("-no-replay can't be combined with -replay-until")
+	}
+	if config.noReplay && config.backend == boltBackend {
+		log.Fatal(errBoltNoReplay)
+	}
+
+	// BoltDB files get a default filename of their own.
+	if config.backend == boltBackend {
+		filenameSet := false
+		flag.Visit(func(f *flag.Flag) { filenameSet = filenameSet || f.Name == "filename" })
+		if !filenameSet {
+			logFilename = defaultBoltFilename
+		}
+	}
+
+	if clientMode {
+		if err := runREPL(os.Stdin, os.Stdout, client.New(serverAddr, config.apiKey)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if selfTest {
+		if !runSelfTest(os.Stdout, logFilename) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if migrateLog {
+		if !runMigrateLog(os.Stdout, logFilename) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if config.pidFile != "" {
+		if err := writePIDFile(config.pidFile); err != nil {
+			log.Fatalf("Error occurred while writing the PID file: %v", err)
+		}
+		defer os.Remove(config.pidFile)
+	}
+
 	addr := fmt.Sprintf("%s:%d", config.host, config.port)
 	fmt.Printf("yakv is starting on address: %s 🥳\n", addr)
 	fmt.Println("yakv is up and running! 🚀🥳")
 
+	// The store is allocated up front, instead of growing while replaying.
+	if config.storeHint > 0 {
+		store.presize(config.storeHint)
+	}
+
+	// Every database has to exist before replaying, so that their transactions can be applied.
+	initDatabases(config.numDatabases)
+
+	// The index has to be set up before replaying, so replayed values are indexed too.
+	if config.enableValueIndex {
+		store.index = newValueIndex()
+	}
+
+	// Schemas only apply to new values, replayed values are not validated.
+	if config.schemaFile != "" {
+		if err := loadSchemaFile(config.schemaFile); err != nil {
+			log.Fatalf("Error occurred while loading schemas: %v", err)
+		}
+	}
+
 	fmt.Println("yakv is initializing the transaction log! 🔨")
 
-	err := InitLog(logFilename)
+	var err error
+	if config.backend == boltBackend {
+		err = InitBolt(logFilename)
+	} else {
+		err = InitLog(logFilename)
+	}
 	if err != nil {
-		_ = fmt.Errorf("Error occurred while initializing log: %w", err)
+		log.Fatalf("Error occurred while initializing log: %v", err)
 	}
 
-	// yakv URLs are set to v0.
-	r := gin.Default()
-	r.GET("yakv/v0/get", gin.WrapF(GetHandler))
-	r.PUT("yakv/v0/put", gin.WrapF(PutHandler))
-	r.DELETE("yakv/v0/delete", gin.WrapF(DeleteHandler))
-
-	// Handle secure flag and serve.
-	if secure {
-		fmt.Println("yakv is running in secure mode.... 🔒")
-		log.Fatal(http.ListenAndServeTLS(addr, certFilename, keyFilename, r))
+	store.RLock()
+	fmt.Printf("yakv replayed %d keys up to transaction %d\n", len(store.m), replayedID)
+	store.RUnlock()
+
+	// Shutdown is triggered by SIGINT or SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// So is the end of the grace period of a drain.
+	shutdownAfterDrain = stop
+
+	// SIGHUP reopens the transaction log after it was rotated.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go reopenOnSignal(ctx, hup)
+
+	// Errors writing the transaction log are logged and counted until shutdown.
+	go drainLogErrors(ctx, logger.Err())
+
+	// Changes are delivered to webhooks in the background until shutdown.
+	go runWebhooks(ctx)
+
+	// Export traces when an OTLP endpoint is configured.
+	if config.otelEndpoint != "" {
+		shutdownTracing, err := initTracing(ctx, config.otelEndpoint)
+		if err != nil {
+			log.Fatalf("Error occurred while initializing tracing: %v", err)
+		}
+		defer shutdownTracing(context.Background())
+	}
+
+	// yakv URLs are set to v0 by default.
+	r := newEngine(ctx, clientCAFilename != "")
+
+	// Mount the routes under every configured prefix.
+	for _, prefix := range routePrefixes(config.routePrefix) {
+		registerRoutes(r, prefix)
+	}
+
+	// The health and readiness checks and the metrics are served outside of the prefixes, for load balancers and scrapers.
+	r.GET("/healthz", gin.WrapF(HealthHandler))
+	r.GET("/readyz", gin.WrapF(ReadyHandler))
+	r.GET("/metrics", gin.WrapF(MetricsHandler))
+	registerMethodRoutes(r)
+
+	// Expired keys are swept in the background until shutdown.
+	if config.expirySweepInterval > 0 && config.replayUntil == 0 {
+		go runExpirySweeper(ctx, config.expirySweepInterval)
+	}
+
+	// The transaction log is snapshotted in the background until shutdown.
+	if (config.snapshotInterval > 0 || config.snapshotEveryNEvents > 0) && config.replayUntil == 0 && !config.noReplay {
+		go runSnapshots(ctx, config.snapshotInterval)
+	}
+
+	// Handle secure flag and serve. With a TLS port, HTTP and HTTPS are served at the same time.
+	var listeners []listener
+	if tlsPort > 0 {
+		var plain http.Handler = r
+		if redirectHTTPS {
+			plain = redirectToHTTPS(r, tlsPort)
+		}
+
+		tlsAddr := fmt.Sprintf("%s:%d", config.host, tlsPort)
+		listeners = []listener{
+			{server: newServer(addr, plain)},
+			{server: newServer(tlsAddr, r), tls: true},
+		}
 	} else {
-		fmt.Println("yakv is running in insecure mode.... 🔓❎")
-		log.Fatal(http.ListenAndServe(addr, r))
+		listeners = []listener{{server: newServer(addr, r), tls: secure}}
+	}
+
+	// The admin routes get a listener of their own, which can be firewalled separately.
+	if config.adminPort > 0 {
+		admin := newEngine(ctx, clientCAFilename != "")
+		for _, prefix := range routePrefixes(config.routePrefix) {
+			registerAdminRoutes(admin, prefix)
+		}
+		registerMethodRoutes(admin)
+
+		adminAddr := fmt.Sprintf("%s:%d", config.adminHost, config.adminPort)
+		listeners = append(listeners, listener{server: newServer(adminAddr, admin), tls: secure || tlsPort > 0})
+	}
+
+	// With a client CA, connections to the TLS listener without a valid client certificate are rejected.
+	if clientCAFilename != "" {
+		if err := checkClientCAListeners(secure, tlsPort, redirectHTTPS, config.binPort); err != nil {
+			log.Fatal(err)
+		}
+
+		tlsConfig, err := newClientAuthTLSConfig(clientCAFilename)
+		if err != nil {
+			log.Fatalf("Error occurred while loading the client CA: %v", err)
+		}
+
+		for _, l := range listeners {
+			if l.tls {
+				l.server.TLSConfig = tlsConfig
+			}
+		}
+	}
+
+	serve(listeners, certFilename, keyFilename)
+
+	// The binary protocol is served on a TCP listener of its own.
+	var bin *binServer
+	if config.binPort > 0 {
+		binAddr := fmt.Sprintf("%s:%d", config.host, config.binPort)
+		ln, err := net.Listen("tcp", binAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if config.maxConnsPerIP > 0 {
+			ln = limitConnsPerIP(ln, config.maxConnsPerIP)
+		}
+
+		fmt.Printf("yakv is serving the binary protocol on %s.... 🔓❎\n", binAddr)
+		bin = serveBinary(ln)
+	}
+
+	<-ctx.Done()
+	fmt.Println("yakv is shutting down.... 👋")
+
+	// Let in-flight requests finish before the transaction log is closed. Each has a deadline of its own, so
+	// that a stuck request can't eat up the time for flushing the log, and a stuck write can't hang the shutdown.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.shutdownTimeout)
+	defer cancel()
+
+	shutdown(shutdownCtx, listeners)
+	if bin != nil {
+		bin.Close()
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), config.logFlushTimeout)
+	defer cancelFlush()
+
+	if err := closeLogger(flushCtx, logger); err != nil {
+		log.Printf("Error occurred while closing the transaction log: %v", err)
 	}
 }
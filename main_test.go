@@ -1,10 +1,18 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
 // Helper function for checking whether a file exists or not.
@@ -152,7 +160,7 @@ func TestInitLogger(t *testing.T) {
 	// Create a new file logger.
 	ftl, err := NewFileTransactionLogger(filename)
 	if err != nil {
-		t.Errorf("Error: %w", err)
+		t.Errorf("Error: %v", err)
 	}
 
 	// Check whether a logger was returned from the function.
@@ -253,3 +261,658 @@ func TestWritePut(t *testing.T) {
 		t.Errorf("IDs are not matching: %d != %d", transactionLogger.LastID(), transactionLogger2.LastID())
 	}
 }
+
+// Function for testing that Close flushes events which are still buffered.
+func TestCloseFlushesBatch(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-batch.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+
+	// Use a batch that never fills up and an interval that never elapses during the test.
+	config.logBatchSize, config.logBatchInterval = 1000, time.Hour
+	defer func() { config.logBatchSize, config.logBatchInterval = 0, 0 }()
+
+	// Create a new file logger.
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Start logging.
+	transactionLogger.Log()
+
+	// Send events to the logger and close it before any flush could happen.
+	transactionLogger.WritePut("yakv1", "yak1")
+	transactionLogger.WritePut("yakv2", "yak2")
+	if err := transactionLogger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the events back from the log.
+	transactionLogger2, _ := NewFileTransactionLogger(filename)
+	defer transactionLogger2.Close()
+
+	inEvents, inErrors := transactionLogger2.ReadEvents()
+	for range inEvents {
+	}
+
+	if err := <-inErrors; err != nil {
+		t.Error(err)
+	}
+
+	checkLastID(t, transactionLogger2, 2)
+}
+
+// Function for testing that synchronous writes return only once their events are in the file.
+func TestSyncWrites(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-sync.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+
+	// Without synchronous writes, nothing would be flushed before the logger is closed.
+	config.logBatchSize, config.logBatchInterval, config.syncWrites = 1000, time.Hour, true
+	defer func() { config.logBatchSize, config.logBatchInterval, config.syncWrites = 0, 0, false }()
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transactionLogger.Close()
+
+	transactionLogger.Log()
+
+	for i, key := range []string{"yakv1", "yakv2"} {
+		transactionLogger.WritePut(key, "yak")
+
+		// The event is readable right away, without waiting for the logger.
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if lines := strings.Count(string(data), "\n"); lines != i+2 {
+			t.Errorf("Expected the header and %d events after writing %q, got %d lines", i+1, key, lines)
+		}
+	}
+}
+
+// Function for testing that a synchronous write whose event couldn't be written fails instead of succeeding.
+func TestSyncWriteFailure(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-sync-failure.log")()
+	defer resetStores()
+	defer func() { writeLogFile = (*os.File).Write }()
+	defer func(delay time.Duration) { logWriteRetryDelay = delay }(logWriteRetryDelay)
+	defer setLogHealth(nil, 0)
+	defer func() { config.syncWrites = false }()
+	logWriteRetryDelay = time.Millisecond
+	config.syncWrites = true
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	failLogWrites(-1)
+	if err := logger.WritePut("yakv", "yak"); !errors.Is(err, errNotLogged) {
+		t.Errorf("Expected the write to fail, got %v", err)
+	}
+
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "yak"}`},
+		{http.MethodPost, "/yakv/v0/append", `{"key": "yakv", "value": "yak"}`},
+		{http.MethodDelete, "/yakv/v0/delete", `{"key": "yakv"}`},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected 500 for %s %s which couldn't be logged, got %d", req.method, req.path, rec.Code)
+		}
+	}
+}
+
+// Function for testing that a synchronous write waits for its event to be written without holding the store's
+// lock, so that writes of other keys aren't held up.
+func TestSyncWriteReleasesStoreLock(t *testing.T) {
+	// Restore to original state after test.
+	defer func() { writeLogFile = (*os.File).Write }()
+	defer useTempLogger(t, "temp-sync-unlocked.log")()
+	defer resetStores()
+	defer func() { config.syncWrites = false }()
+	config.syncWrites = true
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	// Writes to the file never finish until released.
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	writeLogFile = func(f *os.File, b []byte) (int, error) {
+		once.Do(func() { close(started) })
+		<-release
+		return f.Write(b)
+	}
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(`{"key": "a", "value": "yak"}`)))
+		done <- rec.Code
+	}()
+	<-started
+
+	written := make(chan error)
+	go func() { written <- putStored("b", "yak", false, time.Time{}, "", false) }()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected a write of another key not to wait for the synchronous write")
+		close(release)
+		<-written
+		<-done
+		return
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusCreated {
+		t.Errorf("Expected 201 once the write was released, got %d", code)
+	}
+}
+
+// Helper function for benchmarking the transaction logger with a given batch size.
+func benchmarkLog(b *testing.B, batchSize int) {
+	// Temporary log filename.
+	const filename = "temp-bench.log"
+
+	// Restore to original state after benchmark.
+	defer os.Remove(filename)
+
+	config.logBatchSize = batchSize
+	defer func() { config.logBatchSize = 0 }()
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	transactionLogger.Log()
+	defer transactionLogger.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		transactionLogger.WritePut("yakv", "hello, yakv!")
+	}
+	transactionLogger.Wait()
+}
+
+// Benchmark for logging with a flush after every event (unbatched).
+func BenchmarkLogUnbatched(b *testing.B) {
+	benchmarkLog(b, 1)
+}
+
+// Benchmark for logging with the default batch size.
+func BenchmarkLogBatched(b *testing.B) {
+	benchmarkLog(b, defaultLogBatchSize)
+}
+
+// Function for testing that a directory can't be used as the transaction log.
+func TestLoggerRejectsDirectory(t *testing.T) {
+	// Temporary directory standing in for the log filename.
+	const dirname = "temp-log-dir"
+
+	if err := os.Mkdir(dirname, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore to original state after test.
+	defer os.Remove(dirname)
+
+	ftl, err := NewFileTransactionLogger(dirname)
+	if err == nil {
+		ftl.Close()
+		t.Error("Expected an error for a directory used as the transaction log.")
+	}
+}
+
+// Function for testing the permissions of a new transaction log.
+func TestLoggerFileMode(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-mode.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+
+	ftl, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ftl.Close()
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The umask can only remove permission bits.
+	if mode := info.Mode().Perm(); mode&^defaultLogFileMode != 0 {
+		t.Errorf("Expected permissions within %o, got %o", defaultLogFileMode, mode)
+	}
+}
+
+// Helper function for replacing the global logger with a logger writing to a temporary file.
+// The returned function closes the logger and removes the file.
+func useTempLogger(t *testing.T, filename string) func() {
+	var err error
+
+	logger, err = NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Log()
+
+	return func() {
+		logger.Close()
+		os.Remove(filename)
+	}
+}
+
+// Function for testing that every endpoint rejects the empty key with 400, without touching the store.
+func TestEmptyKey(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-empty-key.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/yakv/v0/get", `{"Key": ""}`},
+		{http.MethodPut, "/yakv/v0/put", `{"Key": "", "Value": "hello, yakv!"}`},
+		{http.MethodDelete, "/yakv/v0/delete", `{"Key": ""}`},
+		{http.MethodPost, "/yakv/v0/touch", `{"Key": "", "ttl_seconds": 60}`},
+	}
+
+	for _, req := range requests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s %s, got %d", req.method, req.path, rec.Code)
+		}
+	}
+
+	if _, ok := store.m[""]; ok {
+		t.Error("The empty key was stored.")
+	}
+
+	// Nothing was written to the log.
+	logger.Wait()
+	checkLastID(t, logger, 0)
+}
+
+// Function for testing that GET answers a missing key with the default value, without storing it.
+func TestGetDefault(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-get-default.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, strings.NewReader(`{"key": "eHl6"}`)))
+		return rec
+	}
+
+	// Keys are base64-encoded in every request, "eHl6" being "xyz".
+	for path, expected := range map[string]string{
+		"/yakv/v0/get?encoding=base64&default=ZmFsbGJhY2s=": "ZmFsbGJhY2s=",
+		"/yakv/v0/get?encoding=base64&default=":             "",
+	} {
+		rec := get(path)
+		if rec.Code != http.StatusOK || rec.Body.String() != expected || rec.Header().Get("X-Yakv-Default") != "true" {
+			t.Errorf("Expected the default %q for %s, got %d %q", expected, path, rec.Code, rec.Body.String())
+		}
+	}
+
+	// The default isn't stored.
+	if rec := get("/yakv/v0/get?encoding=base64"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without a default, got %d", rec.Code)
+	}
+
+	// Existing keys ignore the default.
+	Put("xyz", "value")
+	if rec := get("/yakv/v0/get?encoding=base64&default=ZmFsbGJhY2s="); rec.Body.String() != "dmFsdWU=" || rec.Header().Get("X-Yakv-Default") != "" {
+		t.Errorf("Expected the stored value, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// A default which isn't base64-encoded is rejected.
+	if rec := get("/yakv/v0/get?encoding=base64&default=!"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid default, got %d", rec.Code)
+	}
+}
+
+// Function for testing that GET reads the key from the query string, URL-decoded, and from the body otherwise.
+func TestGetQueryKey(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-get-query.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	get := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, strings.NewReader(body))
+		if body == "" {
+			req = httptest.NewRequest(http.MethodGet, path, nil)
+		}
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	Put("user 1/name", "hello, yakv!")
+
+	// The query string and the body return the same value.
+	byQuery := get("/yakv/v0/get?key=user%201%2Fname", "")
+	byBody := get("/yakv/v0/get", `{"key": "user 1/name"}`)
+	if byQuery.Code != http.StatusOK || byQuery.Body.String() != "hello, yakv!" || byBody.Body.String() != byQuery.Body.String() {
+		t.Errorf("Expected the same value by query and body, got %d %q and %d %q", byQuery.Code, byQuery.Body.String(), byBody.Code, byBody.Body.String())
+	}
+
+	// The query string takes precedence over the body.
+	if rec := get("/yakv/v0/get?key=user%201%2Fname", `{"key": "missing"}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected the key of the query string, got %d", rec.Code)
+	}
+
+	// Missing and empty keys are reported as they are for bodies.
+	if rec := get("/yakv/v0/get?key=missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing key, got %d", rec.Code)
+	}
+	if rec := get("/yakv/v0/get?key=", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty key, got %d", rec.Code)
+	}
+
+	// Query keys are base64-encoded like body keys, "eXlr" being "yyk".
+	Put("yyk", "value")
+	if rec := get("/yakv/v0/get?encoding=base64&key=eXlr", ""); rec.Code != http.StatusOK || rec.Body.String() != "dmFsdWU=" {
+		t.Errorf("Expected the base64-encoded value, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+// Function for testing that unknown fields are only rejected outside of lenient mode.
+func TestLenientJSON(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-lenient.log")()
+	defer resetStores()
+	defer func(lenient bool) { config.lenientJSON = lenient }(config.lenientJSON)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	// A field which a newer client might send, but which this server doesn't know.
+	const body = `{"key": "yakv", "value": "hello, yakv!", "ttl_ms": 1000}`
+
+	put := func() int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	// Unknown fields are rejected by default.
+	config.lenientJSON = false
+	if code := put(); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown field, got %d", code)
+	}
+
+	// In lenient mode, they are ignored and the rest of the body is used.
+	config.lenientJSON = true
+	if code := put(); code != http.StatusCreated {
+		t.Fatalf("Expected 201 in lenient mode, got %d", code)
+	}
+	if value, err := Get("yakv"); err != nil || value != "hello, yakv!" {
+		t.Errorf("Expected the value to be stored, got %q %v", value, err)
+	}
+}
+
+// Function for testing that request bodies use lowercase field names, while still accepting capitalized ones.
+func TestBodyFieldNames(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-field-names.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	for _, fields := range [][2]string{{"key", "value"}, {"Key", "Value"}} {
+		key := "yakv-" + fields[0]
+
+		if rec := serve(http.MethodPut, "/yakv/v0/put", fmt.Sprintf(`{"%s": %q, "%s": "hello, yakv!"}`, fields[0], key, fields[1])); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 for %s and %s, got %d %s", fields[0], fields[1], rec.Code, rec.Body.String())
+		}
+		if value, err := Get(key); err != nil || value != "hello, yakv!" {
+			t.Errorf("Expected %s and %s to populate the key and value, got %q %v", fields[0], fields[1], value, err)
+		}
+
+		if rec := serve(http.MethodGet, "/yakv/v0/get", fmt.Sprintf(`{"%s": %q}`, fields[0], key)); rec.Code != http.StatusOK || rec.Body.String() != "hello, yakv!" {
+			t.Errorf("Expected %s to get the value, got %d %q", fields[0], rec.Code, rec.Body.String())
+		}
+
+		if rec := serve(http.MethodDelete, "/yakv/v0/delete", fmt.Sprintf(`{"%s": %q}`, fields[0], key)); rec.Code != http.StatusOK {
+			t.Errorf("Expected %s to delete the key, got %d", fields[0], rec.Code)
+		}
+		if _, err := Get(key); err != ErrorNoSuchKey {
+			t.Errorf("Expected %s to be deleted, got %v", key, err)
+		}
+	}
+}
+
+// Function for testing that keys and values which aren't valid UTF-8 are rejected unless base64-encoded,
+// while multibyte UTF-8 makes it through the transaction log unchanged.
+func TestUTF8(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-utf8.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	// Invalid byte sequences in keys, values and query parameters are rejected.
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPut, "/yakv/v0/put", "{\"key\": \"yakv\", \"value\": \"hello, \xff\xfe!\"}"},
+		{http.MethodPut, "/yakv/v0/put", "{\"key\": \"ya\xc3kv\", \"value\": \"hello, yakv!\"}"},
+		{http.MethodGet, "/yakv/v0/keys?prefix=%ff", ""},
+	} {
+		if rec := serve(req.method, req.path, req.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s %s %q, got %d", req.method, req.path, req.body, rec.Code)
+		}
+	}
+	if _, err := Get("yakv"); !errors.Is(err, ErrorNoSuchKey) {
+		t.Errorf("Expected nothing to be stored, got %v", err)
+	}
+
+	// The same bytes are accepted base64-encoded, "//4=" being "\xff\xfe".
+	if rec := serve(http.MethodPut, "/yakv/v0/put?encoding=base64", `{"key": "eWFrdg==", "value": "//4="}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected 201 for a base64-encoded binary value, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Emoji and CJK are stored and logged as they are.
+	values := map[string]string{"emoji": "hello, 🐃!", "cjk": "你好，世界", "キー": "値"}
+	for key, value := range values {
+		body, _ := json.Marshal(PutBody{Key: key, Value: value})
+		if rec := serve(http.MethodPut, "/yakv/v0/put", string(body)); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 for %q, got %d %q", value, rec.Code, rec.Body.String())
+		}
+	}
+	logger.Wait()
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transactionLogger.Close()
+
+	events, errs := transactionLogger.ReadEvents()
+	for e := range events {
+		if expected, ok := values[e.Key]; ok && e.Value != expected {
+			t.Errorf("Expected %q to be logged for %q, got %q", expected, e.Key, e.Value)
+		}
+		delete(values, e.Key)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 0 {
+		t.Errorf("Expected every value to be logged, missing %v", values)
+	}
+}
+
+// Function for testing that values put with a content type are served with it, also after replaying the log.
+func TestContentType(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-content-type.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "logo", "value": "<svg/>", "content_type": "image/svg+xml"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "logo", "value": "<svg/>", "content_type": "not a type"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid content type, got %d", rec.Code)
+	}
+	serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "hello, yakv!"}`)
+
+	// Values without a content type are left without one, for net/http to detect it.
+	expected := map[string]string{"logo": "image/svg+xml", "yakv": ""}
+	check := func(when string) {
+		for key, contentType := range expected {
+			rec := serve(http.MethodGet, "/yakv/v0/get", `{"key": "`+key+`"}`)
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != contentType {
+				t.Errorf("Expected %s to be served as %s %s, got %d %q", key, contentType, when, rec.Code, rec.Header().Get("Content-Type"))
+			}
+		}
+	}
+	check("after putting it")
+
+	// Base64-encoded values are never served with the stored content type.
+	if rec := serve(http.MethodGet, "/yakv/v0/get?encoding=base64", `{"key": "bG9nbw=="}`); rec.Header().Get("Content-Type") == "image/svg+xml" {
+		t.Error("Expected a base64-encoded value not to be served with its content type")
+	}
+
+	// The content type survives replaying the log.
+	logger.Wait()
+	resetStores()
+	reader, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, errs := reader.ReadEvents()
+	for e := range events {
+		if err := applyEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	reader.Close()
+	check("after replaying the log")
+
+	// Putting a value without a content type drops the previous one.
+	serve(http.MethodPut, "/yakv/v0/put", `{"key": "logo", "value": "plain"}`)
+	expected = map[string]string{"logo": ""}
+	check("after putting it without one")
+}
+
+// Function for testing that concurrent writes of the same key through different routes are logged in the order
+// they were applied, so that replaying the log rebuilds the same value.
+func TestConcurrentWriteOrder(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-write-order.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	requests := []struct {
+		method, path, body string
+	}{
+		{http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "put"}`},
+		{http.MethodPost, "/yakv/v0/append", `{"key": "yakv", "value": "+"}`},
+		{http.MethodDelete, "/yakv/v0/delete", `{"key": "yakv"}`},
+		{http.MethodPost, "/yakv/v0/getset", `{"key": "yakv", "value": "getset"}`},
+	}
+
+	done := make(chan struct{})
+	for _, req := range requests {
+		go func(method, path, body string) {
+			defer func() { done <- struct{}{} }()
+			for i := 0; i < 200; i++ {
+				r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, strings.NewReader(body)))
+			}
+		}(req.method, req.path, req.body)
+	}
+	for range requests {
+		<-done
+	}
+
+	expected, expectedErr := Get("yakv")
+
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := Get("yakv"); value != expected || !errors.Is(err, expectedErr) {
+		t.Errorf("Expected the replayed value %q %v, got %q %v", expected, expectedErr, value, err)
+	}
+}
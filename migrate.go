@@ -0,0 +1,164 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Suffix of the file a log is migrated to before it replaces the transaction log.
+const migrateSuffix = ".migrate"
+
+// MigrateResult describes a transaction log file rewritten in the current format.
+type MigrateResult struct {
+	Filename string // Name of the file.
+	Version  int    // Version the file was in before.
+	Events   int    // Number of events rewritten, zero if the file already was in the current version.
+	Backup   string // Name the file in its old version was kept under, empty if it wasn't rewritten.
+}
+
+// MigrateLog rewrites the transaction log at filename, and every shard of it, in the current format, keeping the
+// IDs and the order of their events. Each old file is kept next to the log, suffixed with its version, e.g.
+// transaction.log.v0. Files already in the current version are left as they are. Corrupt lines fail the
+// migration, even with -repair-log.
+func MigrateLog(filename string) ([]MigrateResult, error) {
+	if config.backend == boltBackend {
+		return nil, errors.New("the bolt backend has no transaction log to migrate")
+	}
+
+	// A missing log would be created by opening it, in the current version.
+	if _, err := os.Stat(filename); err != nil {
+		return nil, err
+	}
+
+	defer func(repair bool) { config.repairLog = repair }(config.repairLog)
+	config.repairLog = false
+
+	var results []MigrateResult
+	for i := 0; ; i++ {
+		name := shardFilename(filename, i)
+		if _, err := os.Stat(name); i > 0 && os.IsNotExist(err) {
+			return results, nil
+		}
+
+		result, err := migrateFile(name)
+		if err != nil {
+			return results, fmt.Errorf("failed to migrate %s. %w", name, err)
+		}
+		results = append(results, result)
+	}
+}
+
+// migrateFile rewrites a single file of the transaction log in the current format.
+func migrateFile(name string) (MigrateResult, error) {
+	result := MigrateResult{Filename: name}
+
+	ftl, err := NewFileTransactionLogger(name)
+	if err != nil {
+		return result, err
+	}
+	defer ftl.Close()
+
+	result.Version = ftl.(*FileTransactionLogger).version
+	if result.Version == ftlVersion {
+		return result, nil
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return result, err
+	}
+
+	// The new file keeps the permissions of the old one.
+	file, err := os.OpenFile(name+migrateSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return result, err
+	}
+	defer os.Remove(name + migrateSuffix)
+
+	w := bufio.NewWriter(file)
+	fmt.Fprintln(w, formatHeader(ftlVersion))
+
+	n, err := migrateEvents(w, ftl)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return result, err
+	}
+
+	// The old file is linked under its backup name first, so that the log is never missing.
+	backup := fmt.Sprintf("%s.v%d", name, result.Version)
+	if err := os.Link(name, backup); err != nil {
+		return result, fmt.Errorf("failed to keep the old log. %w", err)
+	}
+	if err := os.Rename(name+migrateSuffix, name); err != nil {
+		os.Remove(backup)
+		return result, err
+	}
+
+	result.Events, result.Backup = n, backup
+	return result, nil
+}
+
+// migrateEvents writes every event of a log to w in the current format, and returns the number of events.
+func migrateEvents(w io.Writer, ftl TransactionLogger) (int, error) {
+	n := 0
+
+	events, errs := ftl.ReadEvents()
+	for e := range events {
+		// Values were read as they were written, so they are not trimmed again.
+		e.verbatim = true
+		if _, err := fmt.Fprintln(w, formatEvent(ftlVersion, e)); err != nil {
+			// Draining the events lets the reading goroutine finish.
+			for range events {
+			}
+			return n, err
+		}
+		n++
+	}
+
+	return n, <-errs
+}
+
+// runMigrateLog runs MigrateLog on filename and prints a summary to out. It returns whether the migration succeeded.
+func runMigrateLog(out io.Writer, filename string) bool {
+	results, err := MigrateLog(filename)
+
+	for _, result := range results {
+		if result.Backup == "" {
+			fmt.Fprintf(out, "%s: already version %d\n", result.Filename, result.Version)
+			continue
+		}
+		fmt.Fprintf(out, "%s: migrated %d events from version %d to %d, kept the old log as %s\n", result.Filename, result.Events, result.Version, ftlVersion, result.Backup)
+	}
+
+	if err != nil {
+		fmt.Fprintf(out, "FAIL: %v\n", err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Function for testing that a version 0 log is rewritten in the current format with the same transactions,
+// keeping the old log, and that migrating again leaves it alone.
+func TestMigrateLog(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-migrate.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer os.Remove(filename + ".v0")
+	defer resetStores()
+
+	events := []Event{
+		{ID: 1, EventType: EventPut, Key: "yakv1", Value: "hello, yakv!"},
+		{ID: 2, EventType: EventPut, Key: "yakv2", Value: " padded ", verbatim: true},
+		{ID: 3, EventType: EventPut, Namespace: "users", Key: "alice", Value: "v1", Expiry: 1700000000000000000},
+		{ID: 5, EventType: EventDelete, Key: "yakv1"},
+	}
+	var old []string
+	for _, e := range events {
+		old = append(old, formatEvent(0, e))
+	}
+	if err := os.WriteFile(filename, []byte(strings.Join(old, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if !runMigrateLog(&out, filename) || !strings.Contains(out.String(), "migrated 4 events from version 0 to 1") {
+		t.Fatalf("Expected the log to be migrated, got %s", out.String())
+	}
+
+	// The new log starts with the header, and keeps the IDs and values of every transaction.
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != len(events)+1 || lines[0] != formatHeader(ftlVersion) {
+		t.Fatalf("Expected a header and %d transactions, got %q", len(events), lines)
+	}
+	for i, e := range events {
+		if got, err := parseEvent(ftlVersion, lines[i+1]); err != nil || got.ID != e.ID || got.Value != e.Value || got.Namespace != e.Namespace || got.Expiry != e.Expiry {
+			t.Errorf("Expected transaction %+v, got %+v %v", e, got, err)
+		}
+	}
+
+	// The old log is kept as it was.
+	if data, err := os.ReadFile(filename + ".v0"); err != nil || string(data) != strings.Join(old, "\n")+"\n" {
+		t.Errorf("Expected the old log to be kept, got %q %v", data, err)
+	}
+
+	// The migrated log replays to the same state, and is already in the current version.
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+	if len(store.m) != 1 || store.m["yakv2"] != " padded " {
+		t.Errorf("Expected the state of the old log, got %v", store.m)
+	}
+
+	out.Reset()
+	if !runMigrateLog(&out, filename) || !strings.Contains(out.String(), "already version 1") {
+		t.Errorf("Expected the migrated log to be left alone, got %s", out.String())
+	}
+}
+
+// Function for testing that corrupt and missing logs fail the migration without touching the log.
+func TestMigrateLogFails(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-migrate-corrupt.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+
+	corrupt := formatEvent(0, Event{ID: 1, EventType: EventPut, Key: "yakv", Value: "v1"}) + "\nnot an event\n"
+	if err := os.WriteFile(filename, []byte(corrupt), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if runMigrateLog(&out, filename) || !strings.Contains(out.String(), "FAIL") {
+		t.Errorf("Expected a corrupt log to fail, got %s", out.String())
+	}
+	if data, _ := os.ReadFile(filename); string(data) != corrupt {
+		t.Errorf("Expected the corrupt log to be left as it was, got %q", data)
+	}
+	for _, name := range []string{filename + ".v0", filename + migrateSuffix} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be left behind", name)
+		}
+	}
+
+	os.Remove(filename)
+	if runMigrateLog(&out, filename) {
+		t.Error("Expected a missing log to fail")
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Error("Expected a missing log not to be created")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newClientAuthTLSConfig returns a TLS configuration requiring clients to present a certificate signed
+// by one of the certificate authorities in the PEM file caFilename.
+func newClientAuthTLSConfig(caFilename string) (*tls.Config, error) {
+	data, err := os.ReadFile(caFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %q", caFilename)
+	}
+
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// checkClientCAListeners returns an error unless every listener serving the routes requires a client certificate
+// with a client CA: a plaintext listener next to -tls-port must only redirect to HTTPS, and the binary protocol,
+// which has no TLS, can't be served at all.
+func checkClientCAListeners(secure bool, tlsPort int, redirectHTTPS bool, binPort int) error {
+	if !secure && tlsPort == 0 {
+		return errors.New("-client-ca requires -secure or -tls-port")
+	}
+	if tlsPort > 0 && !redirectHTTPS {
+		return errors.New("-client-ca with -tls-port requires -redirect-https, so that -port doesn't serve the routes without a client certificate")
+	}
+	if binPort > 0 {
+		return errors.New("-client-ca can't be combined with -bin-port, whose connections don't carry a client certificate")
+	}
+
+	return nil
+}
+
+// clientCommonName returns the common name of the verified client certificate of a request, if any.
+func clientCommonName(c *gin.Context) string {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+
+	return c.Request.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// ClientCertMiddleware logs the common name of the client certificate of every request, for auditing.
+// It is also made available to later handlers as "client_cn".
+func ClientCertMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cn := clientCommonName(c); cn != "" {
+			c.Set("client_cn", cn)
+			log.Printf("%s %s by client %q", c.Request.Method, c.Request.URL.Path, cn)
+		}
+
+		c.Next()
+	}
+}
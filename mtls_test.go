@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testCertificate creates a certificate with the given common name, signed by parent or self-signed
+// when parent is nil.
+func testCertificate(t *testing.T, cn string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, interface{}(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// Function for testing that clients without a certificate signed by the client CA are rejected.
+func TestClientCertificates(t *testing.T) {
+	ca := testCertificate(t, "yakv test CA", nil)
+	client := testCertificate(t, "yakv-client", &ca)
+	stranger := testCertificate(t, "stranger", nil)
+
+	caFilename := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFilename, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate[0]}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfig, err := newClientAuthTLSConfig(caFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The handler replies with the common name of the client.
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ClientCertMiddleware())
+	r.GET("/whoami", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("client_cn"))
+	})
+
+	server := httptest.NewUnstartedServer(r)
+	server.TLS = tlsConfig
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // rejected handshakes are expected
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(certs ...tls.Certificate) (*http.Response, error) {
+		c := server.Client()
+		c.Transport.(*http.Transport).TLSClientConfig.Certificates = certs
+		return c.Get(server.URL + "/whoami")
+	}
+
+	// Clients without a certificate, or with one not signed by the CA, are rejected.
+	if resp, err := get(); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected a client without a certificate to be rejected, got %d", resp.StatusCode)
+	}
+	if resp, err := get(stranger); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected a client with an unknown certificate to be rejected, got %d", resp.StatusCode)
+	}
+
+	resp, err := get(client)
+	if err != nil {
+		t.Fatalf("Expected the client with a certificate signed by the CA to be accepted, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	body := make([]byte, 64)
+	n, _ := resp.Body.Read(body)
+	if resp.StatusCode != http.StatusOK || string(body[:n]) != "yakv-client" {
+		t.Errorf("Expected 200 with the client's common name, got %d %q", resp.StatusCode, body[:n])
+	}
+}
+
+// Function for testing that a client CA file without certificates is an error.
+func TestClientCAInvalid(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(filename, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newClientAuthTLSConfig(filename); err == nil {
+		t.Error("Expected an error for a file without certificates")
+	}
+}
+
+// Function for testing that a client CA is refused unless every listener serving the routes requires a client certificate.
+func TestClientCAListeners(t *testing.T) {
+	tests := []struct {
+		name          string
+		secure        bool
+		tlsPort       int
+		redirectHTTPS bool
+		binPort       int
+		valid         bool
+	}{
+		{name: "secure", secure: true, valid: true},
+		{name: "plaintext only"},
+		{name: "tls port with redirect", tlsPort: 8443, redirectHTTPS: true, valid: true},
+		{name: "tls port without redirect", tlsPort: 8443},
+		{name: "binary protocol", secure: true, binPort: 9000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkClientCAListeners(tt.secure, tt.tlsPort, tt.redirectHTTPS, tt.binPort)
+			if (err == nil) != tt.valid {
+				t.Errorf("Expected valid to be %v, got error %v", tt.valid, err)
+			}
+		})
+	}
+}
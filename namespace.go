@@ -0,0 +1,337 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Registry of namespaced key-value stores. Each namespace is isolated from the
+// default store and from every other namespace. All namespaces share the
+// transaction log, their events are told apart by the event's namespace field.
+var namespaces = struct {
+	sync.RWMutex
+	m map[string]*keyValueStore
+}{m: make(map[string]*keyValueStore)}
+
+// ErrorNoSuchNamespace is raised when a namespace is not found.
+var ErrorNoSuchNamespace = errors.New("namespace doesn't exist")
+
+// NamespacePutBody is a struct for defining the namespaced PUT request body structure.
+type NamespacePutBody struct {
+	Value string `json:"value"`
+}
+
+// lookupNamespace returns the store for a namespace, creating it if create is set.
+func lookupNamespace(namespace string, create bool) (*keyValueStore, error) {
+	namespaces.RLock()
+	ns, ok := namespaces.m[namespace]
+	namespaces.RUnlock()
+
+	if ok {
+		return ns, nil
+	}
+
+	if !create {
+		return nil, ErrorNoSuchNamespace
+	}
+
+	namespaces.Lock()
+	defer namespaces.Unlock()
+
+	// Another writer might have created the namespace in the meantime.
+	if ns, ok = namespaces.m[namespace]; !ok {
+		ns = newKeyValueStore()
+		namespaces.m[namespace] = ns
+	}
+
+	return ns, nil
+}
+
+// NamespacePut sets the value of a key in the given namespace, creating the namespace if needed, calling logged,
+// unless it's nil, to write the put to the transaction log while the namespace's lock is still held. Values aren't
+// validated against schemas, whose prefixes only select keys of the default store.
+func NamespacePut(namespace, key, value string, logged func()) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	ns, err := lookupNamespace(namespace, true)
+	if err != nil {
+		return err
+	}
+
+	ns.Lock()
+	defer ns.Unlock()
+
+	_, exists := ns.m[key]
+	if err := checkPut(exists); err != nil {
+		return err
+	}
+	ns.m[key] = value
+	if logged != nil {
+		logged()
+	}
+
+	return nil
+}
+
+// NamespaceGet gets the value assigned to a key in the given namespace.
+func NamespaceGet(namespace, key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	ns, err := lookupNamespace(namespace, false)
+	if err != nil {
+		return "", ErrorNoSuchKey
+	}
+
+	ns.RLock()
+	value, ok := ns.m[key]
+	ns.RUnlock()
+
+	if !ok {
+		return "", ErrorNoSuchKey
+	}
+
+	return value, nil
+}
+
+// NamespaceDelete deletes a key from the given namespace, calling logged like NamespacePut.
+func NamespaceDelete(namespace, key string, logged func()) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	ns, err := lookupNamespace(namespace, false)
+	if err != nil {
+		return ErrorNoSuchKey
+	}
+
+	ns.Lock()
+	defer ns.Unlock()
+
+	if _, ok := ns.m[key]; !ok {
+		return ErrorNoSuchKey
+	}
+
+	delete(ns.m, key)
+	if logged != nil {
+		logged()
+	}
+
+	return nil
+}
+
+// DropNamespace removes a namespace along with all of its keys, calling logged, unless it's nil, to write the drop
+// to the transaction log while the lock of the namespaces is still held.
+func DropNamespace(namespace string, logged func()) error {
+	namespaces.Lock()
+	defer namespaces.Unlock()
+
+	if _, ok := namespaces.m[namespace]; !ok {
+		return ErrorNoSuchNamespace
+	}
+
+	delete(namespaces.m, namespace)
+	if logged != nil {
+		logged()
+	}
+
+	return nil
+}
+
+// ListNamespaces returns the sorted names of all namespaces.
+func ListNamespaces() []string {
+	namespaces.RLock()
+	names := make([]string, 0, len(namespaces.m))
+	for name := range namespaces.m {
+		names = append(names, name)
+	}
+	namespaces.RUnlock()
+
+	sort.Strings(names)
+
+	return names
+}
+
+// replayNamespaceEvent applies a namespaced event read from the transaction log.
+func replayNamespaceEvent(e Event) error {
+	var err error
+
+	switch e.EventType {
+	case EventPut:
+		err = NamespacePut(e.Namespace, e.Key, e.Value, nil)
+	case EventDelete:
+		err = NamespaceDelete(e.Namespace, e.Key, nil)
+	case EventDropNamespace:
+		err = DropNamespace(e.Namespace, nil)
+	}
+
+	// Replaying a delete for something which is already gone leaves the store in the same state.
+	if errors.Is(err, ErrorNoSuchKey) || errors.Is(err, ErrorNoSuchNamespace) {
+		return nil
+	}
+
+	return err
+}
+
+// NamespaceGetHandler is a handler function for the namespaced GET endpoint.
+func NamespaceGetHandler(c *gin.Context) {
+	namespace, key := c.Param("namespace"), c.Param("key")
+
+	// Calls NamespaceGet to get the value assigned to the key
+	value, err := NamespaceGet(namespace, key)
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(c.Writer, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Any other error that can't be handled
+	if err != nil {
+		writeError(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Clients which already have the value are answered with 304 Not Modified.
+	if writeCacheHeaders(c.Writer, c.Request, value) {
+		return
+	}
+
+	// Clients can request a range of the value, e.g. to resume a download.
+	if err := writeValue(c.Writer, c.Request, value); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// NamespacePutHandler is a handler function for the namespaced PUT endpoint.
+func NamespacePutHandler(c *gin.Context) {
+	var body NamespacePutBody
+	namespace, key := c.Param("namespace"), c.Param("key")
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(c.Writer, c.Request, &body)
+	defer c.Request.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(c.Writer, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(c.Writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Call NamespacePut to add a key-value pair to the namespace, writing the PUT event to the log.
+	value := strings.Replace(body.Value, "\n", "", -1)
+	changes.RLock()
+	defer changes.RUnlock()
+	var logErr error
+	err := NamespacePut(namespace, key, value, func() { logErr = logger.WriteNamespacePut(namespace, key, value) })
+	if err == nil {
+		err = logErr
+	}
+	if errors.Is(err, errKeyExists) {
+		writeError(c.Writer, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(c.Writer, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("added value: \"%s\" to key \"%s\" in namespace \"%s\"\n", value, key, namespace)
+	c.Status(http.StatusCreated)
+}
+
+// NamespaceDeleteHandler is a handler function for the namespaced DELETE endpoint.
+func NamespaceDeleteHandler(c *gin.Context) {
+	namespace, key := c.Param("namespace"), c.Param("key")
+
+	// Calls NamespaceDelete for deleting a key-value pair, writing the DELETE event to the log.
+	changes.RLock()
+	defer changes.RUnlock()
+	var logErr error
+	err := NamespaceDelete(namespace, key, func() { logErr = logger.WriteNamespaceDelete(namespace, key) })
+	if err == nil {
+		err = logErr
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(c.Writer, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Any other error that can't be handled
+	if err != nil {
+		writeError(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("deleting key: %s in namespace: %s\n", key, namespace)
+}
+
+// ListNamespacesHandler is a handler function for listing all namespaces.
+func ListNamespacesHandler(c *gin.Context) {
+	c.Header("Content-Type", "application/json")
+
+	if err := json.NewEncoder(c.Writer).Encode(struct {
+		Namespaces []string `json:"namespaces"`
+	}{ListNamespaces()}); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// DropNamespaceHandler is a handler function for dropping a namespace and all of its keys.
+func DropNamespaceHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+
+	changes.RLock()
+	defer changes.RUnlock()
+	var logErr error
+	err := DropNamespace(namespace, func() { logErr = logger.WriteDropNamespace(namespace) })
+	if err == nil {
+		err = logErr
+	}
+	if errors.Is(err, ErrorNoSuchNamespace) {
+		writeError(c.Writer, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Any other error that can't be handled
+	if err != nil {
+		writeError(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Println("dropping namespace:", namespace)
+}
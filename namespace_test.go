@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// Function for testing that namespaces don't see each other's keys.
+func TestNamespaceIsolation(t *testing.T) {
+	// Sample data
+	const key = "yakv"
+
+	// Restore to original state after test.
+	defer DropNamespace("ns1", nil)
+	defer DropNamespace("ns2", nil)
+
+	if err := NamespacePut("ns1", key, "one", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := NamespacePut("ns2", key, "two", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each namespace holds its own value.
+	if val, _ := NamespaceGet("ns1", key); val != "one" {
+		t.Errorf("Expected \"one\" in ns1, got %q", val)
+	}
+	if val, _ := NamespaceGet("ns2", key); val != "two" {
+		t.Errorf("Expected \"two\" in ns2, got %q", val)
+	}
+
+	// The default store is unaffected.
+	if _, err := Get(key); !errors.Is(err, ErrorNoSuchKey) {
+		t.Error("Namespaced key leaked into the default store.")
+	}
+
+	// Deleting in one namespace doesn't affect the other.
+	if err := NamespaceDelete("ns1", key, nil); err != nil {
+		t.Error(err)
+	}
+	if _, err := NamespaceGet("ns1", key); !errors.Is(err, ErrorNoSuchKey) {
+		t.Error("Key wasn't deleted from ns1.")
+	}
+	if val, _ := NamespaceGet("ns2", key); val != "two" {
+		t.Error("Deleting from ns1 affected ns2.")
+	}
+}
+
+// Function for testing dropping a namespace.
+func TestDropNamespace(t *testing.T) {
+	if err := NamespacePut("ns-drop", "yakv", "value", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DropNamespace("ns-drop", nil); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := NamespaceGet("ns-drop", "yakv"); !errors.Is(err, ErrorNoSuchKey) {
+		t.Error("Key survived dropping its namespace.")
+	}
+
+	if err := DropNamespace("ns-drop", nil); !errors.Is(err, ErrorNoSuchNamespace) {
+		t.Error("Expected ErrorNoSuchNamespace, got", err)
+	}
+}
+
+// Function for testing that namespaced events are replayed into the right namespace.
+func TestNamespaceReplay(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-namespace.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer delete(store.m, "yakv")
+	defer DropNamespace("ns1", nil)
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactionLogger.Log()
+	transactionLogger.WritePut("yakv", "default")
+	transactionLogger.WriteNamespacePut("ns1", "yakv", "one")
+	transactionLogger.WriteNamespacePut("ns2", "yakv", "two")
+	transactionLogger.WriteDropNamespace("ns2")
+	transactionLogger.Close()
+
+	// Replay the log into the stores.
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	if val, _ := Get("yakv"); val != "default" {
+		t.Errorf("Expected \"default\" in the default store, got %q", val)
+	}
+	if val, _ := NamespaceGet("ns1", "yakv"); val != "one" {
+		t.Errorf("Expected \"one\" in ns1, got %q", val)
+	}
+	if _, err := NamespaceGet("ns2", "yakv"); !errors.Is(err, ErrorNoSuchKey) {
+		t.Error("Dropped namespace was restored by the replay.")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+)
+
+// Size of the chunks the end of the transaction log is read in, looking for its last transaction.
+const tailChunkSize = 64 << 10
+
+// errBoltNoReplay is raised when the BoltDB backend is asked to skip its replay.
+var errBoltNoReplay = errors.New("the bolt backend holds the store itself, so it can't skip replaying it")
+
+// SkipEvents reads the last ID of the transaction log from its last transaction, without reading the ones
+// before it, so that new transactions follow it. A last line cut short by an interrupted write, or a corrupt
+// last transaction, is handled like reading every transaction would, dropping or skipping it with -repair-log.
+func (ftl *FileTransactionLogger) SkipEvents() error {
+	info, err := ftl.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	end := info.Size()
+	if end == 0 {
+		return nil
+	}
+
+	// Every transaction ends with a newline, so a last line without one was cut short by an interrupted write,
+	// unless it parses.
+	last := make([]byte, 1)
+	if _, err := ftl.file.ReadAt(last, end-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		line, start, err := ftl.lineBefore(end)
+		if err != nil {
+			return err
+		}
+
+		switch e, err := parseEvent(ftl.version, line); {
+		case start == 0 && ftl.version >= 1:
+			ftl.unterminated = true
+			return nil
+		case err == nil:
+			ftl.lastID, ftl.unterminated = e.ID, true
+			return nil
+		case config.repairLog:
+			log.Printf("dropping the partial transaction at the end of the transaction log, left behind by an interrupted write: %v", err)
+			ftl.partial, ftl.partialAt = true, start
+		default:
+			return fmt.Errorf("failed while parsing the last line, which was cut short by an interrupted write, start with -repair-log to drop it. %w", err)
+		}
+
+		end = start
+	}
+
+	// Corrupt transactions are skipped in repair mode, so the last ID is the one of the last valid transaction.
+	for end > 0 {
+		line, start, err := ftl.lineBefore(end - 1)
+		if err != nil {
+			return err
+		}
+
+		// The header is the first line of versioned logs.
+		if start == 0 && ftl.version >= 1 {
+			return nil
+		}
+
+		e, err := parseEvent(ftl.version, line)
+		if err == nil {
+			ftl.lastID = e.ID
+			return nil
+		}
+		if !config.repairLog {
+			return fmt.Errorf("failed while parsing the last transaction. %w", err)
+		}
+
+		log.Printf("skipping corrupt transaction at the end of the transaction log: %v", err)
+		end = start
+	}
+
+	return nil
+}
+
+// lineBefore returns the line of the transaction log which ends at the offset end, without its newline, along
+// with the offset it starts at. The file is read backwards in chunks until the newline before the line.
+func (ftl *FileTransactionLogger) lineBefore(end int64) (string, int64, error) {
+	var line []byte
+
+	start := end
+	for start > 0 {
+		size := int64(tailChunkSize)
+		if size > start {
+			size = start
+		}
+
+		chunk := make([]byte, size)
+		if _, err := ftl.file.ReadAt(chunk, start-size); err != nil && err != io.EOF {
+			return "", 0, err
+		}
+
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			return string(append(chunk[i+1:], line...)), start - size + int64(i) + 1, nil
+		}
+
+		line = append(chunk, line...)
+		start -= size
+		if len(line) > ftlMaxLineSize {
+			return "", 0, fmt.Errorf("the last line of the transaction log is longer than %d bytes", ftlMaxLineSize)
+		}
+	}
+
+	return string(line), 0, nil
+}
+
+// SkipEvents reads the last ID of every shard, the IDs of new transactions following the last of them.
+func (sl *shardedLogger) SkipEvents() error {
+	var lastID uint64
+	for _, ftl := range sl.shards {
+		if err := ftl.SkipEvents(); err != nil {
+			return fmt.Errorf("failed while reading %s. %w", ftl.file.Name(), err)
+		}
+		if ftl.lastID > lastID {
+			lastID = ftl.lastID
+		}
+	}
+
+	atomic.StoreUint64(&sl.lastID, lastID)
+	return nil
+}
+
+// SkipEvents fails, as the BoltDB file is the store, not a log of it.
+func (bl *boltLogger) SkipEvents() error {
+	return errBoltNoReplay
+}
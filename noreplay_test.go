@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Function for testing that skipping the replay starts with an empty store, and that new transactions follow
+// the last ID of the log, so that replaying it later still sees them in sequence.
+func TestNoReplay(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-no-replay.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	defer func() { config.noReplay = false }()
+
+	event := func(id uint64, key string) string {
+		return formatEvent(ftlVersion, Event{ID: id, EventType: EventPut, Key: key, Value: "hello, yakv!"})
+	}
+	if err := os.WriteFile(filename, []byte(formatHeader(ftlVersion)+"\n"+event(1, "yakv1")+"\n"+event(2, "yakv2")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config.noReplay = true
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.m) != 0 || logger.LastID() != 2 {
+		t.Errorf("Expected an empty store after ID 2, got %d keys after ID %d", len(store.m), logger.LastID())
+	}
+
+	logger.WritePut("yakv3", "hello, yakv!")
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying the log sees every transaction, the new one included.
+	config.noReplay = false
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	expected := map[string]string{"yakv1": "hello, yakv!", "yakv2": "hello, yakv!", "yakv3": "hello, yakv!"}
+	if !reflect.DeepEqual(store.m, expected) || logger.LastID() != 3 {
+		t.Errorf("Expected %v up to ID 3, got %v up to ID %d", expected, store.m, logger.LastID())
+	}
+}
+
+// Function for testing that the last ID is read from the last valid transaction of the log, whatever its end looks like.
+func TestSkipEvents(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-skip-events.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer func() { config.repairLog = false }()
+
+	event := func(id uint64, value string) string {
+		return formatEvent(ftlVersion, Event{ID: id, EventType: EventPut, Key: "yakv", Value: value})
+	}
+	header := formatHeader(ftlVersion) + "\n"
+	complete := header + event(1, "v1") + "\n" + event(2, "v2") + "\n"
+	partial := event(3, "v3")
+	partial = partial[:len(partial)-5]
+
+	// Values spanning several chunks are read back to the start of their line.
+	large := strings.Repeat("y", 3*tailChunkSize)
+
+	tests := []struct {
+		name, content string
+		repair        bool
+		lastID        uint64
+		err           string
+	}{
+		{"an empty log", "", false, 0, ""},
+		{"a log with only a header", header, false, 0, ""},
+		{"a log without a header", "1\t2\t\"yakv\"\t\"v1\"\n2\t2\t\"yakv\"\t\"v2\"\n", false, 2, ""},
+		{"a complete log", complete, false, 2, ""},
+		{"a large last value", complete + event(3, large) + "\n", false, 3, ""},
+		{"an unterminated last line", strings.TrimSuffix(complete, "\n"), false, 2, ""},
+		{"a partial last line", complete + partial, false, 0, "interrupted write"},
+		{"a repaired partial last line", complete + partial, true, 2, ""},
+		{"a corrupt last transaction", complete + partial + "\n", false, 0, "last transaction"},
+		{"a repaired corrupt last transaction", complete + partial + "\n" + partial + "\n", true, 2, ""},
+	}
+
+	for _, test := range tests {
+		if err := os.WriteFile(filename, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config.repairLog = test.repair
+
+		tl, err := NewFileTransactionLogger(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = tl.SkipEvents()
+		switch {
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("Expected an error about %s for %s, got %v", test.err, test.name, err)
+		case test.err == "" && (err != nil || tl.LastID() != test.lastID):
+			t.Errorf("Expected ID %d for %s, got %d %v", test.lastID, test.name, tl.LastID(), err)
+		}
+		if err != nil {
+			tl.Close()
+			continue
+		}
+
+		// The next transaction starts on a line of its own, after cutting off a partial line.
+		tl.Log()
+		tl.WritePut("next", "hello, yakv!")
+		if err := tl.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Corrupt transactions which were skipped are still in the log.
+		if tl, err = NewFileTransactionLogger(filename); err != nil {
+			t.Fatal(err)
+		}
+		var last Event
+		events, errs := tl.ReadEvents()
+		for e := range events {
+			last = e
+		}
+		if err := <-errs; err != nil || last.Key != "next" || last.ID != test.lastID+1 {
+			t.Errorf("Expected the next transaction to follow ID %d for %s, got %+v %v", test.lastID, test.name, last, err)
+		}
+		tl.Close()
+	}
+}
+
+// Function for testing that the shards of a log continue after the last ID of any of them.
+func TestSkipEventsShards(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-skip-shards.log"
+
+	// Restore to original state after test.
+	defer func(n int) { config.logShards = n }(config.logShards)
+	config.logShards = 3
+	defer func() {
+		for i := 0; i < 3; i++ {
+			os.Remove(shardFilename(filename, i))
+		}
+	}()
+
+	tl, err := NewTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tl.Log()
+	for _, key := range []string{"yakv1", "yakv2", "yakv3", "yakv4", "yakv5"} {
+		tl.WritePut(key, "hello, yakv!")
+	}
+	if err := tl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tl, err = NewTransactionLogger(filename); err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	if err := tl.SkipEvents(); err != nil || tl.LastID() != 5 {
+		t.Errorf("Expected ID 5, got %d %v", tl.LastID(), err)
+	}
+}
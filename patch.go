@@ -0,0 +1,602 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Content types of the supported patch formats.
+const (
+	jsonPatchContentType  = "application/json-patch+json"  // RFC 6902 JSON Patch.
+	mergePatchContentType = "application/merge-patch+json" // RFC 7386 JSON Merge Patch.
+)
+
+// errNotJSON is raised when a value to patch or project isn't a JSON document.
+var errNotJSON = errors.New("value is not a JSON document")
+
+// PatchError is raised when a patch is malformed or can't be applied to a value.
+type PatchError struct {
+	msg string
+}
+
+// Error returns the reason the patch failed.
+func (pe *PatchError) Error() string {
+	return pe.msg
+}
+
+// patchErrorf returns a PatchError with a formatted message.
+func patchErrorf(format string, a ...interface{}) error {
+	return &PatchError{msg: fmt.Sprintf(format, a...)}
+}
+
+// decodeJSON decodes a JSON document, keeping numbers as they were written so that patching doesn't round them.
+func decodeJSON(data string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	// Anything after the document makes it invalid.
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the document")
+	}
+
+	return doc, nil
+}
+
+// copyJSON returns a deep copy of a decoded JSON document.
+func copyJSON(doc interface{}) interface{} {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			c[key] = copyJSON(value)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, value := range v {
+			c[i] = copyJSON(value)
+		}
+		return c
+	}
+
+	return doc
+}
+
+// equalJSON reports whether two decoded JSON documents are equal, comparing numbers by their value.
+func equalJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errX := strconv.ParseFloat(string(a), 64)
+		y, errY := strconv.ParseFloat(string(b), 64)
+		return errX == nil && errY == nil && x == y
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			if other, ok := b[key]; !ok || !equalJSON(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// mergePatch applies an RFC 7386 merge patch to a document: members of the patch replace those of the
+// document, objects are merged recursively and null members are removed.
+func mergePatch(doc, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	target, ok := doc.(map[string]interface{})
+	if !ok {
+		target = make(map[string]interface{})
+	}
+
+	for key, value := range members {
+		if value == nil {
+			delete(target, key)
+		} else {
+			target[key] = mergePatch(target[key], value)
+		}
+	}
+
+	return target
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, patchErrorf("path %q must be empty or start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+// arrayIndex parses a reference token as an index of an array of length n. With end, the index may point
+// just past the last element, as for adding an element.
+func arrayIndex(token string, n int, end bool) (int, error) {
+	if token == "-" && end {
+		return n, nil
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, patchErrorf("%q is not an array index", token)
+	}
+	if i > n || (i == n && !end) {
+		return 0, patchErrorf("array index %d is out of bounds", i)
+	}
+
+	return i, nil
+}
+
+// lookupPointer returns the value tokens refer to in a document.
+func lookupPointer(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			value, ok := v[token]
+			if !ok {
+				return nil, patchErrorf("member %q doesn't exist", token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, patchErrorf("%q can't be looked up in a scalar", token)
+		}
+	}
+
+	return doc, nil
+}
+
+// updatePointer calls update with the container tokens refer to and the last token, and returns the document
+// with the updated container in place. There must be at least one token.
+func updatePointer(doc interface{}, tokens []string, update func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return update(doc, tokens[0])
+	}
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		child, ok := v[tokens[0]]
+		if !ok {
+			return nil, patchErrorf("member %q doesn't exist", tokens[0])
+		}
+		child, err := updatePointer(child, tokens[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		v[tokens[0]] = child
+		return v, nil
+	case []interface{}:
+		i, err := arrayIndex(tokens[0], len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := updatePointer(v[i], tokens[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = child
+		return v, nil
+	}
+
+	return nil, patchErrorf("%q can't be looked up in a scalar", tokens[0])
+}
+
+// addPointer adds value at the location tokens refer to, replacing a member of an object or inserting into an array.
+func addPointer(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return updatePointer(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			v[token] = value
+			return v, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), true)
+			if err != nil {
+				return nil, err
+			}
+			v = append(v, nil)
+			copy(v[i+1:], v[i:])
+			v[i] = value
+			return v, nil
+		}
+
+		return nil, patchErrorf("%q can't be added to a scalar", token)
+	})
+}
+
+// removePointer removes the value at the location tokens refer to, which must exist.
+func removePointer(doc interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, patchErrorf("the whole document can't be removed")
+	}
+
+	return updatePointer(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			if _, ok := v[token]; !ok {
+				return nil, patchErrorf("member %q doesn't exist", token)
+			}
+			delete(v, token)
+			return v, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(v[:i], v[i+1:]...), nil
+		}
+
+		return nil, patchErrorf("%q can't be removed from a scalar", token)
+	})
+}
+
+// patchOperation is a single operation of an RFC 6902 JSON Patch.
+type patchOperation struct {
+	op       string
+	path     []string
+	from     []string
+	value    interface{}
+	hasValue bool
+}
+
+// parseJSONPatch parses an RFC 6902 JSON Patch, an array of operations.
+func parseJSONPatch(data []byte) ([]patchOperation, error) {
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, patchErrorf("patch must be an array of operations")
+	}
+
+	ops := make([]patchOperation, 0, len(raw))
+	for i, fields := range raw {
+		var op patchOperation
+		var path, from string
+
+		if err := json.Unmarshal(fields["op"], &op.op); err != nil {
+			return nil, patchErrorf("operation %d must have an op", i)
+		}
+		if err := json.Unmarshal(fields["path"], &path); err != nil {
+			return nil, patchErrorf("operation %d must have a path", i)
+		}
+
+		var err error
+		if op.path, err = parsePointer(path); err != nil {
+			return nil, err
+		}
+
+		switch op.op {
+		case "add", "replace", "test":
+			value, ok := fields["value"]
+			if !ok {
+				return nil, patchErrorf("operation %d must have a value", i)
+			}
+			if op.value, err = decodeJSON(string(value)); err != nil {
+				return nil, patchErrorf("value of operation %d is invalid. %v", i, err)
+			}
+		case "move", "copy":
+			if err := json.Unmarshal(fields["from"], &from); err != nil {
+				return nil, patchErrorf("operation %d must have a from", i)
+			}
+			if op.from, err = parsePointer(from); err != nil {
+				return nil, err
+			}
+		case "remove":
+		default:
+			return nil, patchErrorf("operation %d has an unknown op %q", i, op.op)
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+// applyJSONPatch applies the operations of an RFC 6902 JSON Patch to a document in order. The patch is
+// applied atomically: if an operation fails, the error is returned and the document mustn't be used.
+func applyJSONPatch(doc interface{}, ops []patchOperation) (interface{}, error) {
+	var err error
+	for i, op := range ops {
+		switch op.op {
+		case "add":
+			doc, err = addPointer(doc, op.path, op.value)
+		case "remove":
+			doc, err = removePointer(doc, op.path)
+		case "replace":
+			if _, err = lookupPointer(doc, op.path); err == nil {
+				if len(op.path) == 0 {
+					doc = op.value
+				} else if doc, err = removePointer(doc, op.path); err == nil {
+					doc, err = addPointer(doc, op.path, op.value)
+				}
+			}
+		case "move":
+			var value interface{}
+			if isPrefix(op.from, op.path) && len(op.from) < len(op.path) {
+				err = patchErrorf("a value can't be moved into itself")
+			} else if value, err = lookupPointer(doc, op.from); err == nil {
+				if doc, err = removePointer(doc, op.from); err == nil {
+					doc, err = addPointer(doc, op.path, value)
+				}
+			}
+		case "copy":
+			var value interface{}
+			if value, err = lookupPointer(doc, op.from); err == nil {
+				doc, err = addPointer(doc, op.path, copyJSON(value))
+			}
+		case "test":
+			var value interface{}
+			if value, err = lookupPointer(doc, op.path); err == nil && !equalJSON(value, op.value) {
+				err = patchErrorf("test of operation %d failed", i)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// isPrefix reports whether the tokens of prefix start the tokens of path.
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// patchFormat returns the patch format of a request by its content type, or an empty string for an unsupported one.
+func patchFormat(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+
+	switch mediaType {
+	case jsonPatchContentType, mergePatchContentType:
+		return mediaType
+	}
+
+	return ""
+}
+
+// Patch applies a patch in the given format to the JSON value of key under a single lock, and returns the
+// new value. The key keeps its expiry and content type, and the whole new value is logged as a put, so that
+// replaying doesn't depend on earlier values. Members of objects in the new value are sorted by name.
+func Patch(key, format string, patch []byte) (string, error) {
+	key = normalizeKey(key)
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	// The patch is parsed before taking the lock.
+	var ops []patchOperation
+	var members interface{}
+	var err error
+	if format == jsonPatchContentType {
+		ops, err = parseJSONPatch(patch)
+	} else if members, err = decodeJSON(string(patch)); err != nil {
+		err = patchErrorf("patch is not a JSON document. %v", err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	changes.RLock()
+	defer changes.RUnlock()
+	stripe := keyLock(key)
+	stripe.Lock()
+	defer stripe.Unlock()
+
+	store.Lock()
+	value, e, err := patchLocked(key, format, ops, members, now)
+	store.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if err := logger.WriteEvent(e); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// patchLocked applies the parsed patch to the value of key for Patch, returning the new value and the event
+// logging it. The caller must hold the store's lock.
+func patchLocked(key, format string, ops []patchOperation, members interface{}, now time.Time) (string, Event, error) {
+	// Keys which have expired but haven't been swept yet are missing.
+	stored, ok := store.m[key]
+	expiresAt, expires := store.expiry[key]
+	if !ok || (expires && hasExpired(key, expiresAt, now)) {
+		return "", Event{}, ErrorNoSuchKey
+	}
+	if err := checkPutLocked(key); err != nil {
+		return "", Event{}, err
+	}
+
+	item, err := storeEntry{key: key, value: stored, compressed: store.compressed[key]}.decode()
+	if err != nil {
+		return "", Event{}, err
+	}
+
+	doc, err := decodeJSON(item.Value)
+	if err != nil {
+		return "", Event{}, errNotJSON
+	}
+
+	if format == jsonPatchContentType {
+		if doc, err = applyJSONPatch(doc, ops); err != nil {
+			return "", Event{}, err
+		}
+	} else {
+		doc = mergePatch(doc, members)
+	}
+
+	// Encoding without escaping HTML keeps the value as close to what was put as possible.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return "", Event{}, err
+	}
+	value := strings.TrimSuffix(buf.String(), "\n")
+
+	stored, compressed, err := encodeValue(key, value)
+	if err != nil {
+		return "", Event{}, err
+	}
+
+	store.m[key] = stored
+	bumpVersionLocked(key)
+	if compressed {
+		store.compressed[key] = true
+	} else {
+		delete(store.compressed, key)
+	}
+	store.index.add(key, value)
+
+	return value, Event{EventType: EventPut, Key: key, Value: stored, Expiry: unixNano(store.expiry[key]), Compressed: compressed, ContentType: store.contentType[key], Pinned: store.pinned[key], verbatim: true}, nil
+}
+
+// PatchHandler is a handler function for the endpoint patching the JSON value of a key.
+func PatchHandler(c *gin.Context) {
+	rw, r := c.Writer, c.Request
+
+	format := patchFormat(r)
+	if format == "" {
+		writeError(rw, fmt.Sprintf("Content-Type header must be %s or %s", jsonPatchContentType, mergePatchContentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := decodeKey(binary, c.Param("key"))
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Patches are limited like any other request body.
+	patch, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxBodySize))
+	defer r.Body.Close()
+	if err != nil {
+		writeError(rw, "Request body must not be larger than 1MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	value, err := Patch(key, format, patch)
+	var schemaErr *SchemaError
+	var patchErr *PatchError
+	if errors.Is(err, ErrorEmptyKey) || errors.Is(err, errNotJSON) || errors.As(err, &schemaErr) || errors.As(err, &patchErr) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, errKeyExists) {
+		writeError(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrorValueTooLarge) {
+		writeError(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("patched key \"%s\"\n", key)
+
+	rw.Header().Set("Content-Type", "application/json")
+	if _, err := io.WriteString(rw, value); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing the operations of RFC 6902 JSON Patches, including their examples.
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"add","path":"/baz/bar","value":2}]`, `{"baz":{"bar":2},"foo":{"bar":1}}`},
+		{`{"a/b":1,"m~n":2}`, `[{"op":"test","path":"/a~1b","value":1.0},{"op":"remove","path":"/m~0n"}]`, `{"a/b":1}`},
+		{`{"big":12345678901234567890}`, `[{"op":"add","path":"","value":{"big":12345678901234567890}}]`, `{"big":12345678901234567890}`},
+	}
+
+	for _, test := range tests {
+		doc, _ := decodeJSON(test.doc)
+		ops, err := parseJSONPatch([]byte(test.patch))
+		if err != nil {
+			t.Errorf("Expected %s to parse, got %v", test.patch, err)
+			continue
+		}
+
+		if doc, err = applyJSONPatch(doc, ops); err != nil {
+			t.Errorf("Expected %s to apply to %s, got %v", test.patch, test.doc, err)
+			continue
+		}
+
+		expected, _ := decodeJSON(test.expected)
+		if !equalJSON(doc, expected) {
+			t.Errorf("Expected %s after applying %s to %s, got %v", test.expected, test.patch, test.doc, doc)
+		}
+	}
+
+	// Patches which fail leave it to the caller to discard the document.
+	failing := []string{
+		`[{"op":"test","path":"/foo","value":"baz"}]`,
+		`[{"op":"remove","path":"/missing"}]`,
+		`[{"op":"replace","path":"/missing","value":1}]`,
+		`[{"op":"add","path":"/list/5","value":1}]`,
+		`[{"op":"add","path":"/list/01","value":1}]`,
+		`[{"op":"move","from":"/list","path":"/list/0"}]`,
+		`[{"op":"add","path":"/foo/bar","value":1}]`,
+	}
+	for _, patch := range failing {
+		doc, _ := decodeJSON(`{"foo":"bar","list":[1]}`)
+		ops, err := parseJSONPatch([]byte(patch))
+		if err == nil {
+			_, err = applyJSONPatch(doc, ops)
+		}
+
+		var patchErr *PatchError
+		if !errors.As(err, &patchErr) {
+			t.Errorf("Expected %s to fail with a PatchError, got %v", patch, err)
+		}
+	}
+
+	// Malformed patches are rejected before applying them.
+	for _, patch := range []string{`{"op":"add"}`, `[{"op":"add","path":"/foo"}]`, `[{"op":"upsert","path":"/foo"}]`, `[{"op":"copy","path":"/foo"}]`, `[{"op":"add","path":"foo","value":1}]`} {
+		if _, err := parseJSONPatch([]byte(patch)); err == nil {
+			t.Errorf("Expected %s to be rejected", patch)
+		}
+	}
+}
+
+// Function for testing RFC 7386 merge patches, using the examples of the RFC.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+	}
+
+	for _, test := range tests {
+		doc, _ := decodeJSON(test.doc)
+		patch, _ := decodeJSON(test.patch)
+		expected, _ := decodeJSON(test.expected)
+
+		if actual := mergePatch(doc, patch); !equalJSON(actual, expected) {
+			t.Errorf("Expected %s after merging %s into %s, got %v", test.expected, test.patch, test.doc, actual)
+		}
+	}
+}
+
+// Function for testing the PATCH endpoint, which stores and logs the patched value.
+func TestPatchHandler(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-patch.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	expiresAt := time.Now().Add(time.Hour)
+	PutWithExpiry("config", `{"name":"yakv","limits":{"keys":10,"values":5}}`, expiresAt)
+	Put("text", "not json")
+
+	patch := func(key, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/yakv/v0/keys/"+key, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := patch("config", mergePatchContentType, `{"limits":{"keys":20,"values":null},"owner":"<me>"}`)
+	if expected := `{"limits":{"keys":20},"name":"yakv","owner":"<me>"}`; rec.Code != http.StatusOK || rec.Body.String() != expected {
+		t.Errorf("Expected 200 with %s, got %d %s", expected, rec.Code, rec.Body.String())
+	}
+
+	rec = patch("config", jsonPatchContentType+"; charset=utf-8", `[{"op":"test","path":"/name","value":"yakv"},{"op":"remove","path":"/owner"}]`)
+	if expected := `{"limits":{"keys":20},"name":"yakv"}`; rec.Code != http.StatusOK || rec.Body.String() != expected {
+		t.Errorf("Expected 200 with %s, got %d %s", expected, rec.Code, rec.Body.String())
+	}
+
+	// The key keeps its expiry, and the new value is logged.
+	if value, _ := Get("config"); value != `{"limits":{"keys":20},"name":"yakv"}` || store.expiry["config"] != expiresAt {
+		t.Errorf("Expected the patched value to be stored with its expiry, got %q %v", value, store.expiry["config"])
+	}
+	logger.Wait()
+	if lastID := logger.LastID(); lastID != 2 {
+		t.Errorf("Expected 2 logged puts, got %d", lastID)
+	}
+
+	// A failing patch leaves the value as it was.
+	failing := []struct {
+		key, contentType, body string
+		status                 int
+	}{
+		{"config", jsonPatchContentType, `[{"op":"remove","path":"/name"},{"op":"test","path":"/name","value":"yakv"}]`, http.StatusBadRequest},
+		{"config", jsonPatchContentType, `{"op":"remove","path":"/name"}`, http.StatusBadRequest},
+		{"config", mergePatchContentType, `{"name":`, http.StatusBadRequest},
+		{"config", "application/json", `{"name":"other"}`, http.StatusUnsupportedMediaType},
+		{"text", mergePatchContentType, `{"name":"other"}`, http.StatusBadRequest},
+		{"missing", mergePatchContentType, `{"name":"other"}`, http.StatusNotFound},
+	}
+	for _, test := range failing {
+		if rec := patch(test.key, test.contentType, test.body); rec.Code != test.status {
+			t.Errorf("Expected %d for %s %s, got %d %s", test.status, test.contentType, test.body, rec.Code, rec.Body.String())
+		}
+	}
+	if value, _ := Get("config"); value != `{"limits":{"keys":20},"name":"yakv"}` {
+		t.Errorf("Expected failing patches to leave the value alone, got %q", value)
+	}
+}
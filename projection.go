@@ -0,0 +1,109 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errInvalidField is raised when the field of a projection isn't a dotted path.
+var errInvalidField = errors.New("field must be a dotted path, e.g. address.city or items.0.name")
+
+// FieldNotFoundError is raised when the field of a projection doesn't exist in the value.
+type FieldNotFoundError struct {
+	Field string
+}
+
+// Error returns the field which doesn't exist.
+func (e *FieldNotFoundError) Error() string {
+	return fmt.Sprintf("field %q doesn't exist in the value", e.Field)
+}
+
+// projectField returns the field of a JSON value at a dotted path, encoded as JSON. Every segment of the path
+// is the name of a member of an object, or the index of an element of an array.
+func projectField(value, path string) (string, error) {
+	doc, err := decodeJSON(value)
+	if err != nil {
+		return "", errNotJSON
+	}
+
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		if segment == "" {
+			return "", errInvalidField
+		}
+
+		found := false
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			doc, found = v[segment]
+		case []interface{}:
+			if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(v) {
+				doc, found = v[index], true
+			}
+		}
+
+		if !found {
+			return "", &FieldNotFoundError{Field: strings.Join(segments[:i+1], ".")}
+		}
+	}
+
+	// The field is sent as it was stored, without escaping HTML characters.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// projectRequest returns the field of a value requested with the field query parameter, or the value itself
+// without one. It responds with 400 Bad Request when the value isn't JSON or the field isn't a path, and with
+// 404 Not Found when the field doesn't exist, reporting whether the value can be sent. Fields are sent as JSON,
+// unless they're sent base64-encoded.
+func projectRequest(rw http.ResponseWriter, r *http.Request, value string, binary bool) (string, bool) {
+	fields, ok := r.URL.Query()["field"]
+	if !ok {
+		return value, true
+	}
+
+	projected, err := projectField(value, fields[0])
+	var notFound *FieldNotFoundError
+	switch {
+	case errors.Is(err, errNotJSON) || errors.Is(err, errInvalidField):
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return "", false
+	case errors.As(err, &notFound):
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return "", false
+	case err != nil:
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+
+	if !binary {
+		rw.Header().Set("Content-Type", "application/json")
+	}
+
+	return projected, true
+}
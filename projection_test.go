@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that fields of JSON values are found by their dotted path.
+func TestProjectField(t *testing.T) {
+	const value = `{"name": "alice", "address": {"city": "Chennai", "zip": 600001}, "tags": ["a", "<b>"], "ratio": 0.10000000000000001}`
+
+	tests := []struct {
+		path, expected string
+	}{
+		{"name", `"alice"`},
+		{"address", `{"city":"Chennai","zip":600001}`},
+		{"address.zip", `600001`},
+		{"tags.1", `"<b>"`},
+		{"ratio", `0.10000000000000001`},
+	}
+	for _, test := range tests {
+		if projected, err := projectField(value, test.path); err != nil || projected != test.expected {
+			t.Errorf("Expected %s for %s, got %s %v", test.expected, test.path, projected, err)
+		}
+	}
+
+	for _, path := range []string{"missing", "address.street", "tags.2", "tags.-1", "name.first"} {
+		if _, err := projectField(value, path); err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+			t.Errorf("Expected %s not to exist, got %v", path, err)
+		}
+	}
+	for _, path := range []string{"", "address.", ".name"} {
+		if _, err := projectField(value, path); err != errInvalidField {
+			t.Errorf("Expected %q to be rejected, got %v", path, err)
+		}
+	}
+	if _, err := projectField("hello, yakv!", "name"); err != errNotJSON {
+		t.Errorf("Expected a value which isn't JSON to be rejected, got %v", err)
+	}
+}
+
+// Function for testing that GET only sends the requested field, and that GETs without one are unaffected.
+func TestGetField(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-get-field.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	Put("user", `{"name": "alice", "address": {"city": "Chennai"}}`)
+	Put("greeting", "hello, yakv!")
+
+	rec := serve("/yakv/v0/get?key=user&field=address.city")
+	if rec.Code != http.StatusOK || rec.Body.String() != `"Chennai"` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the city as JSON, got %d %q %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	if rec := serve("/yakv/v0/get?key=user"); rec.Body.String() != `{"name": "alice", "address": {"city": "Chennai"}}` {
+		t.Errorf("Expected the whole value without a field, got %s", rec.Body.String())
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/yakv/v0/get?key=user&field=address.street", http.StatusNotFound},
+		{"/yakv/v0/get?key=user&field=", http.StatusBadRequest},
+		{"/yakv/v0/get?key=greeting&field=name", http.StatusBadRequest},
+		{"/yakv/v0/get?key=missing&field=name", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		if rec := serve(test.path); rec.Code != test.status {
+			t.Errorf("Expected %d for %s, got %d %s", test.status, test.path, rec.Code, rec.Body.String())
+		}
+	}
+}
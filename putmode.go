@@ -0,0 +1,74 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Modes of -put-mode, deciding which keys a PUT may write.
+const (
+	putModeOverwrite          = "overwrite"             // PUT creates and overwrites keys.
+	putModeRejectExisting     = "reject-existing"       // PUT only creates keys, existing keys are write-once.
+	putModeCreateOnlyViaSetNX = "create-only-via-setnx" // PUT only overwrites keys, which are created with SETNX.
+)
+
+// errKeyExists is raised when a PUT would overwrite a key with -put-mode=reject-existing.
+var errKeyExists = errors.New("key already exists, and -put-mode doesn't allow overwriting it")
+
+// errPutCreate is raised when a PUT would create a key with -put-mode=create-only-via-setnx.
+var errPutCreate = fmt.Errorf("%w, and -put-mode only allows creating keys with /setnx", ErrorNoSuchKey)
+
+// checkPutMode checks that -put-mode is one of the modes.
+func checkPutMode() error {
+	switch config.putMode {
+	case putModeOverwrite, putModeRejectExisting, putModeCreateOnlyViaSetNX:
+		return nil
+	}
+
+	return fmt.Errorf("-put-mode must be %s, %s or %s, got %q", putModeOverwrite, putModeRejectExisting, putModeCreateOnlyViaSetNX, config.putMode)
+}
+
+// checkPutLocked checks that -put-mode allows a PUT of key, depending on whether the key exists. Every write
+// setting the value of a key other than SETNX and ADD, which only create keys, is checked like a PUT. Keys which
+// have expired but haven't been swept yet don't exist anymore. The caller must hold the store's lock, so that the
+// key can't be created or deleted before it's written.
+func checkPutLocked(key string) error {
+	_, exists := store.m[key]
+	if expiresAt, expires := store.expiry[key]; exists && expires && hasExpired(key, expiresAt, time.Now()) {
+		exists = false
+	}
+
+	return checkPut(exists)
+}
+
+// checkPut checks that -put-mode allows a PUT of a key which exists or not, for stores without expiry. Every PUT
+// is allowed while replaying.
+func checkPut(exists bool) error {
+	if replaying || config.putMode == putModeOverwrite || config.putMode == "" {
+		return nil
+	}
+
+	switch {
+	case exists && config.putMode == putModeRejectExisting:
+		return errKeyExists
+	case !exists && config.putMode == putModeCreateOnlyViaSetNX:
+		return errPutCreate
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing which keys PUT may create and overwrite in every -put-mode.
+func TestPutMode(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-put-mode.log")()
+	defer resetStores()
+	defer func(mode string) { config.putMode = mode }(config.putMode)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(method, path, body string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	tests := []struct {
+		mode              string
+		create, overwrite int // Statuses of a PUT creating a key, and overwriting it.
+	}{
+		{putModeOverwrite, http.StatusCreated, http.StatusCreated},
+		{putModeRejectExisting, http.StatusCreated, http.StatusConflict},
+		{putModeCreateOnlyViaSetNX, http.StatusNotFound, http.StatusCreated},
+	}
+
+	for _, test := range tests {
+		resetStores()
+		config.putMode = test.mode
+
+		if code := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "first"}`); code != test.create {
+			t.Errorf("Expected %d for a new key with -put-mode=%s, got %d", test.create, test.mode, code)
+		}
+
+		// SETNX creates keys whatever the mode.
+		serve(http.MethodPost, "/yakv/v0/setnx", `{"key": "yakv", "value": "first"}`)
+
+		if code := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "second"}`); code != test.overwrite {
+			t.Errorf("Expected %d for an existing key with -put-mode=%s, got %d", test.overwrite, test.mode, code)
+		}
+
+		// A rejected PUT leaves the value as it is.
+		expected := "second"
+		if test.overwrite != http.StatusCreated {
+			expected = "first"
+		}
+		if value, err := Get("yakv"); err != nil || value != expected {
+			t.Errorf("Expected %q with -put-mode=%s, got %q %v", expected, test.mode, value, err)
+		}
+	}
+
+	// The mode applies to Put too, not only to the HTTP API.
+	config.putMode = putModeRejectExisting
+	if err := Put("yakv", "third"); !errors.Is(err, errKeyExists) {
+		t.Errorf("Expected Put to reject an existing key, got %v", err)
+	}
+
+	// Replaying isn't affected, so that a log with overwrites still replays.
+	replaying = true
+	err := Put("yakv", "third")
+	replaying = false
+	if err != nil {
+		t.Errorf("Expected an overwrite while replaying, got %v", err)
+	}
+
+	config.putMode = "sometimes"
+	if err := checkPutMode(); err == nil {
+		t.Error("Expected an unknown -put-mode to be rejected.")
+	}
+}
+
+// Function for testing that every route writing a key is checked like a PUT, leaving the key as it is.
+func TestPutModeRoutes(t *testing.T) {
+	// Restore to original state after test.
+	defer initDatabases(len(databases))
+	initDatabases(2)
+	defer useTempLogger(t, "temp-put-mode-routes.log")()
+	defer resetStores()
+	defer func(mode string) { config.putMode = mode }(config.putMode)
+	defer func(allow bool) { config.allowDump = allow }(config.allowDump)
+	config.allowDump = true
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(db, method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if db != "" {
+			req.Header.Set(databaseHeader, db)
+		}
+		if method == http.MethodPatch {
+			req.Header.Set("Content-Type", mergePatchContentType)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		mode, name, db, method, path, body string
+		code                               int
+	}{
+		{putModeRejectExisting, "getset", "", http.MethodPost, "/yakv/v0/getset", `{"key": "yakv", "value": "2"}`, http.StatusConflict},
+		{putModeRejectExisting, "append", "", http.MethodPost, "/yakv/v0/append", `{"key": "yakv", "value": "2"}`, http.StatusConflict},
+		{putModeRejectExisting, "patch", "", http.MethodPatch, "/yakv/v0/keys/yakv", `{"a": 2}`, http.StatusConflict},
+		{putModeRejectExisting, "rename", "", http.MethodPost, "/yakv/v0/rename", `{"from": "other", "to": "yakv", "overwrite": true}`, http.StatusConflict},
+		{putModeRejectExisting, "transform", "", http.MethodPost, "/yakv/v0/admin/transform", `{"prefix": "yakv", "op": "suffix", "to": "2"}`, http.StatusConflict},
+		{putModeRejectExisting, "getreset", "", http.MethodPost, "/yakv/v0/getreset", `{"key": "yakv"}`, http.StatusConflict},
+		{putModeRejectExisting, "load", "", http.MethodPost, "/yakv/v0/admin/load", `{"yakv": "2"}`, http.StatusConflict},
+		{putModeRejectExisting, "namespace", "", http.MethodPut, "/yakv/v0/ns/users/keys/yakv", `{"value": "2"}`, http.StatusConflict},
+		{putModeRejectExisting, "database", "1", http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "2"}`, http.StatusConflict},
+		{putModeCreateOnlyViaSetNX, "getset", "", http.MethodPost, "/yakv/v0/getset", `{"key": "new", "value": "2"}`, http.StatusNotFound},
+		{putModeCreateOnlyViaSetNX, "append", "", http.MethodPost, "/yakv/v0/append", `{"key": "new", "value": "2"}`, http.StatusNotFound},
+		{putModeCreateOnlyViaSetNX, "rename", "", http.MethodPost, "/yakv/v0/rename", `{"from": "other", "to": "new"}`, http.StatusNotFound},
+		{putModeCreateOnlyViaSetNX, "load", "", http.MethodPost, "/yakv/v0/admin/load", `{"new": "2"}`, http.StatusNotFound},
+		{putModeCreateOnlyViaSetNX, "namespace", "", http.MethodPut, "/yakv/v0/ns/users/keys/new", `{"value": "2"}`, http.StatusNotFound},
+		{putModeCreateOnlyViaSetNX, "database", "1", http.MethodPut, "/yakv/v0/put", `{"key": "new", "value": "2"}`, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		resetStores()
+		config.putMode = putModeOverwrite
+		Put("yakv", "1")
+		Put("other", "1")
+		NamespacePut("users", "yakv", "1", nil)
+		DatabasePut(1, "yakv", "1", nil)
+
+		config.putMode = test.mode
+		if code := serve(test.db, test.method, test.path, test.body); code != test.code {
+			t.Errorf("Expected %d for %s with -put-mode=%s, got %d", test.code, test.name, test.mode, code)
+		}
+
+		// The rejected write leaves every key as it is.
+		if value, err := Get("yakv"); err != nil || value != "1" {
+			t.Errorf("Expected yakv to be kept by %s with -put-mode=%s, got %q %v", test.name, test.mode, value, err)
+		}
+		if value, err := NamespaceGet("users", "yakv"); err != nil || value != "1" {
+			t.Errorf("Expected the namespaced key to be kept by %s with -put-mode=%s, got %q %v", test.name, test.mode, value, err)
+		}
+		if value, err := DatabaseGet(1, "yakv"); err != nil || value != "1" {
+			t.Errorf("Expected the key of database 1 to be kept by %s with -put-mode=%s, got %q %v", test.name, test.mode, value, err)
+		}
+		if _, err := Get("new"); !errors.Is(err, ErrorNoSuchKey) {
+			t.Errorf("Expected no new key to be created by %s with -put-mode=%s, got %v", test.name, test.mode, err)
+		}
+		if _, err := Get("other"); err != nil {
+			t.Errorf("Expected other to be kept by %s with -put-mode=%s, got %v", test.name, test.mode, err)
+		}
+	}
+}
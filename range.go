@@ -0,0 +1,123 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errInvalidRange is raised when a Range header is malformed, or none of its bytes are in the value.
+var errInvalidRange = errors.New("range is malformed or not satisfiable")
+
+// byteRange is a range of bytes of a value, end excluded.
+type byteRange struct {
+	start, end int
+}
+
+// parseRange parses a Range header for a single range of bytes of a value of size bytes. Ranges of other
+// units and multiple ranges aren't supported, so they are ignored and the whole value is served, as allowed
+// for servers. ok is false if the whole value has to be served.
+func parseRange(header string, size int) (br byteRange, ok bool, err error) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return byteRange{}, false, nil
+	}
+
+	spec := strings.TrimSpace(strings.TrimPrefix(header, "bytes="))
+	if strings.Contains(spec, ",") {
+		return byteRange{}, false, nil
+	}
+
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return byteRange{}, false, errInvalidRange
+	}
+	first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+
+	// A suffix range, e.g. -500, selects the last bytes of the value.
+	if first == "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n <= 0 || size == 0 {
+			return byteRange{}, false, errInvalidRange
+		}
+		if n > size {
+			n = size
+		}
+		return byteRange{start: size - n, end: size}, true, nil
+	}
+
+	start, err := strconv.Atoi(first)
+	if err != nil || start < 0 || start >= size {
+		return byteRange{}, false, errInvalidRange
+	}
+
+	// An open range, e.g. 500-, selects the rest of the value.
+	end := size
+	if last != "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < start {
+			return byteRange{}, false, errInvalidRange
+		}
+		if n+1 < size {
+			end = n + 1
+		}
+	}
+
+	return byteRange{start: start, end: end}, true, nil
+}
+
+// strongETagMatches reports whether an If-Range header matches etag, using the strong comparison. Weak ETags
+// never match, since a range of one representation doesn't fit another, so every ETag of bodyETag is refused.
+func strongETagMatches(header, etag string) bool {
+	return !strings.HasPrefix(header, "W/") && !strings.HasPrefix(etag, "W/") && header == etag
+}
+
+// writeValue writes the value a GET returns, or the range of it requested by a Range header with 206 Partial
+// Content. With an If-Range header, the range is only served if it strongly matches the ETag set by
+// writeCacheHeaders, and the whole value otherwise. Invalid ranges are answered with 416 Range Not Satisfiable.
+func writeValue(rw http.ResponseWriter, r *http.Request, body string) error {
+	rw.Header().Set("Accept-Ranges", "bytes")
+
+	header := r.Header.Get("Range")
+	if ifRange := r.Header.Get("If-Range"); header == "" || (ifRange != "" && !strongETagMatches(ifRange, rw.Header().Get("ETag"))) {
+		_, err := rw.Write([]byte(body))
+		return err
+	}
+
+	br, ok, err := parseRange(header, len(body))
+	if err != nil {
+		rw.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(body)))
+		writeError(rw, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+	if !ok {
+		_, err := rw.Write([]byte(body))
+		return err
+	}
+
+	// The content type is detected from the whole value, not from the start of the range.
+	if rw.Header().Get("Content-Type") == "" {
+		rw.Header().Set("Content-Type", http.DetectContentType([]byte(body)))
+	}
+	rw.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", br.start, br.end-1, len(body)))
+	rw.Header().Set("Content-Length", strconv.Itoa(br.end-br.start))
+	rw.WriteHeader(http.StatusPartialContent)
+
+	_, err = rw.Write([]byte(body[br.start:br.end]))
+	return err
+}
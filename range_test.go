@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that GET serves ranges of values with 206, and rejects invalid ranges with 416.
+func TestGetRange(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-range.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	Put("blob", "0123456789")
+	get := func(header ...string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/yakv/v0/get", strings.NewReader(`{"key": "blob"}`))
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		header, body, contentRange string
+	}{
+		{"bytes=2-5", "2345", "bytes 2-5/10"},
+		{"bytes=7-", "789", "bytes 7-9/10"},
+		{"bytes=-3", "789", "bytes 7-9/10"},
+		{"bytes=8-100", "89", "bytes 8-9/10"},
+		{"bytes=-100", "0123456789", "bytes 0-9/10"},
+	}
+	for _, test := range tests {
+		rec := get("Range", test.header)
+		if rec.Code != http.StatusPartialContent || rec.Body.String() != test.body || rec.Header().Get("Content-Range") != test.contentRange {
+			t.Errorf("Expected 206 %q with Content-Range %q for %s, got %d %q %q", test.body, test.contentRange, test.header, rec.Code, rec.Body.String(), rec.Header().Get("Content-Range"))
+		}
+	}
+
+	// Malformed and unsatisfiable ranges are rejected.
+	for _, header := range []string{"bytes=10-", "bytes=5-2", "bytes=-0", "bytes=abc", "bytes=1"} {
+		if rec := get("Range", header); rec.Code != http.StatusRequestedRangeNotSatisfiable || rec.Header().Get("Content-Range") != "bytes */10" {
+			t.Errorf("Expected 416 for %s, got %d %q", header, rec.Code, rec.Header().Get("Content-Range"))
+		}
+	}
+
+	// Without a range, with multiple ranges, other units or a stale If-Range, the whole value is served.
+	for _, header := range [][]string{nil, {"Range", "bytes=0-1,4-5"}, {"Range", "items=0-1"}, {"Range", "bytes=0-1", "If-Range", `"stale"`}} {
+		rec := get(header...)
+		if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" || rec.Header().Get("Accept-Ranges") != "bytes" {
+			t.Errorf("Expected the whole value for %v, got %d %q", header, rec.Code, rec.Body.String())
+		}
+	}
+
+	// The ETag is weak, so even a matching If-Range serves the whole value.
+	etag := get().Header().Get("ETag")
+	if rec := get("Range", "bytes=0-1", "If-Range", etag); rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Errorf("Expected the whole value for a weak If-Range, got %d %q", rec.Code, rec.Body.String())
+	}
+}
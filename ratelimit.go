@@ -0,0 +1,146 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Interval for removing idle clients from the rate limiter.
+const rateLimitCleanupInterval = time.Minute
+
+// Paths which are never rate limited.
+var rateLimitExempt = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// tokenBucket holds the tokens available to a single client.
+type tokenBucket struct {
+	tokens float64   // Tokens left in the bucket.
+	last   time.Time // Last time the bucket was refilled.
+}
+
+// rateLimiter is a concurrency-safe token-bucket rate limiter keyed by client.
+type rateLimiter struct {
+	sync.Mutex
+	rate    float64 // Tokens added per second.
+	burst   float64 // Maximum number of tokens in a bucket.
+	buckets map[string]*tokenBucket
+}
+
+// newRateLimiter creates a rate limiter allowing rate requests per second, with bursts of up to burst requests.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token from the client's bucket. If the bucket is empty, it returns false
+// along with the time until the next token is available.
+func (rl *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	bucket, ok := rl.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[client] = bucket
+	}
+
+	// Refill the bucket for the time passed since the last request.
+	bucket.tokens = math.Min(rl.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rl.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / rl.rate * float64(time.Second))
+		return false, wait
+	}
+
+	bucket.tokens--
+	return true, 0
+}
+
+// cleanup removes clients which haven't made a request for longer than idle.
+// Their buckets would be full again anyway, so forgetting them doesn't change any decision.
+func (rl *rateLimiter) cleanup(idle time.Duration, now time.Time) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	for client, bucket := range rl.buckets {
+		if now.Sub(bucket.last) > idle {
+			delete(rl.buckets, client)
+		}
+	}
+}
+
+// runCleanup periodically removes idle clients until the context is done.
+func (rl *rateLimiter) runCleanup(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+
+	// A client is idle once its bucket has had enough time to refill completely.
+	idle := time.Duration(rl.burst/rl.rate*float64(time.Second)) + rateLimitCleanupInterval
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			rl.cleanup(idle, now)
+		}
+	}
+}
+
+// rateLimitClient identifies the client of a request, by API key once it was authenticated and by IP otherwise,
+// so that a client can't get a bucket of its own by sending any X-API-Key header.
+func rateLimitClient(c *gin.Context) string {
+	if apiKey := c.GetString("api_key"); apiKey != "" {
+		return "key:" + apiKey
+	}
+
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimitMiddleware rejects requests with 429 Too Many Requests once a client exceeds its rate limit.
+func RateLimitMiddleware(rl *rateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rateLimitExempt[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		allowed, wait := rl.allow(rateLimitClient(c), time.Now())
+		if !allowed {
+			// Retry-After is expressed in whole seconds.
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(c.Writer, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Helper function for creating a router behind the rate limiter.
+func newRateLimitedRouter(rl *rateLimiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(RateLimitMiddleware(rl))
+	r.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	return r
+}
+
+// Function for testing that requests past the limit are rejected with 429.
+func TestRateLimitMiddleware(t *testing.T) {
+	r := newRateLimitedRouter(newRateLimiter(1, 3))
+
+	var limited int
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+		if rec.Code == http.StatusTooManyRequests {
+			limited++
+
+			if rec.Header().Get("Retry-After") == "" {
+				t.Error("Expected a Retry-After header on a 429 response.")
+			}
+		}
+	}
+
+	// Only the burst is allowed through.
+	if limited != 7 {
+		t.Errorf("Expected 7 requests to be limited, got %d", limited)
+	}
+
+	// An API key which wasn't authenticated doesn't get a bucket of its own.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("X-API-Key", "another-client")
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected an unauthenticated API key to share the bucket of its IP, got %d", rec.Code)
+	}
+
+	// Other clients have their own bucket.
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.RemoteAddr = "192.0.2.2:1234"
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected another client to be allowed, got %d", rec.Code)
+	}
+}
+
+// Function for testing that authenticated API keys have a bucket of their own, even from the same IP.
+func TestRateLimitAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(APIKeyMiddleware("secret"))
+	r.Use(RateLimitMiddleware(newRateLimiter(1, 1)))
+	r.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(apiKey string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("secret"); code != http.StatusOK {
+		t.Fatalf("Expected the first request to be allowed, got %d", code)
+	}
+	if code := serve("secret"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the second request of the key to be limited, got %d", code)
+	}
+
+	// Requests with an invalid key are rejected before they reach the rate limiter.
+	if code := serve("guess"); code != http.StatusUnauthorized {
+		t.Errorf("Expected an invalid key to be rejected, got %d", code)
+	}
+}
+
+// Function for testing that exempt paths are never rate limited.
+func TestRateLimitExempt(t *testing.T) {
+	r := newRateLimitedRouter(newRateLimiter(1, 1))
+
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected /healthz to be exempt, got %d", rec.Code)
+		}
+	}
+}
+
+// Function for testing the removal of idle clients.
+func TestRateLimitCleanup(t *testing.T) {
+	rl := newRateLimiter(1, 1)
+	now := time.Now()
+
+	rl.allow("ip:1", now)
+	rl.allow("ip:2", now.Add(time.Minute))
+	rl.cleanup(30*time.Second, now.Add(time.Minute))
+
+	if _, ok := rl.buckets["ip:1"]; ok {
+		t.Error("Idle client wasn't removed.")
+	}
+	if _, ok := rl.buckets["ip:2"]; !ok {
+		t.Error("Active client was removed.")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Whether writes are paused for maintenance, 1 if they are. Accessed atomically.
+var writesPaused int32
+
+// Admin routes which keep working while writes are paused, relative to the admin route prefix.
+var pauseExempt = map[string]bool{
+	"/readonly":   true,
+	"/drain":      true,
+	"/verify-log": true,
+}
+
+// ReadOnlyBody is a struct for defining the request body structure for pausing writes.
+type ReadOnlyBody struct {
+	Enabled bool `json:"enabled"`
+}
+
+// ID of the last event applied while replaying the transaction log.
+var replayedID uint64
+
+// replayUntil forwards the events up to and including the event with the given ID, and discards the
+// events after it. An ID of 0 forwards every event. The ID of the last forwarded event is kept in replayedID.
+// Errors are forwarded once all events have been, so that no event is lost when the reader stops at an error.
+func replayUntil(events <-chan Event, errors <-chan error, id uint64) (<-chan Event, <-chan error) {
+	outEvent := make(chan Event)
+	outError := make(chan error, 1)
+
+	go func() {
+		defer close(outError)
+
+		func() {
+			defer close(outEvent)
+
+			for e := range events {
+				// Later events are still read, so that the log is read until the end.
+				if id > 0 && e.ID > id {
+					continue
+				}
+
+				replayedID = e.ID
+				outEvent <- e
+			}
+		}()
+
+		if err := <-errors; err != nil {
+			outError <- err
+		}
+	}()
+
+	return outEvent, outError
+}
+
+// ReadOnlyMiddleware rejects every request which could modify the store with 403 Forbidden.
+func ReadOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+		default:
+			writeError(c.Writer, "yakv is in read-only mode", http.StatusForbidden)
+			c.Abort()
+		}
+	}
+}
+
+// setWritesPaused pauses or resumes writes.
+func setWritesPaused(paused bool) {
+	var value int32
+	if paused {
+		value = 1
+	}
+
+	atomic.StoreInt32(&writesPaused, value)
+}
+
+// isWritesPaused reports whether writes are paused.
+func isWritesPaused() bool {
+	return atomic.LoadInt32(&writesPaused) == 1
+}
+
+// PauseWritesMiddleware rejects every request which could modify the store with 503 Service Unavailable
+// while writes are paused for maintenance, except for the routes in exempt. The prefix of the group is stripped
+// from the route before checking exempt.
+func PauseWritesMiddleware(prefix string, exempt map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if isWritesPaused() && !exempt[strings.TrimPrefix(c.FullPath(), prefix)] {
+			writeError(c.Writer, "writes are paused for maintenance, try again later", http.StatusServiceUnavailable)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ReadOnlyHandler is a handler function for the admin endpoint pausing and resuming writes.
+func ReadOnlyHandler(rw http.ResponseWriter, r *http.Request) {
+	var body ReadOnlyBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	setWritesPaused(body.Enabled)
+	log.Printf("writes paused: %t", body.Enabled)
+}
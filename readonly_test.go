@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that a replay stops at the given event ID.
+func TestReplayUntil(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-replay-until.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	defer func() { config.replayUntil = 0 }()
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactionLogger.Log()
+	transactionLogger.WritePut("yakv1", "yak1")
+	transactionLogger.WritePut("yakv2", "yak2")
+	transactionLogger.WriteDelete("yakv1")
+	transactionLogger.Close()
+
+	resetStores()
+	config.replayUntil = 2
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+
+	// The delete of yakv1 comes after the replayed events.
+	if len(store.m) != 2 || store.m["yakv1"] != "yak1" {
+		t.Errorf("Expected the state as of transaction 2, got %v", store.m)
+	}
+	if replayedID != 2 {
+		t.Errorf("Expected transaction 2 to be the last replayed, got %d", replayedID)
+	}
+}
+
+// Function for testing that read-only mode only lets reads through.
+func TestReadOnlyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ReadOnlyMiddleware())
+	r.GET("/get", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.PUT("/put", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/get", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected reads to be allowed, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/put", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected writes to be rejected with 403, got %d", rec.Code)
+	}
+}
+
+// Function for testing that pausing writes rejects writes with 503 while reads keep working.
+func TestPauseWrites(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-pause.log")()
+	defer resetStores()
+	defer setWritesPaused(false)
+
+	Put("yakv", "hello, yakv!")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := serve(http.MethodPost, "/yakv/v0/admin/readonly", `{"enabled": true}`); rec.Code != http.StatusOK || !isWritesPaused() {
+		t.Fatalf("Expected writes to be paused, got %d", rec.Code)
+	}
+
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "changed"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a PUT, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodDelete, "/yakv/v0/delete", `{"key": "yakv"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a DELETE, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/yakv/v0/get", `{"key": "yakv"}`); rec.Code != http.StatusOK || rec.Body.String() != "hello, yakv!" {
+		t.Errorf("Expected reads to keep working, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodGet, "/yakv/v0/stats", ""); !strings.Contains(rec.Body.String(), `"read_only":true`) {
+		t.Errorf("Expected the stats to report read-only mode, got %q", rec.Body.String())
+	}
+
+	// The pause can be lifted while writes are paused.
+	if rec := serve(http.MethodPost, "/yakv/v0/admin/readonly", `{"enabled": false}`); rec.Code != http.StatusOK || isWritesPaused() {
+		t.Fatalf("Expected writes to be resumed, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "changed"}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected 201 after resuming writes, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ErrorKeyExists is raised when renaming a key to a key which already exists, without overwriting it.
+var ErrorKeyExists = errors.New("key already exists")
+
+// RenameBody is a struct for defining the request body structure for renaming a key.
+type RenameBody struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Overwrite bool   `json:"overwrite"` // Whether an existing key named To is replaced.
+}
+
+// Rename moves the value of the key from to the key to under a single lock, along with its expiry and content type.
+// Unless overwrite is set, it fails with ErrorKeyExists if to already exists. The move is logged as a put of to
+// followed by a delete of from.
+func Rename(from, to string, overwrite bool) error {
+	from, to = normalizeKey(from), normalizeKey(to)
+	if err := validateKey(from); err != nil {
+		return err
+	}
+	if err := validateKey(to); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	// Both stripes are held until the move is logged.
+	changes.RLock()
+	defer changes.RUnlock()
+	unlock := lockKeys(from, to)
+	defer unlock()
+
+	store.Lock()
+	e, moved, err := renameLocked(from, to, overwrite, now)
+	store.Unlock()
+	if err != nil || !moved {
+		return err
+	}
+
+	err = logger.WriteEvent(e)
+	if deleteErr := logger.WriteDelete(from); err == nil {
+		err = deleteErr
+	}
+
+	return err
+}
+
+// renameLocked moves the value of from to to for Rename, returning the put of to logging the move and whether
+// the key was moved. The caller must hold the store's lock.
+func renameLocked(from, to string, overwrite bool, now time.Time) (Event, bool, error) {
+	// Keys which have expired but haven't been swept yet are treated as missing.
+	live := func(key string) bool {
+		_, ok := store.m[key]
+		expiresAt, expires := store.expiry[key]
+		return ok && (!expires || !hasExpired(key, expiresAt, now))
+	}
+
+	if !live(from) {
+		return Event{}, false, ErrorNoSuchKey
+	}
+	if from == to {
+		return Event{}, false, nil
+	}
+	if !overwrite && live(to) {
+		return Event{}, false, ErrorKeyExists
+	}
+	if err := checkPutLocked(to); err != nil {
+		return Event{}, false, err
+	}
+
+	// The value index holds uncompressed values.
+	if store.index != nil {
+		item, err := storeEntry{key: from, value: store.m[from], compressed: store.compressed[from]}.decode()
+		if err != nil {
+			return Event{}, false, err
+		}
+		store.index.remove(from)
+		store.index.add(to, item.Value)
+	}
+
+	e := Event{EventType: EventPut, Key: to, Value: store.m[from], Compressed: store.compressed[from], ContentType: store.contentType[from], Pinned: store.pinned[from], verbatim: true}
+
+	// The value of from moves along with its spill file.
+	store.m[to] = e.Value
+	bumpVersionLocked(to)
+	if expiresAt, ok := store.expiry[from]; ok {
+		store.expiry[to] = expiresAt
+		e.Expiry = unixNano(expiresAt)
+	} else {
+		delete(store.expiry, to)
+	}
+	if e.Compressed {
+		store.compressed[to] = true
+	} else {
+		delete(store.compressed, to)
+	}
+	if e.ContentType != "" {
+		store.contentType[to] = e.ContentType
+	} else {
+		delete(store.contentType, to)
+	}
+	if e.Pinned {
+		store.pinned[to] = true
+	} else {
+		delete(store.pinned, to)
+	}
+	touchKey(to, e.Pinned)
+
+	delete(store.m, from)
+	delete(store.expiry, from)
+	delete(store.compressed, from)
+	delete(store.contentType, from)
+	delete(store.pinned, from)
+	delete(store.version, from)
+	forgetKey(from)
+
+	return e, true, nil
+}
+
+// RenameHandler is a handler function for the endpoint renaming a key.
+func RenameHandler(rw http.ResponseWriter, r *http.Request) {
+	var body RenameBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := decodeKey(binary, body.From)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := decodeKey(binary, body.To)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = Rename(from, to, body.Overwrite)
+	switch {
+	case errors.Is(err, ErrorEmptyKey):
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrorNoSuchKey):
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrorKeyExists), errors.Is(err, errKeyExists):
+		writeError(rw, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("renamed key \"%s\" to \"%s\"\n", from, to)
+}
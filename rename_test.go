@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that renaming moves the value along with its expiry, and replays to the same state.
+func TestRename(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-rename.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	store.index = newValueIndex()
+
+	expiresAt := time.Now().Add(time.Hour)
+	PutWithExpiry("staging:config", "v2", expiresAt)
+	Put("prod:config", "v1")
+
+	// An existing key isn't replaced without overwrite.
+	if err := Rename("staging:config", "prod:config", false); !errors.Is(err, ErrorKeyExists) {
+		t.Fatalf("Expected ErrorKeyExists, got %v", err)
+	}
+	if err := Rename("staging:config", "prod:config", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Get("staging:config"); !errors.Is(err, ErrorNoSuchKey) {
+		t.Errorf("Expected the old key to be gone, got %v", err)
+	}
+	if value, _ := Get("prod:config"); value != "v2" || store.expiry["prod:config"] != expiresAt {
+		t.Errorf("Expected the value and expiry to move, got %q %v", value, store.expiry["prod:config"])
+	}
+	if keys, _ := Find("v2"); len(keys) != 1 || keys[0] != "prod:config" {
+		t.Errorf("Expected the value index to follow the rename, got %v", keys)
+	}
+
+	if err := Rename("missing", "prod:config", true); !errors.Is(err, ErrorNoSuchKey) {
+		t.Errorf("Expected ErrorNoSuchKey, got %v", err)
+	}
+
+	// Renaming a key to itself changes nothing.
+	if err := Rename("prod:config", "prod:config", false); err != nil {
+		t.Errorf("Expected renaming a key to itself to succeed, got %v", err)
+	}
+
+	logger.Wait()
+	reader, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	events, errs := reader.ReadEvents()
+	replayed, _, err := replayState(events, errs, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 1 || replayed["prod:config"] != "v2" {
+		t.Errorf("Expected replaying to give only the renamed key, got %v", replayed)
+	}
+}
+
+// Function for testing the status codes of the rename endpoint.
+func TestRenameHandler(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-rename-handler.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	Put("a", "one")
+	Put("b", "two")
+
+	for _, c := range []struct {
+		body   string
+		status int
+	}{
+		{`{"from": "a", "to": "b"}`, http.StatusConflict},
+		{`{"from": "missing", "to": "c"}`, http.StatusNotFound},
+		{`{"from": "a", "to": ""}`, http.StatusBadRequest},
+		{`{"from": "a", "to": "c"}`, http.StatusOK},
+		{`{"from": "c", "to": "b", "overwrite": true}`, http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/rename", strings.NewReader(c.body)))
+		if rec.Code != c.status {
+			t.Errorf("Expected %d for %s, got %d %q", c.status, c.body, rec.Code, rec.Body.String())
+		}
+	}
+
+	if value, _ := Get("b"); value != "one" || Count("") != 1 {
+		t.Errorf("Expected only b holding a's value, got %q and %d keys", value, Count(""))
+	}
+}
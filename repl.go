@@ -0,0 +1,86 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/burntcarrot/yakv/client"
+)
+
+// Help text for the REPL.
+const replHelp = `commands:
+    get <key>            get the value of a key
+    put <key> <value>    set the value of a key
+    del <key>            delete a key
+    keys [prefix]        list keys, optionally starting with prefix
+    help                 show this help
+    exit                 leave the REPL`
+
+// runREPL reads commands from in, runs them against the server and prints the results to out.
+func runREPL(in io.Reader, out io.Writer, c *client.Client) error {
+	scanner := bufio.NewScanner(in)
+
+	fmt.Fprint(out, "yakv> ")
+	for scanner.Scan() {
+		// Values may contain spaces, so the line is split into at most three fields.
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 3)
+
+		switch cmd := fields[0]; {
+		case cmd == "":
+		case cmd == "exit" || cmd == "quit":
+			return nil
+		case cmd == "help":
+			fmt.Fprintln(out, replHelp)
+		case cmd == "get" && len(fields) == 2:
+			value, err := c.Get(fields[1])
+			printResult(out, value, err)
+		case cmd == "put" && len(fields) == 3:
+			printResult(out, "OK", c.Put(fields[1], fields[2]))
+		case cmd == "del" && len(fields) == 2:
+			printResult(out, "OK", c.Delete(fields[1]))
+		case cmd == "keys" && len(fields) <= 2:
+			var prefix string
+			if len(fields) == 2 {
+				prefix = fields[1]
+			}
+
+			keys, err := c.Keys(prefix)
+			printResult(out, strings.Join(keys, "\n"), err)
+		default:
+			fmt.Fprintf(out, "invalid command %q, type \"help\" for a list of commands\n", scanner.Text())
+		}
+
+		fmt.Fprint(out, "yakv> ")
+	}
+
+	return scanner.Err()
+}
+
+// printResult prints the result of a command, or its error.
+func printResult(out io.Writer, result string, err error) {
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		fmt.Fprintln(out, "(not found)")
+	case err != nil:
+		fmt.Fprintln(out, "error:", err)
+	case result != "":
+		fmt.Fprintln(out, result)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/burntcarrot/yakv/client"
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing the REPL against a running server.
+func TestREPL(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-repl.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+
+	var err error
+	logger, err = NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Log()
+	defer logger.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(APIKeyMiddleware("secret"))
+	r.GET("yakv/v0/get", gin.WrapF(GetHandler))
+	r.PUT("yakv/v0/put", gin.WrapF(PutHandler))
+	r.DELETE("yakv/v0/delete", gin.WrapF(DeleteHandler))
+	r.GET("yakv/v0/keys", gin.WrapF(KeysHandler))
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	in := strings.NewReader(strings.Join([]string{
+		"put repl:1 hello, yakv!",
+		"put repl:2 two",
+		"get repl:1",
+		"keys repl:",
+		"del repl:1",
+		"get repl:1",
+		"bogus",
+		"exit",
+	}, "\n"))
+
+	var out bytes.Buffer
+	if err := runREPL(in, &out, client.New(server.URL, "secret")); err != nil {
+		t.Fatal(err)
+	}
+	defer Delete("repl:2")
+
+	for _, expected := range []string{"OK", "hello, yakv!", "repl:1\nrepl:2", "(not found)", "invalid command"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected REPL output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+
+	// Requests without the API key are rejected.
+	out.Reset()
+	if err := runREPL(strings.NewReader("get repl:2"), &out, client.New(server.URL, "")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "401") {
+		t.Errorf("Expected an unauthorized error, got:\n%s", out.String())
+	}
+}
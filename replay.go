@@ -0,0 +1,220 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Maximum number of attempts to read the transaction log to its end.
+const logReadMaxAttempts = 5
+
+// Delay before the first retry of a failed read of the transaction log, doubled after every attempt.
+var logReadRetryDelay = 50 * time.Millisecond
+
+// readLogFile reads from the transaction log file. It is replaced in tests to simulate failing disks.
+var readLogFile = (*os.File).Read
+
+// logFileReader reads the transaction log file through readLogFile.
+type logFileReader struct {
+	file *os.File
+	end  int64 // Offset at which reading stops, 0 reading to the end of the file.
+	err  error // Last error other than io.EOF.
+}
+
+// Read reads from the file.
+func (r *logFileReader) Read(b []byte) (int, error) {
+	if r.end > 0 {
+		offset, err := r.file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		if offset >= r.end {
+			return 0, io.EOF
+		}
+		if int64(len(b)) > r.end-offset {
+			b = b[:r.end-offset]
+		}
+	}
+
+	n, err := readLogFile(r.file, b)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+
+	return n, err
+}
+
+// LogReadError is raised when the transaction log can't be read to its end, e.g. on a flaky network filesystem,
+// unlike a transaction which can't be parsed or is out of sequence.
+type LogReadError struct {
+	Line int // Number of the last line which was read.
+	Err  error
+}
+
+// Error returns the line after which reading failed and the reason.
+func (e *LogReadError) Error() string {
+	return fmt.Sprintf("failed reading transaction log after line %d. %v", e.Line, e.Err)
+}
+
+// Unwrap returns the error reading the file.
+func (e *LogReadError) Unwrap() error {
+	return e.Err
+}
+
+// asLogReadError returns err as a LogReadError, if it is one.
+func asLogReadError(err error) (*LogReadError, bool) {
+	var readErr *LogReadError
+	return readErr, errors.As(err, &readErr)
+}
+
+// newLogScanner returns a scanner for the lines of the transaction log up to the offset end, or to the end of the
+// file when it's 0, adding the size of every line read, including its line ending, to offset. Reading can
+// continue at offset once the scanner fails. When the file doesn't end with a newline, the offset of its last
+// line is stored in unterminated.
+func newLogScanner(file *os.File, end int64, offset, unterminated *int64) *bufio.Scanner {
+	reader := &logFileReader{file: file, end: end}
+	scanner := bufio.NewScanner(reader)
+
+	// Large values make for long lines, which the default buffer can't hold.
+	scanner.Buffer(make([]byte, 0, 1<<20), ftlMaxLineSize)
+
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		// A line cut short by an error isn't complete, unlike the last line at the end of the file.
+		if atEOF && reader.err != nil && bytes.IndexByte(data, '\n') < 0 {
+			return 0, nil, nil
+		}
+
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil && atEOF && bytes.IndexByte(data, '\n') < 0 {
+			*unterminated = *offset
+		}
+		*offset += int64(advance)
+		return advance, token, err
+	})
+
+	return scanner
+}
+
+// replayKey identifies a key across namespaces and databases during a collapsed replay.
+type replayKey struct {
+	database  int
+	namespace string
+	key       string
+}
+
+// replayCollapsed reads all events and applies only the last event of each key. A later PUT
+// or DELETE overrides whatever happened to the key before it, so the resulting state is the
+// same as replaying every event, while a hot key is only written once.
+func replayCollapsed(events <-chan Event, errors <-chan error) error {
+	latest := make(map[replayKey]Event, config.storeHint)
+
+	// Namespaces which exist (true) or have been dropped (false) at the end of the log.
+	namespaceExists := make(map[string]bool)
+
+	var err error
+	e, ok := Event{}, true
+
+	for ok && err == nil {
+		select {
+		case err, ok = <-errors:
+		case e, ok = <-events:
+			if !ok {
+				break
+			}
+
+			if e.EventType == EventDropNamespace {
+				// Dropping a namespace discards every earlier event in it.
+				for k := range latest {
+					if k.namespace == e.Namespace {
+						delete(latest, k)
+					}
+				}
+
+				namespaceExists[e.Namespace] = false
+				continue
+			}
+
+			if e.EventType == EventTouch {
+				// A touch only changes the expiry of the last PUT of the key.
+				if put, ok := latest[replayKey{e.Database, e.Namespace, e.Key}]; ok && put.EventType == EventPut {
+					put.Expiry = e.Expiry
+					latest[replayKey{e.Database, e.Namespace, e.Key}] = put
+				}
+				continue
+			}
+
+			if e.Namespace != "" && e.EventType == EventPut {
+				namespaceExists[e.Namespace] = true
+			}
+
+			// Only the last put of a key is applied, so it carries the version the puts before it left the key at.
+			if e.EventType == EventPut {
+				var previous uint64
+				if put, ok := latest[replayKey{e.Database, e.Namespace, e.Key}]; ok && put.EventType == EventPut {
+					previous = put.Version
+				}
+				e.Version = eventVersion(e, previous)
+			}
+
+			latest[replayKey{e.Database, e.Namespace, e.Key}] = e
+		}
+	}
+
+	// The events channel might have been closed before a pending error was received.
+	if err == nil {
+		err = <-errors
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Apply the remaining events in their original order.
+	collapsed := make([]Event, 0, len(latest))
+	for _, e := range latest {
+		collapsed = append(collapsed, e)
+	}
+	sort.Slice(collapsed, func(i, j int) bool { return collapsed[i].ID < collapsed[j].ID })
+
+	for namespace, exists := range namespaceExists {
+		if exists {
+			// Namespaces whose keys were all deleted still exist after a naive replay.
+			_, err = lookupNamespace(namespace, true)
+		} else {
+			err = replayNamespaceEvent(Event{EventType: EventDropNamespace, Namespace: namespace})
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range collapsed {
+		if err := applyEvent(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
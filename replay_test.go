@@ -0,0 +1,296 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Helper function for resetting the default store and all namespaces.
+func resetStores() {
+	store = newKeyValueStore()
+
+	modifications.Lock()
+	modifications.latest, modifications.order, modifications.last, modifications.live, modifications.forgotten = make(map[string]Modification), nil, 0, 0, 0
+	modifications.Unlock()
+
+	lru.Lock()
+	lru.order.Init()
+	lru.elems = make(map[string]*list.Element)
+	lru.Unlock()
+
+	namespaces.Lock()
+	namespaces.m = make(map[string]*keyValueStore)
+	namespaces.Unlock()
+
+	initDatabases(len(databases))
+}
+
+// Helper function for capturing the state of the default store and all namespaces.
+func snapshotStores() map[string]map[string]string {
+	snapshot := map[string]map[string]string{"": store.m}
+	for _, name := range ListNamespaces() {
+		ns, _ := lookupNamespace(name, false)
+		snapshot[name] = ns.m
+	}
+
+	return snapshot
+}
+
+// Function for testing that a collapsed replay results in the same state as a naive replay.
+func TestCollapsedReplay(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-collapse.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	defer func() { config.collapseReplay = false }()
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactionLogger.Log()
+	for i := 0; i < 100; i++ {
+		transactionLogger.WritePut("hot", fmt.Sprint(i))
+	}
+	transactionLogger.WritePut("deleted", "value")
+	transactionLogger.WriteDelete("deleted")
+	transactionLogger.WriteDelete("cold")
+	transactionLogger.WritePut("cold", "value")
+	transactionLogger.WriteNamespacePut("ns1", "hot", "one")
+	transactionLogger.WriteNamespaceDelete("ns1", "hot")
+	transactionLogger.WriteNamespacePut("ns2", "hot", "two")
+	transactionLogger.WriteDropNamespace("ns2")
+	transactionLogger.WriteNamespacePut("ns3", "hot", "dropped")
+	transactionLogger.WriteDropNamespace("ns3")
+	transactionLogger.WriteNamespacePut("ns3", "hot", "three")
+	transactionLogger.Close()
+
+	// Replay every event.
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+	naive := snapshotStores()
+
+	// Replay only the final state of each key.
+	resetStores()
+	config.collapseReplay = true
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+	collapsed := snapshotStores()
+
+	if !reflect.DeepEqual(naive, collapsed) {
+		t.Errorf("Collapsed replay diverged from naive replay.\nnaive:     %v\ncollapsed: %v", naive, collapsed)
+	}
+
+	if naive[""]["hot"] != "99" {
+		t.Errorf("Expected the last value of the hot key, got %q", naive[""]["hot"])
+	}
+}
+
+// Helper function for writing a transaction log with a put of each key, returning the log's contents.
+func writeReplayLog(t *testing.T, filename string, keys ...string) string {
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactionLogger.Log()
+	for _, key := range keys {
+		transactionLogger.WritePut(key, "value")
+	}
+	transactionLogger.Close()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+// Helper function for making reads of the transaction log fail once the first cut bytes were read, either
+// once or every time.
+func failLogReads(cut int, always bool) func() {
+	calls := 0
+	readLogFile = func(f *os.File, b []byte) (int, error) {
+		calls++
+		switch {
+		case calls == 1 && len(b) > cut:
+			return f.Read(b[:cut])
+		case calls == 2 || (calls > 2 && always):
+			return 0, errors.New("input/output error")
+		}
+		return f.Read(b)
+	}
+
+	return func() { readLogFile = (*os.File).Read }
+}
+
+// Function for testing that a transient error reading the log is retried after the last complete line.
+func TestReplayReadRetry(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-replay-retry.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	defer func(delay time.Duration) { logReadRetryDelay = delay }(logReadRetryDelay)
+	logReadRetryDelay = time.Millisecond
+
+	data := writeReplayLog(t, filename, "one", "two", "three")
+
+	// The first read ends in the middle of the second transaction.
+	cut := strings.Index(data, "two") + 1
+	defer failLogReads(cut, false)()
+
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+
+	if len(store.m) != 3 || logger.LastID() != 3 {
+		t.Errorf("Expected every transaction to be replayed after retrying, got %v up to ID %d", store.m, logger.LastID())
+	}
+}
+
+// Function for testing that a log which keeps failing stops the start-up, unless partial replays are allowed.
+func TestReplayReadFailure(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-replay-failure.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	defer func(delay time.Duration) { logReadRetryDelay = delay }(logReadRetryDelay)
+	defer func() { config.allowPartialReplay = false }()
+	logReadRetryDelay = time.Millisecond
+
+	data := writeReplayLog(t, filename, "one", "two", "three")
+	cut := strings.Index(data, "two") + 1
+	defer failLogReads(cut, true)()
+
+	resetStores()
+	err := InitLog(filename)
+	logger.Close()
+
+	var readErr *LogReadError
+	if !errors.As(err, &readErr) || readErr.Line != 2 {
+		t.Fatalf("Expected a read error after line 2, got %v", err)
+	}
+
+	// Only the transactions read before the error are replayed.
+	config.allowPartialReplay = true
+	failLogReads(cut, true)
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatalf("Expected the partial replay to be accepted, got %v", err)
+	}
+	logger.Close()
+
+	if len(store.m) != 1 || store.m["one"] != "value" {
+		t.Errorf("Expected only the first transaction to be replayed, got %v", store.m)
+	}
+}
+
+// Helper function for writing a transaction log of n puts of distinct keys for replay benchmarks.
+func writeBenchmarkLog(b *testing.B, filename string, n int) {
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	transactionLogger.Log()
+	for i := 0; i < n; i++ {
+		transactionLogger.WritePut(fmt.Sprintf("key-%d", i), "hello, yakv!")
+	}
+	if err := transactionLogger.Close(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// Helper function for benchmarking replaying a log of 200000 keys, with the store allocated for hint keys.
+func benchmarkReplayStoreHint(b *testing.B, hint int) {
+	// Temporary log filename.
+	const filename = "temp-bench-store-hint.log"
+	const n = 200000
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+
+	writeBenchmarkLog(b, filename, n)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resetStores()
+		if hint > 0 {
+			store.presize(hint)
+		}
+
+		if err := InitLog(filename); err != nil {
+			b.Fatal(err)
+		}
+		logger.Close()
+	}
+}
+
+// Benchmark for replaying a large log into a store which grows as keys are replayed.
+func BenchmarkReplayStoreGrowing(b *testing.B) {
+	benchmarkReplayStoreHint(b, 0)
+}
+
+// Benchmark for replaying a large log into a store allocated for its keys with -store-hint.
+func BenchmarkReplayStoreHint(b *testing.B) {
+	benchmarkReplayStoreHint(b, 200000)
+}
+
+// Function for testing that a store allocated with -store-hint replays the same keys, collapsed or not.
+func TestReplayStoreHint(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-store-hint.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	defer func(hint int, collapse bool) { config.storeHint, config.collapseReplay = hint, collapse }(config.storeHint, config.collapseReplay)
+	config.storeHint = 10
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactionLogger.Log()
+	for i := 0; i < 100; i++ {
+		transactionLogger.WritePut(fmt.Sprintf("key-%d", i), "hello, yakv!")
+	}
+	transactionLogger.WriteDelete("key-0")
+	transactionLogger.Close()
+
+	for _, collapse := range []bool{false, true} {
+		resetStores()
+		store.presize(config.storeHint)
+		config.collapseReplay = collapse
+		if err := InitLog(filename); err != nil {
+			t.Fatal(err)
+		}
+		logger.Close()
+
+		if len(store.m) != 99 {
+			t.Errorf("Expected 99 keys to be replayed (collapsed: %t), got %d", collapse, len(store.m))
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default prefix of the routes.
+const defaultRoutePrefix = "yakv/v0"
+
+// Default prefix of the admin routes, relative to the route prefix.
+const defaultAdminPrefix = "admin"
+
+// routeGroup returns a group of routes under path, with the middleware shared by every route. The routes
+// in pauseExempt, relative to path, keep working while writes are paused, only the routes in tenantAllowed
+// can be used by tenants, and only the routes in databaseAllowed serve databases other than 0.
+func routeGroup(r *gin.Engine, path string, pauseExempt, tenantAllowed, databaseAllowed map[string]bool) *gin.RouterGroup {
+	g := r.Group(path)
+	g.Use(NoStoreMiddleware(), PauseWritesMiddleware(g.BasePath(), pauseExempt), TenantMiddleware(g.BasePath(), tenantAllowed), DatabaseMiddleware(g.BasePath(), databaseAllowed))
+
+	// Writes are rejected while the transaction log falls behind, instead of queueing up.
+	if config.rejectOnBackpressure {
+		g.Use(BackpressureMiddleware())
+	}
+
+	// Writes are rejected while the transaction log fails, instead of being lost.
+	if config.autoReadOnlyAfter > 0 {
+		g.Use(AutoReadOnlyMiddleware(g.BasePath(), pauseExempt))
+	}
+
+	return g
+}
+
+// registerRoutes registers all of yakv's routes on the engine under prefix, e.g. "yakv/v0".
+// Calling it again with another prefix mounts the same routes a second time, so API versions can coexist.
+// With a separate admin listener, the admin routes are left out, so that they're only served by the admin
+// listener.
+func registerRoutes(r *gin.Engine, prefix string) {
+	g := routeGroup(r, "/"+strings.Trim(prefix, "/"), nil, tenantRoutes, databaseRoutes)
+
+	g.GET("/get", gin.WrapF(GetHandler))
+	g.PUT("/put", gin.WrapF(PutHandler))
+	g.POST("/append", gin.WrapF(AppendHandler))
+	g.POST("/getreset", gin.WrapF(GetResetHandler))
+	g.POST("/setnx", gin.WrapF(SetNXHandler))
+	g.POST("/add", gin.WrapF(AddHandler))
+	g.POST("/getset", gin.WrapF(GetSetHandler))
+	g.POST("/rename", gin.WrapF(RenameHandler))
+	g.DELETE("/delete", gin.WrapF(DeleteHandler))
+	g.POST("/touch", gin.WrapF(TouchHandler))
+	g.POST("/mdelete", gin.WrapF(MDeleteHandler))
+	g.PATCH("/keys/:key", PatchHandler)
+	g.GET("/keys", gin.WrapF(KeysHandler))
+	g.GET("/scan", gin.WrapF(ScanHandler))
+	g.GET("/getall", gin.WrapF(GetAllHandler))
+	g.GET("/count", gin.WrapF(CountHandler))
+	g.GET("/changed-since", gin.WrapF(ChangedSinceHandler))
+	g.GET("/find", gin.WrapF(FindHandler))
+	g.GET("/hot", gin.WrapF(HotHandler))
+	g.GET("/idle", gin.WrapF(IdleHandler))
+	g.GET("/export", gin.WrapF(ExportHandler))
+	g.GET("/stats", gin.WrapF(StatsHandler))
+	g.GET("/capabilities", gin.WrapF(CapabilitiesHandler))
+
+	// Namespaced keys.
+	g.GET("/ns", ListNamespacesHandler)
+	g.DELETE("/ns/:namespace", DropNamespaceHandler)
+	g.GET("/ns/:namespace/keys/:key", NamespaceGetHandler)
+	g.PUT("/ns/:namespace/keys/:key", NamespacePutHandler)
+	g.DELETE("/ns/:namespace/keys/:key", NamespaceDeleteHandler)
+
+	if config.adminPort == 0 {
+		registerAdminRoutes(r, prefix)
+	}
+}
+
+// registerAdminRoutes registers yakv's admin routes on the engine under prefix and the admin prefix,
+// e.g. "yakv/v0/admin".
+func registerAdminRoutes(r *gin.Engine, prefix string) {
+	adminPrefix := strings.Trim(config.adminPrefix, "/")
+	if adminPrefix == "" {
+		adminPrefix = defaultAdminPrefix
+	}
+
+	g := routeGroup(r, "/"+strings.Trim(prefix, "/")+"/"+adminPrefix, pauseExempt, nil, nil)
+
+	// Schemas of values.
+	g.GET("/schemas", gin.WrapF(ListSchemasHandler))
+	g.PUT("/schemas", gin.WrapF(PutSchemaHandler))
+	g.DELETE("/schemas", gin.WrapF(DeleteSchemaHandler))
+
+	g.POST("/verify-log", gin.WrapF(VerifyLogHandler))
+	g.GET("/events", gin.WrapF(EventsHandler))
+	g.GET("/history", gin.WrapF(HistoryHandler))
+	g.POST("/readonly", gin.WrapF(ReadOnlyHandler))
+	g.POST("/drain", gin.WrapF(DrainHandler))
+	g.POST("/transform", gin.WrapF(TransformHandler))
+	g.POST("/snapshot", gin.WrapF(SnapshotHandler))
+
+	// Destructive admin endpoints have to be enabled explicitly.
+	if config.allowFlush {
+		g.POST("/flush", gin.WrapF(FlushHandler))
+	}
+	if config.allowDump {
+		g.GET("/dump", gin.WrapF(DumpHandler))
+		g.POST("/load", gin.WrapF(LoadHandler))
+	}
+}
+
+// registerMethodRoutes answers OPTIONS requests for every route registered on the engine so far with
+// 204 No Content and an Allow header listing the methods of its path, and requests of a registered path
+// with another method with 405 Method Not Allowed and the same Allow header. Without trailing slash redirects,
+// requests which only miss a route by a trailing slash get a 404 pointing at the route. It has to be called once
+// every other route is registered, so that the Allow headers stay accurate as routes are added.
+func registerMethodRoutes(r *gin.Engine) {
+	methods := make(map[string][]string)
+	for _, route := range r.Routes() {
+		if route.Method != http.MethodOptions {
+			methods[route.Path] = append(methods[route.Path], route.Method)
+		}
+	}
+
+	allows := make(map[string]string, len(methods))
+	for path, allowed := range methods {
+		allowed = append(allowed, http.MethodOptions)
+		sort.Strings(allowed)
+		allow := strings.Join(allowed, ", ")
+		allows[path] = allow
+
+		r.OPTIONS(path, func(c *gin.Context) {
+			c.Header("Allow", allow)
+			c.Status(http.StatusNoContent)
+		})
+	}
+
+	r.HandleMethodNotAllowed = true
+	r.NoMethod(func(c *gin.Context) {
+		if pattern, ok := matchRoute(allows, c.Request.URL.Path); ok {
+			c.Header("Allow", allows[pattern])
+		}
+		writeError(c.Writer, "method not allowed, see the Allow header for the methods of the route", http.StatusMethodNotAllowed)
+	})
+
+	r.NoRoute(func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if strings.HasSuffix(path, "/") {
+			path = strings.TrimSuffix(path, "/")
+		} else {
+			path += "/"
+		}
+
+		if _, ok := matchRoute(allows, path); ok && path != "" {
+			writeError(c.Writer, fmt.Sprintf("route not found, did you mean %s?", path), http.StatusNotFound)
+			return
+		}
+
+		writeError(c.Writer, "route not found", http.StatusNotFound)
+	})
+}
+
+// matchRoute returns the pattern of the routes matching path, where :param matches a segment and *param
+// the rest of the path.
+func matchRoute(routes map[string]string, path string) (string, bool) {
+	for pattern := range routes {
+		if matchPattern(pattern, path) {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
+
+// matchPattern reports whether path matches the pattern of a route.
+func matchPattern(pattern, path string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+
+	return len(pathSegments) == len(patternSegments)
+}
+
+// routePrefixes splits a comma-separated list of route prefixes.
+func routePrefixes(list string) []string {
+	var prefixes []string
+	for _, prefix := range strings.Split(list, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return prefixes
+}
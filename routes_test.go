@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that routes mounted under several prefixes share the same store.
+func TestRegisterRoutes(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-routes.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	for _, prefix := range routePrefixes("yakv/v0, /api/v1/") {
+		registerRoutes(r, prefix)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/v1/put", strings.NewReader(`{"key": "yakv", "value": "hello, yakv!"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/get", strings.NewReader(`{"key": "yakv"}`)))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello, yakv!" {
+		t.Errorf("Expected the value written through /api/v1, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+// Function for testing that admin routes move under the admin prefix, and off the public engine when
+// they get a listener of their own.
+func TestAdminRoutes(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-admin-routes.log")()
+	defer resetStores()
+	defer setWritesPaused(false)
+	defer func(prefix string, port int) { config.adminPrefix, config.adminPort = prefix, port }(config.adminPrefix, config.adminPort)
+	config.adminPrefix, config.adminPort = "/ops/", 9090
+
+	gin.SetMode(gin.TestMode)
+	public, admin := gin.New(), gin.New()
+	registerRoutes(public, defaultRoutePrefix)
+	registerAdminRoutes(admin, defaultRoutePrefix)
+
+	serve := func(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	// The public engine doesn't serve admin routes under either prefix.
+	for _, path := range []string{"/yakv/v0/ops/readonly", "/yakv/v0/admin/readonly"} {
+		if rec := serve(public, http.MethodPost, path, `{"enabled": true}`); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s on the public engine, got %d", path, rec.Code)
+		}
+	}
+
+	// The admin engine serves them, but not the other routes.
+	if rec := serve(admin, http.MethodPost, "/yakv/v0/ops/readonly", `{"enabled": true}`); rec.Code != http.StatusOK || !isWritesPaused() {
+		t.Fatalf("Expected writes to be paused through the admin engine, got %d", rec.Code)
+	}
+	if rec := serve(admin, http.MethodGet, "/yakv/v0/get", `{"key": "yakv"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a public route on the admin engine, got %d", rec.Code)
+	}
+
+	// Pausing still applies to the public engine, and the admin engine can still resume writes.
+	if rec := serve(public, http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "hello, yakv!"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while writes are paused, got %d", rec.Code)
+	}
+	if rec := serve(admin, http.MethodPost, "/yakv/v0/ops/readonly", `{"enabled": false}`); rec.Code != http.StatusOK || isWritesPaused() {
+		t.Errorf("Expected writes to be resumed through the admin engine, got %d", rec.Code)
+	}
+}
+
+// Function for testing that OPTIONS requests are answered with the methods registered for the path.
+func TestOptionsRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+	registerMethodRoutes(r)
+
+	tests := []struct {
+		path, allow string
+	}{
+		{"/yakv/v0/get", "GET, OPTIONS"},
+		{"/yakv/v0/put", "OPTIONS, PUT"},
+		{"/yakv/v0/delete", "DELETE, OPTIONS"},
+		{"/yakv/v0/keys", "GET, OPTIONS"},
+		{"/yakv/v0/keys/config", "OPTIONS, PATCH"},
+		{"/yakv/v0/ns/users/keys/alice", "DELETE, GET, OPTIONS, PUT"},
+		{"/yakv/v0/admin/schemas", "DELETE, GET, OPTIONS, PUT"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, test.path, nil))
+		if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") != test.allow {
+			t.Errorf("Expected 204 with Allow: %s for %s, got %d %q", test.allow, test.path, rec.Code, rec.Header().Get("Allow"))
+		}
+	}
+
+	// Unknown paths are still unknown.
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/yakv/v0/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown path, got %d", rec.Code)
+	}
+}
+
+// Function for testing that known paths requested with another method get 405 with an Allow header, and that
+// trailing slashes are redirected or pointed out.
+func TestMethodNotAllowed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, redirect := range []bool{true, false} {
+		r := gin.New()
+		r.RedirectTrailingSlash = redirect
+		registerRoutes(r, defaultRoutePrefix)
+		registerMethodRoutes(r)
+
+		serve := func(method, path string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+			return rec
+		}
+
+		tests := []struct {
+			method, path, allow string
+		}{
+			{http.MethodPut, "/yakv/v0/get", "GET, OPTIONS"},
+			{http.MethodGet, "/yakv/v0/put", "OPTIONS, PUT"},
+			{http.MethodPost, "/yakv/v0/ns/users/keys/alice", "DELETE, GET, OPTIONS, PUT"},
+		}
+		for _, test := range tests {
+			rec := serve(test.method, test.path)
+			if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != test.allow || !strings.Contains(rec.Body.String(), "METHOD_NOT_ALLOWED") {
+				t.Errorf("Expected 405 with Allow: %s for %s %s, got %d %q %s", test.allow, test.method, test.path, rec.Code, rec.Header().Get("Allow"), rec.Body.String())
+			}
+		}
+
+		// A trailing slash is redirected, or pointed out in the error.
+		rec := serve(http.MethodGet, "/yakv/v0/get/")
+		switch {
+		case redirect && (rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/yakv/v0/get"):
+			t.Errorf("Expected a redirect to /yakv/v0/get, got %d %q", rec.Code, rec.Header().Get("Location"))
+		case !redirect && (rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "did you mean /yakv/v0/get?")):
+			t.Errorf("Expected 404 pointing at /yakv/v0/get, got %d %s", rec.Code, rec.Body.String())
+		}
+
+		// Unknown paths are still unknown, whatever the method.
+		if rec := serve(http.MethodPut, "/yakv/v0/missing"); rec.Code != http.StatusNotFound || strings.Contains(rec.Body.String(), "did you mean") {
+			t.Errorf("Expected a plain 404 for an unknown path, got %d %s", rec.Code, rec.Body.String())
+		}
+	}
+}
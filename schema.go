@@ -0,0 +1,208 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Registry of the JSON schemas which values are validated against, keyed by the key prefix they apply to.
+var schemas = struct {
+	sync.RWMutex
+	m map[string]*gojsonschema.Schema
+}{m: make(map[string]*gojsonschema.Schema)}
+
+// SchemaError is raised when a value doesn't match the schema of its key.
+type SchemaError struct {
+	Prefix string
+	Errors []string
+}
+
+func (se *SchemaError) Error() string {
+	return fmt.Sprintf("value doesn't match the schema for prefix %q: %s", se.Prefix, strings.Join(se.Errors, "; "))
+}
+
+// SchemaBody is the body of a request registering a schema for a key prefix.
+type SchemaBody struct {
+	Prefix string          `json:"prefix"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// SchemaDeleteBody is the body of a request removing the schema of a key prefix.
+type SchemaDeleteBody struct {
+	Prefix string `json:"prefix"`
+}
+
+// RegisterSchema registers a JSON schema for the values of all keys starting with prefix, replacing any previous schema of the prefix.
+func RegisterSchema(prefix string, schema []byte) error {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema))
+	if err != nil {
+		return fmt.Errorf("invalid schema for prefix %q. %w", prefix, err)
+	}
+
+	schemas.Lock()
+	schemas.m[prefix] = compiled
+	schemas.Unlock()
+
+	return nil
+}
+
+// RemoveSchema removes the schema of prefix.
+func RemoveSchema(prefix string) {
+	schemas.Lock()
+	delete(schemas.m, prefix)
+	schemas.Unlock()
+}
+
+// SchemaPrefixes returns the sorted prefixes which have a schema.
+func SchemaPrefixes() []string {
+	schemas.RLock()
+	prefixes := make([]string, 0, len(schemas.m))
+	for prefix := range schemas.m {
+		prefixes = append(prefixes, prefix)
+	}
+	schemas.RUnlock()
+
+	sort.Strings(prefixes)
+
+	return prefixes
+}
+
+// validateValue validates value against the schema with the longest prefix of key, if there is one.
+func validateValue(key, value string) error {
+	schemas.RLock()
+	var prefix string
+	var schema *gojsonschema.Schema
+	for p, s := range schemas.m {
+		if strings.HasPrefix(key, p) && (schema == nil || len(p) > len(prefix)) {
+			prefix, schema = p, s
+		}
+	}
+	schemas.RUnlock()
+
+	if schema == nil {
+		return nil
+	}
+
+	result, err := schema.Validate(gojsonschema.NewStringLoader(value))
+	if err != nil {
+		// Values which aren't JSON at all can't match any schema.
+		return &SchemaError{Prefix: prefix, Errors: []string{err.Error()}}
+	}
+
+	if !result.Valid() {
+		se := &SchemaError{Prefix: prefix}
+		for _, e := range result.Errors() {
+			se.Errors = append(se.Errors, e.String())
+		}
+		return se
+	}
+
+	return nil
+}
+
+// loadSchemaFile registers the schemas of a JSON file, which maps key prefixes to schemas.
+func loadSchemaFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var file map[string]json.RawMessage
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid schema file %q. %w", filename, err)
+	}
+
+	for prefix, schema := range file {
+		if err := RegisterSchema(prefix, schema); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListSchemasHandler is a handler function for listing the prefixes which have a schema.
+func ListSchemasHandler(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(struct {
+		Prefixes []string `json:"prefixes"`
+	}{SchemaPrefixes()}); err != nil {
+		log.Println(err)
+	}
+}
+
+// PutSchemaHandler is a handler function for registering the schema of a key prefix.
+func PutSchemaHandler(rw http.ResponseWriter, r *http.Request) {
+	var body SchemaBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := RegisterSchema(body.Prefix, body.Schema); err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rw.WriteHeader(http.StatusCreated)
+}
+
+// DeleteSchemaHandler is a handler function for removing the schema of a key prefix.
+func DeleteSchemaHandler(rw http.ResponseWriter, r *http.Request) {
+	var body SchemaDeleteBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	RemoveSchema(body.Prefix)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Schema used by the tests, requiring an object with an integer port.
+const testSchema = `{"type": "object", "properties": {"port": {"type": "integer"}}, "required": ["port"]}`
+
+// Function for testing that Put validates values against the schema of their prefix.
+func TestPutValidatesSchema(t *testing.T) {
+	// Restore to original state after test.
+	defer RemoveSchema("config/")
+	defer resetStores()
+
+	if err := RegisterSchema("config/", []byte(testSchema)); err != nil {
+		t.Fatal(err)
+	}
+
+	var schemaErr *SchemaError
+	for _, value := range []string{`{"port": "8080"}`, `{}`, `not json`} {
+		if err := Put("config/server", value); !errors.As(err, &schemaErr) {
+			t.Errorf("Expected a schema error for %s, got %v", value, err)
+		}
+	}
+	if _, err := Get("config/server"); !errors.Is(err, ErrorNoSuchKey) {
+		t.Error("A value which doesn't match the schema was stored.")
+	}
+
+	if err := Put("config/server", `{"port": 8080}`); err != nil {
+		t.Error(err)
+	}
+
+	// Keys outside of the prefix aren't validated.
+	if err := Put("other", "not json"); err != nil {
+		t.Error(err)
+	}
+
+	// Neither are namespaced keys, even with the prefix.
+	if err := NamespacePut("users", "config/server", "not json", nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// Function for testing that a schema registered through the admin endpoint rejects values with 400.
+func TestSchemaHandlers(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-schema.log")()
+	defer RemoveSchema("config/")
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/admin/schemas", strings.NewReader(`{"prefix": "config/", "schema": `+testSchema+`}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(`{"key": "config/server", "value": "{\"port\": \"8080\"}"}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "port") {
+		t.Errorf("Expected 400 with the validation errors, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/yakv/v0/admin/schemas", strings.NewReader(`{"prefix": "config/"}`)))
+	if rec.Code != http.StatusOK || len(SchemaPrefixes()) != 0 {
+		t.Errorf("Expected the schema to be removed, got %d %v", rec.Code, SchemaPrefixes())
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Value of the Strict-Transport-Security header: browsers only use HTTPS for a year.
+const hstsHeader = "max-age=31536000"
+
+// SecureHeadersMiddleware sets security headers on every response, including errors. Strict-Transport-Security
+// is only sent over TLS, since browsers ignore it over plaintext HTTP.
+func SecureHeadersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+
+		if c.Request.TLS != nil {
+			header.Set("Strict-Transport-Security", hstsHeader)
+		}
+
+		c.Next()
+	}
+}
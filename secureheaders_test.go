@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that security headers are set on successful and failed requests alike.
+func TestSecureHeadersMiddleware(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-secure-headers.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(SecureHeadersMiddleware())
+	registerRoutes(r, defaultRoutePrefix)
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{http.MethodPut, "/yakv/v0/put", `{"key": "yakv", "value": "hello, yakv!"}`, http.StatusCreated},
+		{http.MethodGet, "/yakv/v0/get", `{"key": "yakv"}`, http.StatusOK},
+		{http.MethodDelete, "/yakv/v0/delete", `{"key": "yakv"}`, http.StatusOK},
+		{http.MethodGet, "/yakv/v0/get", `{"key": "yakv"}`, http.StatusNotFound},
+		{http.MethodPut, "/yakv/v0/put", `{"key": `, http.StatusBadRequest},
+	}
+
+	for _, overTLS := range []bool{false, true} {
+		for _, req := range requests {
+			request := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+			if overTLS {
+				request.TLS = &tls.ConnectionState{}
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, request)
+			if rec.Code != req.code {
+				t.Errorf("Expected %d for %s %s, got %d", req.code, req.method, req.path, rec.Code)
+			}
+
+			header := rec.Header()
+			if header.Get("X-Content-Type-Options") != "nosniff" || header.Get("X-Frame-Options") != "DENY" {
+				t.Errorf("Expected security headers for %s %s, got %v", req.method, req.path, header)
+			}
+
+			if hsts := header.Get("Strict-Transport-Security"); (hsts != "") != overTLS {
+				t.Errorf("Expected HSTS only over TLS (tls=%t) for %s %s, got %q", overTLS, req.method, req.path, hsts)
+			}
+		}
+	}
+}
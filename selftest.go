@@ -0,0 +1,67 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// SelfTestResult summarizes a transaction log read from start to end.
+type SelfTestResult struct {
+	Events int    // Number of events read.
+	LastID uint64 // ID of the last event.
+	Keys   int    // Number of keys of the default store after replaying, leaving out expired keys.
+}
+
+// SelfTest reads the transaction log at filename to completion without starting the server, checking
+// that every line parses and that IDs are in sequence. Corrupt lines are never skipped, even with -repair-log.
+func SelfTest(filename string) (SelfTestResult, error) {
+	var result SelfTestResult
+
+	defer func(repair bool) { config.repairLog = repair }(config.repairLog)
+	config.repairLog = false
+
+	// The log is opened read-only, so that a missing log isn't created and a log in use isn't written to.
+	reader, err := newTransactionLogReader(filename)
+	if err != nil {
+		return result, err
+	}
+	defer reader.Close()
+
+	events, errors := reader.ReadEvents()
+	values, n, err := replayState(events, errors, time.Now())
+	result.Events, result.LastID, result.Keys = n, reader.LastID(), len(values)
+
+	return result, err
+}
+
+// runSelfTest runs SelfTest on filename and prints a summary for CI logs to out. It returns whether the log passed.
+func runSelfTest(out io.Writer, filename string) bool {
+	result, err := SelfTest(filename)
+
+	fmt.Fprintf(out, "log:     %s\n", filename)
+	fmt.Fprintf(out, "events:  %d\n", result.Events)
+	fmt.Fprintf(out, "last id: %d\n", result.LastID)
+	if err != nil {
+		fmt.Fprintf(out, "result:  FAIL: %v\n", err)
+		return false
+	}
+
+	fmt.Fprintf(out, "keys:    %d\n", result.Keys)
+	fmt.Fprintln(out, "result:  OK")
+	return true
+}
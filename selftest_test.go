@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Function for testing the self-test of a valid, a corrupt, an out-of-sequence and a missing transaction log.
+func TestSelfTest(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-selftest.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactionLogger.Log()
+	transactionLogger.WritePut("yakv1", "yak1")
+	transactionLogger.WritePut("yakv2", "yak2")
+	transactionLogger.WriteDelete("yakv1")
+	transactionLogger.WriteNamespacePut("ns", "yakv3", "yak3")
+	transactionLogger.Close()
+
+	// Only keys of the default store are counted.
+	var out bytes.Buffer
+	if !runSelfTest(&out, filename) {
+		t.Fatalf("Expected the log to pass, got %s", out.String())
+	}
+	for _, line := range []string{"events:  4", "last id: 4", "keys:    1", "result:  OK"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected the summary to contain %q, got %s", line, out.String())
+		}
+	}
+
+	appendLine := func(line string) {
+		f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		if _, err := f.WriteString(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A corrupt line fails, even when replaying would repair it.
+	defer func() { config.repairLog = false }()
+	config.repairLog = true
+	appendLine("not an event\n")
+
+	out.Reset()
+	if runSelfTest(&out, filename) || !strings.Contains(out.String(), "result:  FAIL") {
+		t.Errorf("Expected a corrupt log to fail, got %s", out.String())
+	}
+	if !config.repairLog {
+		t.Error("Expected -repair-log to be restored after the self-test")
+	}
+
+	// IDs out of sequence fail too.
+	lines := []string{
+		formatHeader(ftlVersion),
+		formatEvent(ftlVersion, Event{ID: 2, EventType: EventPut, Key: "a", Value: "b"}),
+		formatEvent(ftlVersion, Event{ID: 1, EventType: EventPut, Key: "c", Value: "d"}),
+	}
+	if err := os.WriteFile(filename, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if runSelfTest(&out, filename) || !strings.Contains(out.String(), "out of sequence") {
+		t.Errorf("Expected IDs out of sequence to fail, got %s", out.String())
+	}
+
+	// An empty log passes without being written to, not even its header.
+	if err := os.WriteFile(filename, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if !runSelfTest(&out, filename) {
+		t.Errorf("Expected an empty log to pass, got %s", out.String())
+	}
+	if info, err := os.Stat(filename); err != nil || info.Size() != 0 {
+		t.Errorf("Expected the empty log to be left alone, got %v %v", info, err)
+	}
+
+	// A missing log fails instead of being created.
+	os.Remove(filename)
+
+	out.Reset()
+	if runSelfTest(&out, filename) {
+		t.Errorf("Expected a missing log to fail, got %s", out.String())
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Error("Expected a missing log not to be created")
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Default timeouts of the HTTP servers.
+const (
+	defaultReadTimeout  = 10 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+	defaultIdleTimeout  = 60 * time.Second
+)
+
+// Paths which the plaintext listener keeps serving instead of redirecting to HTTPS.
+var redirectExempt = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// listener is an HTTP server along with whether it serves TLS.
+type listener struct {
+	server *http.Server
+	tls    bool
+}
+
+// newServer returns an HTTP server for addr with the configured timeouts, which keep slow or idle
+// clients from holding connections open indefinitely.
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       config.readTimeout,
+		ReadHeaderTimeout: config.readTimeout,
+		WriteTimeout:      config.writeTimeout,
+		IdleTimeout:       config.idleTimeout,
+	}
+}
+
+// serve starts every listener in its own goroutine. Any error other than a shutdown is fatal.
+// With -max-conns-per-ip, each listener limits the connections of every client IP address on its own.
+func serve(listeners []listener, certFilename, keyFilename string) {
+	for _, l := range listeners {
+		go func(l listener) {
+			ln, err := net.Listen("tcp", l.server.Addr)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if config.maxConnsPerIP > 0 {
+				ln = limitConnsPerIP(ln, config.maxConnsPerIP)
+			}
+
+			// TLS listeners negotiate HTTP/2 on their own.
+			if config.h2c && !l.tls {
+				l.server.Handler = h2cHandler(l.server.Handler, l.server.IdleTimeout)
+			}
+
+			if l.tls {
+				fmt.Printf("yakv is running in secure mode on %s.... 🔒\n", l.server.Addr)
+				err = l.server.ServeTLS(ln, certFilename, keyFilename)
+			} else {
+				fmt.Printf("yakv is running in insecure mode on %s.... 🔓❎\n", l.server.Addr)
+				err = l.server.Serve(ln)
+			}
+
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}(l)
+	}
+}
+
+// h2cHandler returns a handler which serves HTTP/2 over cleartext, both with prior knowledge and upgraded from
+// HTTP/1.1, and HTTP/1.1 as before. An HTTP/2 connection is closed once it has been idle for idleTimeout.
+func h2cHandler(next http.Handler, idleTimeout time.Duration) http.Handler {
+	return h2c.NewHandler(next, &http2.Server{IdleTimeout: idleTimeout})
+}
+
+// shutdown gracefully shuts every listener down concurrently, waiting for in-flight requests until ctx is done.
+// Connections of requests which are still in flight by then are closed.
+func shutdown(ctx context.Context, listeners []listener) {
+	var wg sync.WaitGroup
+
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(l listener) {
+			defer wg.Done()
+
+			if err := l.server.Shutdown(ctx); err != nil {
+				log.Printf("Error occurred while shutting down the server on %s: %v, %d requests still in flight", l.server.Addr, err, inFlightRequests())
+				l.server.Close()
+			}
+		}(l)
+	}
+
+	wg.Wait()
+}
+
+// closeLogger closes the transaction logger, giving up once ctx is done so that a write stuck on the disk
+// can't hang the shutdown. Events which haven't been flushed by then are lost.
+func closeLogger(ctx context.Context, logger TransactionLogger) error {
+	closed := make(chan error, 1)
+	go func() { closed <- logger.Close() }()
+
+	select {
+	case err := <-closed:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w, %d events still pending", ctx.Err(), pendingLogEvents())
+	}
+}
+
+// redirectToHTTPS returns a handler which permanently redirects requests to the HTTPS listener on tlsPort,
+// except for health checks which are still served by next.
+func redirectToHTTPS(next http.Handler, tlsPort int) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if redirectExempt[r.URL.Path] {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			// The Host header doesn't carry a port.
+			host = r.Host
+		}
+
+		target := "https://" + net.JoinHostPort(host, strconv.Itoa(tlsPort)) + r.URL.RequestURI()
+		http.Redirect(rw, r, target, http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/http2"
+)
+
+// Function for testing the redirects of the plaintext listener to HTTPS.
+func TestRedirectToHTTPS(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	handler := redirectToHTTPS(next, 8443)
+
+	// Regular routes are redirected, keeping the host, path and query.
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com:8080/yakv/v0/keys?prefix=a", nil))
+
+	if rec.Code != http.StatusMovedPermanently {
+		t.Errorf("Expected 301, got %d", rec.Code)
+	}
+	if location := rec.Header().Get("Location"); location != "https://example.com:8443/yakv/v0/keys?prefix=a" {
+		t.Errorf("Unexpected redirect location %q", location)
+	}
+
+	// Health checks are served over plaintext.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected health checks to be served, got %d", rec.Code)
+	}
+}
+
+// Function for testing that a client sending its request too slowly is disconnected.
+func TestServerReadTimeout(t *testing.T) {
+	// Restore to original state after test.
+	defer func() { config.readTimeout = 0 }()
+	config.readTimeout = 50 * time.Millisecond
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newServer(ln.Addr().String(), http.NotFoundHandler())
+	go server.Serve(ln)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Never finish the request headers.
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: yakv\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("Expected the server to close the connection, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the connection to be closed after the read timeout, took %v", elapsed)
+	}
+}
+
+// Function for testing that shutting down gives up on a slow request once the timeout elapses.
+func TestShutdownTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The handler takes much longer than the timeout.
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	server := newServer(ln.Addr().String(), http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	server.ErrorLog = log.New(io.Discard, "", 0)
+	go server.Serve(ln)
+
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	shutdown(ctx, []listener{{server: server}})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the shutdown to give up after the timeout, took %v", elapsed)
+	}
+}
+
+// Function for testing that closing the transaction logger gives up on a stuck write once the timeout elapses.
+func TestCloseLoggerTimeout(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-close-timeout.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer func() { writeLogFile = (*os.File).Write }()
+
+	// Writes to the file never finish until released.
+	release := make(chan struct{})
+	writeLogFile = func(f *os.File, b []byte) (int, error) {
+		<-release
+		return f.Write(b)
+	}
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactionLogger.Log()
+	transactionLogger.WritePut("yakv", "hello, yakv!")
+
+	// The stuck write is released, and the logger waited for, before writeLogFile is restored.
+	defer func() {
+		close(release)
+		<-transactionLogger.(*FileTransactionLogger).done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = closeLogger(ctx, transactionLogger)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "events still pending") {
+		t.Errorf("Expected the deadline with the pending events, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected closing to give up after the timeout, took %v", elapsed)
+	}
+}
+
+// Function for testing that HTTP/2 clients multiplex requests over a single cleartext connection with -h2c,
+// beyond the read timeout of the first request, while HTTP/1.1 clients are still served.
+func TestH2C(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-h2c.log")()
+	defer resetStores()
+	defer func() { config.readTimeout = 0 }()
+	config.readTimeout = 100 * time.Millisecond
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+	Put("yakv", "hello, yakv!")
+
+	// h2 clients speak HTTP/2 right away, without TLS.
+	h2Client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+
+	get := func(client *http.Client, addr string) (*http.Response, error) {
+		resp, err := client.Get("http://" + addr + "/yakv/v0/get?key=yakv")
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err == nil && (resp.StatusCode != http.StatusOK || string(body) != "hello, yakv!") {
+			err = fmt.Errorf("unexpected %d %q", resp.StatusCode, body)
+		}
+		return resp, err
+	}
+
+	for _, enabled := range []bool{true, false} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var handler http.Handler = r
+		if enabled {
+			handler = h2cHandler(r, time.Minute)
+		}
+
+		var conns int32
+		server := newServer(ln.Addr().String(), handler)
+		server.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt32(&conns, 1)
+			}
+		}
+		go server.Serve(ln)
+
+		addr := ln.Addr().String()
+
+		// HTTP/1.1 works either way.
+		if resp, err := get(http.DefaultClient, addr); err != nil || resp.ProtoMajor != 1 {
+			t.Errorf("Expected an HTTP/1.1 response, got %v", err)
+		}
+		http.DefaultClient.CloseIdleConnections()
+
+		if !enabled {
+			if _, err := get(h2Client, addr); err == nil {
+				t.Error("Expected HTTP/2 over cleartext to fail without -h2c")
+			}
+			server.Close()
+			continue
+		}
+
+		atomic.StoreInt32(&conns, 0)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp, err := get(h2Client, addr); err != nil || resp.ProtoMajor != 2 {
+					t.Errorf("Expected an HTTP/2 response, got %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// The connection outlives the read timeout of the request which started it.
+		time.Sleep(2 * config.readTimeout)
+		if resp, err := get(h2Client, addr); err != nil || resp.ProtoMajor != 2 {
+			t.Errorf("Expected an HTTP/2 response after the read timeout, got %v", err)
+		}
+
+		if n := atomic.LoadInt32(&conns); n != 1 {
+			t.Errorf("Expected the requests to share 1 connection, got %d", n)
+		}
+
+		h2Client.CloseIdleConnections()
+		server.Close()
+	}
+}
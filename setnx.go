@@ -0,0 +1,195 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// SetNXBody is a struct for defining the request body structure for setting a key only if it doesn't exist.
+type SetNXBody struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	TTLSeconds *int64 `json:"ttl_seconds"` // Optional lifetime of the key, zero means the key never expires. Defaults to the TTL of the key's prefix.
+}
+
+// SetIfNotExists sets the value of key only if it doesn't exist, and reports whether it was created. The key
+// expires according to the default TTL of its prefix, if any.
+func SetIfNotExists(key, value string) (bool, error) {
+	key = normalizeKey(key)
+	return setIfNotExists(key, value, defaultExpiry(key, time.Now()))
+}
+
+// setIfNotExists sets the value of key, which expires at expiresAt, only if it doesn't exist. Checking for the key
+// and creating it happen under a single lock, so of concurrent calls for the same key exactly one creates it.
+// Keys which have expired but haven't been swept yet don't exist anymore. Only a created key is logged.
+func setIfNotExists(key, value string, expiresAt time.Time) (bool, error) {
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	stored, compressed, err := encodeValue(key, value)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+
+	changes.RLock()
+	defer changes.RUnlock()
+	stripe := keyLock(key)
+	stripe.Lock()
+	defer stripe.Unlock()
+	store.Lock()
+
+	if _, ok := store.m[key]; ok {
+		if current, expires := store.expiry[key]; !expires || !hasExpired(key, current, now) {
+			store.Unlock()
+			return false, nil
+		}
+	}
+
+	err = setLocked(key, value, stored, compressed, expiresAt, "")
+	pinned := store.pinned[key]
+	store.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	if err := logger.WriteEvent(Event{EventType: EventPut, Key: key, Value: stored, Expiry: unixNano(expiresAt), Compressed: compressed, Pinned: pinned, verbatim: true}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// setLocked sets a new value of key, as it is stored after encodeValue, along with its expiry and content type.
+// Whether the key is pinned stays as it is, and a new key may evict another one, see admitKey. Values are encoded
+// before taking the lock, which validating, compressing and spilling them would hold up. The caller must hold the
+// stripe of key and the store's lock, and log the write after releasing the store's lock.
+func setLocked(key, value, stored string, compressed bool, expiresAt time.Time, contentType string) error {
+	if err := admitKey(key); err != nil {
+		return err
+	}
+
+	store.m[key] = stored
+	bumpVersionLocked(key)
+	if expiresAt.IsZero() {
+		delete(store.expiry, key)
+	} else {
+		store.expiry[key] = expiresAt
+	}
+	if compressed {
+		store.compressed[key] = true
+	} else {
+		delete(store.compressed, key)
+	}
+	if contentType != "" {
+		store.contentType[key] = contentType
+	} else {
+		delete(store.contentType, key)
+	}
+	touchKey(key, store.pinned[key])
+	store.index.add(key, value)
+
+	return nil
+}
+
+// SetNXHandler is a handler function for the endpoint setting a key only if it doesn't exist.
+func SetNXHandler(rw http.ResponseWriter, r *http.Request) {
+	var body SetNXBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := decodeKey(binary, body.Key)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value, err := decodeWire(binary, body.Value)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value = stripNewlines(binary, value)
+
+	expiresAt, err := requestExpiry(key, body.TTLSeconds)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := setIfNotExists(key, value, expiresAt)
+	var schemaErr *SchemaError
+	if errors.Is(err, ErrorEmptyKey) || errors.As(err, &schemaErr) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrorValueTooLarge) {
+		writeError(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, errStoreFull) {
+		writeError(rw, err.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// An existing key is a conflict, so that clients can tell whether they won without parsing the body.
+	rw.Header().Set("Content-Type", "application/json")
+	if created {
+		fmt.Printf("created key \"%s\"\n", key)
+		rw.WriteHeader(http.StatusCreated)
+	} else {
+		rw.WriteHeader(http.StatusConflict)
+	}
+
+	if err := json.NewEncoder(rw).Encode(struct {
+		Created bool `json:"created"`
+	}{created}); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that of concurrent calls for the same key exactly one creates it, and only it is logged.
+func TestSetIfNotExists(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-setnx.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+
+	var created int32
+	var winner atomic.Value
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+
+			ok, err := SetIfNotExists("lock:job", owner)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				atomic.AddInt32(&created, 1)
+				winner.Store(owner)
+			}
+		}(string(rune('a' + i%26)))
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Fatalf("Expected exactly one call to create the key, got %d", created)
+	}
+	if value, _ := Get("lock:job"); value != winner.Load() {
+		t.Errorf("Expected the value of the winner %q, got %q", winner.Load(), value)
+	}
+
+	// Keys which have expired can be created again.
+	PutWithExpiry("lock:stale", "owner1", time.Now().Add(-time.Second))
+	if ok, err := SetIfNotExists("lock:stale", "owner2"); err != nil || !ok {
+		t.Errorf("Expected an expired key to be created again, got %t %v", ok, err)
+	}
+
+	// Only the created keys are logged.
+	logger.Wait()
+	reader, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	events, errs := reader.ReadEvents()
+	replayed, n, err := replayState(events, errs, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || replayed["lock:job"] != winner.Load() || replayed["lock:stale"] != "owner2" {
+		t.Errorf("Expected 2 puts replaying to the created keys, got %d events and %q", n, replayed)
+	}
+}
+
+// Function for testing the setnx endpoint.
+func TestSetNXHandler(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-setnx-handler.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	setNX := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/setnx", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := setNX(`{"key": "lock:job", "value": "owner1", "ttl_seconds": 30}`); rec.Code != http.StatusCreated || strings.TrimSpace(rec.Body.String()) != `{"created":true}` {
+		t.Fatalf("Expected 201 for a new key, got %d %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := store.expiry["lock:job"]; !ok {
+		t.Errorf("Expected the key to expire")
+	}
+
+	if rec := setNX(`{"key": "lock:job", "value": "owner2"}`); rec.Code != http.StatusConflict || strings.TrimSpace(rec.Body.String()) != `{"created":false}` {
+		t.Errorf("Expected 409 for an existing key, got %d %s", rec.Code, rec.Body.String())
+	}
+	if value, _ := Get("lock:job"); value != "owner1" {
+		t.Errorf("Expected the existing value to be kept, got %q", value)
+	}
+
+	if rec := setNX(`{"key": "", "value": "owner1"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty key, got %d", rec.Code)
+	}
+	if rec := setNX(`{"key": "lock:other", "value": "owner1", "ttl_seconds": -1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a negative TTL, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sync/atomic"
+)
+
+// Default number of files the transaction log is sharded across.
+const defaultLogShards = 1
+
+// shardedLogger is a transaction logger spreading events across several file-based transaction loggers, so that
+// writes of different keys are batched and written to different files in parallel. Every event of a key, or of a
+// namespace, goes to the same shard, and the shards take event IDs from a shared counter, so that merging the
+// shards by ID replays the events of each key in the order they were logged.
+type shardedLogger struct {
+	shards []*FileTransactionLogger
+	lastID uint64     // Last used event ID, shared by the shards.
+	errors chan error // Errors of every shard.
+}
+
+// NewTransactionLogger creates the transaction logger for filename of the configured backend, sharded across
+// -log-shards files if there is more than one.
+func NewTransactionLogger(filename string) (TransactionLogger, error) {
+	return openTransactionLogger(filename, false)
+}
+
+// newTransactionLogReader opens the transaction log for filename like NewTransactionLogger, but read-only, so
+// that its events can be read without creating or modifying any file. Shards which don't exist are left out.
+func newTransactionLogReader(filename string) (TransactionLogger, error) {
+	return openTransactionLogger(filename, true)
+}
+
+// openTransactionLogger opens the transaction logger for filename of the configured backend, read-only or not.
+func openTransactionLogger(filename string, readOnly bool) (TransactionLogger, error) {
+	// The BoltDB file is read as the state of the store.
+	if config.backend == boltBackend {
+		return newBoltLogger(filename, readOnly)
+	}
+
+	n := config.logShards
+	if n < 1 {
+		n = defaultLogShards
+	}
+
+	// The events of shards beyond the configured number would never be replayed.
+	if _, err := os.Stat(shardFilename(filename, n)); err == nil {
+		return nil, fmt.Errorf("transaction log %q has more than %d shards, -log-shards can't be lowered", filename, n)
+	}
+
+	if n == 1 && readOnly {
+		return newFileLogReader(filename)
+	}
+	if n == 1 {
+		return NewFileTransactionLogger(filename)
+	}
+
+	return newShardedLogger(filename, n, readOnly)
+}
+
+// shardFilename returns the filename of shard i of the transaction log, the first shard being the log itself.
+func shardFilename(filename string, i int) string {
+	if i == 0 {
+		return filename
+	}
+
+	return fmt.Sprintf("%s.shard-%d", filename, i)
+}
+
+// newShardedLogger creates a transaction logger sharded across n files. A read-only logger leaves out the
+// shards other than the first which don't exist instead of creating them.
+func newShardedLogger(filename string, n int, readOnly bool) (*shardedLogger, error) {
+	sl := &shardedLogger{}
+	for i := 0; i < n; i++ {
+		var tl TransactionLogger
+		var err error
+		if readOnly {
+			if _, statErr := os.Stat(shardFilename(filename, i)); i > 0 && os.IsNotExist(statErr) {
+				continue
+			}
+			tl, err = newFileLogReader(shardFilename(filename, i))
+		} else {
+			tl, err = NewFileTransactionLogger(shardFilename(filename, i))
+		}
+		if err != nil {
+			sl.Close()
+			return nil, err
+		}
+
+		ftl := tl.(*FileTransactionLogger)
+		ftl.ids = &sl.lastID
+		sl.shards = append(sl.shards, ftl)
+	}
+
+	return sl, nil
+}
+
+// shard returns the shard the event belongs to, by the hash of its namespace or of its key in the default store.
+func (sl *shardedLogger) shard(e Event) *FileTransactionLogger {
+	key := e.Key
+	if e.Namespace != "" {
+		key = e.Namespace
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return sl.shards[h.Sum32()%uint32(len(sl.shards))]
+}
+
+// WritePut sends events of type EventPut to the shard of the key.
+func (sl *shardedLogger) WritePut(key, value string) error {
+	return sl.WriteEvent(Event{EventType: EventPut, Key: key, Value: value})
+}
+
+// WriteDelete sends events of type EventDelete to the shard of the key.
+func (sl *shardedLogger) WriteDelete(key string) error {
+	return sl.WriteEvent(Event{EventType: EventDelete, Key: key})
+}
+
+// WriteNamespacePut sends events of type EventPut for a namespaced key to the shard of the namespace.
+func (sl *shardedLogger) WriteNamespacePut(namespace, key, value string) error {
+	return sl.WriteEvent(Event{EventType: EventPut, Namespace: namespace, Key: key, Value: value})
+}
+
+// WriteNamespaceDelete sends events of type EventDelete for a namespaced key to the shard of the namespace.
+func (sl *shardedLogger) WriteNamespaceDelete(namespace, key string) error {
+	return sl.WriteEvent(Event{EventType: EventDelete, Namespace: namespace, Key: key})
+}
+
+// WriteDropNamespace sends events of type EventDropNamespace to the shard of the namespace.
+func (sl *shardedLogger) WriteDropNamespace(namespace string) error {
+	return sl.WriteEvent(Event{EventType: EventDropNamespace, Namespace: namespace})
+}
+
+// WriteEvent sends an arbitrary event to its shard.
+func (sl *shardedLogger) WriteEvent(e Event) error {
+	return sl.shard(e).WriteEvent(e)
+}
+
+// Close closes every shard, returning the first error.
+func (sl *shardedLogger) Close() error {
+	var err error
+	for _, ftl := range sl.shards {
+		if closeErr := ftl.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// Reopen reopens every shard, returning the first error. Shards which can't be opened again keep writing to the old file.
+func (sl *shardedLogger) Reopen() error {
+	var err error
+	for _, ftl := range sl.shards {
+		if reopenErr := ftl.Reopen(); err == nil {
+			err = reopenErr
+		}
+	}
+
+	return err
+}
+
+// Compact replaces every shard with the events belonging to it, one shard after another. taken is only called
+// once every shard is replaced, so writes are held back for longer than with a single log. A shard which can't
+// be replaced keeps its old file, along with the shards after it.
+func (sl *shardedLogger) Compact(events []Event, taken func()) (int64, error) {
+	parts := make(map[*FileTransactionLogger][]Event)
+	for _, e := range events {
+		ftl := sl.shard(e)
+		parts[ftl] = append(parts[ftl], e)
+	}
+
+	var size int64
+	for _, ftl := range sl.shards {
+		n, err := ftl.Compact(parts[ftl], func() {})
+		if err != nil {
+			return 0, err
+		}
+		size += n
+	}
+	taken()
+
+	return size, nil
+}
+
+// Wait blocks until every shard has flushed its events.
+func (sl *shardedLogger) Wait() {
+	for _, ftl := range sl.shards {
+		ftl.Wait()
+	}
+}
+
+// Err returns the channel receiving the errors of every shard.
+func (sl *shardedLogger) Err() <-chan error {
+	return sl.errors
+}
+
+// LastID returns the last used event ID across the shards.
+func (sl *shardedLogger) LastID() uint64 {
+	return atomic.LoadUint64(&sl.lastID)
+}
+
+// Log starts logging transactions to every shard.
+func (sl *shardedLogger) Log() {
+	sl.errors = make(chan error, logErrorBuffer)
+
+	for _, ftl := range sl.shards {
+		ftl.Log()
+		go sl.forwardErrors(ftl)
+	}
+}
+
+// forwardErrors sends the errors of a shard to the errors channel until the shard is closed, without blocking
+// when nobody reads it.
+func (sl *shardedLogger) forwardErrors(ftl *FileTransactionLogger) {
+	for {
+		select {
+		case err := <-ftl.errors:
+			select {
+			case sl.errors <- err:
+			default:
+				log.Printf("Error occurred while writing the transaction log: %v", err)
+			}
+		case <-ftl.done:
+			return
+		}
+	}
+}
+
+// ReadEvents reads all transactions from every shard, merged in the order of their IDs.
+func (sl *shardedLogger) ReadEvents() (<-chan Event, <-chan error) {
+	outEvent := make(chan Event, 256) // Buffered channel for events, so parsing runs ahead of replaying.
+	outError := make(chan error, 1)   // Buffered channel for errors.
+
+	// Next event of each shard, if it has any left.
+	type head struct {
+		events <-chan Event
+		errors <-chan error
+		e      Event
+		ok     bool
+	}
+
+	heads := make([]head, len(sl.shards))
+	for i, ftl := range sl.shards {
+		heads[i].events, heads[i].errors = ftl.ReadEvents()
+	}
+
+	// advance reads the next event of a shard, returning the error of the shard once it has none left.
+	advance := func(i int) error {
+		h := &heads[i]
+		if h.e, h.ok = <-h.events; h.ok {
+			return nil
+		}
+
+		if err := <-h.errors; err != nil {
+			return fmt.Errorf("failed while reading %s. %w", sl.shards[i].file.Name(), err)
+		}
+
+		return nil
+	}
+
+	// Goroutine for merging transactions.
+	go func() {
+		defer close(outEvent)
+		defer close(outError)
+
+		// After an error, the rest of the other shards is read in the background, so that their readers finish.
+		defer func() {
+			go func() {
+				for _, h := range heads {
+					for range h.events {
+					}
+					<-h.errors
+				}
+			}()
+		}()
+
+		for i := range heads {
+			if err := advance(i); err != nil {
+				outError <- err
+				return
+			}
+		}
+
+		for {
+			next := -1
+			for i, h := range heads {
+				if h.ok && (next < 0 || h.e.ID < heads[next].e.ID) {
+					next = i
+				}
+			}
+			if next < 0 {
+				return
+			}
+
+			// IDs are unique across shards, so a repeated ID means shards of different logs.
+			e := heads[next].e
+			if lastID := sl.LastID(); lastID >= e.ID {
+				outError <- fmt.Errorf("transaction IDs out of sequence across shards. %d != %d", lastID, e.ID)
+				return
+			}
+			atomic.StoreUint64(&sl.lastID, e.ID)
+
+			outEvent <- e
+
+			if err := advance(next); err != nil {
+				outError <- err
+				return
+			}
+		}
+	}()
+
+	return outEvent, outError
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Helper function for removing a transaction log along with its shards.
+func removeShards(filename string, n int) {
+	for i := 0; i < n; i++ {
+		os.Remove(shardFilename(filename, i))
+	}
+}
+
+// Function for testing that replaying a sharded log restores the events of every key in order.
+func TestShardedReplay(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-sharded.log"
+
+	// Restore to original state after test.
+	defer removeShards(filename, 4)
+	defer resetStores()
+	defer func(n int) { config.logShards = n }(config.logShards)
+	config.logShards = 4
+
+	transactionLogger, err := NewTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactionLogger.Log()
+
+	// Writers of different keys run concurrently, each overwriting its keys a few times.
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("key-%d-%d", w, i%10)
+				transactionLogger.WritePut(key, fmt.Sprint(i))
+				if i%7 == 0 {
+					transactionLogger.WriteDelete(key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Events of a namespace stay in order around dropping it.
+	transactionLogger.WriteNamespacePut("ns1", "hot", "one")
+	transactionLogger.WriteNamespaceDelete("ns1", "hot")
+	transactionLogger.WriteNamespacePut("ns2", "hot", "dropped")
+	transactionLogger.WriteDropNamespace("ns2")
+	transactionLogger.WriteNamespacePut("ns2", "hot", "two")
+	transactionLogger.Close()
+
+	expected := map[string]map[string]string{"": {}, "ns1": {}, "ns2": {"hot": "two"}}
+	for w := 0; w < 8; w++ {
+		for i := 40; i < 50; i++ {
+			// i%7 == 0 means the last write of the key deleted it.
+			if i%7 != 0 {
+				expected[""][fmt.Sprintf("key-%d-%d", w, i%10)] = fmt.Sprint(i)
+			}
+		}
+	}
+
+	// Every shard got a share of the events.
+	for i := 0; i < 4; i++ {
+		data, err := os.ReadFile(shardFilename(filename, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Count(string(data), "\n"); lines < 2 {
+			t.Errorf("Expected events in shard %d, got %d lines", i, lines)
+		}
+	}
+
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	lastID := logger.LastID()
+	logger.Close()
+
+	if actual := snapshotStores(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v after replaying the shards, got %v", expected, actual)
+	}
+
+	// IDs are unique across the shards.
+	if events := uint64(8*(50+8) + 5); lastID != events {
+		t.Errorf("Expected the last ID to be %d, got %d", events, lastID)
+	}
+}
+
+// Function for testing that a snapshot of a sharded log replaces every shard.
+func TestShardedSnapshot(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-sharded-snapshot.log"
+
+	// Restore to original state after test.
+	defer removeShards(filename, 3)
+	defer resetStores()
+	defer func(n int) { config.logShards = n }(config.logShards)
+	config.logShards = 3
+
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	put := func(key, value string) {
+		Put(key, value)
+		logger.WritePut(key, value)
+	}
+	for i := 0; i < 20; i++ {
+		put(fmt.Sprintf("key-%d", i%5), fmt.Sprint(i))
+	}
+
+	result, err := Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Keys != 5 || result.LastID != 25 {
+		t.Errorf("Expected 5 keys up to ID 25, got %+v", result)
+	}
+
+	// Writes after the snapshot follow its IDs.
+	put("after", "snapshot")
+	expected := snapshotStores()
+	logger.Close()
+
+	selfTest, err := SelfTest(filename)
+	if err != nil || selfTest.Events != 6 || selfTest.LastID != 26 {
+		t.Errorf("Expected 6 events up to ID 26 in the shards, got %+v %v", selfTest, err)
+	}
+
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+
+	if actual := snapshotStores(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v after replaying the snapshot, got %v", expected, actual)
+	}
+}
+
+// Function for testing that a log can't be opened with fewer shards than it has.
+func TestLowerLogShards(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-lower-shards.log"
+
+	// Restore to original state after test.
+	defer removeShards(filename, 2)
+	defer func(n int) { config.logShards = n }(config.logShards)
+	config.logShards = 2
+
+	transactionLogger, err := NewTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactionLogger.Close()
+
+	config.logShards = 1
+	if transactionLogger, err = NewTransactionLogger(filename); err == nil {
+		transactionLogger.Close()
+		t.Error("Expected an error for a log with more shards than configured")
+	}
+}
+
+// Helper function for benchmarking concurrent writes of distinct keys to a log with a given number of shards.
+func benchmarkLogShards(b *testing.B, shards int) {
+	// Temporary log filename.
+	const filename = "temp-bench-shards.log"
+
+	// Restore to original state after benchmark.
+	defer removeShards(filename, shards)
+	defer func(n int) { config.logShards = n }(config.logShards)
+	config.logShards = shards
+
+	transactionLogger, err := NewTransactionLogger(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	transactionLogger.Log()
+	defer transactionLogger.Close()
+
+	var n int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			transactionLogger.WritePut(fmt.Sprintf("key-%d", atomic.AddInt64(&n, 1)), "hello, yakv!")
+		}
+	})
+	transactionLogger.Wait()
+}
+
+// Benchmark for concurrent writes to a single transaction log.
+func BenchmarkLogShards1(b *testing.B) {
+	benchmarkLogShards(b, 1)
+}
+
+// Benchmark for concurrent writes to a transaction log sharded across 4 files.
+func BenchmarkLogShards4(b *testing.B) {
+	benchmarkLogShards(b, 4)
+}
@@ -0,0 +1,316 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Suffix of the file a snapshot is written to before it replaces the transaction log.
+const snapshotSuffix = ".snapshot"
+
+// errSnapshotRunning is raised when a snapshot is requested while another one is running.
+var errSnapshotRunning = errors.New("a snapshot is already running")
+
+// Whether a snapshot is running, only one snapshot runs at a time.
+var snapshotRunning int32
+
+// Number of events written to the transaction log since the last snapshot.
+var loggedSinceSnapshot int64
+
+// Signals the scheduler that -snapshot-every-n-events events were written since the last snapshot.
+var snapshotDue = make(chan struct{}, 1)
+
+// compaction is a request for the Log() goroutine to replace the transaction log with a snapshot.
+type compaction struct {
+	events []Event
+	taken  func()
+	result chan compactionResult
+}
+
+// compactionResult is the size of the new transaction log, or the error replacing it.
+type compactionResult struct {
+	size int64
+	err  error
+}
+
+// SnapshotResult describes a snapshot which replaced the transaction log.
+type SnapshotResult struct {
+	Keys   int    `json:"keys"`
+	Events int    `json:"events"`
+	LastID uint64 `json:"last_id"`
+	Size   int64  `json:"size"`
+}
+
+// countLoggedEvents records that n events were written to the transaction log, signalling the scheduler
+// once enough events were written for the next snapshot.
+func countLoggedEvents(n int) {
+	logged := atomic.AddInt64(&loggedSinceSnapshot, int64(n))
+	if config.snapshotEveryNEvents > 0 && logged >= int64(config.snapshotEveryNEvents) {
+		select {
+		case snapshotDue <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// storeEvents returns a PUT event for every key of the store which hasn't expired, in sorted order.
+func storeEvents(s *keyValueStore, namespace string, now time.Time) []Event {
+	s.RLock()
+	defer s.RUnlock()
+
+	keys := make([]string, 0, len(s.m))
+	for key := range s.m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	events := make([]Event, 0, len(keys))
+	for _, key := range keys {
+		// Values are logged as they are stored, compressed or binary values included.
+		e := Event{EventType: EventPut, Namespace: namespace, Key: key, Value: s.m[key], Compressed: s.compressed[key], ContentType: s.contentType[key], Pinned: s.pinned[key], verbatim: true}
+
+		// The put replaces the ones before it, so it carries the version they left the key at.
+		if version := s.version[key]; version > 1 {
+			e.Version = version
+		}
+
+		if expiresAt, ok := s.expiry[key]; ok {
+			// Keys which expired but weren't swept yet are left out.
+			if hasExpired(key, expiresAt, now) {
+				continue
+			}
+			e.Expiry = expiresAt.UnixNano()
+		}
+
+		events = append(events, e)
+	}
+
+	return events
+}
+
+// snapshotEvents returns the events recreating the default store, every namespace and every numbered database.
+// Namespaces without keys aren't recreated. The caller must hold the changes lock.
+func snapshotEvents(now time.Time) []Event {
+	events := storeEvents(store, "", now)
+
+	namespaces.RLock()
+	names := make([]string, 0, len(namespaces.m))
+	for name := range namespaces.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		events = append(events, storeEvents(namespaces.m[name], name, now)...)
+	}
+	namespaces.RUnlock()
+
+	return append(events, databaseEvents()...)
+}
+
+// Snapshot replaces the transaction log with the current state of the store, so that it no longer grows
+// with every write. Writes are only held back while the state is copied; while the new log is written,
+// their events queue up for the logger. With -keep-versions, the log is compacted down to the latest
+// versions of every key instead, only holding back writes while catching up on the events written while it
+// was read.
+func Snapshot() (SnapshotResult, error) {
+	// A store replayed up to an event would lose the events after it.
+	if config.replayUntil > 0 {
+		return SnapshotResult{}, errors.New("snapshots are disabled while replaying up to a transaction")
+	}
+	if config.backend == boltBackend {
+		return SnapshotResult{}, errBoltSnapshot
+	}
+	// A store which didn't replay the log would drop the transactions in it.
+	if config.noReplay {
+		return SnapshotResult{}, errors.New("snapshots are disabled without replaying the transaction log")
+	}
+
+	if !atomic.CompareAndSwapInt32(&snapshotRunning, 0, 1) {
+		return SnapshotResult{}, errSnapshotRunning
+	}
+	defer atomic.StoreInt32(&snapshotRunning, 0)
+
+	start := time.Now()
+
+	// The log is mostly read before writes are held back, leaving only its tail to catch up on.
+	var versions *versionReader
+	if config.keepVersions > 0 {
+		var err error
+		if versions, err = newVersionReader(config.keepVersions); err != nil {
+			return SnapshotResult{}, err
+		}
+		defer versions.Close()
+	}
+
+	// While the lock is held, every write in the store has been handed to the logger.
+	var release sync.Once
+	changes.Lock()
+	defer release.Do(changes.Unlock)
+
+	logger.Wait()
+	lastID := logger.LastID()
+
+	var events []Event
+	var keys int
+	if versions != nil {
+		var err error
+		if events, keys, err = versions.versionEvents(); err != nil {
+			return SnapshotResult{}, err
+		}
+	} else {
+		events = snapshotEvents(start)
+		keys = len(events)
+	}
+
+	size, err := logger.Compact(events, func() { release.Do(changes.Unlock) })
+	if err != nil {
+		return SnapshotResult{}, err
+	}
+
+	result := SnapshotResult{Keys: keys, Events: len(events), LastID: lastID + uint64(len(events)), Size: size}
+
+	// The events of overwritten and deleted values are gone, and with them the last references to their files.
+	if config.spillThreshold > 0 {
+		if n, err := removeOrphanedSpills(start.Add(-orphanedSpillAge)); err != nil {
+			log.Printf("Error occurred while removing orphaned spill files: %v", err)
+		} else if n > 0 {
+			log.Printf("Snapshot removed %d orphaned spill files", n)
+		}
+	}
+
+	log.Printf("Snapshot of %d keys in %d transactions took %v, the transaction log is now %d bytes", result.Keys, result.Events, time.Since(start), result.Size)
+
+	return result, nil
+}
+
+// replaceFile writes the events to a new file next to the transaction log and moves it over the log once
+// it's synced, so that a crash leaves either the old or the new log behind.
+func (ftl *FileTransactionLogger) replaceFile(events []Event) (int64, error) {
+	name := ftl.file.Name()
+
+	// The new log keeps the permissions of the old one.
+	info, err := ftl.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.OpenFile(name+snapshotSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create snapshot. %w", err)
+	}
+
+	w := bufio.NewWriter(file)
+	fmt.Fprintln(w, formatHeader(ftlVersion))
+
+	id := ftl.lastID
+	for _, e := range events {
+		id = ftl.nextID(id)
+		e.ID = id
+		w.WriteString(formatEvent(ftlVersion, e))
+		w.WriteByte('\n')
+	}
+
+	err = w.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(name+snapshotSuffix, name)
+	}
+	if err != nil {
+		os.Remove(name + snapshotSuffix)
+		return 0, fmt.Errorf("failed to write snapshot. %w", err)
+	}
+
+	ftl.lastID = id
+	atomic.StoreInt64(&loggedSinceSnapshot, 0)
+
+	if err := ftl.reopenFile(); err != nil {
+		return 0, fmt.Errorf("failed to reopen the transaction log after the snapshot. %w", err)
+	}
+
+	if info, err = ftl.file.Stat(); err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// runSnapshots takes a snapshot every interval, and whenever -snapshot-every-n-events events were written
+// since the last snapshot, until the context is done. A zero interval only snapshots after events.
+func runSnapshots(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-snapshotDue:
+			// The signal might predate a snapshot which was taken since.
+			if atomic.LoadInt64(&loggedSinceSnapshot) < int64(config.snapshotEveryNEvents) {
+				continue
+			}
+		}
+
+		// Writes are paused for backups, which need the transaction log to stay as it is.
+		if isWritesPaused() {
+			continue
+		}
+
+		if _, err := Snapshot(); err != nil && !errors.Is(err, errSnapshotRunning) {
+			log.Printf("Error occurred while taking a snapshot: %v", err)
+		}
+	}
+}
+
+// SnapshotHandler is a handler function for the endpoint taking a snapshot.
+func SnapshotHandler(rw http.ResponseWriter, r *http.Request) {
+	result, err := Snapshot()
+	if errors.Is(err, errSnapshotRunning) {
+		writeError(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(result); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that a snapshot replaces the transaction log with the state of the store, which
+// replaying it restores, while later writes keep being appended to it.
+func TestSnapshot(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-snapshot.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(method, path, body string) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if rec.Code >= http.StatusBadRequest {
+			t.Fatalf("Unexpected %d for %s %s", rec.Code, method, path)
+		}
+	}
+
+	// A hot key and a deleted key only leave their final state behind.
+	for i := 0; i < 10; i++ {
+		serve(http.MethodPut, "/yakv/v0/put", `{"key": "hot", "value": "hello, yakv!"}`)
+	}
+	serve(http.MethodPut, "/yakv/v0/put", `{"key": "deleted", "value": "bye"}`)
+	serve(http.MethodDelete, "/yakv/v0/delete", `{"key": "deleted"}`)
+	serve(http.MethodPut, "/yakv/v0/put", `{"key": "logo", "value": "<svg/>", "content_type": "image/svg+xml"}`)
+	serve(http.MethodPut, "/yakv/v0/ns/users/keys/1", `{"value": "alice"}`)
+
+	result, err := Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Keys != 3 || result.LastID != 14+3 {
+		t.Errorf("Expected 3 keys up to ID 17, got %+v", result)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1+3 || int64(len(data)) != result.Size {
+		t.Errorf("Expected the header and 3 transactions in %d bytes, got %d lines in %d bytes", result.Size, lines, len(data))
+	}
+
+	// Writes after the snapshot follow its IDs.
+	serve(http.MethodPut, "/yakv/v0/put", `{"key": "after", "value": "snapshot"}`)
+	logger.Wait()
+
+	selfTest, err := SelfTest(filename)
+	if err != nil || selfTest.LastID != result.LastID+1 {
+		t.Errorf("Expected the log to pass the self-test up to ID %d, got %+v %v", result.LastID+1, selfTest, err)
+	}
+
+	// Replaying the new log restores the store.
+	expected := snapshotStores()
+	contentType := store.contentType["logo"]
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := snapshotStores(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v after replaying the snapshot, got %v", expected, actual)
+	}
+	if store.contentType["logo"] != contentType {
+		t.Errorf("Expected the content type %q to survive the snapshot, got %q", contentType, store.contentType["logo"])
+	}
+}
+
+// Function for testing that expiring keys keep their expiry in a snapshot, and expired keys are left out.
+func TestSnapshotExpiry(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-snapshot-expiry.log")()
+	defer resetStores()
+
+	now := time.Now()
+	PutWithExpiry("expiring", "soon", now.Add(time.Hour))
+	PutWithExpiry("expired", "already", now.Add(-time.Second))
+
+	events := snapshotEvents(now)
+	if len(events) != 1 || events[0].Key != "expiring" || events[0].Expiry != now.Add(time.Hour).UnixNano() {
+		t.Errorf("Expected only the expiring key with its expiry, got %+v", events)
+	}
+}
+
+// Function for testing that only one snapshot runs at a time.
+func TestSnapshotRunning(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-snapshot-running.log")()
+
+	atomic.StoreInt32(&snapshotRunning, 1)
+	defer atomic.StoreInt32(&snapshotRunning, 0)
+
+	if _, err := Snapshot(); !errors.Is(err, errSnapshotRunning) {
+		t.Errorf("Expected the snapshot to be refused, got %v", err)
+	}
+}
+
+// Function for testing that the scheduler is signalled once enough events were written.
+func TestSnapshotEveryNEvents(t *testing.T) {
+	// Restore to original state after test.
+	defer func(n int) { config.snapshotEveryNEvents = n }(config.snapshotEveryNEvents)
+	defer atomic.StoreInt64(&loggedSinceSnapshot, 0)
+	config.snapshotEveryNEvents = 3
+	atomic.StoreInt64(&loggedSinceSnapshot, 0)
+
+	due := func() bool {
+		select {
+		case <-snapshotDue:
+			return true
+		default:
+			return false
+		}
+	}
+	due()
+
+	countLoggedEvents(2)
+	if due() {
+		t.Error("Expected no snapshot after 2 events")
+	}
+
+	countLoggedEvents(1)
+	if !due() {
+		t.Error("Expected a snapshot after 3 events")
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Prefix of a stored value which references a spill file instead of holding the value. Compressed values
+// start with the gzip magic number, so a reference can't be mistaken for one.
+const spillRefPrefix = "yakv-spill:"
+
+// Suffix of spill files holding gzip-compressed values.
+const spillCompressedSuffix = ".gz"
+
+// Default directory spill files are written to.
+const defaultSpillDir = "spill"
+
+// Number of random bytes in the name of a spill file.
+const spillNameBytes = 16
+
+// Whether the transaction log is being replayed.
+var replaying bool
+
+// Age a spill file no value references must have before it's removed by a snapshot. A value is spilled before
+// the write takes the store's lock, so a newer file might belong to a write which hasn't stored it yet.
+var orphanedSpillAge = time.Minute
+
+// spillValue writes values stored with at least -spill-threshold bytes to a file of their own, and returns
+// the reference to the file which is stored instead. References are stored as compressed values, so that
+// they are read back by decompressValue wherever values are decoded.
+func spillValue(stored string, compressed bool) (string, bool, error) {
+	if config.spillThreshold <= 0 || len(stored) < config.spillThreshold {
+		return stored, compressed, nil
+	}
+
+	// Every value gets a new file, so that the file of a logged reference never changes.
+	b := make([]byte, spillNameBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", false, err
+	}
+
+	name := hex.EncodeToString(b)
+	if compressed {
+		name += spillCompressedSuffix
+	}
+
+	if err := os.MkdirAll(config.spillDir, 0755); err != nil {
+		return "", false, fmt.Errorf("failed to create spill directory. %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(config.spillDir, name), []byte(stored), 0600); err != nil {
+		return "", false, fmt.Errorf("failed to spill value. %w", err)
+	}
+
+	return spillRefPrefix + name, true, nil
+}
+
+// spillName returns the name of the spill file a stored value references, and whether it references one.
+func spillName(stored string) (string, bool) {
+	if !strings.HasPrefix(stored, spillRefPrefix) {
+		return "", false
+	}
+
+	return filepath.Base(strings.TrimPrefix(stored, spillRefPrefix)), true
+}
+
+// readSpill reads a spilled value back from its file, decompressing it if needed.
+func readSpill(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(config.spillDir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read spilled value. %w", err)
+	}
+
+	if strings.HasSuffix(name, spillCompressedSuffix) {
+		return decompressValue(string(data))
+	}
+
+	return string(data), nil
+}
+
+// isSpillFile returns whether name is the name of a spill file.
+func isSpillFile(name string) bool {
+	name = strings.TrimSuffix(name, spillCompressedSuffix)
+	b, err := hex.DecodeString(name)
+	return err == nil && len(b) == spillNameBytes
+}
+
+// removeOrphanedSpills removes the spill files which neither a value of the store nor an event of the transaction
+// log references, e.g. the files of values which were spilled but never logged before a crash, or of values
+// which were overwritten or deleted before the log was compacted, and returns the number of removed files.
+// Files are never removed on writes, since the events of earlier values still reference them. Only files
+// modified before the given time are removed, unless it's zero.
+func removeOrphanedSpills(before time.Time) (int, error) {
+	entries, err := os.ReadDir(config.spillDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	referenced := make(map[string]bool)
+	store.RLock()
+	for key := range store.compressed {
+		if name, ok := spillName(store.m[key]); ok {
+			referenced[name] = true
+		}
+	}
+	store.RUnlock()
+
+	var orphans []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isSpillFile(entry.Name()) || referenced[entry.Name()] {
+			continue
+		}
+
+		if !before.IsZero() {
+			info, err := entry.Info()
+			if err != nil || !info.ModTime().Before(before) {
+				continue
+			}
+		}
+		orphans = append(orphans, entry.Name())
+	}
+	if len(orphans) == 0 {
+		return 0, nil
+	}
+
+	// Values of namespaces, of other databases and of earlier events are only referenced by the log, which is
+	// only read, so it's opened read-only. The BoltDB file holds no earlier events, and is read by its logger,
+	// since the lock the logger holds on the file keeps it from being opened again.
+	reader := logger
+	if config.backend != boltBackend {
+		if reader, err = newTransactionLogReader(transactionLogFilename); err != nil {
+			return 0, err
+		}
+		defer reader.Close()
+	}
+
+	events, errors := reader.ReadEvents()
+	for e := range events {
+		if name, ok := spillName(e.Value); ok && e.Compressed {
+			referenced[name] = true
+		}
+	}
+	if err := <-errors; err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, name := range orphans {
+		if referenced[name] {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(config.spillDir, name)); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Helper function for spilling values of at least threshold bytes to a temporary directory.
+func useSpillDir(t *testing.T, threshold int) func() {
+	spillThreshold, spillDir := config.spillThreshold, config.spillDir
+	config.spillThreshold, config.spillDir = threshold, t.TempDir()
+
+	return func() { config.spillThreshold, config.spillDir = spillThreshold, spillDir }
+}
+
+// Helper function for listing the spill files.
+func spillFiles(t *testing.T) []string {
+	entries, err := os.ReadDir(config.spillDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	return names
+}
+
+// Function for testing that large values are spilled to files, which are kept for the events of overwritten and
+// deleted values until a snapshot.
+func TestSpill(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-spill.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(filename string) { transactionLogFilename = filename }(transactionLogFilename)
+	transactionLogFilename = filename
+	defer useSpillDir(t, 16)()
+
+	large := strings.Repeat("yakv", 8)
+
+	// Small values stay in memory.
+	Put("small", "hello")
+	if store.compressed["small"] || len(spillFiles(t)) != 0 {
+		t.Fatal("Expected a small value to stay in memory")
+	}
+
+	// Large values are replaced by a reference to their file.
+	Put("large", large)
+	files := spillFiles(t)
+	if len(files) != 1 || store.m["large"] != spillRefPrefix+files[0] {
+		t.Fatalf("Expected the value to reference its spill file, got %q and %v", store.m["large"], files)
+	}
+	if value, err := Get("large"); err != nil || value != large {
+		t.Errorf("Expected the spilled value, got %q %v", value, err)
+	}
+
+	// Overwriting or deleting the value keeps its files.
+	Put("large", large+"!")
+	Delete("large")
+	if files := spillFiles(t); len(files) != 2 {
+		t.Errorf("Expected the old spill files to be kept, got %v", files)
+	}
+
+	// Files no longer referenced are removed by a snapshot, once they are old enough.
+	defer func(age time.Duration) { orphanedSpillAge = age }(orphanedSpillAge)
+	if _, err := Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if files := spillFiles(t); len(files) != 2 {
+		t.Errorf("Expected recent spill files to be kept, got %v", files)
+	}
+
+	orphanedSpillAge = -time.Second
+	if _, err := Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if files := spillFiles(t); len(files) != 0 {
+		t.Errorf("Expected the spill files to be removed, got %v", files)
+	}
+}
+
+// Function for testing that the values of earlier events stay readable after they are overwritten.
+func TestSpillHistory(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-spill-history.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer useSpillDir(t, 16)()
+	defer func(filename string) { transactionLogFilename = filename }(transactionLogFilename)
+	transactionLogFilename = filename
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	large := strings.Repeat("yakv", 8)
+	for _, value := range []string{large, large + "!"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(`{"key": "large", "value": "`+value+`"}`)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Unexpected %d for PUT", rec.Code)
+		}
+	}
+	logger.Wait()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/admin/history?key=large", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"value":"`+large+`"`) {
+		t.Errorf("Expected the overwritten value in the history, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// Function for testing that compressed values are spilled compressed.
+func TestSpillCompressed(t *testing.T) {
+	// Restore to original state after test.
+	defer resetStores()
+	defer useSpillDir(t, 16)()
+	defer func(threshold int) { config.compressThreshold = threshold }(config.compressThreshold)
+	config.compressThreshold = 1
+
+	large := strings.Repeat("yakv", 64)
+	Put("large", large)
+
+	files := spillFiles(t)
+	if len(files) != 1 || !strings.HasSuffix(files[0], spillCompressedSuffix) {
+		t.Fatalf("Expected a compressed spill file, got %v", files)
+	}
+	if value, err := Get("large"); err != nil || value != large {
+		t.Errorf("Expected the decompressed value, got %q %v", value, err)
+	}
+}
+
+// Function for testing that replaying keeps the files of renamed keys, and removes orphaned files.
+func TestSpillReplay(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-spill-replay.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer useSpillDir(t, 16)()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	large := strings.Repeat("yakv", 8)
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPut, "/yakv/v0/put", `{"key": "from", "value": "` + large + `"}`},
+		{http.MethodPost, "/yakv/v0/rename", `{"from": "from", "to": "to"}`},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+		if rec.Code >= http.StatusBadRequest {
+			t.Fatalf("Unexpected %d for %s %s", rec.Code, req.method, req.path)
+		}
+	}
+
+	// A value which was spilled but never logged, e.g. before a crash.
+	orphan := filepath.Join(config.spillDir, strings.Repeat("ab", spillNameBytes))
+	if err := os.WriteFile(orphan, []byte(large), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := Get("to"); err != nil || value != large {
+		t.Errorf("Expected the renamed value to survive the replay, got %q %v", value, err)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("Expected the orphaned spill file to be removed, got %v", err)
+	}
+}
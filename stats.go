@@ -0,0 +1,171 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Upper bounds of the latency histogram buckets, a last bucket holds everything slower.
+var latencyBuckets = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// latencyHistogram counts the durations of an operation in latencyBuckets.
+type latencyHistogram struct {
+	count   uint64
+	sum     time.Duration
+	max     time.Duration
+	buckets []uint64
+}
+
+// observe records a duration in the histogram.
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return d <= latencyBuckets[i] })
+	h.buckets[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Latency histograms of the operations, keyed by the name of the operation.
+// lockHeld only holds the operations which lock the store, for the time the lock is held.
+var latencies = struct {
+	sync.Mutex
+	total    map[string]*latencyHistogram
+	lockHeld map[string]*latencyHistogram
+}{total: make(map[string]*latencyHistogram), lockHeld: make(map[string]*latencyHistogram)}
+
+// histogram returns the histogram of op in histograms, creating it if needed. The caller must hold the latencies lock.
+func histogram(histograms map[string]*latencyHistogram, op string) *latencyHistogram {
+	h, ok := histograms[op]
+	if !ok {
+		h = &latencyHistogram{buckets: make([]uint64, len(latencyBuckets)+1)}
+		histograms[op] = h
+	}
+
+	return h
+}
+
+// recordLatency records the duration of an operation, and warns about it if it exceeds the slow threshold.
+func recordLatency(op string, total time.Duration) {
+	latencies.Lock()
+	histogram(latencies.total, op).observe(total)
+	latencies.Unlock()
+
+	if config.slowThreshold > 0 && total > config.slowThreshold {
+		log.Printf("slow operation %s: took %v", op, total)
+	}
+}
+
+// recordLockedLatency records the duration of an operation on the store along with how long it held the lock.
+// The difference between both is mostly time spent waiting for the lock.
+func recordLockedLatency(op string, total, held time.Duration) {
+	latencies.Lock()
+	histogram(latencies.total, op).observe(total)
+	histogram(latencies.lockHeld, op).observe(held)
+	latencies.Unlock()
+
+	if config.slowThreshold > 0 && total > config.slowThreshold {
+		log.Printf("slow operation %s: took %v, held the lock for %v", op, total, held)
+	}
+}
+
+// LatencyBucket is the number of durations up to LE, and above the previous bucket.
+type LatencyBucket struct {
+	LE    string `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// LatencySummary summarizes a latency histogram.
+type LatencySummary struct {
+	Count      uint64          `json:"count"`
+	MeanMicros float64         `json:"mean_us"`
+	MaxMicros  float64         `json:"max_us"`
+	Buckets    []LatencyBucket `json:"buckets"`
+}
+
+// OperationStats holds the latency summaries of an operation.
+type OperationStats struct {
+	Total    LatencySummary  `json:"total"`
+	LockHeld *LatencySummary `json:"lock_held,omitempty"`
+}
+
+// summary summarizes the histogram.
+func (h *latencyHistogram) summary() LatencySummary {
+	s := LatencySummary{Count: h.count, MaxMicros: float64(h.max) / float64(time.Microsecond)}
+	if h.count > 0 {
+		s.MeanMicros = float64(h.sum) / float64(h.count) / float64(time.Microsecond)
+	}
+
+	for i, count := range h.buckets {
+		le := "+Inf"
+		if i < len(latencyBuckets) {
+			le = latencyBuckets[i].String()
+		}
+		s.Buckets = append(s.Buckets, LatencyBucket{LE: le, Count: count})
+	}
+
+	return s
+}
+
+// Stats returns the latency summaries of all operations which ran at least once.
+func Stats() map[string]OperationStats {
+	latencies.Lock()
+	defer latencies.Unlock()
+
+	stats := make(map[string]OperationStats, len(latencies.total))
+	for op, h := range latencies.total {
+		s := OperationStats{Total: h.summary()}
+		if held, ok := latencies.lockHeld[op]; ok {
+			summary := held.summary()
+			s.LockHeld = &summary
+		}
+		stats[op] = s
+	}
+
+	return stats
+}
+
+// StatsHandler is a handler function for the stats endpoint.
+func StatsHandler(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(struct {
+		ReadOnly         bool                      `json:"read_only"`
+		AutoReadOnly     *AutoReadOnly             `json:"auto_read_only,omitempty"`
+		Draining         *DrainState               `json:"draining,omitempty"`
+		InFlight         int64                     `json:"in_flight"`
+		LogBackpressure  bool                      `json:"log_backpressure"`
+		BlockedLogWrites uint64                    `json:"blocked_log_writes"`
+		RejectedConns    uint64                    `json:"rejected_connections"`
+		EvictedKeys      uint64                    `json:"evicted_keys"`
+		Consistency      LogConsistency            `json:"consistency"`
+		Operations       map[string]OperationStats `json:"operations"`
+	}{isWritesPaused(), autoReadOnlyStats(), drainStats(), inFlightRequests(), underBackpressure(), blockedLogWrites(), rejectedConnections(), evictedCount(), logConsistency(), Stats()}); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Function for testing that durations land in the right bucket.
+func TestLatencyHistogram(t *testing.T) {
+	h := &latencyHistogram{buckets: make([]uint64, len(latencyBuckets)+1)}
+
+	h.observe(5 * time.Microsecond)
+	h.observe(10 * time.Microsecond)
+	h.observe(2 * time.Millisecond)
+	h.observe(time.Minute)
+
+	s := h.summary()
+	if s.Count != 4 || s.MaxMicros != float64(time.Minute/time.Microsecond) {
+		t.Errorf("Unexpected summary: %+v", s)
+	}
+
+	// Bucket bounds are inclusive, and the last bucket holds everything slower.
+	expected := []uint64{2, 0, 0, 1, 0, 0, 1}
+	for i, b := range s.Buckets {
+		if b.Count != expected[i] {
+			t.Errorf("Expected %d durations up to %s, got %d", expected[i], b.LE, b.Count)
+		}
+	}
+}
+
+// Function for testing that the stats endpoint reports store operations along with their lock-held time.
+func TestStatsHandler(t *testing.T) {
+	// Restore to original state after test.
+	defer delete(store.m, "yakv")
+
+	Put("yakv", "hello, yakv!")
+	Get("yakv")
+
+	rec := httptest.NewRecorder()
+	StatsHandler(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/stats", nil))
+
+	var stats struct {
+		Operations map[string]OperationStats `json:"operations"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []string{"put", "get"} {
+		s, ok := stats.Operations[op]
+		if !ok || s.Total.Count == 0 || s.LockHeld == nil || s.LockHeld.Count == 0 {
+			t.Errorf("Expected latencies for %s, got %+v", op, s)
+		}
+	}
+}
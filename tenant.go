@@ -0,0 +1,142 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Key prefixes of tenants, keyed by the API key of the tenant.
+var tenants = struct {
+	sync.RWMutex
+	prefixes map[string]string
+}{}
+
+// Routes tenants can use, relative to the route prefix. Tenants are rejected from every other route, since
+// their keys aren't scoped to the tenant's prefix.
+var tenantRoutes = map[string]bool{
+	"/get":          true,
+	"/put":          true,
+	"/delete":       true,
+	"/keys":         true,
+	"/scan":         true,
+	"/getall":       true,
+	"/count":        true,
+	"/capabilities": true,
+}
+
+// tenantContextKey is the key of the tenant's prefix in the context of a request.
+type tenantContextKey struct{}
+
+// checkTenants checks that every tenant has a prefix, and that no prefix starts another one, which would let
+// a tenant see the keys of the other.
+func checkTenants(prefixes map[string]string) error {
+	sorted := make([]string, 0, len(prefixes))
+	for apiKey, prefix := range prefixes {
+		if apiKey == "" || prefix == "" {
+			return fmt.Errorf("tenants must have an API key and a prefix, got %q for %q", prefix, apiKey)
+		}
+		sorted = append(sorted, prefix)
+	}
+
+	// A prefix starting another one sorts right before it, or before other prefixes it starts.
+	sort.Strings(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if strings.HasPrefix(sorted[i], sorted[i-1]) {
+			return fmt.Errorf("tenant prefix %q starts tenant prefix %q", sorted[i-1], sorted[i])
+		}
+	}
+
+	return nil
+}
+
+// setTenants replaces the key prefixes of tenants.
+func setTenants(prefixes map[string]string) {
+	tenants.Lock()
+	tenants.prefixes = prefixes
+	tenants.Unlock()
+}
+
+// hasTenants reports whether any tenant is configured.
+func hasTenants() bool {
+	tenants.RLock()
+	defer tenants.RUnlock()
+
+	return len(tenants.prefixes) > 0
+}
+
+// lookupTenant returns the key prefix of the tenant with the API key. Every API key is compared in constant time.
+func lookupTenant(apiKey string) (string, bool) {
+	tenants.RLock()
+	defer tenants.RUnlock()
+
+	var prefix string
+	found := false
+	for key, p := range tenants.prefixes {
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+			prefix, found = p, true
+		}
+	}
+
+	return prefix, found
+}
+
+// tenantPrefix returns the key prefix of the tenant a request was authenticated as, empty for other requests.
+func tenantPrefix(r *http.Request) string {
+	prefix, _ := r.Context().Value(tenantContextKey{}).(string)
+	return prefix
+}
+
+// scopeKey returns a key of a request as it is stored, i.e. with the prefix of the request's tenant. The empty
+// key stays empty, so that it's still rejected.
+func scopeKey(r *http.Request, key string) string {
+	if key == "" {
+		return key
+	}
+
+	return tenantPrefix(r) + key
+}
+
+// unscopeKey returns a stored key as the request's tenant sees it, i.e. without the tenant's prefix.
+func unscopeKey(r *http.Request, key string) string {
+	return strings.TrimPrefix(key, tenantPrefix(r))
+}
+
+// TenantMiddleware rejects requests of tenants with 403 Forbidden, unless their route is in allowed. The prefix
+// of the group is stripped from the route before checking allowed.
+func TenantMiddleware(prefix string, allowed map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenantPrefix(c.Request) != "" && !allowed[strings.TrimPrefix(c.FullPath(), prefix)] {
+			writeError(c.Writer, "route is not available to tenants", http.StatusForbidden)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// withTenant returns the request scoped to the key prefix of a tenant.
+func withTenant(r *http.Request, prefix string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, prefix))
+}
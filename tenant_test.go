@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that tenants only see their own keys, which are stored and logged with their prefix.
+func TestTenants(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-tenants.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer setTenants(nil)
+	setTenants(map[string]string{"acme-key": "acme:", "globex-key": "globex:"})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(APIKeyMiddleware("admin-key"))
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(apiKey, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Both tenants write the same key name.
+	serve("acme-key", http.MethodPut, "/yakv/v0/put", `{"key": "config", "value": "acme"}`)
+	serve("globex-key", http.MethodPut, "/yakv/v0/put", `{"key": "config", "value": "globex"}`)
+	serve("globex-key", http.MethodPut, "/yakv/v0/put", `{"key": "other", "value": "globex"}`)
+
+	if rec := serve("acme-key", http.MethodGet, "/yakv/v0/get", `{"key": "config"}`); rec.Body.String() != "acme" {
+		t.Errorf("Expected acme to get its own value, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve("acme-key", http.MethodGet, "/yakv/v0/get", `{"key": "other"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a key of another tenant, got %d", rec.Code)
+	}
+
+	// Listings are scoped and stripped, including their cursors.
+	if rec := serve("globex-key", http.MethodGet, "/yakv/v0/keys?limit=1", ""); !strings.Contains(rec.Body.String(), `"keys":["config"]`) {
+		t.Errorf("Expected globex to list its first key without the prefix, got %s", rec.Body.String())
+	} else if rec = serve("globex-key", http.MethodGet, "/yakv/v0/keys?after="+encodeCursor("config"), ""); !strings.Contains(rec.Body.String(), `"keys":["other"]`) {
+		t.Errorf("Expected the cursor to continue with the next key of globex, got %s", rec.Body.String())
+	}
+	if rec := serve("acme-key", http.MethodGet, "/yakv/v0/getall", ""); rec.Body.String() != "{\"config\":\"acme\"}\n" {
+		t.Errorf("Expected acme to get only its own values, got %s", rec.Body.String())
+	}
+	if rec := serve("acme-key", http.MethodGet, "/yakv/v0/count", ""); rec.Body.String() != "{\"count\":1}\n" {
+		t.Errorf("Expected acme to count only its own keys, got %s", rec.Body.String())
+	}
+
+	// The empty key is still rejected rather than resolving to the prefix.
+	if rec := serve("acme-key", http.MethodDelete, "/yakv/v0/delete", `{"key": ""}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for the empty key, got %d", rec.Code)
+	}
+
+	// Routes which aren't scoped to tenants are forbidden to them, but not to the admin key.
+	for _, path := range []string{"/yakv/v0/append", "/yakv/v0/admin/readonly"} {
+		if rec := serve("acme-key", http.MethodPost, path, `{}`); rec.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for %s, got %d", path, rec.Code)
+		}
+	}
+	if rec := serve("admin-key", http.MethodGet, "/yakv/v0/get", `{"key": "globex:config"}`); rec.Body.String() != "globex" {
+		t.Errorf("Expected the admin key to see every key, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve("nobody", http.MethodGet, "/yakv/v0/get", `{"key": "config"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown API key, got %d", rec.Code)
+	}
+
+	// The log holds the full keys, so replaying doesn't depend on tenants.
+	logger.Wait()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "acme:config") || !strings.Contains(string(data), "globex:config") {
+		t.Errorf("Expected the log to hold the prefixed keys, got %s", data)
+	}
+}
+
+// Function for testing that only tenants are let in without an admin API key.
+func TestTenantsWithoutAPIKey(t *testing.T) {
+	// Restore to original state after test.
+	defer setTenants(nil)
+	setTenants(map[string]string{"acme-key": "acme:"})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(APIKeyMiddleware(""))
+	r.GET("/capabilities", gin.WrapF(CapabilitiesHandler))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without an API key, got %d", rec.Code)
+	}
+}
+
+// Function for testing that tenants need a prefix which doesn't overlap with the prefixes of others.
+func TestCheckTenants(t *testing.T) {
+	tests := []struct {
+		prefixes map[string]string
+		valid    bool
+	}{
+		{map[string]string{"a": "acme:", "b": "globex:"}, true},
+		{map[string]string{"a": "acme:", "b": "acme:labs:"}, false},
+		{map[string]string{"a": "acme:", "b": "acme:"}, false},
+		{map[string]string{"a": ""}, false},
+		{nil, true},
+	}
+
+	for _, test := range tests {
+		if err := checkTenants(test.prefixes); (err == nil) != test.valid {
+			t.Errorf("Expected %v to be valid: %t, got %v", test.prefixes, test.valid, err)
+		}
+	}
+}
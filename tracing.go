@@ -0,0 +1,102 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer for yakv's spans. It is a no-op tracer unless tracing is initialized.
+var tracer = trace.NewNoopTracerProvider().Tracer("yakv")
+
+// initTracing sets up a tracer exporting spans over OTLP/HTTP to endpoint, and returns a
+// function flushing the remaining spans on shutdown.
+func initTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OTLP exporter. %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "yakv"))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	tracer = tp.Tracer("yakv")
+
+	return tp.Shutdown, nil
+}
+
+// TracingMiddleware starts a span for every request, continuing the trace from the incoming headers.
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.String("http.method", c.Request.Method), attribute.String("http.route", route), attribute.Int("http.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprint("status ", status))
+		}
+	}
+}
+
+// startOperationSpan starts a child span for an operation on a key. Only the length of the key is recorded, not the key itself.
+func startOperationSpan(ctx context.Context, operation, key string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "yakv."+operation, trace.WithAttributes(
+		attribute.String("yakv.operation", operation),
+		attribute.Int("yakv.key_length", len(key)),
+	))
+}
+
+// endOperationSpan records the result of an operation on its span and ends the span.
+func endOperationSpan(span trace.Span, err error) {
+	switch {
+	case err == nil:
+		span.SetAttributes(attribute.String("yakv.result", "ok"))
+	case errors.Is(err, ErrorNoSuchKey):
+		span.SetAttributes(attribute.String("yakv.result", "not_found"))
+	default:
+		span.SetAttributes(attribute.String("yakv.result", "error"))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
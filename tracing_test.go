@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Function for testing that requests and operations are traced.
+func TestTracing(t *testing.T) {
+	// Record the spans instead of exporting them.
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	// Restore to original state after test.
+	defer func() { tracer = trace.NewNoopTracerProvider().Tracer("yakv") }()
+	tracer = tp.Tracer("yakv")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TracingMiddleware())
+	r.GET("yakv/v0/get", gin.WrapF(GetHandler))
+
+	req := httptest.NewRequest(http.MethodGet, "/yakv/v0/get", strings.NewReader(`{"key": "secret-key"}`))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+
+	get, server := spans[0], spans[1]
+
+	// The request continues the incoming trace.
+	if traceID := server.SpanContext().TraceID().String(); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the incoming trace ID, got %s", traceID)
+	}
+	if get.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("Expected the Get span to be a child of the request span.")
+	}
+
+	attrs := map[string]string{}
+	for _, attr := range get.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+
+		if strings.Contains(attr.Value.Emit(), "secret-key") {
+			t.Error("The key must not be recorded on spans.")
+		}
+	}
+
+	if attrs["yakv.operation"] != "get" || attrs["yakv.key_length"] != "10" || attrs["yakv.result"] != "not_found" {
+		t.Errorf("Unexpected attributes on the Get span: %v", attrs)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TransformBody is a struct for defining the transform request body structure.
+type TransformBody struct {
+	Prefix string `json:"prefix"`
+	Op     string `json:"op"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// transformFunc returns the function applying the operation of the body to a value.
+//
+//   - replace replaces every occurrence of from with to, from must not be empty;
+//   - prefix adds to at the start of the value;
+//   - suffix adds to at the end of the value;
+//   - trim removes leading and trailing white space.
+func (body TransformBody) transformFunc() (func(string) string, error) {
+	switch body.Op {
+	case "replace":
+		if body.From == "" {
+			return nil, errors.New("from must not be empty for replace")
+		}
+		return func(v string) string { return strings.ReplaceAll(v, body.From, body.To) }, nil
+	case "prefix":
+		return func(v string) string { return body.To + v }, nil
+	case "suffix":
+		return func(v string) string { return v + body.To }, nil
+	case "trim":
+		return strings.TrimSpace, nil
+	}
+
+	return nil, fmt.Errorf("unknown op %q, expected replace, prefix, suffix or trim", body.Op)
+}
+
+// transformedValue is the new value of a key, as it is stored.
+type transformedValue struct {
+	key        string
+	value      string // Uncompressed value, for the value index.
+	stored     string
+	compressed bool
+}
+
+// Transform applies transform to the values of every key starting with prefix under a single lock,
+// logging a put for every value which changed, and returns the number of changed values. Keys keep
+// their expiry. No value is changed if any new value is rejected by its schema.
+func Transform(prefix string, transform func(string) string) (int, error) {
+	now := time.Now()
+
+	changes.RLock()
+	defer changes.RUnlock()
+	unlock := lockAllKeys()
+	defer unlock()
+
+	store.Lock()
+	events, err := transformLocked(prefix, transform, now)
+	store.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	var logErr error
+	for _, e := range events {
+		if err := logger.WriteEvent(e); err != nil && logErr == nil {
+			logErr = err
+		}
+	}
+
+	return len(events), logErr
+}
+
+// transformLocked applies transform for Transform, returning the puts logging the changed values. The caller
+// must hold the store's lock.
+func transformLocked(prefix string, transform func(string) string, now time.Time) ([]Event, error) {
+	// Every new value is encoded before any is stored, so that a rejected value changes nothing.
+	var changed []transformedValue
+	for key, stored := range store.m {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		// Skip keys which have expired but haven't been swept yet.
+		if expiresAt, ok := store.expiry[key]; ok && hasExpired(key, expiresAt, now) {
+			continue
+		}
+
+		value, err := storeEntry{key: key, value: stored, compressed: store.compressed[key]}.decode()
+		if err != nil {
+			return nil, err
+		}
+
+		newValue := transform(value.Value)
+		if newValue == value.Value {
+			continue
+		}
+
+		if err := checkPutLocked(key); err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+
+		if err := checkValueSize(newValue); err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+
+		if err := validateValue(key, newValue); err != nil {
+			return nil, err
+		}
+
+		newStored, compressed, err := encodeStored(newValue)
+		if err != nil {
+			return nil, err
+		}
+
+		changed = append(changed, transformedValue{key: key, value: newValue, stored: newStored, compressed: compressed})
+	}
+
+	events := make([]Event, 0, len(changed))
+	for _, v := range changed {
+		store.m[v.key] = v.stored
+		bumpVersionLocked(v.key)
+		if v.compressed {
+			store.compressed[v.key] = true
+		} else {
+			delete(store.compressed, v.key)
+		}
+		store.index.add(v.key, v.value)
+
+		// Values are logged verbatim, since trimming them would make the log diverge from the store.
+		events = append(events, Event{EventType: EventPut, Key: v.key, Value: v.stored, Expiry: unixNano(store.expiry[v.key]), Compressed: v.compressed, ContentType: store.contentType[v.key], Pinned: store.pinned[v.key], verbatim: true})
+	}
+
+	return events, nil
+
+}
+
+// TransformHandler is a handler function for the admin transform endpoint.
+func TransformHandler(rw http.ResponseWriter, r *http.Request) {
+	var body TransformBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	transform, err := body.transformFunc()
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	modified, err := Transform(normalizeKey(body.Prefix), transform)
+	var schemaErr *SchemaError
+	if errors.As(err, &schemaErr) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrorValueTooLarge) {
+		writeError(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, errKeyExists) {
+		writeError(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("transformed %d values of prefix \"%s\"\n", modified, body.Prefix)
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(struct {
+		Modified int `json:"modified"`
+	}{modified}); err != nil {
+		log.Println(err.Error())
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that transforming values only changes and logs the values of the prefix which differ.
+func TestTransform(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-transform.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	expiresAt := time.Now().Add(time.Hour)
+	PutWithExpiry("url:1", "http://yakv.dev", expiresAt)
+	Put("url:2", "https://yakv.dev")
+	Put("other", "http://yakv.dev")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/yakv/v0/admin/transform", strings.NewReader(`{"prefix": "url:", "op": "replace", "from": "http://", "to": "https://"}`)))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"modified":1}` {
+		t.Fatalf("Expected a single modified value, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	for key, expected := range map[string]string{"url:1": "https://yakv.dev", "url:2": "https://yakv.dev", "other": "http://yakv.dev"} {
+		if value, _ := Get(key); value != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, key, value)
+		}
+	}
+
+	// The transformed key keeps its expiry, and only the changed value is logged.
+	logger.Wait()
+	checkLastID(t, logger, 1)
+
+	store.RLock()
+	kept := store.expiry["url:1"].Equal(expiresAt)
+	store.RUnlock()
+	if !kept {
+		t.Error("Expected the transformed key to keep its expiry.")
+	}
+
+	// Replaying the log gives the transformed value.
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := Get("url:1"); value != "https://yakv.dev" {
+		t.Errorf("Expected the replayed value to be transformed, got %q", value)
+	}
+}
+
+// Function for testing the operations of transforms.
+func TestTransformOps(t *testing.T) {
+	ops := []struct {
+		body     TransformBody
+		expected string
+	}{
+		{TransformBody{Op: "replace", From: "a", To: "o"}, " yokv, yokv "},
+		{TransformBody{Op: "prefix", To: ">"}, "> yakv, yakv "},
+		{TransformBody{Op: "suffix", To: "<"}, " yakv, yakv <"},
+		{TransformBody{Op: "trim"}, "yakv, yakv"},
+	}
+
+	for _, op := range ops {
+		transform, err := op.body.transformFunc()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := transform(" yakv, yakv "); got != op.expected {
+			t.Errorf("Expected %q for %s, got %q", op.expected, op.body.Op, got)
+		}
+	}
+
+	for _, body := range []TransformBody{{Op: "replace"}, {Op: "eval"}} {
+		if _, err := body.transformFunc(); err == nil {
+			t.Errorf("Expected an error for %+v", body)
+		}
+	}
+}
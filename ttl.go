@@ -0,0 +1,303 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default interval for sweeping expired keys.
+const defaultExpirySweepInterval = time.Second
+
+// jitter randomizes the expiry of keys with -ttl-jitter. rand.Rand isn't safe for concurrent use, hence the lock.
+var jitter = struct {
+	sync.Mutex
+	rand *rand.Rand
+}{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// checkTTLJitter checks that the TTL jitter is a percentage below 100, so that keys never expire right away.
+func checkTTLJitter() error {
+	if config.ttlJitter < 0 || config.ttlJitter >= 100 {
+		return fmt.Errorf("-ttl-jitter must be at least 0 and below 100, got %v", config.ttlJitter)
+	}
+
+	return nil
+}
+
+// errNegativeTTL is raised when a request sets a negative TTL.
+var errNegativeTTL = errors.New("ttl_seconds must not be negative")
+
+// requestExpiry returns when a key written now with the optional ttl_seconds of a request expires. Without
+// ttl_seconds, the key gets the default TTL of its prefix, and a TTL of zero means it never expires.
+func requestExpiry(key string, ttlSeconds *int64) (time.Time, error) {
+	switch {
+	case ttlSeconds == nil:
+		return defaultExpiry(key, time.Now()), nil
+	case *ttlSeconds < 0:
+		return time.Time{}, errNegativeTTL
+	case *ttlSeconds > 0:
+		return expiryAfter(time.Now(), time.Duration(*ttlSeconds)*time.Second), nil
+	}
+
+	return time.Time{}, nil
+}
+
+// expiryAfter returns when a key written at now with the given TTL expires. With -ttl-jitter, the TTL is
+// randomly shortened or lengthened by up to that percentage, so that keys written together don't all expire
+// together. The jittered expiry is what gets logged, so replaying the log doesn't jitter it again.
+func expiryAfter(now time.Time, ttl time.Duration) time.Time {
+	if config.ttlJitter > 0 {
+		jitter.Lock()
+		factor := 1 + config.ttlJitter/100*(2*jitter.rand.Float64()-1)
+		jitter.Unlock()
+
+		ttl = time.Duration(float64(ttl) * factor)
+	}
+
+	return now.Add(ttl)
+}
+
+// expiryTime converts an event's expiry in Unix nanoseconds to a time, zero meaning no expiry.
+func expiryTime(expiry int64) time.Time {
+	if expiry == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, expiry)
+}
+
+// hasExpired returns whether key, which expires at expiresAt, has expired by now. Keys under a protected prefix
+// never expire, whatever their expiry.
+func hasExpired(key string, expiresAt, now time.Time) bool {
+	return !now.Before(expiresAt) && !isProtected(key)
+}
+
+// Keys under a protected prefix which the sweeper warned about, so that they're only reported once. Accessed
+// under the store's lock.
+var protectedWarned = make(map[string]bool)
+
+// unixNano converts an expiry time to Unix nanoseconds for an event, zero meaning no expiry.
+func unixNano(expiresAt time.Time) int64 {
+	if expiresAt.IsZero() {
+		return 0
+	}
+
+	return expiresAt.UnixNano()
+}
+
+// Touch sets the expiry of an existing key without changing its value. A zero expiresAt means the key never expires.
+// Keys which have expired can't be touched anymore.
+func Touch(key string, expiresAt time.Time) error {
+	return touchLogged(key, expiresAt, nil)
+}
+
+// touchLogged is Touch calling logged, unless it's nil, to write the touch to the transaction log under the
+// stripe of the key, see putVersioned.
+func touchLogged(key string, expiresAt time.Time, logged func()) error {
+	key = normalizeKey(key)
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	return touch(key, expiresAt, time.Now(), logged)
+}
+
+// touch sets the expiry of key if it exists and hasn't expired by now. A zero now skips the check for expiry.
+// Unless it's nil, logged is called to write the touch to the transaction log like touchLogged.
+func touch(key string, expiresAt time.Time, now time.Time, logged func()) error {
+	if logged != nil {
+		stripe := keyLock(key)
+		stripe.Lock()
+		defer stripe.Unlock()
+	}
+
+	start := time.Now()
+	store.Lock()
+	locked := time.Now()
+	err := touchLocked(key, expiresAt, now)
+	store.Unlock()
+	recordLockedLatency("touch", time.Since(start), time.Since(locked))
+
+	if err == nil && logged != nil {
+		logged()
+	}
+
+	return err
+}
+
+// touchLocked sets the expiry of key for touch. The caller must hold the store's lock.
+func touchLocked(key string, expiresAt time.Time, now time.Time) error {
+	if _, ok := store.m[key]; !ok {
+		return ErrorNoSuchKey
+	}
+	if current, ok := store.expiry[key]; ok && !now.IsZero() && hasExpired(key, current, now) {
+		return ErrorNoSuchKey
+	}
+
+	if expiresAt.IsZero() {
+		delete(store.expiry, key)
+	} else {
+		store.expiry[key] = expiresAt
+	}
+
+	return nil
+}
+
+// replayTouch applies a touch read from the transaction log. The key was alive when it was touched,
+// even if its previous expiry has passed by the time the log is replayed.
+func replayTouch(e Event) error {
+	if err := touch(e.Key, expiryTime(e.Expiry), time.Time{}, nil); err != nil && !errors.Is(err, ErrorNoSuchKey) {
+		return err
+	}
+
+	return nil
+}
+
+// TouchHandler is a handler function for the TOUCH endpoint, refreshing the expiry of a key.
+func TouchHandler(rw http.ResponseWriter, r *http.Request) {
+	var body TouchBody
+
+	// Use custom JSON decoder
+	decodeErr := DecodeJSONBody(rw, r, &body)
+	defer r.Body.Close()
+
+	if decodeErr != nil {
+		var mr *malformedRequest
+
+		// Match errors with malformed requests
+		if errors.As(decodeErr, &mr) {
+			writeError(rw, mr.msg, mr.status)
+		} else {
+			log.Println(decodeErr.Error())
+			writeError(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if body.TTLSeconds < 0 {
+		writeError(rw, "ttl_seconds must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	// A TTL of zero clears the expiry.
+	var expiresAt time.Time
+	if body.TTLSeconds > 0 {
+		expiresAt = expiryAfter(time.Now(), time.Duration(body.TTLSeconds)*time.Second)
+	}
+
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := decodeKey(binary, body.Key)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Calls touchLogged for setting the expiry, writing the TOUCH event to the log.
+	changes.RLock()
+	defer changes.RUnlock()
+	var logErr error
+	err = touchLogged(key, expiresAt, func() {
+		logErr = logger.WriteEvent(Event{EventType: EventTouch, Key: key, Expiry: unixNano(expiresAt)})
+	})
+	if err == nil {
+		err = logErr
+	}
+	if errors.Is(err, ErrorEmptyKey) {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errors.Is(err, ErrorNoSuchKey) {
+		writeError(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// sweepExpired deletes all keys which have expired by now, and returns the deleted keys. logged, unless it's nil,
+// is called for every deleted key to write its delete to the transaction log while the store's lock is still held.
+// Keys being written are left for the next sweep, see keyBeingWritten.
+func sweepExpired(now time.Time, logged func(key string)) []string {
+	var expired []string
+
+	store.Lock()
+	for key, expiresAt := range store.expiry {
+		if now.Before(expiresAt) {
+			continue
+		}
+
+		// Keys under a protected prefix are kept, in case their expiry was set by mistake.
+		if isProtected(key) {
+			if !protectedWarned[key] {
+				log.Printf("not sweeping key %q, which expired at %v but is under a protected prefix", key, expiresAt)
+				protectedWarned[key] = true
+			}
+			continue
+		}
+		if keyBeingWritten(key, "") {
+			continue
+		}
+
+		removeLocked(key)
+		expired = append(expired, key)
+		if logged != nil {
+			logged(key)
+		}
+	}
+	store.Unlock()
+
+	return expired
+}
+
+// runExpirySweeper periodically sweeps expired keys until the context is done.
+// A DELETE event is written to the log for every swept key, so replaying the log doesn't resurrect it.
+func runExpirySweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Sweeping writes to the store, which is paused along with every other write.
+			if isWritesPaused() {
+				continue
+			}
+
+			changes.RLock()
+			for _, key := range sweepExpired(now, func(key string) { logger.WriteEvent(Event{EventType: EventDelete, Key: key, queued: true}) }) {
+				fmt.Println("expired key:", key)
+			}
+			changes.RUnlock()
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that an expired key is treated as missing before it is swept.
+func TestGetLazyExpiry(t *testing.T) {
+	// Sample data
+	const key = "yakv-expired"
+
+	// Restore to original state after test.
+	defer Delete(key)
+
+	if err := PutWithExpiry(key, "hello, yakv!", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Get(key); !errors.Is(err, ErrorNoSuchKey) {
+		t.Error("Expected an expired key to be missing, got", err)
+	}
+}
+
+// Function for testing that the sweeper removes expired keys from the store.
+func TestExpirySweeper(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-sweeper.log"
+
+	// Sample data
+	const key = "yakv-ttl"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer Delete(key)
+
+	var err error
+	logger, err = NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Log()
+	defer logger.Close()
+
+	// Keys without a TTL are left alone.
+	if err := Put("yakv", "hello, yakv!"); err != nil {
+		t.Fatal(err)
+	}
+	defer Delete("yakv")
+
+	if err := PutWithExpiry(key, "hello, yakv!", time.Now().Add(20*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sweeper uses the store, so it has to stop before the store is reset.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runExpirySweeper(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	store.RLock()
+	_, swept := store.m[key]
+	_, kept := store.m["yakv"]
+	store.RUnlock()
+
+	if swept {
+		t.Error("Expired key wasn't swept from the store.")
+	}
+	if !kept {
+		t.Error("Key without a TTL was swept from the store.")
+	}
+
+	// The sweep must have been logged as a DELETE.
+	logger.Wait()
+	checkLastID(t, logger, 1)
+}
+
+// Function for testing that keys under a protected prefix are neither swept nor treated as missing once they expire.
+func TestProtectedPrefixes(t *testing.T) {
+	// Restore to original state after test.
+	defer resetStores()
+	defer setProtectedPrefixes(nil)
+	resetStores()
+	setProtectedPrefixes([]string{"config:"})
+
+	expired := time.Now().Add(-time.Second)
+	for _, key := range []string{"config:site", "tmp:upload"} {
+		if err := PutWithExpiry(key, "hello, yakv!", expired); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the unprotected key is swept.
+	if swept := sweepExpired(time.Now(), nil); len(swept) != 1 || swept[0] != "tmp:upload" {
+		t.Errorf("Expected only tmp:upload to be swept, got %v", swept)
+	}
+
+	// The protected key is still served and listed, and keeps its expiry.
+	if value, err := Get("config:site"); err != nil || value != "hello, yakv!" {
+		t.Errorf("Expected the protected key to be served, got %q %v", value, err)
+	}
+	if keys, _ := Keys("", "", defaultKeysLimit); len(keys) != 1 || keys[0] != "config:site" {
+		t.Errorf("Expected the protected key to be listed, got %v", keys)
+	}
+
+	store.RLock()
+	_, expires := store.expiry["config:site"]
+	store.RUnlock()
+	if !expires {
+		t.Error("Expected the protected key to keep its expiry.")
+	}
+
+	// The sweeper remembers warning about the protected key, so that it only warns once.
+	store.RLock()
+	warned := protectedWarned["config:site"]
+	store.RUnlock()
+	if !warned {
+		t.Error("Expected the sweeper to warn about the protected key.")
+	}
+}
+
+// Function for testing that touching a key only changes its expiry.
+func TestTouch(t *testing.T) {
+	// Sample data
+	const key = "yakv-touch"
+
+	// Restore to original state after test.
+	defer Delete(key)
+
+	if err := PutWithExpiry(key, "hello, yakv!", time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A zero expiry clears the TTL.
+	if err := Touch(key, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	store.RLock()
+	_, expires := store.expiry[key]
+	value := store.m[key]
+	store.RUnlock()
+	if expires || value != "hello, yakv!" {
+		t.Errorf("Expected the value without an expiry, got %q with expiry %t", value, expires)
+	}
+
+	// Expired and missing keys can't be touched.
+	if err := PutWithExpiry(key, "hello, yakv!", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Touch(key, time.Now().Add(time.Hour)); !errors.Is(err, ErrorNoSuchKey) {
+		t.Error("Expected an expired key to be missing, got", err)
+	}
+	if err := Touch("yakv-missing", time.Time{}); !errors.Is(err, ErrorNoSuchKey) {
+		t.Error("Expected a missing key to be missing, got", err)
+	}
+}
+
+// Function for testing that a touch is replayed even after the expiry it replaced has passed.
+func TestTouchReplay(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-touch.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	defer func() { config.collapseReplay = false }()
+
+	transactionLogger, err := NewFileTransactionLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactionLogger.Log()
+	transactionLogger.WriteEvent(Event{EventType: EventPut, Key: "yakv", Value: "hello, yakv!", Expiry: time.Now().Add(-time.Minute).UnixNano()})
+	transactionLogger.WriteEvent(Event{EventType: EventTouch, Key: "yakv", Expiry: time.Now().Add(time.Hour).UnixNano()})
+	transactionLogger.Close()
+
+	for _, collapse := range []bool{false, true} {
+		resetStores()
+		config.collapseReplay = collapse
+		if err := InitLog(filename); err != nil {
+			t.Fatal(err)
+		}
+		logger.Close()
+
+		if val, err := Get("yakv"); err != nil || val != "hello, yakv!" {
+			t.Errorf("Expected the touched key to be alive (collapsed: %t), got %q %v", collapse, val, err)
+		}
+	}
+}
+
+// Function for testing that the TTL jitter spreads expiries within the jitter percentage.
+func TestTTLJitter(t *testing.T) {
+	// Restore to original state after test.
+	defer func(jitter float64) { config.ttlJitter = jitter }(config.ttlJitter)
+	config.ttlJitter = 20
+
+	now := time.Now()
+	shorter, longer := false, false
+	for i := 0; i < 200; i++ {
+		ttl := expiryAfter(now, 100*time.Second).Sub(now)
+		if ttl < 80*time.Second || ttl > 120*time.Second {
+			t.Fatalf("Expected a TTL within 20%% of 100s, got %v", ttl)
+		}
+		shorter = shorter || ttl < 100*time.Second
+		longer = longer || ttl > 100*time.Second
+	}
+	if !shorter || !longer {
+		t.Errorf("Expected TTLs to be both shortened and lengthened, got shorter: %t, longer: %t", shorter, longer)
+	}
+
+	// Without jitter, TTLs are applied exactly.
+	config.ttlJitter = 0
+	if ttl := expiryAfter(now, 100*time.Second).Sub(now); ttl != 100*time.Second {
+		t.Errorf("Expected a TTL of 100s without jitter, got %v", ttl)
+	}
+}
+
+// Function for testing that replaying the log restores the jittered expiry instead of jittering again.
+func TestTTLJitterReplay(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-ttl-jitter.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(jitter float64) { config.ttlJitter = jitter }(config.ttlJitter)
+	config.ttlJitter = 50
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/yakv/v0/put", strings.NewReader(`{"key": "session", "value": "hello, yakv!", "ttl_seconds": 3600}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rec.Code)
+	}
+	expiresAt := store.expiry["session"]
+
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if !store.expiry["session"].Equal(expiresAt) {
+		t.Errorf("Expected the replayed expiry to be %v, got %v", expiresAt, store.expiry["session"])
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// valueIndex maps values to the keys holding them, for exact-match lookups by value.
+// It isn't safe for concurrent use, the store's lock guards it.
+type valueIndex struct {
+	keys   map[string]map[string]bool // Keys holding each value.
+	values map[string]string          // Uncompressed value of each indexed key.
+}
+
+// newValueIndex creates an empty value index.
+func newValueIndex() *valueIndex {
+	return &valueIndex{keys: make(map[string]map[string]bool), values: make(map[string]string)}
+}
+
+// add indexes the key under its uncompressed value, replacing its previous value. A nil index does nothing.
+func (ix *valueIndex) add(key, value string) {
+	if ix == nil {
+		return
+	}
+
+	ix.remove(key)
+
+	if ix.keys[value] == nil {
+		ix.keys[value] = make(map[string]bool)
+	}
+	ix.keys[value][key] = true
+	ix.values[key] = value
+}
+
+// remove removes the key from the index. A nil index does nothing.
+func (ix *valueIndex) remove(key string) {
+	if ix == nil {
+		return
+	}
+
+	value, ok := ix.values[key]
+	if !ok {
+		return
+	}
+
+	delete(ix.values, key)
+	delete(ix.keys[value], key)
+	if len(ix.keys[value]) == 0 {
+		delete(ix.keys, value)
+	}
+}
+
+// reset removes every key from the index. A nil index does nothing.
+func (ix *valueIndex) reset() {
+	if ix == nil {
+		return
+	}
+
+	ix.keys = make(map[string]map[string]bool)
+	ix.values = make(map[string]string)
+}
+
+// ErrorValueIndexDisabled is returned when looking up keys by value without -enable-value-index.
+var ErrorValueIndexDisabled = errors.New("the value index is disabled, see -enable-value-index")
+
+// Find returns the keys of the default store whose value is exactly value, in sorted order.
+func Find(value string) ([]string, error) {
+	// The index is only set up on start-up, so it can be checked without the lock.
+	if store.index == nil {
+		return nil, ErrorValueIndexDisabled
+	}
+
+	now := time.Now()
+
+	store.RLock()
+	keys := make([]string, 0, len(store.index.keys[value]))
+	for key := range store.index.keys[value] {
+		// Skip keys which have expired but haven't been swept yet.
+		if expiresAt, ok := store.expiry[key]; ok && hasExpired(key, expiresAt, now) {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+	store.RUnlock()
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// FindHandler is a handler function for the endpoint looking up keys by value.
+func FindHandler(rw http.ResponseWriter, r *http.Request) {
+	binary, err := wireEncoding(r)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value, err := decodeWire(binary, r.URL.Query().Get("value"))
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	keys, err := Find(value)
+	if errors.Is(err, ErrorValueIndexDisabled) {
+		writeError(rw, err.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	for i := range keys {
+		keys[i] = encodeWire(binary, keys[i])
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(struct {
+		Keys []string `json:"keys"`
+	}{keys}); err != nil {
+		log.Println(err.Error())
+	}
+}
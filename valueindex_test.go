@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Helper function for checking the keys found for a value.
+func checkFind(t *testing.T, value string, expected []string) {
+	t.Helper()
+
+	keys, err := Find(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected %v for %q, got %v", expected, value, keys)
+	}
+}
+
+// Function for testing that the value index follows puts, deletes and replays.
+func TestValueIndex(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-value-index.log")()
+	defer resetStores()
+
+	// Compress long values, which are indexed uncompressed.
+	config.compressThreshold = 64
+	defer func() { config.compressThreshold = 0 }()
+
+	resetStores()
+	store.index = newValueIndex()
+
+	long := strings.Repeat("yakv", 32)
+	for key, value := range map[string]string{"a": "red", "b": "red", "c": "blue", "d": long} {
+		if err := Put(key, value); err != nil {
+			t.Fatal(err)
+		}
+		logger.WritePut(key, value)
+	}
+
+	checkFind(t, "red", []string{"a", "b"})
+	checkFind(t, long, []string{"d"})
+	checkFind(t, "re", []string{})
+
+	// Overwriting a key moves it to its new value.
+	if err := Put("b", "blue"); err != nil {
+		t.Fatal(err)
+	}
+	logger.WritePut("b", "blue")
+
+	if err := Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	logger.WriteDelete("a")
+
+	checkFind(t, "red", []string{})
+	checkFind(t, "blue", []string{"b", "c"})
+
+	// Replaying the log rebuilds the same index.
+	logger.Close()
+	resetStores()
+	store.index = newValueIndex()
+	if err := InitLog("temp-value-index.log"); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFind(t, "red", []string{})
+	checkFind(t, "blue", []string{"b", "c"})
+	checkFind(t, long, []string{"d"})
+}
+
+// Function for testing the find endpoint.
+func TestFindHandler(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-find.log")()
+	defer resetStores()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	// Without the index, lookups aren't supported.
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/find?value=red", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without the value index, got %d", rec.Code)
+	}
+
+	store.index = newValueIndex()
+	if err := Put("yakv", "red"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/yakv/v0/find?value=red", nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"keys":["yakv"]}` {
+		t.Errorf("Expected the key holding the value, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Filename of the transaction log opened by InitLog.
+var transactionLogFilename string
+
+// LogDivergence lists the keys of the default store whose state differs between the store and the transaction log.
+type LogDivergence struct {
+	Consistent       bool     `json:"consistent"`
+	Events           int      `json:"events"`
+	MissingFromLog   []string `json:"missing_from_log"`   // Keys in the store, but not in the replayed log.
+	MissingFromStore []string `json:"missing_from_store"` // Keys in the replayed log, but not in the store.
+	Differing        []string `json:"differing"`          // Keys with different values.
+}
+
+// replayState replays the events of the default store into a map from keys to values, and returns it along
+// with the number of events read. Keys which have expired by now are left out, as they are from listings.
+// Values are only decoded once replayed, since the spill files of overwritten values are gone.
+func replayState(events <-chan Event, errors <-chan error, now time.Time) (map[string]string, int, error) {
+	values := make(map[string]string)
+	expiry := make(map[string]time.Time)
+	compressed := make(map[string]bool)
+	n := 0
+
+	for e := range events {
+		n++
+
+		// Namespaces and the other databases aren't part of the default store.
+		if e.Namespace != "" || e.Database != 0 || e.EventType == EventDropNamespace {
+			continue
+		}
+
+		switch e.EventType {
+		case EventPut:
+			values[e.Key] = e.Value
+			expiry[e.Key] = expiryTime(e.Expiry)
+			compressed[e.Key] = e.Compressed
+		case EventDelete:
+			delete(values, e.Key)
+			delete(expiry, e.Key)
+			delete(compressed, e.Key)
+		case EventTouch:
+			if _, ok := values[e.Key]; ok {
+				expiry[e.Key] = expiryTime(e.Expiry)
+			}
+		}
+	}
+
+	if err := <-errors; err != nil {
+		return nil, n, err
+	}
+
+	for key, expiresAt := range expiry {
+		if !expiresAt.IsZero() && hasExpired(key, expiresAt, now) {
+			delete(values, key)
+		}
+	}
+
+	for key, value := range values {
+		if !compressed[key] {
+			continue
+		}
+
+		var err error
+		if values[key], err = decompressValue(value); err != nil {
+			return nil, n, err
+		}
+	}
+
+	return values, n, nil
+}
+
+// VerifyLog replays the transaction log into a temporary state and compares it against the default store.
+// Writes which are in flight while verifying can show up as divergences, so it is best run on a quiet server.
+func VerifyLog() (LogDivergence, error) {
+	var d LogDivergence
+
+	// Pending events have to be in the file before reading it.
+	logger.Wait()
+
+	// The BoltDB file is already open, and can be read while it's written.
+	reader := logger
+	if config.backend != boltBackend {
+		var err error
+		if reader, err = NewTransactionLogger(transactionLogFilename); err != nil {
+			return d, err
+		}
+		defer reader.Close()
+	}
+
+	events, errors := reader.ReadEvents()
+	replayed, n, err := replayState(events, errors, time.Now())
+	if err != nil {
+		return d, err
+	}
+	d.Events = n
+
+	live := make(map[string]string)
+	entries, _ := copyEntries("", "", 0)
+	for _, e := range entries {
+		item, err := e.decode()
+		if err != nil {
+			return d, err
+		}
+		live[item.Key] = item.Value
+	}
+
+	d.MissingFromLog, d.MissingFromStore, d.Differing = []string{}, []string{}, []string{}
+	for key, value := range live {
+		replayedValue, ok := replayed[key]
+		switch {
+		case !ok:
+			d.MissingFromLog = append(d.MissingFromLog, key)
+		case replayedValue != value:
+			d.Differing = append(d.Differing, key)
+		}
+	}
+	for key := range replayed {
+		if _, ok := live[key]; !ok {
+			d.MissingFromStore = append(d.MissingFromStore, key)
+		}
+	}
+
+	sort.Strings(d.MissingFromLog)
+	sort.Strings(d.MissingFromStore)
+	sort.Strings(d.Differing)
+	d.Consistent = len(d.MissingFromLog)+len(d.MissingFromStore)+len(d.Differing) == 0
+
+	return d, nil
+}
+
+// VerifyLogHandler is a handler function for the admin endpoint verifying the transaction log against the store.
+func VerifyLogHandler(rw http.ResponseWriter, r *http.Request) {
+	d, err := VerifyLog()
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !d.Consistent {
+		log.Printf("transaction log diverged from the store: %d keys missing from the log, %d missing from the store, %d differing", len(d.MissingFromLog), len(d.MissingFromStore), len(d.Differing))
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(d); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// Function for testing that the verification reports keys which drifted from the transaction log.
+func TestVerifyLog(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-verify.log"
+
+	// Restore to original state after test.
+	defer os.Remove(filename)
+	defer resetStores()
+	resetStores()
+
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	for _, key := range []string{"yakv1", "yakv2", "yakv3"} {
+		Put(key, "value")
+		logger.WritePut(key, "value")
+	}
+	Delete("yakv3")
+	logger.WriteDelete("yakv3")
+
+	d, err := VerifyLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Consistent || d.Events != 4 {
+		t.Errorf("Expected a consistent store after 4 events, got %+v", d)
+	}
+
+	// Drift the store away from the log without logging it.
+	Put("yakv1", "changed")
+	Delete("yakv2")
+	Put("yakv4", "value")
+
+	d, err = VerifyLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := LogDivergence{
+		Events:           4,
+		MissingFromLog:   []string{"yakv4"},
+		MissingFromStore: []string{"yakv2"},
+		Differing:        []string{"yakv1"},
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, d)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "sort"
+
+// keyVersions holds the latest events of a key, from the oldest of its kept versions on.
+type keyVersions struct {
+	events   []Event
+	versions int
+	version  uint64 // Version of the key after the last event, see bumpVersionLocked.
+}
+
+// add appends an event to the events of a key, dropping the oldest version along with the touches following
+// it once more than limit versions are kept. Puts, deletes and drops of the namespace are versions, touches
+// only refresh the expiry of the version before them.
+func (h *keyVersions) add(e Event, limit int) {
+	switch e.EventType {
+	case EventPut:
+		h.version = eventVersion(e, h.version)
+		e.Version = h.version
+	case EventDelete, EventDropNamespace:
+		h.version = 0
+	}
+
+	h.events = append(h.events, e)
+	if e.EventType == EventTouch {
+		return
+	}
+
+	if h.versions++; h.versions <= limit {
+		return
+	}
+
+	i := 1
+	for i < len(h.events) && h.events[i].EventType == EventTouch {
+		i++
+	}
+	h.events = append(h.events[:0:0], h.events[i:]...)
+	h.versions--
+}
+
+// keyStore identifies the store a key belongs to, the default store, a namespace or a numbered database.
+type keyStore struct {
+	database  int
+	namespace string
+}
+
+// versionReader reads the latest versions of every key from the transaction log, see versionEvents.
+type versionReader struct {
+	reader TransactionLogger
+	limit  int
+	keys   map[keyStore]map[string]*keyVersions
+}
+
+// newVersionReader returns a reader keeping up to limit of the latest versions of every key, which has read the
+// transaction log up to its end as of now. Writes are only held back while the end is recorded, not while the
+// log is read up to it. A sharded log is only read by versionEvents.
+func newVersionReader(limit int) (*versionReader, error) {
+	reader, err := NewTransactionLogger(transactionLogFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &versionReader{reader: reader, limit: limit, keys: make(map[keyStore]map[string]*keyVersions)}
+
+	ftl, ok := reader.(*FileTransactionLogger)
+	if !ok {
+		return v, nil
+	}
+
+	// Once every event has been written, the log ends with a complete line.
+	changes.Lock()
+	logger.Wait()
+	info, err := ftl.file.Stat()
+	changes.Unlock()
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	ftl.readEnd = info.Size()
+	err = v.read()
+	ftl.readEnd = 0
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// read reads the events of the transaction log which haven't been read yet.
+func (v *versionReader) read() error {
+	events, errs := v.reader.ReadEvents()
+	for e := range events {
+		namespace := v.keys[keyStore{e.Database, e.Namespace}]
+		if namespace == nil {
+			namespace = make(map[string]*keyVersions)
+			v.keys[keyStore{e.Database, e.Namespace}] = namespace
+		}
+
+		if e.EventType == EventDropNamespace {
+			for _, h := range namespace {
+				h.add(e, v.limit)
+			}
+			continue
+		}
+
+		h := namespace[e.Key]
+		if h == nil {
+			h = &keyVersions{}
+			namespace[e.Key] = h
+		}
+		h.add(e, v.limit)
+	}
+
+	return <-errs
+}
+
+// Close closes the transaction log.
+func (v *versionReader) Close() error {
+	return v.reader.Close()
+}
+
+// versionEvents catches up on the events written since the reader was created and returns the events keeping
+// up to limit of the latest versions of every key, in their original order, along with the number of keys they
+// belong to. A drop of a namespace is a version of every key in the namespace before it, and a deleted key keeps
+// its delete, so that replaying the events restores the store as well as the latest history of its keys. The
+// caller must hold the changes lock, and every event must have been written.
+func (v *versionReader) versionEvents() ([]Event, int, error) {
+	if err := v.read(); err != nil {
+		return nil, 0, err
+	}
+
+	n := 0
+	var kept []Event
+	for _, namespace := range v.keys {
+		for _, h := range namespace {
+			for i, e := range h.events {
+				// Only the oldest put of a key needs the version which the puts compacted away left it at,
+				// replaying counts the versions of the puts after it.
+				if e.EventType == EventPut && (i > 0 || e.Namespace != "" || e.Database != 0 || e.Version == 1) {
+					e.Version = 0
+				}
+				kept = append(kept, e)
+			}
+			n++
+		}
+	}
+
+	// A drop of a namespace is kept once, even when it's a version of several keys.
+	sort.Slice(kept, func(i, j int) bool { return kept[i].ID < kept[j].ID })
+	versions := kept[:0]
+	for _, e := range kept {
+		if len(versions) > 0 && e.ID == versions[len(versions)-1].ID {
+			continue
+		}
+
+		// Values were read as they were written, so they are not trimmed again.
+		e.verbatim = true
+		versions = append(versions, e)
+	}
+
+	return versions, n, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that compacting the log with -keep-versions keeps the latest versions of every key,
+// shrinks the log, and still replays to the same store.
+func TestKeepVersions(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-keep-versions.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(filename string) { transactionLogFilename = filename }(transactionLogFilename)
+	transactionLogFilename = filename
+	defer func(n int) { config.keepVersions = n }(config.keepVersions)
+	config.keepVersions = 2
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	serve := func(method, path, body string) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if rec.Code >= http.StatusBadRequest {
+			t.Fatalf("Unexpected %d for %s %s", rec.Code, method, path)
+		}
+	}
+
+	// A hot key, a deleted key, a touched key, and keys of a namespace which was dropped.
+	for i := 1; i <= 5; i++ {
+		serve(http.MethodPut, "/yakv/v0/put", fmt.Sprintf(`{"key": "hot", "value": "v%d"}`, i))
+	}
+	for i := 1; i <= 3; i++ {
+		serve(http.MethodPut, "/yakv/v0/put", fmt.Sprintf(`{"key": "deleted", "value": "v%d"}`, i))
+	}
+	serve(http.MethodDelete, "/yakv/v0/delete", `{"key": "deleted"}`)
+	serve(http.MethodPut, "/yakv/v0/put", `{"key": "touched", "value": "v1"}`)
+	serve(http.MethodPost, "/yakv/v0/touch", `{"key": "touched", "ttl_seconds": 3600}`)
+	serve(http.MethodPost, "/yakv/v0/touch", `{"key": "touched", "ttl_seconds": 7200}`)
+	serve(http.MethodPut, "/yakv/v0/ns/users/keys/1", `{"value": "alice"}`)
+	serve(http.MethodPut, "/yakv/v0/ns/users/keys/2", `{"value": "bob"}`)
+	serve(http.MethodDelete, "/yakv/v0/ns/users", "")
+	serve(http.MethodPut, "/yakv/v0/ns/users/keys/1", `{"value": "carol"}`)
+	logger.Wait()
+
+	before, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// hot: v4, v5. deleted: v3 and the delete. touched: the put and both touches.
+	// users: the put of bob, the drop and the put of carol.
+	if result.Keys != 5 || result.Events != 10 || result.LastID != 16+10 {
+		t.Errorf("Expected 10 transactions of 5 keys up to ID 26, got %+v", result)
+	}
+	if result.Size >= before.Size() {
+		t.Errorf("Expected the log to shrink from %d bytes, got %d bytes", before.Size(), result.Size)
+	}
+
+	// version is an event of the history of a key, without its ID which was rewritten.
+	type version struct {
+		EventType EventType
+		Value     string
+	}
+
+	versions := func(namespace, key string) []version {
+		history, _, err := History(namespace, key, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		versions := make([]version, 0, len(history))
+		for _, e := range history {
+			versions = append(versions, version{e.EventType, e.Value})
+		}
+		return versions
+	}
+
+	tests := []struct {
+		namespace, key string
+		history        []version
+	}{
+		{"", "hot", []version{{EventPut, "v4"}, {EventPut, "v5"}}},
+		{"", "deleted", []version{{EventPut, "v3"}, {EventDelete, ""}}},
+		{"", "touched", []version{{EventPut, "v1"}, {EventTouch, ""}, {EventTouch, ""}}},
+		{"users", "1", []version{{EventDropNamespace, ""}, {EventPut, "carol"}}},
+		{"users", "2", []version{{EventPut, "bob"}, {EventDropNamespace, ""}}},
+	}
+	for _, test := range tests {
+		if actual := versions(test.namespace, test.key); !reflect.DeepEqual(actual, test.history) {
+			t.Errorf("Expected the history %v of %s/%s, got %v", test.history, test.namespace, test.key, actual)
+		}
+	}
+
+	// Replaying the compacted log restores the store, expiries included.
+	expected := snapshotStores()
+	expiry := store.expiry["touched"]
+	logger.Close()
+	resetStores()
+	if err := InitLog(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := snapshotStores(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v after replaying the compacted log, got %v", expected, actual)
+	}
+	if !store.expiry["touched"].Equal(expiry) {
+		t.Errorf("Expected the expiry %v to survive the compaction, got %v", expiry, store.expiry["touched"])
+	}
+}
+
+// Function for testing that the versions read before writes are held back catch up on the events written after.
+func TestVersionReaderCatchUp(t *testing.T) {
+	// Temporary log filename.
+	const filename = "temp-version-reader.log"
+
+	// Restore to original state after test.
+	defer useTempLogger(t, filename)()
+	defer resetStores()
+	defer func(filename string) { transactionLogFilename = filename }(transactionLogFilename)
+	transactionLogFilename = filename
+
+	for i := 1; i <= 3; i++ {
+		if err := logger.WritePut("hot", fmt.Sprintf("v%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger.Wait()
+
+	versions, err := newVersionReader(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer versions.Close()
+	if h := versions.keys[keyStore{}]["hot"]; h == nil || len(h.events) != 2 {
+		t.Fatalf("Expected the two latest versions of hot to be read before writes are held back, got %+v", h)
+	}
+
+	// Writes after the log was read, one of them to a new key.
+	for _, key := range []string{"hot", "new"} {
+		if err := logger.WritePut(key, "v4"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes.Lock()
+	logger.Wait()
+	events, keys, err := versions.versionEvents()
+	changes.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []string
+	for _, e := range events {
+		values = append(values, e.Key+"="+e.Value)
+	}
+	if expected := []string{"hot=v3", "hot=v4", "new=v4"}; keys != 2 || !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v of 2 keys, got %v of %d keys", expected, values, keys)
+	}
+}
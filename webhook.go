@@ -0,0 +1,169 @@
+// Copyright 2021 Aadhav Vignesh
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum number of webhook deliveries waiting to be sent. Changes past it are dropped.
+const webhookQueueSize = 1024
+
+// Maximum number of attempts to deliver a change to a webhook.
+const webhookMaxAttempts = 5
+
+// Delay before the first retry of a failed delivery, doubled after every attempt.
+var webhookRetryDelay = time.Second
+
+// Client used for delivering changes to webhooks.
+var webhookClient = &http.Client{Timeout: 5 * time.Second}
+
+// webhookTarget is a URL notified of the changes to the keys starting with prefix.
+type webhookTarget struct {
+	URL    string `json:"url"`
+	Prefix string `json:"prefix"`
+}
+
+// WebhookEvent is the change of a key, as it is posted to webhooks.
+type WebhookEvent struct {
+	Type  string `json:"type"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// webhookDelivery is a change waiting to be delivered to a webhook. The value is decompressed
+// when the change is delivered, so that the write path only has to queue it.
+type webhookDelivery struct {
+	url   string
+	event Event
+}
+
+// Configured webhooks, along with the queue of deliveries.
+var webhooks = struct {
+	sync.RWMutex
+	targets []webhookTarget
+	queue   chan webhookDelivery
+}{queue: make(chan webhookDelivery, webhookQueueSize)}
+
+// setWebhooks replaces the configured webhooks.
+func setWebhooks(targets []webhookTarget) {
+	webhooks.Lock()
+	webhooks.targets = targets
+	webhooks.Unlock()
+}
+
+// notifyWebhooks queues a logged event for the webhooks whose prefix matches its key. Only puts and
+// deletes of the default store are delivered. It never blocks: when the queue is full, the change is
+// dropped for that webhook and a warning is logged.
+func notifyWebhooks(e Event) {
+	if e.Namespace != "" || e.Database != 0 || (e.EventType != EventPut && e.EventType != EventDelete) {
+		return
+	}
+
+	webhooks.RLock()
+	defer webhooks.RUnlock()
+
+	for _, target := range webhooks.targets {
+		if !strings.HasPrefix(e.Key, target.Prefix) {
+			continue
+		}
+
+		select {
+		case webhooks.queue <- webhookDelivery{url: target.URL, event: e}:
+		default:
+			log.Printf("Webhook queue is full, dropping change of key %q for %s", e.Key, target.URL)
+		}
+	}
+}
+
+// payload returns the JSON body posted for the delivery.
+func (d webhookDelivery) payload() ([]byte, error) {
+	event := WebhookEvent{Type: "delete", Key: d.event.Key}
+
+	if d.event.EventType == EventPut {
+		event.Type = "put"
+		event.Value = d.event.Value
+
+		if d.event.Compressed {
+			var err error
+			if event.Value, err = decompressValue(d.event.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return json.Marshal(event)
+}
+
+// deliver posts the change to the webhook, retrying with an exponential backoff until it's
+// accepted with a 2xx status, the attempts run out or the context is done.
+func (d webhookDelivery) deliver(ctx context.Context) error {
+	body, err := d.payload()
+	if err != nil {
+		return err
+	}
+
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := webhookClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return nil
+			}
+			err = fmt.Errorf("unexpected status %s", resp.Status)
+		}
+
+		if attempt == webhookMaxAttempts {
+			return fmt.Errorf("giving up after %d attempts. %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// runWebhooks delivers queued changes until the context is done. Changes are delivered one at a
+// time, in the order they were logged.
+func runWebhooks(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-webhooks.queue:
+			if err := d.deliver(ctx); err != nil {
+				log.Printf("Error occurred while delivering the change of key %q to %s: %v", d.event.Key, d.url, err)
+			}
+		}
+	}
+}
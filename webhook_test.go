@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function for testing that changes of matching keys are posted to webhooks, retrying failed deliveries.
+func TestWebhooks(t *testing.T) {
+	// Restore to original state after test.
+	defer useTempLogger(t, "temp-webhooks.log")()
+	defer resetStores()
+	defer setWebhooks(nil)
+	defer func(delay time.Duration) { webhookRetryDelay = delay }(webhookRetryDelay)
+	webhookRetryDelay = time.Millisecond
+
+	// The webhook fails its first request, so that the first change is retried.
+	var mu sync.Mutex
+	var received []WebhookEvent
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !failed {
+			failed = true
+			http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var event WebhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		received = append(received, event)
+	}))
+	defer server.Close()
+
+	setWebhooks([]webhookTarget{{URL: server.URL, Prefix: "user:"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runWebhooks(ctx)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, defaultRoutePrefix)
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPut, "/yakv/v0/put", `{"key": "user:1", "value": "alice"}`},
+		{http.MethodPut, "/yakv/v0/put", `{"key": "config:1", "value": "ignored"}`},
+		{http.MethodDelete, "/yakv/v0/delete", `{"key": "user:1"}`},
+	}
+
+	for _, req := range requests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+		if rec.Code >= 300 {
+			t.Fatalf("Expected %s %s to succeed, got %d", req.method, req.path, rec.Code)
+		}
+	}
+
+	expected := []WebhookEvent{{Type: "put", Key: "user:1", Value: "alice"}, {Type: "delete", Key: "user:1"}}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+
+		if n >= len(expected) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(received) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, received)
+	}
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], received[i])
+		}
+	}
+}
+
+// Function for testing that queuing changes never blocks, even once the queue is full.
+func TestWebhookQueueFull(t *testing.T) {
+	// Restore to original state after test.
+	defer setWebhooks(nil)
+	defer func() {
+		for len(webhooks.queue) > 0 {
+			<-webhooks.queue
+		}
+	}()
+
+	setWebhooks([]webhookTarget{{URL: "http://127.0.0.1:0"}})
+
+	// Every dropped change is logged.
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*webhookQueueSize; i++ {
+			notifyWebhooks(Event{EventType: EventPut, Key: "yakv", Value: "hello, yakv!"})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Queuing changes blocked on a full queue.")
+	}
+
+	if n := len(webhooks.queue); n != webhookQueueSize {
+		t.Errorf("Expected a full queue of %d changes, got %d", webhookQueueSize, n)
+	}
+}